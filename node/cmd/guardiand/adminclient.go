@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os/user"
 	"strconv"
 	"strings"
 	"time"
@@ -26,8 +27,16 @@ import (
 )
 
 var (
-	clientSocketPath *string
-	shouldBackfill   *bool
+	clientSocketPath     *string
+	shouldBackfill       *bool
+	backfillParallelism  *uint
+	backfillNodeTimeout  *time.Duration
+	backfillMaxAttempts  *uint
+	restartBackfill      *bool
+	backfillGuardianSets *[]string
+	undoneGuardianSets   *[]string
+	undoneOperator       *string
+	undoneDryRun         *bool
 )
 
 func init() {
@@ -41,11 +50,33 @@ func init() {
 
 	shouldBackfill = AdminClientFindMissingMessagesCmd.Flags().Bool(
 		"backfill", false, "backfill missing VAAs from public RPC")
+	backfillParallelism = AdminClientFindMissingMessagesCmd.Flags().Uint(
+		"parallelism", 0, "maximum number of VAAs to backfill concurrently (default 8)")
+	backfillNodeTimeout = AdminClientFindMissingMessagesCmd.Flags().Duration(
+		"nodeTimeout", 0, "timeout of a single backfill request to a public RPC node (default 5s)")
+	backfillMaxAttempts = AdminClientFindMissingMessagesCmd.Flags().Uint(
+		"maxAttempts", 0, "number of backfill attempts per VAA (default 3)")
+	restartBackfill = AdminClientFindMissingMessagesCmd.Flags().Bool(
+		"restart", false, "discard the progress of an interrupted backfill instead of resuming it")
+	backfillGuardianSets = AdminClientFindMissingMessagesCmd.Flags().StringArray("guardianSet", nil,
+		"historical guardian set to verify backfilled VAAs against, as <index>:<address>,<address>,... (repeatable)")
+
+	defaultOperator := ""
+	if u, err := user.Current(); err == nil {
+		defaultOperator = u.Username
+	}
+	undoneOperator = AdminClientExecuteUndoneSequenceCmd.Flags().String(
+		"operator", defaultOperator, "operator name recorded in the undone sequence history")
+	undoneDryRun = AdminClientExecuteUndoneSequenceCmd.Flags().Bool(
+		"dryRun", false, "show the governance VAA body and digest without setting the sequence executing")
+	undoneGuardianSets = AdminClientExecuteUndoneSequenceCmd.Flags().StringArray("guardianSet", nil,
+		"historical guardian set to verify a backfilled VAA against, as <index>:<address>,<address>,... (repeatable)")
 
 	AdminClientInjectGuardianSetUpdateCmd.Flags().AddFlagSet(pf)
 	AdminClientFindMissingMessagesCmd.Flags().AddFlagSet(pf)
 	AdminClientExecuteUndoneSequenceCmd.Flags().AddFlagSet(pf)
 	AdminClientListUndoneSequenceCmd.Flags().AddFlagSet(pf)
+	AdminClientUndoneSequenceHistoryCmd.Flags().AddFlagSet(pf)
 	AdminClientListNodes.Flags().AddFlagSet(pf)
 	DumpVAAByMessageID.Flags().AddFlagSet(pf)
 	SendObservationRequest.Flags().AddFlagSet(pf)
@@ -54,6 +85,8 @@ func init() {
 	AdminCmd.AddCommand(AdminClientFindMissingMessagesCmd)
 	AdminCmd.AddCommand(AdminClientExecuteUndoneSequenceCmd)
 	AdminCmd.AddCommand(AdminClientListUndoneSequenceCmd)
+	AdminClientUndoneSequenceCmd.AddCommand(AdminClientUndoneSequenceHistoryCmd)
+	AdminCmd.AddCommand(AdminClientUndoneSequenceCmd)
 	AdminCmd.AddCommand(AdminClientGovernanceVAAVerifyCmd)
 	AdminCmd.AddCommand(AdminClientListNodes)
 	AdminCmd.AddCommand(DumpVAAByMessageID)
@@ -86,6 +119,18 @@ var AdminClientListUndoneSequenceCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 }
 
+var AdminClientUndoneSequenceCmd = &cobra.Command{
+	Use:   "undone-sequence",
+	Short: "Undone sequence commands",
+}
+
+var AdminClientUndoneSequenceHistoryCmd = &cobra.Command{
+	Use:   "history [CHAIN_ID] [SEQUENCE]",
+	Short: "Show the state transitions of an undone sequence",
+	Run:   runUndoneSequenceHistory,
+	Args:  cobra.ExactArgs(2),
+}
+
 var AdminClientExecuteUndoneSequenceCmd = &cobra.Command{
 	Use:   "execute-undone-sequence [CHAIN_ID] [EMITTER_ADDRESS] [SEQUENCE] [GOV_SEQUENCE]",
 	Short: "Execute undone sequence and get the governance vaa",
@@ -178,16 +223,35 @@ func runFindMissingMessages(cmd *cobra.Command, args []string) {
 	}
 
 	msg := nodev1.FindMissingMessagesRequest{
-		EmitterChain:   uint32(chainID),
-		EmitterAddress: emitterAddress,
-		RpcBackfill:    *shouldBackfill,
-		BackfillNodes:  common.PublicRPCEndpoints,
+		EmitterChain:          uint32(chainID),
+		EmitterAddress:        emitterAddress,
+		RpcBackfill:           *shouldBackfill,
+		BackfillNodes:         common.PublicRPCEndpoints,
+		BackfillParallelism:   uint32(*backfillParallelism),
+		BackfillNodeTimeoutMs: uint32(backfillNodeTimeout.Milliseconds()),
+		BackfillMaxAttempts:   uint32(*backfillMaxAttempts),
+		RestartBackfill:       *restartBackfill,
+		GuardianSets:          *backfillGuardianSets,
 	}
 	resp, err := c.FindMissingMessages(ctx, &msg)
 	if err != nil {
 		log.Fatalf("failed to run find FindMissingMessages RPC: %v", err)
 	}
 
+	for _, o := range resp.BackfillOutcomes {
+		line := fmt.Sprintf("backfill %d/%s/%d: %s (%d attempts)", chainID, emitterAddress, o.Sequence, o.Status, o.Attempts)
+		if o.Node != "" {
+			line += " from " + o.Node
+		}
+		if o.Error != "" {
+			line += ": " + o.Error
+		}
+		if o.Resumed {
+			line += " [resumed]"
+		}
+		log.Print(line)
+	}
+
 	for _, id := range resp.MissingMessages {
 		fmt.Println(id)
 	}
@@ -226,12 +290,19 @@ func runExecuteUndoneSequence(cmd *cobra.Command, args []string) {
 		Sequence:       sequence,
 		GovSequence:    govSequence,
 		BackfillNodes:  common.PublicRPCEndpoints,
+		Operator:       *undoneOperator,
+		DryRun:         *undoneDryRun,
+		GuardianSets:   *undoneGuardianSets,
 	}
 	resp, err := c.ExecuteUndoneSequence(ctx, request)
 	if err != nil {
 		log.Fatalf("failed to run ExecuteUndoneSequence rpc, error: %v", err)
 	}
 	log.Printf("vaa body: %v", resp.VaaBody)
+	log.Printf("digest: %s", resp.Digest)
+	if *undoneDryRun {
+		log.Printf("dry run, the sequence has not been set executing")
+	}
 }
 
 func runListUndoneSequences(cmd *cobra.Command, args []string) {
@@ -263,6 +334,52 @@ func runListUndoneSequences(cmd *cobra.Command, args []string) {
 	fmt.Printf("undone sequence size: %v\n", len(undoneSequences))
 }
 
+func runUndoneSequenceHistory(cmd *cobra.Command, args []string) {
+	chainId, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("invalid chain id: %v", err)
+	}
+	sequence, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		log.Fatalf("invalid sequence: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	conn, err, c := getAdminClient(ctx, *clientSocketPath)
+	defer conn.Close()
+	if err != nil {
+		log.Fatalf("failed to get admin client: %v", err)
+	}
+
+	resp, err := c.GetUndoneSequences(ctx, &nodev1.GetUndoneSequencesRequest{
+		RemoteChainId:  uint32(chainId),
+		IncludeHistory: true,
+	})
+	if err != nil {
+		log.Fatalf("failed to run GetUndoneSequences rpc, error: %v", err)
+	}
+	for _, s := range resp.Sequences {
+		if s.Sequence != sequence {
+			continue
+		}
+		fmt.Printf("sequence: %v, status: %v\n", s.Sequence, s.Status)
+		for _, t := range s.History {
+			fmt.Printf("%s %v: %s", time.Unix(t.Timestamp, 0).UTC().Format(time.RFC3339), t.Status, t.Reason)
+			if t.VaaId != "" {
+				fmt.Printf(", vaa id: %s, digest: %s", t.VaaId, t.Digest)
+			}
+			if t.Operator != "" {
+				fmt.Printf(", operator: %s", t.Operator)
+			}
+			fmt.Println()
+		}
+		return
+	}
+	log.Fatalf("no history for sequence %d from remote chain %d", sequence, chainId)
+}
+
 // runDumpVAAByMessageID uses GetSignedVAA to request the given message,
 // then decode and dump the VAA.
 func runDumpVAAByMessageID(cmd *cobra.Command, args []string) {
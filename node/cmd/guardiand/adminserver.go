@@ -3,18 +3,15 @@ package guardiand
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"math/big"
-	"math/rand"
 	"net"
-	"net/http"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/certusone/wormhole/node/pkg/alephium"
@@ -22,11 +19,13 @@ import (
 	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
 	publicrpcv1 "github.com/certusone/wormhole/node/pkg/proto/publicrpc/v1"
 	"github.com/certusone/wormhole/node/pkg/publicrpc"
+	"github.com/certusone/wormhole/node/pkg/reporter"
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/certusone/wormhole/node/pkg/backfill"
 	"github.com/certusone/wormhole/node/pkg/common"
 	nodev1 "github.com/certusone/wormhole/node/pkg/proto/node/v1"
 	"github.com/certusone/wormhole/node/pkg/supervisor"
@@ -40,7 +39,9 @@ type nodePrivilegedService struct {
 	injectC      chan<- *vaa.VAA
 	obsvReqSendC chan *gossipv1.ObservationRequest
 	logger       *zap.Logger
-	signedInC    chan *gossipv1.SignedVAAWithQuorum
+	// attestationEvents is notified of backfilled VAAs.
+	attestationEvents *reporter.AttestationEventReporter
+	gst               *common.GuardianSetState
 }
 
 // adminGuardianSetUpdateToVAA converts a nodev1.GuardianSetUpdate message to its canonical VAA representation.
@@ -208,101 +209,86 @@ func (s *nodePrivilegedService) InjectGovernanceVAA(ctx context.Context, req *no
 	return &nodev1.InjectGovernanceVAAResponse{Digests: digests}, nil
 }
 
-// fetchMissing attempts to backfill a gap by fetching and storing missing signed VAAs from the network.
-// Returns true if the gap was filled, false otherwise.
-func (s *nodePrivilegedService) fetchMissing(
-	ctx context.Context,
-	nodes []string,
-	c *http.Client,
-	chain vaa.ChainID,
-	addr string,
-	seq uint64) (bool, error) {
-
-	// shuffle the list of public RPC endpoints
-	rand.Shuffle(len(nodes), func(i, j int) {
-		nodes[i], nodes[j] = nodes[j], nodes[i]
-	})
-
-	ctx, cancel := context.WithTimeout(ctx, time.Second)
-	defer cancel()
+// storeBackfilledVAA stores a verified backfilled VAA and reports it like a VAA which reached quorum.
+//
+// The VAA is stored directly instead of being injected into the gossip signed VAA receive path, since the
+// processor only accepts VAAs of the current guardian set, while backfilled VAAs may be signed by an older one.
+func (s *nodePrivilegedService) storeBackfilledVAA(fetched *backfill.FetchedVAA) error {
+	_, err := s.db.GetSignedVAABytes(*db.VaaIDFromVAA(fetched.VAA))
+	if err == nil {
+		// stored in the meantime, e.g. received via gossip
+		return nil
+	} else if err != db.ErrVAANotFound {
+		return err
+	}
+	if err := s.db.StoreSignedVAA(fetched.VAA); err != nil {
+		return err
+	}
+	s.attestationEvents.ReportVAAQuorum(fetched.VAA)
 
-	for _, node := range nodes {
-		req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(
-			"%s/v1/signed_vaa/%d/%s/%d", node, chain, addr, seq), nil)
-		if err != nil {
-			return false, fmt.Errorf("failed to create request: %w", err)
-		}
+	s.logger.Info("backfilled VAA",
+		zap.String("message_id", fetched.VAA.MessageID()),
+		zap.String("node", fetched.Node),
+		zap.Int("numBytes", len(fetched.Bytes)),
+	)
+	return nil
+}
 
-		resp, err := c.Do(req)
+// parseGuardianSets parses the historical guardian sets given in a backfill request.
+func parseGuardianSets(sets []string) ([]*common.GuardianSet, error) {
+	parsed := make([]*common.GuardianSet, 0, len(sets))
+	for _, s := range sets {
+		gs, err := parseGuardianSet(s)
 		if err != nil {
-			s.logger.Warn("failed to fetch missing VAA",
-				zap.String("node", node),
-				zap.String("chain", chain.String()),
-				zap.String("address", addr),
-				zap.Uint64("sequence", seq),
-				zap.Error(err),
-			)
-			continue
+			return nil, err
 		}
+		parsed = append(parsed, gs)
+	}
+	return parsed, nil
+}
 
-		switch resp.StatusCode {
-		case http.StatusNotFound:
-			resp.Body.Close()
-			continue
-		case http.StatusOK:
-			type getVaaResp struct {
-				VaaBytes string `json:"vaaBytes"`
-			}
-			var respBody getVaaResp
-			if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
-				resp.Body.Close()
-				s.logger.Warn("failed to decode VAA response",
-					zap.String("node", node),
-					zap.String("chain", chain.String()),
-					zap.String("address", addr),
-					zap.Uint64("sequence", seq),
-					zap.Error(err),
-				)
-				continue
-			}
-
-			// base64 decode the VAA bytes
-			vaaBytes, err := base64.StdEncoding.DecodeString(respBody.VaaBytes)
-			if err != nil {
-				resp.Body.Close()
-				s.logger.Warn("failed to decode VAA body",
-					zap.String("node", node),
-					zap.String("chain", chain.String()),
-					zap.String("address", addr),
-					zap.Uint64("sequence", seq),
-					zap.Error(err),
-				)
-				continue
-			}
-
-			s.logger.Info("backfilled VAA",
-				zap.Uint16("chain", uint16(chain)),
-				zap.String("address", addr),
-				zap.Uint64("sequence", seq),
-				zap.Int("numBytes", len(vaaBytes)),
-			)
-
-			// Inject into the gossip signed VAA receive path.
-			// This has the same effect as if the VAA was received from the network
-			// (verifying signature, publishing to BigTable, storing in local DB...).
-			s.signedInC <- &gossipv1.SignedVAAWithQuorum{
-				Vaa: vaaBytes,
-			}
-
-			resp.Body.Close()
-			return true, nil
-		default:
-			resp.Body.Close()
-			return false, fmt.Errorf("unexpected response status: %d", resp.StatusCode)
-		}
+// backfillConfig returns the backfill configuration for the request, using the defaults for unset fields.
+func backfillConfig(req *nodev1.FindMissingMessagesRequest) (backfill.Config, error) {
+	config := backfill.DefaultConfig
+	sets, err := parseGuardianSets(req.GuardianSets)
+	if err != nil {
+		return config, err
+	}
+	config.GuardianSets = sets
+	if req.BackfillParallelism != 0 {
+		config.Parallelism = int(req.BackfillParallelism)
+	}
+	if req.BackfillNodeTimeoutMs != 0 {
+		config.NodeTimeout = time.Duration(req.BackfillNodeTimeoutMs) * time.Millisecond
 	}
+	if req.BackfillMaxAttempts != 0 {
+		config.MaxAttempts = int(req.BackfillMaxAttempts)
+	}
+	return config, nil
+}
 
-	return false, nil
+func backfillOutcomeToProto(o backfill.Outcome) *nodev1.BackfillOutcome {
+	var st nodev1.BackfillStatus
+	switch o.Status {
+	case backfill.StatusStored:
+		st = nodev1.BackfillStatus_BACKFILL_STATUS_STORED
+	case backfill.StatusNotFound:
+		st = nodev1.BackfillStatus_BACKFILL_STATUS_NOT_FOUND
+	case backfill.StatusFailed:
+		st = nodev1.BackfillStatus_BACKFILL_STATUS_FAILED
+	case backfill.StatusRejected:
+		st = nodev1.BackfillStatus_BACKFILL_STATUS_REJECTED
+	case backfill.StatusMismatched:
+		st = nodev1.BackfillStatus_BACKFILL_STATUS_MISMATCHED
+	}
+	return &nodev1.BackfillOutcome{
+		Sequence: o.Sequence,
+		Status:   st,
+		Node:     o.Node,
+		Attempts: uint32(o.Attempts),
+		Error:    o.Error,
+		Resumed:  o.Resumed,
+	}
 }
 
 func (s *nodePrivilegedService) FindMissingMessages(ctx context.Context, req *nodev1.FindMissingMessagesRequest) (*nodev1.FindMissingMessagesResponse, error) {
@@ -321,17 +307,41 @@ func (s *nodePrivilegedService) FindMissingMessages(ctx context.Context, req *no
 		return nil, status.Errorf(codes.Internal, "database operation failed: %v", err)
 	}
 
+	var outcomes []*nodev1.BackfillOutcome
 	if req.RpcBackfill {
-		c := &http.Client{}
+		config, err := backfillConfig(req)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid guardian set: %v", err)
+		}
+		emitter := db.VAAID{EmitterChain: vaa.ChainID(req.EmitterChain), EmitterAddress: emitterAddress}
+		if req.RestartBackfill {
+			if err := s.db.DeleteBackfillProgress(emitter); err != nil {
+				return nil, status.Errorf(codes.Internal, "database operation failed: %v", err)
+			}
+		}
+
+		f := backfill.NewFetcher(s.logger, req.BackfillNodes, s.gst, config)
+		results, err := f.Run(ctx, s.db, emitter, ids, func(fetched *backfill.FetchedVAA) error {
+			return s.storeBackfilledVAA(fetched)
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "backfill interrupted after %d of %d sequences, rerun to resume: %v", len(results), len(ids), err)
+		}
+
 		unfilled := make([]uint64, 0, len(ids))
-		for _, id := range ids {
-			if ok, err := s.fetchMissing(ctx, req.BackfillNodes, c, vaa.ChainID(req.EmitterChain), emitterAddress.String(), id); err != nil {
-				return nil, status.Errorf(codes.Internal, "failed to backfill VAA: %v", err)
-			} else if ok {
-				continue
+		for _, o := range results {
+			outcomes = append(outcomes, backfillOutcomeToProto(o))
+			if o.Status != backfill.StatusStored {
+				unfilled = append(unfilled, o.Sequence)
 			}
-			unfilled = append(unfilled, id)
 		}
+		s.logger.Info("backfill completed",
+			zap.Stringer("chain", emitter.EmitterChain),
+			zap.Stringer("address", emitterAddress),
+			zap.Int("missing", len(ids)),
+			zap.Int("unfilled", len(unfilled)),
+			zap.Any("node_scores", f.Scores()),
+		)
 		ids = unfilled
 	}
 
@@ -340,9 +350,10 @@ func (s *nodePrivilegedService) FindMissingMessages(ctx context.Context, req *no
 		resp[i] = fmt.Sprintf("%d/%s/%d", req.EmitterChain, emitterAddress, v)
 	}
 	return &nodev1.FindMissingMessagesResponse{
-		MissingMessages: resp,
-		FirstSequence:   first,
-		LastSequence:    last,
+		MissingMessages:  resp,
+		FirstSequence:    first,
+		LastSequence:     last,
+		BackfillOutcomes: outcomes,
 	}, nil
 }
 
@@ -350,7 +361,7 @@ func adminServiceRunnable(
 	logger *zap.Logger,
 	socketPath string,
 	injectC chan<- *vaa.VAA,
-	signedInC chan *gossipv1.SignedVAAWithQuorum,
+	attestationEvents *reporter.AttestationEventReporter,
 	obsvReqSendC chan *gossipv1.ObservationRequest,
 	db *db.Database,
 	alphDb *alephium.Database,
@@ -388,15 +399,16 @@ func adminServiceRunnable(
 	logger.Info("admin server listening on", zap.String("path", socketPath))
 
 	nodeService := &nodePrivilegedService{
-		injectC:      injectC,
-		obsvReqSendC: obsvReqSendC,
-		db:           db,
-		alphDb:       alphDb,
-		logger:       logger.Named("adminservice"),
-		signedInC:    signedInC,
+		injectC:           injectC,
+		obsvReqSendC:      obsvReqSendC,
+		db:                db,
+		alphDb:            alphDb,
+		logger:            logger.Named("adminservice"),
+		attestationEvents: attestationEvents,
+		gst:               gst,
 	}
 
-	publicrpcService := publicrpc.NewPublicrpcServer(logger, db, gst)
+	publicrpcService := publicrpc.NewPublicrpcServer(logger, db, gst, nil)
 
 	grpcServer := common.NewInstrumentedGRPCServer(logger)
 	nodev1.RegisterNodePrivilegedServiceServer(grpcServer, nodeService)
@@ -415,41 +427,32 @@ func (s *nodePrivilegedService) GetUndoneSequences(ctx context.Context, req *nod
 	if err != nil {
 		return nil, err
 	}
+	if req.IncludeHistory {
+		histories, err := s.alphDb.GetUndoneSequenceHistory(uint16(req.RemoteChainId))
+		if err != nil {
+			return nil, err
+		}
+		for _, seq := range sequences {
+			seq.History = histories[seq.Sequence]
+			delete(histories, seq.Sequence)
+		}
+		// the remaining sequences have been removed
+		for sequence, history := range histories {
+			sequences = append(sequences, &nodev1.UndoneSequence{
+				Sequence: sequence,
+				Status:   nodev1.UndoneSequenceStatus_UNDONE_SEQUENCE_STATUS_REMOVED,
+				History:  history,
+			})
+		}
+		sort.Slice(sequences, func(i, j int) bool {
+			return sequences[i].Sequence < sequences[j].Sequence
+		})
+	}
 	return &nodev1.GetUndoneSequencesResponse{
 		Sequences: sequences,
 	}, nil
 }
 
-func (s *nodePrivilegedService) tryToGetVAA(vaaId *db.VAAID) ([]byte, error) {
-	maxTimes := 15
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	times := 0
-	for {
-		select {
-		case <-ticker.C:
-			times += 1
-			if times > maxTimes {
-				return nil, errors.New("failed to get vaa after fetched from remote guardian")
-			}
-
-			vaaBytes, err := s.db.GetSignedVAABytes(*vaaId)
-			if err == nil {
-				return vaaBytes, nil
-			}
-
-			if err == db.ErrVAANotFound {
-				continue
-			}
-
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-}
-
 type transfer struct {
 	amount       big.Int
 	tokenId      alephium.Byte32
@@ -545,20 +548,21 @@ func (s *nodePrivilegedService) ExecuteUndoneSequence(ctx context.Context, req *
 	}
 
 	if err == db.ErrVAANotFound {
-		client := &http.Client{}
-		succeed, err := s.fetchMissing(ctx, req.BackfillNodes, client, emitterChain, req.EmitterAddress, req.Sequence)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch vaa from remote guardians, error: %v", err)
+		config := backfill.DefaultConfig
+		if config.GuardianSets, err = parseGuardianSets(req.GuardianSets); err != nil {
+			return nil, fmt.Errorf("invalid guardian set, error: %v", err)
 		}
-
-		if !succeed {
+		f := backfill.NewFetcher(s.logger, req.BackfillNodes, s.gst, config)
+		fetched, err := f.Fetch(ctx, emitterChain, address, req.Sequence)
+		if err == backfill.ErrNotFound {
 			return nil, fmt.Errorf("failed to fetch vaa from remote guardians, try other guardians")
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to fetch vaa from remote guardians, error: %v", err)
 		}
-
-		vaaBytes, err = s.tryToGetVAA(&vaaId)
-		if err != nil {
-			return nil, err
+		if err := s.storeBackfilledVAA(fetched); err != nil {
+			return nil, fmt.Errorf("failed to store vaa fetched from remote guardians, error: %v", err)
 		}
+		vaaBytes = fetched.Bytes
 	}
 
 	transferVAA, err := vaa.Unmarshal(vaaBytes)
@@ -576,22 +580,44 @@ func (s *nodePrivilegedService) ExecuteUndoneSequence(ctx context.Context, req *
 		return nil, fmt.Errorf("failed to get token wrapper id, error: %v", err)
 	}
 
+	// The body only depends on the undone transfer, so that all guardians sign the same digest.
 	vaaBody := &vaa.VAA{
-		Timestamp:        time.Now(),
-		Nonce:            rand.Uint32(),
+		Timestamp:        transferVAA.Timestamp,
+		Nonce:            transferVAA.Nonce,
 		Sequence:         req.GovSequence,
 		ConsistencyLevel: transferVAA.ConsistencyLevel,
 		EmitterChain:     vaa.GovernanceChain,
 		EmitterAddress:   vaa.GovernanceEmitter,
 		Payload:          transferPayload(transferMsg, tokenWrapperId, req.Sequence),
 	}
-	// TODO: save the vaa id
-	if err := s.alphDb.SetSequenceExecuting(uint16(emitterChain), req.Sequence); err != nil {
-		return nil, fmt.Errorf("failed to update undone sequence status, error: %v", err)
+	execution := &alephium.UndoneSequenceExecution{
+		GovSequence: req.GovSequence,
+		VaaId:       vaaBody.MessageID(),
+		Digest:      vaaBody.HexDigest(),
+		Operator:    req.Operator,
 	}
-	return &nodev1.ExecuteUndoneSequenceResponse{
+	resp := &nodev1.ExecuteUndoneSequenceResponse{
 		VaaBody: vaaBody.SerializeBody(),
-	}, nil
+		Digest:  execution.Digest,
+	}
+	if req.DryRun {
+		if err := s.alphDb.CheckSequenceExecuting(uint16(emitterChain), req.Sequence, execution); err != nil {
+			return nil, fmt.Errorf("undone sequence can't be executed, error: %v", err)
+		}
+		return resp, nil
+	}
+
+	if err := s.alphDb.SetSequenceExecuting(uint16(emitterChain), req.Sequence, execution); err != nil {
+		return nil, fmt.Errorf("failed to update undone sequence status, error: %v", err)
+	}
+	s.logger.Info("undone sequence executing",
+		zap.Uint16("remoteChainId", uint16(emitterChain)),
+		zap.Uint64("sequence", req.Sequence),
+		zap.String("vaaId", execution.VaaId),
+		zap.String("digest", execution.Digest),
+		zap.String("operator", execution.Operator),
+	)
+	return resp, nil
 }
 
 // TODO: better name
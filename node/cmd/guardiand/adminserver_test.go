@@ -0,0 +1,90 @@
+package guardiand
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/db"
+	nodev1 "github.com/certusone/wormhole/node/pkg/proto/node/v1"
+	"github.com/certusone/wormhole/node/pkg/reporter"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func testGuardianSet(t *testing.T, index uint32) (*common.GuardianSet, []*ecdsa.PrivateKey) {
+	gs := &common.GuardianSet{Index: index}
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 4; i++ {
+		k, err := crypto.GenerateKey()
+		assert.Nil(t, err)
+		keys = append(keys, k)
+		gs.Keys = append(gs.Keys, crypto.PubkeyToAddress(k.PublicKey))
+	}
+	return gs, keys
+}
+
+func TestBackfillHistoricalGuardianSet(t *testing.T) {
+	current, currentKeys := testGuardianSet(t, 1)
+	old, oldKeys := testGuardianSet(t, 0)
+	gst := common.NewGuardianSetState()
+	gst.Set(current)
+
+	d, err := db.Open(t.TempDir())
+	assert.Nil(t, err)
+	defer d.Close()
+	for _, seq := range []uint64{0, 2} {
+		assert.Nil(t, d.StoreSignedVAA(signedTestVAA(currentKeys, seq)))
+	}
+
+	// the missing VAA was signed before the guardian set update
+	missing := signedTestVAA(nil, 1)
+	missing.GuardianSetIndex = 0
+	for i, k := range oldKeys {
+		missing.AddSignature(k, uint8(i))
+	}
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fmt.Sprintf("/v1/signed_vaa/%d/%s/1", missing.EmitterChain, missing.EmitterAddress) {
+			http.NotFound(w, r)
+			return
+		}
+		b, _ := missing.Marshal()
+		fmt.Fprintf(w, `{"vaaBytes": "%s"}`, base64.StdEncoding.EncodeToString(b))
+	}))
+	defer node.Close()
+
+	events := reporter.EventListener(zap.NewNop())
+	sub := events.Subscribe()
+	defer events.Unsubscribe(sub.ClientId)
+	s := &nodePrivilegedService{db: d, gst: gst, attestationEvents: events, logger: zap.NewNop()}
+
+	addrs := make([]string, len(old.Keys))
+	for i, k := range old.Keys {
+		addrs[i] = k.Hex()
+	}
+	resp, err := s.FindMissingMessages(context.Background(), &nodev1.FindMissingMessagesRequest{
+		EmitterChain:   uint32(missing.EmitterChain),
+		EmitterAddress: hex.EncodeToString(missing.EmitterAddress[:]),
+		RpcBackfill:    true,
+		BackfillNodes:  []string{node.URL},
+		GuardianSets:   []string{"0:" + strings.Join(addrs, ",")},
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, resp.MissingMessages)
+	assert.Equal(t, 1, len(resp.BackfillOutcomes))
+	assert.Equal(t, nodev1.BackfillStatus_BACKFILL_STATUS_STORED, resp.BackfillOutcomes[0].Status)
+
+	stored, err := d.GetSignedVAABytes(*db.VaaIDFromVAA(missing))
+	assert.Nil(t, err)
+	expected, _ := missing.Marshal()
+	assert.Equal(t, expected, stored)
+	assert.Equal(t, missing.MessageID(), (<-sub.Channels.VAAQuorumC).MessageID())
+}
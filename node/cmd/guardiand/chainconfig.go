@@ -0,0 +1,416 @@
+package guardiand
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/certusone/wormhole/node/pkg/alephium"
+	"github.com/certusone/wormhole/node/pkg/algorand"
+	"github.com/certusone/wormhole/node/pkg/devnet"
+	"github.com/certusone/wormhole/node/pkg/ethereum"
+	alephiumv1 "github.com/certusone/wormhole/node/pkg/proto/alephium/v1"
+	"github.com/certusone/wormhole/node/pkg/solana"
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	"github.com/certusone/wormhole/node/pkg/terra"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
+	eth_common "github.com/ethereum/go-ethereum/common"
+	solana_types "github.com/gagliardetto/solana-go"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func enabled(v bool) *bool {
+	return &v
+}
+
+// defaultChainConfigs returns the chains which were hard-coded before the config file existed
+func defaultChainConfigs(unsafeDevMode bool, testnetMode bool) []*watchers.ChainConfig {
+	evm := func(name string, chainID vaa.ChainID, minConfirmations uint64, enabled *bool) *watchers.ChainConfig {
+		return &watchers.ChainConfig{Name: name, Type: watchers.ChainTypeEVM, ChainID: chainID, MinConfirmations: minConfirmations, Enabled: enabled}
+	}
+	return []*watchers.ChainConfig{
+		evm("eth", vaa.ChainIDEthereum, 1, nil),
+		evm("bsc", vaa.ChainIDBSC, 1, nil),
+		// Special case: Polygon can fork like PoW Ethereum, and it's not clear what the safe number of blocks is
+		//
+		// Hardcode the minimum number of confirmations to 512 regardless of what the smart contract specifies to protect
+		// developers from accidentally specifying an unsafe number of confirmations. We can remove this restriction as soon
+		// as specific public guidance exists for Polygon developers.
+		evm("polygon", vaa.ChainIDPolygon, 512, nil),
+		evm("avalanche", vaa.ChainIDAvalanche, 1, nil),
+		evm("oasis", vaa.ChainIDOasis, 1, nil),
+		evm("fantom", vaa.ChainIDFantom, 1, nil),
+		evm("ethropsten", vaa.ChainIDEthereumRopsten, 1, enabled(testnetMode)),
+		evm("karura", vaa.ChainIDKarura, 1, enabled(testnetMode)),
+		evm("acala", vaa.ChainIDAcala, 1, enabled(testnetMode)),
+		{Name: "terra", Type: watchers.ChainTypeTerra, ChainID: vaa.ChainIDTerra},
+		{Name: "solana", Type: watchers.ChainTypeSolana, ChainID: vaa.ChainIDSolana, Enabled: enabled(false)},
+		// The Algorand watcher is enabled by --algorandIndexerRPC
+		{Name: "algorand", Type: watchers.ChainTypeAlgorand, ChainID: vaa.ChainIDAlgorand, Enabled: enabled(false)},
+		{Name: "alephium", Type: watchers.ChainTypeAlephium, ChainID: vaa.ChainIDAlephium, MinConfirmations: 1, Enabled: enabled(unsafeDevMode)},
+	}
+}
+
+type chainFlagOverride struct {
+	chain string
+	flag  string
+	apply func(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) error
+}
+
+func overrideRPC(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) error {
+	value, err := flags.GetString(flag)
+	c.RPC = []string{value}
+	return err
+}
+
+func overrideRPCs(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) (err error) {
+	c.RPC, err = flags.GetStringSlice(flag)
+	return
+}
+
+func overrideContract(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) error {
+	value, err := flags.GetString(flag)
+	c.Contracts = []string{value}
+	return err
+}
+
+func overrideContracts(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) (err error) {
+	c.Contracts, err = flags.GetStringSlice(flag)
+	return
+}
+
+func overrideWebsocket(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) (err error) {
+	c.Websocket, err = flags.GetString(flag)
+	return
+}
+
+func overrideAPIKey(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) (err error) {
+	c.APIKey, err = flags.GetString(flag)
+	return
+}
+
+func overrideIndexerRPC(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) (err error) {
+	c.IndexerRPC, err = flags.GetString(flag)
+	c.Enabled = enabled(c.IndexerRPC != "")
+	return
+}
+
+func overrideIndexerAPIKey(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) (err error) {
+	c.IndexerAPIKey, err = flags.GetString(flag)
+	return
+}
+
+func overrideQuorum(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) error {
+	value, err := flags.GetUint8(flag)
+	c.Quorum = int(value)
+	return err
+}
+
+func overrideGroupIndex(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) (err error) {
+	c.GroupIndex, err = flags.GetUint8(flag)
+	return
+}
+
+func overrideMinConfirmations(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) error {
+	value, err := flags.GetUint8(flag)
+	c.MinConfirmations = uint64(value)
+	return err
+}
+
+func overrideContractServerRPC(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) (err error) {
+	c.ContractServerRPC, err = flags.GetString(flag)
+	return
+}
+
+func overrideContractWebServer(c *watchers.ChainConfig, flags *pflag.FlagSet, flag string) (err error) {
+	c.ContractWebServer, err = flags.GetString(flag)
+	return
+}
+
+var chainFlagOverrides = []chainFlagOverride{
+	{"eth", "ethRPC", overrideRPC},
+	{"eth", "ethContract", overrideContract},
+	{"bsc", "bscRPC", overrideRPC},
+	{"bsc", "bscContract", overrideContract},
+	{"polygon", "polygonRPC", overrideRPC},
+	{"polygon", "polygonContract", overrideContract},
+	{"avalanche", "avalancheRPC", overrideRPC},
+	{"avalanche", "avalancheContract", overrideContract},
+	{"oasis", "oasisRPC", overrideRPC},
+	{"oasis", "oasisContract", overrideContract},
+	{"fantom", "fantomRPC", overrideRPC},
+	{"fantom", "fantomContract", overrideContract},
+	{"ethropsten", "ethRopstenRPC", overrideRPC},
+	{"ethropsten", "ethRopstenContract", overrideContract},
+	{"karura", "karuraRPC", overrideRPC},
+	{"karura", "karuraContract", overrideContract},
+	{"acala", "acalaRPC", overrideRPC},
+	{"acala", "acalaContract", overrideContract},
+
+	{"terra", "terraWS", overrideWebsocket},
+	{"terra", "terraLCD", overrideRPC},
+	{"terra", "terraContract", overrideContract},
+
+	{"solana", "solanaWS", overrideWebsocket},
+	{"solana", "solanaRPC", overrideRPC},
+	{"solana", "solanaContract", overrideContract},
+
+	{"algorand", "algorandRPC", overrideRPC},
+	{"algorand", "algorandToken", overrideAPIKey},
+	{"algorand", "algorandIndexerRPC", overrideIndexerRPC},
+	{"algorand", "algorandIndexerToken", overrideIndexerAPIKey},
+	{"algorand", "algorandContract", overrideContract},
+
+	{"alephium", "alphRPC", overrideRPCs},
+	{"alephium", "alphQuorum", overrideQuorum},
+	{"alephium", "alphApiKey", overrideAPIKey},
+	{"alephium", "alphContractServerRpc", overrideContractServerRPC},
+	{"alephium", "alphContractWebServer", overrideContractWebServer},
+	{"alephium", "alphContractIds", overrideContracts},
+	{"alephium", "alphGroupIndex", overrideGroupIndex},
+	{"alephium", "alphMinConfirmations", overrideMinConfirmations},
+}
+
+// loadChainConfigs reads the chain entries from the config file, falls back to the defaults
+// if there are none, and applies the flags which have been explicitly set on the command line
+func loadChainConfigs(v *viper.Viper, flags *pflag.FlagSet, unsafeDevMode bool, testnetMode bool) ([]*watchers.ChainConfig, error) {
+	var chains []*watchers.ChainConfig
+	if v.IsSet("chains") {
+		if err := v.UnmarshalKey("chains", &chains); err != nil {
+			return nil, fmt.Errorf("failed to parse chain entries: %w", err)
+		}
+	} else {
+		chains = defaultChainConfigs(unsafeDevMode, testnetMode)
+	}
+
+	byName := make(map[string]*watchers.ChainConfig, len(chains))
+	for _, c := range chains {
+		byName[c.Name] = c
+	}
+	for _, override := range chainFlagOverrides {
+		if !flags.Changed(override.flag) {
+			continue
+		}
+		c, ok := byName[override.chain]
+		if !ok {
+			return nil, fmt.Errorf("--%s is set, but there is no %s chain entry", override.flag, override.chain)
+		}
+		if err := override.apply(c, flags, override.flag); err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", override.flag, err)
+		}
+	}
+
+	if unsafeDevMode {
+		// Deterministic ganache ETH devnet address.
+		for _, c := range chains {
+			if c.Type == watchers.ChainTypeEVM {
+				c.Contracts = []string{devnet.GanacheWormholeContractAddress.Hex()}
+			}
+		}
+	}
+	return chains, nil
+}
+
+// testnetOnlyChains can only be enabled in testnet mode, or in dev mode if the value is true
+var testnetOnlyChains = map[vaa.ChainID]bool{
+	vaa.ChainIDEthereumRopsten: false,
+	vaa.ChainIDKarura:          true,
+	vaa.ChainIDAcala:           true,
+}
+
+// devOnlyChains can only be enabled in dev mode
+var devOnlyChains = map[vaa.ChainID]bool{
+	vaa.ChainIDAlgorand: true,
+	vaa.ChainIDAlephium: true,
+}
+
+// validateChainConfigs returns one error for each invalid chain entry
+func validateChainConfigs(chains []*watchers.ChainConfig, unsafeDevMode bool, testnetMode bool) []error {
+	errs := make([]error, 0)
+	names := make(map[string]bool)
+	chainIDs := make(map[vaa.ChainID]bool)
+	for i, c := range chains {
+		if names[c.Name] {
+			errs = append(errs, fmt.Errorf("chain entry %d (%s): duplicate name", i, c.Name))
+			continue
+		}
+		names[c.Name] = true
+		if err := validateChainConfig(c, unsafeDevMode, testnetMode); err != nil {
+			errs = append(errs, fmt.Errorf("chain entry %d (%s): %w", i, c.Name, err))
+			continue
+		}
+		if !c.IsEnabled() {
+			continue
+		}
+		if chainIDs[c.ChainID] {
+			errs = append(errs, fmt.Errorf("chain entry %d (%s): chain %v is enabled more than once", i, c.Name, c.ChainID))
+		}
+		chainIDs[c.ChainID] = true
+	}
+	return errs
+}
+
+func validateChainConfig(c *watchers.ChainConfig, unsafeDevMode bool, testnetMode bool) error {
+	if c.Name == "" {
+		return errors.New("missing name")
+	}
+	if _, err := vaa.ChainIDFromString(c.ChainID.String()); err != nil {
+		return fmt.Errorf("unknown chain id %d", c.ChainID)
+	}
+	switch c.Type {
+	case watchers.ChainTypeEVM, watchers.ChainTypeTerra, watchers.ChainTypeSolana, watchers.ChainTypeAlgorand, watchers.ChainTypeAlephium:
+	default:
+		return fmt.Errorf("unknown chain type %q", c.Type)
+	}
+	if !c.IsEnabled() {
+		return nil
+	}
+
+	if allowInDevMode, ok := testnetOnlyChains[c.ChainID]; ok && !testnetMode && !(allowInDevMode && unsafeDevMode) {
+		return errors.New("can only be enabled in testnet mode")
+	}
+	if devOnlyChains[c.ChainID] && !unsafeDevMode {
+		return errors.New("can only be enabled in dev mode")
+	}
+
+	if len(c.RPC) == 0 || c.RPC[0] == "" {
+		return errors.New("missing rpc endpoint")
+	}
+	if len(c.Contracts) == 0 || c.Contracts[0] == "" {
+		return errors.New("missing contract")
+	}
+	if c.StallThreshold < 0 {
+		return errors.New("negative stall threshold")
+	}
+	if c.StallThreshold != 0 && c.StallThreshold < watchers.MinStallThreshold {
+		return fmt.Errorf("stall threshold must be at least %s", watchers.MinStallThreshold)
+	}
+
+	switch c.Type {
+	case watchers.ChainTypeEVM:
+		if len(c.RPC) != 1 {
+			return errors.New("exactly one rpc endpoint is supported")
+		}
+		// Complain about Infura on mainnet.
+		//
+		// As it turns out, Infura has a bug where it would sometimes incorrectly round
+		// block timestamps, which causes consensus issues - the timestamp is part of
+		// the VAA and nodes using Infura would sometimes derive an incorrect VAA,
+		// accidentally attacking the network by signing a conflicting VAA.
+		//
+		// Node operators do not usually rely on Infura in the first place - doing
+		// so is insecure, since nodes blindly trust the connected nodes to verify
+		// on-chain message proofs. However, node operators sometimes used
+		// Infura during migrations where their primary node was offline, causing
+		// the aforementioned consensus oddities which were eventually found to
+		// be Infura-related. This is generally to the detriment of network security
+		// and a judgement call made by individual operators. In the case of Infura,
+		// we know it's actively dangerous so let's make an opinionated argument.
+		//
+		// Insert "I'm a sign, not a cop" meme.
+		//
+		if strings.Contains(c.PrimaryRPC(), "mainnet.infura.io") {
+			return errors.New("Infura is known to send incorrect blocks - please use your own nodes")
+		}
+		if !eth_common.IsHexAddress(c.PrimaryContract()) {
+			return fmt.Errorf("invalid contract address %s", c.PrimaryContract())
+		}
+		if c.ChainID == vaa.ChainIDPolygon && c.MinConfirmations < 512 {
+			return errors.New("polygon requires at least 512 confirmations")
+		}
+	case watchers.ChainTypeTerra:
+		if c.Websocket == "" {
+			return errors.New("missing websocket endpoint")
+		}
+	case watchers.ChainTypeSolana:
+		if c.Websocket == "" {
+			return errors.New("missing websocket endpoint")
+		}
+		if _, err := solana_types.PublicKeyFromBase58(c.PrimaryContract()); err != nil {
+			return fmt.Errorf("invalid contract address %s: %w", c.PrimaryContract(), err)
+		}
+	case watchers.ChainTypeAlgorand:
+		if c.IndexerRPC == "" {
+			return errors.New("missing indexer endpoint")
+		}
+		if _, err := strconv.ParseUint(c.PrimaryContract(), 10, 64); err != nil {
+			return fmt.Errorf("invalid contract %s, expected an application ID", c.PrimaryContract())
+		}
+	case watchers.ChainTypeAlephium:
+		if len(c.Contracts) != 4 {
+			return errors.New("expected the governance, event emitter, token bridge and token wrapper factory contract ids")
+		}
+		for _, id := range c.Contracts {
+			if _, err := alephium.ToContractId(id); err != nil {
+				return fmt.Errorf("invalid contract id %s: %w", id, err)
+			}
+		}
+		if c.Quorum > len(c.RPC) {
+			return fmt.Errorf("quorum %d is larger than the number of rpc endpoints", c.Quorum)
+		}
+		if c.ContractServerRPC == "" {
+			return errors.New("missing contract server listen address")
+		}
+	}
+	return nil
+}
+
+// enabledChain returns the enabled entry of the given chain, or nil
+func enabledChain(chains []*watchers.ChainConfig, chainID vaa.ChainID) *watchers.ChainConfig {
+	for _, c := range chains {
+		if c.IsEnabled() && c.ChainID == chainID {
+			return c
+		}
+	}
+	return nil
+}
+
+// newWatcherRegistry returns the watcher factories of all supported chain types
+func newWatcherRegistry(alphDb *alephium.Database) *watchers.Registry {
+	registry := watchers.NewRegistry()
+	registry.Register(watchers.ChainTypeEVM, ethereum.Factory)
+	registry.Register(watchers.ChainTypeTerra, terra.Factory)
+	registry.Register(watchers.ChainTypeSolana, solana.Factory)
+	registry.Register(watchers.ChainTypeAlgorand, algorand.Factory)
+	registry.Register(watchers.ChainTypeAlephium, alephium.NewFactory(alphDb))
+	return registry
+}
+
+// publicWebDeps are the settings of the public web servers started next to the watchers
+type publicWebDeps struct {
+	logger      *zap.Logger
+	tlsHostname string
+	tlsProdEnv  bool
+	autocertDir string
+}
+
+type namedRunnable struct {
+	name     string
+	runnable supervisor.Runnable
+}
+
+// chainRunnables returns the watcher of an enabled chain entry and the services which depend on it
+func chainRunnables(c *watchers.ChainConfig, w watchers.Watcher, web *publicWebDeps) ([]namedRunnable, error) {
+	runnables := []namedRunnable{{c.Name + "watch", w.Run}}
+
+	// The Alephium watcher serves the contract state it keeps track of
+	if alph, ok := w.(*alephium.Watcher); ok {
+		contractServer, contractGrpcServer, err := alph.ContractServer(web.logger, c.ContractServerRPC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create alephium contract server: %w", err)
+		}
+		contractWebServer, err := publicwebServiceRunnable(web.logger, c.ContractWebServer, c.ContractServerRPC, contractGrpcServer,
+			web.tlsHostname, web.tlsProdEnv, web.autocertDir, alephiumv1.RegisterContractServiceHandler)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create alephium contract web server: %w", err)
+		}
+		runnables = append(runnables,
+			namedRunnable{"alph-contract-server", contractServer},
+			namedRunnable{"alph-contract-web-server", contractWebServer})
+	}
+	return runnables, nil
+}
@@ -0,0 +1,217 @@
+package guardiand
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/devnet"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func testFlags(args ...string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("node", pflag.ContinueOnError)
+	flags.String("ethRPC", "", "")
+	flags.String("ethContract", "", "")
+	flags.String("terraLCD", "", "")
+	flags.StringSlice("alphRPC", []string{}, "")
+	flags.Uint8("alphMinConfirmations", 1, "")
+	if err := flags.Parse(args); err != nil {
+		panic(err)
+	}
+	return flags
+}
+
+func testConfig(t *testing.T, configType string, config string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType(configType)
+	assert.Nil(t, v.ReadConfig(bytes.NewBufferString(config)))
+	return v
+}
+
+const testYAMLConfig = `
+chains:
+  - name: eth
+    type: evm
+    chainId: 2
+    rpc: ["ws://eth:8545"]
+    contracts: ["0x98f3c9e6E3fAce36bAAd05FE09d375Ef1464288B"]
+  - name: bsc
+    type: evm
+    chainId: 4
+    enabled: false
+  - name: terra
+    type: terra
+    chainId: 3
+    rpc: ["http://terra:1317"]
+    websocket: "ws://terra:26657/websocket"
+    contracts: ["terra1dq03ugtd40zu9hcgdzrsq6z2z4hwhc9tqk2uy5"]
+    stallThreshold: 2m
+`
+
+func TestLoadChainConfigsFromFile(t *testing.T) {
+	v := testConfig(t, "yaml", testYAMLConfig)
+	chains, err := loadChainConfigs(v, testFlags(), false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(chains))
+	assert.Empty(t, validateChainConfigs(chains, false, false))
+
+	eth := enabledChain(chains, vaa.ChainIDEthereum)
+	assert.Equal(t, "eth", eth.Name)
+	assert.Equal(t, "ws://eth:8545", eth.PrimaryRPC())
+	assert.Nil(t, enabledChain(chains, vaa.ChainIDBSC))
+	terra := enabledChain(chains, vaa.ChainIDTerra)
+	assert.Equal(t, "ws://terra:26657/websocket", terra.Websocket)
+	assert.Equal(t, 2*time.Minute, terra.StallThreshold)
+	assert.Equal(t, time.Duration(0), eth.StallThreshold)
+}
+
+func TestLoadChainConfigsFromTOML(t *testing.T) {
+	v := testConfig(t, "toml", `
+[[chains]]
+name = "eth"
+type = "evm"
+chainId = 2
+rpc = ["ws://eth:8545"]
+contracts = ["0x98f3c9e6E3fAce36bAAd05FE09d375Ef1464288B"]
+minConfirmations = 5
+`)
+	chains, err := loadChainConfigs(v, testFlags(), false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(chains))
+	assert.Equal(t, uint64(5), chains[0].MinConfirmations)
+	assert.Empty(t, validateChainConfigs(chains, false, false))
+}
+
+func TestChainFlagOverrides(t *testing.T) {
+	v := testConfig(t, "yaml", testYAMLConfig)
+	chains, err := loadChainConfigs(v, testFlags("--ethRPC", "ws://other:8545", "--terraLCD", "http://other:1317"), false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "ws://other:8545", enabledChain(chains, vaa.ChainIDEthereum).PrimaryRPC())
+	assert.Equal(t, "http://other:1317", enabledChain(chains, vaa.ChainIDTerra).PrimaryRPC())
+	// flags which are not set don't override the config file
+	assert.Equal(t, "0x98f3c9e6E3fAce36bAAd05FE09d375Ef1464288B", enabledChain(chains, vaa.ChainIDEthereum).PrimaryContract())
+
+	// the overridden chain must have an entry
+	_, err = loadChainConfigs(v, testFlags("--alphRPC", "http://alph:22973"), false, false)
+	assert.NotNil(t, err)
+}
+
+func TestDefaultChainConfigs(t *testing.T) {
+	chains, err := loadChainConfigs(viper.New(), testFlags("--ethRPC", "ws://eth:8545", "--alphMinConfirmations", "3"), true, false)
+	assert.Nil(t, err)
+
+	eth := enabledChain(chains, vaa.ChainIDEthereum)
+	assert.Equal(t, "ws://eth:8545", eth.PrimaryRPC())
+	assert.Equal(t, devnet.GanacheWormholeContractAddress.Hex(), eth.PrimaryContract())
+	assert.Equal(t, uint64(512), enabledChain(chains, vaa.ChainIDPolygon).MinConfirmations)
+	assert.Equal(t, uint64(3), enabledChain(chains, vaa.ChainIDAlephium).MinConfirmations)
+	assert.Nil(t, enabledChain(chains, vaa.ChainIDEthereumRopsten))
+	assert.Nil(t, enabledChain(chains, vaa.ChainIDSolana))
+	assert.Nil(t, enabledChain(chains, vaa.ChainIDAlgorand))
+
+	chains, err = loadChainConfigs(viper.New(), testFlags(), false, true)
+	assert.Nil(t, err)
+	assert.NotNil(t, enabledChain(chains, vaa.ChainIDEthereumRopsten))
+	assert.Nil(t, enabledChain(chains, vaa.ChainIDAlephium))
+}
+
+func TestValidateChainConfigs(t *testing.T) {
+	v := testConfig(t, "yaml", `
+chains:
+  - name: eth
+    type: evm
+    chainId: 2
+    rpc: ["https://mainnet.infura.io/v3/key"]
+    contracts: ["0x98f3c9e6E3fAce36bAAd05FE09d375Ef1464288B"]
+  - name: polygon
+    type: evm
+    chainId: 5
+    rpc: ["ws://polygon:8545"]
+    contracts: ["0x7A4B5a56256163F07b2C80A7cA55aBE66c4ec4d7"]
+    minConfirmations: 1
+  - name: unknown
+    type: cosmos
+    chainId: 3
+  - name: ropsten
+    type: evm
+    chainId: 10001
+    rpc: ["ws://ropsten:8545"]
+    contracts: ["0x210c5F5e2AF958B4defFe715Dc621b7a3BA888c5"]
+  - name: disabled
+    type: evm
+    chainId: 4
+    enabled: false
+  - name: eth
+    type: evm
+    chainId: 2
+    enabled: false
+`)
+	chains, err := loadChainConfigs(v, testFlags(), false, false)
+	assert.Nil(t, err)
+
+	errs := validateChainConfigs(chains, false, false)
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	assert.Equal(t, []string{
+		"chain entry 0 (eth): Infura is known to send incorrect blocks - please use your own nodes",
+		"chain entry 1 (polygon): polygon requires at least 512 confirmations",
+		"chain entry 2 (unknown): unknown chain type \"cosmos\"",
+		"chain entry 3 (ropsten): can only be enabled in testnet mode",
+		"chain entry 5 (eth): duplicate name",
+	}, messages)
+}
+
+func TestValidateAlephiumChainConfig(t *testing.T) {
+	c := &watchers.ChainConfig{
+		Name:    "alephium",
+		Type:    watchers.ChainTypeAlephium,
+		ChainID: vaa.ChainIDAlephium,
+		RPC:     []string{"http://alph:12973"},
+		Contracts: []string{
+			"23MARNtX1SpQ9YrSKtnkMbpGonpPYSJ8R1MKC7vXn1PGg",
+			"27qwq9utLRWJ4mmTbsDYfXeUhkGiRJbAiLsCPdPrqDgWB",
+			"zWY1GmNf5iC5eGu4Fhz8ywmbU8zDEwGGNTGnVTaMLcf6",
+			"23MARNtX1SpQ9YrSKtnkMbpGonpPYSJ8R1MKC7vXn1PGg",
+		},
+		Quorum:            1,
+		ContractServerRPC: "[::]:31102",
+	}
+	assert.Nil(t, validateChainConfig(c, true, false))
+
+	// the validated config is accepted by the watcher factory
+	_, err := newWatcherRegistry(nil).Create(c, &watchers.Deps{})
+	assert.Nil(t, err)
+
+	c.StallThreshold = 3 * time.Nanosecond
+	assert.NotNil(t, validateChainConfig(c, true, false))
+	c.StallThreshold = watchers.MinStallThreshold
+	assert.Nil(t, validateChainConfig(c, true, false))
+
+	c.Contracts = c.Contracts[:3]
+	assert.NotNil(t, validateChainConfig(c, true, false))
+	c.Contracts = []string{"a", "b", "c", "d"}
+	assert.NotNil(t, validateChainConfig(c, true, false))
+}
+
+func TestWatcherRegistryCoversChainTypes(t *testing.T) {
+	// every chain type accepted by the validation must have a watcher factory
+	registry := newWatcherRegistry(nil)
+	for _, chainType := range registry.Types() {
+		c := &watchers.ChainConfig{Name: "test", Type: chainType, ChainID: vaa.ChainIDEthereum, Enabled: enabled(false)}
+		assert.Nil(t, validateChainConfig(c, false, false))
+	}
+	assert.ElementsMatch(t, []string{
+		watchers.ChainTypeEVM,
+		watchers.ChainTypeTerra,
+		watchers.ChainTypeSolana,
+		watchers.ChainTypeAlgorand,
+		watchers.ChainTypeAlephium,
+	}, registry.Types())
+}
@@ -0,0 +1,218 @@
+package guardiand
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/db"
+	"github.com/certusone/wormhole/node/pkg/processor"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbDataDir *string
+
+	exportChain        *uint16
+	exportEmitter      *string
+	exportMinSequence  *uint64
+	exportMaxSequence  *uint64
+	importGuardianSets *[]string
+)
+
+func init() {
+	dbDataDir = DBCmd.PersistentFlags().String("dataDir", "", "Data directory of the (stopped) guardian node")
+
+	exportChain = DBExportCmd.Flags().Uint16("chain", 0, "Only export VAAs of the given emitter chain")
+	exportEmitter = DBExportCmd.Flags().String("emitter", "", "Only export VAAs of the given emitter address (hex, requires --chain)")
+	exportMinSequence = DBExportCmd.Flags().Uint64("minSequence", 0, "Only export VAAs with a sequence of at least this value")
+	exportMaxSequence = DBExportCmd.Flags().Uint64("maxSequence", 0, "Only export VAAs with a sequence of at most this value (0 for no limit)")
+
+	importGuardianSets = DBImportCmd.Flags().StringArray("guardianSet", nil,
+		"Guardian set to verify the VAAs against, as <index>:<address>,<address>,... (repeatable)")
+
+	DBCmd.AddCommand(DBExportCmd)
+	DBCmd.AddCommand(DBImportCmd)
+}
+
+var DBCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Guardian database commands (offline)",
+}
+
+var DBExportCmd = &cobra.Command{
+	Use:   "export [FILENAME]",
+	Short: "Export signed VAAs to a checksummed archive",
+	Run:   runDBExport,
+	Args:  cobra.ExactArgs(1),
+}
+
+var DBImportCmd = &cobra.Command{
+	Use:   "import [FILENAME]",
+	Short: "Verify the signed VAAs of an archive and import them",
+	Run:   runDBImport,
+	Args:  cobra.ExactArgs(1),
+}
+
+func openDataDirDB() *db.Database {
+	if *dbDataDir == "" {
+		log.Fatal("Please specify --dataDir")
+	}
+	dbPath := path.Join(*dbDataDir, "db")
+	if err := os.MkdirAll(dbPath, 0700); err != nil {
+		log.Fatalf("failed to create database directory: %v", err)
+	}
+	d, err := db.Open(dbPath)
+	if err != nil {
+		log.Fatalf("failed to open database (is the node still running?): %v", err)
+	}
+	return d
+}
+
+func runDBExport(cmd *cobra.Command, args []string) {
+	filter := db.ExportFilter{
+		EmitterChain: vaa.ChainID(*exportChain),
+		MinSequence:  *exportMinSequence,
+		MaxSequence:  *exportMaxSequence,
+	}
+	if *exportEmitter != "" {
+		addr, err := vaa.StringToAddress(*exportEmitter)
+		if err != nil {
+			log.Fatalf("invalid emitter address: %v", err)
+		}
+		filter.EmitterAddress = &addr
+	}
+
+	d := openDataDirDB()
+	defer d.Close()
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		log.Fatalf("failed to create archive: %v", err)
+	}
+	defer f.Close()
+
+	a, err := db.NewArchiveWriter(f)
+	if err != nil {
+		log.Fatalf("failed to write archive: %v", err)
+	}
+	n, err := d.ExportSignedVAAs(a, filter)
+	if err != nil {
+		log.Fatalf("failed to export VAAs: %v", err)
+	}
+	if err := a.Close(); err != nil {
+		log.Fatalf("failed to write archive: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalf("failed to write archive: %v", err)
+	}
+
+	log.Printf("exported %d VAAs to %s", n, args[0])
+}
+
+// parseGuardianSet parses a guardian set given as <index>:<address>,<address>,...
+func parseGuardianSet(s string) (*common.GuardianSet, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid guardian set %q: expected <index>:<addresses>", s)
+	}
+	index, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid guardian set index: %w", err)
+	}
+	var keys []ethcommon.Address
+	for _, k := range strings.Split(parts[1], ",") {
+		if !ethcommon.IsHexAddress(k) {
+			return nil, fmt.Errorf("invalid guardian address: %s", k)
+		}
+		keys = append(keys, ethcommon.HexToAddress(k))
+	}
+	return &common.GuardianSet{Keys: keys, Index: uint32(index)}, nil
+}
+
+// verifyArchivedVAA checks that the VAA is signed by a quorum of its guardian set.
+func verifyArchivedVAA(b []byte, sets map[uint32]*common.GuardianSet) (*vaa.VAA, error) {
+	v, err := vaa.Unmarshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal VAA: %w", err)
+	}
+	gs, ok := sets[v.GuardianSetIndex]
+	if !ok {
+		return nil, fmt.Errorf("VAA %s is signed by unknown guardian set %d", v.MessageID(), v.GuardianSetIndex)
+	}
+	if err := processor.VerifyQuorum(v, gs); err != nil {
+		return nil, fmt.Errorf("VAA %s is not signed by a quorum: %w", v.MessageID(), err)
+	}
+	return v, nil
+}
+
+// readArchive reads the archive, verifies every VAA and passes it to handle. The archive checksum is only
+// verified at the end, so the whole archive has to be read once before handle may persist anything.
+func readArchive(r io.Reader, sets map[uint32]*common.GuardianSet, handle func(v *vaa.VAA) error) (int, error) {
+	a, err := db.NewArchiveReader(r)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		b, err := a.Next()
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, fmt.Errorf("failed to read archive: %w", err)
+		}
+		v, err := verifyArchivedVAA(b, sets)
+		if err != nil {
+			return n, err
+		}
+		if err := handle(v); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+func runDBImport(cmd *cobra.Command, args []string) {
+	if len(*importGuardianSets) == 0 {
+		log.Fatal("Please specify at least one --guardianSet")
+	}
+	sets := make(map[uint32]*common.GuardianSet)
+	for _, s := range *importGuardianSets {
+		gs, err := parseGuardianSet(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sets[gs.Index] = gs
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		log.Fatalf("failed to open archive: %v", err)
+	}
+	defer f.Close()
+
+	// Verify the whole archive before storing anything.
+	if _, err := readArchive(f, sets, func(*vaa.VAA) error { return nil }); err != nil {
+		log.Fatalf("refusing to import archive: %v", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		log.Fatalf("failed to rewind archive: %v", err)
+	}
+
+	d := openDataDirDB()
+	defer d.Close()
+
+	n, err := readArchive(f, sets, d.StoreSignedVAA)
+	if err != nil {
+		log.Fatalf("failed to import archive after %d VAAs: %v", n, err)
+	}
+
+	log.Printf("imported %d VAAs from %s", n, args[0])
+}
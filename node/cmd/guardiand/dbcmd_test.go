@@ -0,0 +1,96 @@
+package guardiand
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/db"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGuardianSet(t *testing.T) {
+	gs, err := parseGuardianSet("2:0xbeFA429d57cD18b7F8A4d91A2da9AB4AF05d0FBe,0x88D7D8B32a9105d228100E72dFFe2Fae0705D31c")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), gs.Index)
+	assert.Equal(t, 2, len(gs.Keys))
+
+	for _, s := range []string{"", "2", "x:0xbeFA429d57cD18b7F8A4d91A2da9AB4AF05d0FBe", "2:0xbeef"} {
+		_, err := parseGuardianSet(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func signedTestVAA(keys []*ecdsa.PrivateKey, seq uint64) *vaa.VAA {
+	v := &vaa.VAA{
+		Version:          vaa.SupportedVAAVersion,
+		GuardianSetIndex: 1,
+		Timestamp:        time.Unix(1650000000, 0),
+		Sequence:         seq,
+		ConsistencyLevel: 1,
+		EmitterChain:     vaa.ChainIDEthereum,
+		EmitterAddress:   vaa.Address{1},
+		Payload:          []byte{1},
+	}
+	for i, k := range keys {
+		v.AddSignature(k, uint8(i))
+	}
+	return v
+}
+
+func writeTestArchive(t *testing.T, vaas ...*vaa.VAA) []byte {
+	var buf bytes.Buffer
+	a, err := db.NewArchiveWriter(&buf)
+	assert.Nil(t, err)
+	for _, v := range vaas {
+		b, err := v.Marshal()
+		assert.Nil(t, err)
+		assert.Nil(t, a.Write(b))
+	}
+	assert.Nil(t, a.Close())
+	return buf.Bytes()
+}
+
+func TestReadArchiveVerifiesVAAs(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	gs := &common.GuardianSet{Index: 1}
+	for i := 0; i < 4; i++ {
+		k, err := crypto.GenerateKey()
+		assert.Nil(t, err)
+		keys = append(keys, k)
+		gs.Keys = append(gs.Keys, crypto.PubkeyToAddress(k.PublicKey))
+	}
+	sets := map[uint32]*common.GuardianSet{1: gs}
+	count := func(*vaa.VAA) error { return nil }
+
+	n, err := readArchive(bytes.NewReader(writeTestArchive(t, signedTestVAA(keys, 0), signedTestVAA(keys[:3], 1))), sets, count)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	// no quorum
+	_, err = readArchive(bytes.NewReader(writeTestArchive(t, signedTestVAA(keys[:2], 0))), sets, count)
+	assert.NotNil(t, err)
+
+	// signed by someone else
+	other, _ := crypto.GenerateKey()
+	_, err = readArchive(bytes.NewReader(writeTestArchive(t, signedTestVAA([]*ecdsa.PrivateKey{other, keys[1], keys[2]}, 0))), sets, count)
+	assert.NotNil(t, err)
+
+	// a single guardian's signature repeated up to the quorum
+	repeated := signedTestVAA(nil, 0)
+	for i := 0; i < 3; i++ {
+		repeated.AddSignature(keys[0], 0)
+	}
+	_, err = readArchive(bytes.NewReader(writeTestArchive(t, repeated)), sets, count)
+	assert.NotNil(t, err)
+
+	// unknown guardian set
+	unknown := signedTestVAA(keys, 0)
+	unknown.GuardianSetIndex = 2
+	_, err = readArchive(bytes.NewReader(writeTestArchive(t, unknown)), sets, count)
+	assert.NotNil(t, err)
+}
@@ -11,13 +11,14 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/certusone/wormhole/node/pkg/alephium"
 	"github.com/certusone/wormhole/node/pkg/db"
-	"github.com/certusone/wormhole/node/pkg/ethereum"
+	"github.com/certusone/wormhole/node/pkg/health"
+	"github.com/certusone/wormhole/node/pkg/notify"
 	"github.com/certusone/wormhole/node/pkg/notify/discord"
 	"github.com/certusone/wormhole/node/pkg/telemetry"
-	"github.com/certusone/wormhole/node/pkg/terra"
 	"github.com/certusone/wormhole/node/pkg/version"
 	"go.uber.org/zap/zapcore"
 
@@ -28,18 +29,19 @@ import (
 	"github.com/certusone/wormhole/node/pkg/devnet"
 	"github.com/certusone/wormhole/node/pkg/p2p"
 	"github.com/certusone/wormhole/node/pkg/processor"
-	alephiumv1 "github.com/certusone/wormhole/node/pkg/proto/alephium/v1"
 	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
 	publicrpcv1 "github.com/certusone/wormhole/node/pkg/proto/publicrpc/v1"
+	"github.com/certusone/wormhole/node/pkg/publicrpc"
 	"github.com/certusone/wormhole/node/pkg/readiness"
 	"github.com/certusone/wormhole/node/pkg/reporter"
 	"github.com/certusone/wormhole/node/pkg/supervisor"
 	"github.com/certusone/wormhole/node/pkg/vaa"
-	eth_common "github.com/ethereum/go-ethereum/common"
+	"github.com/certusone/wormhole/node/pkg/watchers"
 	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
 	ipfslog "github.com/ipfs/go-log/v2"
@@ -56,56 +58,13 @@ var (
 
 	dataDir *string
 
+	dbRetentionSequences  *uint64
+	dbRetentionAge        *time.Duration
+	dbMaintenanceInterval *time.Duration
+
 	statusAddr *string
 
 	guardianKeyPath *string
-	solanaContract  *string
-
-	ethRPC      *string
-	ethContract *string
-
-	bscRPC      *string
-	bscContract *string
-
-	polygonRPC      *string
-	polygonContract *string
-
-	ethRopstenRPC      *string
-	ethRopstenContract *string
-
-	fantomRPC      *string
-	fantomContract *string
-
-	avalancheRPC      *string
-	avalancheContract *string
-
-	oasisRPC      *string
-	oasisContract *string
-
-	karuraRPC      *string
-	karuraContract *string
-
-	acalaRPC      *string
-	acalaContract *string
-
-	terraWS       *string
-	terraLCD      *string
-	terraContract *string
-
-	algorandRPC      *string
-	algorandToken    *string
-	algorandContract *string
-
-	solanaWsRPC *string
-	solanaRPC   *string
-
-	alphRPC               *string
-	alphApiKey            *string
-	alphContractServerRPC *string
-	alphContractWebServer *string
-	alphContractIds       *[]string
-	alphGroupIndex        *uint8
-	alphMinConfirmations  *uint8
 
 	logLevel *string
 
@@ -114,8 +73,10 @@ var (
 	devNumGuardians *uint
 	nodeName        *string
 
-	publicRPC *string
-	publicWeb *string
+	publicRPC             *string
+	publicRPCStreamBuffer *uint
+	publicRPCMaxStreams   *uint
+	publicWeb             *string
 
 	tlsHostname *string
 	tlsProdEnv  *bool
@@ -128,12 +89,40 @@ var (
 	discordToken   *string
 	discordChannel *string
 
+	slackWebhookURL    *string
+	alertWebhookURL    *string
+	smtpAddr           *string
+	smtpUsername       *string
+	smtpPassword       *string
+	smtpFrom           *string
+	smtpTo             *[]string
+	alertRoutes        *[]string
+	alertDedupWindow   *time.Duration
+	alertRateLimit     *int
+	alertQuorumTimeout *time.Duration
+
+	watcherStallThreshold *time.Duration
+
 	bigTablePersistenceEnabled *bool
 	bigTableGCPProject         *string
 	bigTableInstanceName       *string
 	bigTableTableName          *string
 	bigTableTopicName          *string
 	bigTableKeyPath            *string
+
+	eventSinkFileDir        *string
+	eventSinkFileMaxBytes   *int64
+	eventSinkFileMaxFiles   *int
+	eventSinkWebhookURL     *string
+	eventSinkWebhookTimeout *time.Duration
+	eventSinkWebhookHeaders *[]string
+	eventSinkSQLDriver      *string
+	eventSinkSQLDSN         *string
+	eventSinkSQLTable       *string
+	eventSinkQueueSize      *int
+	eventSinkMaxAttempts    *int
+	eventSinkDeadLetterDir  *string
+	eventSinkReplay         *bool
 )
 
 func init() {
@@ -149,54 +138,63 @@ func init() {
 
 	dataDir = NodeCmd.Flags().String("dataDir", "", "Data directory")
 
+	dbRetentionSequences = NodeCmd.Flags().Uint64("dbRetentionSequences", 0, "Keep the signed VAAs with the N highest sequences of every emitter (0 keeps all)")
+	dbRetentionAge = NodeCmd.Flags().Duration("dbRetentionAge", 0, "Keep the signed VAAs newer than the given duration (0 keeps all)")
+	dbMaintenanceInterval = NodeCmd.Flags().Duration("dbMaintenanceInterval", 10*time.Minute, "Interval of database pruning, value log garbage collection and metrics updates")
+
 	guardianKeyPath = NodeCmd.Flags().String("guardianKey", "", "Path to guardian key (required)")
-	solanaContract = NodeCmd.Flags().String("solanaContract", "", "Address of the Solana program (required)")
 
-	ethRPC = NodeCmd.Flags().String("ethRPC", "", "Ethereum RPC URL")
-	ethContract = NodeCmd.Flags().String("ethContract", "", "Ethereum contract address")
+	// The per-chain flags override the fields of the chain entries in the config file, see chainConfig.
+	NodeCmd.Flags().String("solanaContract", "", "Address of the Solana program")
 
-	bscRPC = NodeCmd.Flags().String("bscRPC", "", "Binance Smart Chain RPC URL")
-	bscContract = NodeCmd.Flags().String("bscContract", "", "Binance Smart Chain contract address")
+	NodeCmd.Flags().String("ethRPC", "", "Ethereum RPC URL")
+	NodeCmd.Flags().String("ethContract", "", "Ethereum contract address")
 
-	polygonRPC = NodeCmd.Flags().String("polygonRPC", "", "Polygon RPC URL")
-	polygonContract = NodeCmd.Flags().String("polygonContract", "", "Polygon contract address")
+	NodeCmd.Flags().String("bscRPC", "", "Binance Smart Chain RPC URL")
+	NodeCmd.Flags().String("bscContract", "", "Binance Smart Chain contract address")
 
-	ethRopstenRPC = NodeCmd.Flags().String("ethRopstenRPC", "", "Ethereum Ropsten RPC URL")
-	ethRopstenContract = NodeCmd.Flags().String("ethRopstenContract", "", "Ethereum Ropsten contract address")
+	NodeCmd.Flags().String("polygonRPC", "", "Polygon RPC URL")
+	NodeCmd.Flags().String("polygonContract", "", "Polygon contract address")
 
-	avalancheRPC = NodeCmd.Flags().String("avalancheRPC", "", "Avalanche RPC URL")
-	avalancheContract = NodeCmd.Flags().String("avalancheContract", "", "Avalanche contract address")
+	NodeCmd.Flags().String("ethRopstenRPC", "", "Ethereum Ropsten RPC URL")
+	NodeCmd.Flags().String("ethRopstenContract", "", "Ethereum Ropsten contract address")
 
-	oasisRPC = NodeCmd.Flags().String("oasisRPC", "", "Oasis RPC URL")
-	oasisContract = NodeCmd.Flags().String("oasisContract", "", "Oasis contract address")
+	NodeCmd.Flags().String("avalancheRPC", "", "Avalanche RPC URL")
+	NodeCmd.Flags().String("avalancheContract", "", "Avalanche contract address")
 
-	fantomRPC = NodeCmd.Flags().String("fantomRPC", "", "Fantom Websocket RPC URL")
-	fantomContract = NodeCmd.Flags().String("fantomContract", "", "Fantom contract address")
+	NodeCmd.Flags().String("oasisRPC", "", "Oasis RPC URL")
+	NodeCmd.Flags().String("oasisContract", "", "Oasis contract address")
 
-	karuraRPC = NodeCmd.Flags().String("karuraRPC", "", "Karura RPC URL")
-	karuraContract = NodeCmd.Flags().String("karuraContract", "", "Karura contract address")
+	NodeCmd.Flags().String("fantomRPC", "", "Fantom Websocket RPC URL")
+	NodeCmd.Flags().String("fantomContract", "", "Fantom contract address")
 
-	acalaRPC = NodeCmd.Flags().String("acalaRPC", "", "Acala RPC URL")
-	acalaContract = NodeCmd.Flags().String("acalaContract", "", "Acala contract address")
+	NodeCmd.Flags().String("karuraRPC", "", "Karura RPC URL")
+	NodeCmd.Flags().String("karuraContract", "", "Karura contract address")
 
-	terraWS = NodeCmd.Flags().String("terraWS", "", "Path to terrad root for websocket connection")
-	terraLCD = NodeCmd.Flags().String("terraLCD", "", "Path to LCD service root for http calls")
-	terraContract = NodeCmd.Flags().String("terraContract", "", "Wormhole contract address on Terra blockchain")
+	NodeCmd.Flags().String("acalaRPC", "", "Acala RPC URL")
+	NodeCmd.Flags().String("acalaContract", "", "Acala contract address")
 
-	algorandRPC = NodeCmd.Flags().String("algorandRPC", "", "Algorand RPC URL")
-	algorandToken = NodeCmd.Flags().String("algorandToken", "", "Algorand access token")
-	algorandContract = NodeCmd.Flags().String("algorandContract", "", "Algorand contract")
+	NodeCmd.Flags().String("terraWS", "", "Path to terrad root for websocket connection")
+	NodeCmd.Flags().String("terraLCD", "", "Path to LCD service root for http calls")
+	NodeCmd.Flags().String("terraContract", "", "Wormhole contract address on Terra blockchain")
 
-	solanaWsRPC = NodeCmd.Flags().String("solanaWS", "", "Solana Websocket URL (required")
-	solanaRPC = NodeCmd.Flags().String("solanaRPC", "", "Solana RPC URL (required")
+	NodeCmd.Flags().String("algorandRPC", "", "Algorand algod RPC URL")
+	NodeCmd.Flags().String("algorandToken", "", "Algorand algod access token")
+	NodeCmd.Flags().String("algorandIndexerRPC", "", "Algorand indexer RPC URL, the Algorand watcher is only enabled if it's set")
+	NodeCmd.Flags().String("algorandIndexerToken", "", "Algorand indexer access token")
+	NodeCmd.Flags().String("algorandContract", "", "Application ID of the Wormhole core contract on Algorand")
 
-	alphRPC = NodeCmd.Flags().String("alphRPC", "", "Alephium RPC URL (required)")
-	alphApiKey = NodeCmd.Flags().String("alphApiKey", "", "Alphium RPC api key")
-	alphContractServerRPC = NodeCmd.Flags().String("alphContractServerRpc", "", "Listen address for alephium contract server gRPC interface (required)")
-	alphContractWebServer = NodeCmd.Flags().String("alphContractWebServer", "", "Listen address for alephium contract server REST interface")
-	alphContractIds = NodeCmd.Flags().StringSlice("alphContractIds", []string{}, "Alephium contract ids (required)")
-	alphGroupIndex = NodeCmd.Flags().Uint8("alphGroupIndex", 0, "The group index where contracts are deployed (required)")
-	alphMinConfirmations = NodeCmd.Flags().Uint8("alphMinConfirmations", 1, "The min confirmations for alephium tx")
+	NodeCmd.Flags().String("solanaWS", "", "Solana Websocket URL")
+	NodeCmd.Flags().String("solanaRPC", "", "Solana RPC URL")
+
+	NodeCmd.Flags().StringSlice("alphRPC", []string{}, "Alephium RPC URLs, the watcher fails over to the next URL if a node is unavailable")
+	NodeCmd.Flags().Uint8("alphQuorum", 0, "Number of Alephium nodes which must return the same events before they are confirmed, 0 disables quorum reads")
+	NodeCmd.Flags().String("alphApiKey", "", "Alphium RPC api key")
+	NodeCmd.Flags().String("alphContractServerRpc", "", "Listen address for alephium contract server gRPC interface")
+	NodeCmd.Flags().String("alphContractWebServer", "", "Listen address for alephium contract server REST interface")
+	NodeCmd.Flags().StringSlice("alphContractIds", []string{}, "Alephium governance, event emitter, token bridge and token wrapper factory contract ids")
+	NodeCmd.Flags().Uint8("alphGroupIndex", 0, "The group index where contracts are deployed")
+	NodeCmd.Flags().Uint8("alphMinConfirmations", 1, "The min confirmations for alephium tx")
 
 	logLevel = NodeCmd.Flags().String("logLevel", "info", "Logging level (debug, info, warn, error, dpanic, panic, fatal)")
 
@@ -206,6 +204,8 @@ func init() {
 	nodeName = NodeCmd.Flags().String("nodeName", "", "Node name to announce in gossip heartbeats")
 
 	publicRPC = NodeCmd.Flags().String("publicRPC", "", "Listen address for public gRPC interface")
+	publicRPCStreamBuffer = NodeCmd.Flags().Uint("publicRPCStreamBuffer", 1000, "Number of VAAs buffered per SubscribeSignedVAA client before it is disconnected")
+	publicRPCMaxStreams = NodeCmd.Flags().Uint("publicRPCMaxStreams", 100, "Maximum number of concurrent SubscribeSignedVAA streams (0 for no limit)")
 	publicWeb = NodeCmd.Flags().String("publicWeb", "", "Listen address for public REST and gRPC Web interface")
 
 	tlsHostname = NodeCmd.Flags().String("tlsHostname", "", "If set, serve publicWeb as TLS with this hostname using Let's Encrypt")
@@ -223,12 +223,41 @@ func init() {
 	discordToken = NodeCmd.Flags().String("discordToken", "", "Discord bot token (optional)")
 	discordChannel = NodeCmd.Flags().String("discordChannel", "", "Discord channel name (optional)")
 
+	slackWebhookURL = NodeCmd.Flags().String("slackWebhookURL", "", "Slack incoming webhook URL to send alerts to (optional)")
+	alertWebhookURL = NodeCmd.Flags().String("alertWebhookURL", "", "URL to POST alerts to as JSON (optional)")
+	smtpAddr = NodeCmd.Flags().String("smtpAddr", "", "SMTP server (host:port) to send alerts by email with (optional)")
+	smtpUsername = NodeCmd.Flags().String("smtpUsername", "", "SMTP username, authentication is skipped if empty")
+	smtpPassword = NodeCmd.Flags().String("smtpPassword", "", "SMTP password")
+	smtpFrom = NodeCmd.Flags().String("smtpFrom", "", "Sender address of alert emails")
+	smtpTo = NodeCmd.Flags().StringSlice("smtpTo", []string{}, "Recipient addresses of alert emails")
+	alertRoutes = NodeCmd.Flags().StringArray("alertRoute", []string{},
+		"Alert routing rule as <types>:<backends>, e.g. 'quorum_timeout,watcher_stalled:slack,email' or '*:discord' (can be repeated, all alerts go to all backends if unset)")
+	alertDedupWindow = NodeCmd.Flags().Duration("alertDedupWindow", 10*time.Minute, "Time during which repeated alerts about the same subject are dropped")
+	alertRateLimit = NodeCmd.Flags().Int("alertRateLimit", 30, "Maximum number of alerts per minute sent to each backend (0 disables the limit)")
+	alertQuorumTimeout = NodeCmd.Flags().Duration("alertQuorumTimeout", 2*time.Minute, "Alert when an observed message doesn't reach quorum within the given time (0 disables the alert)")
+
+	watcherStallThreshold = NodeCmd.Flags().Duration("watcherStallThreshold", 5*time.Minute, "Consider a chain watcher stalled if it doesn't see a new block within the given time (overridden by the stallThreshold of a chain entry)")
+
 	bigTablePersistenceEnabled = NodeCmd.Flags().Bool("bigTablePersistenceEnabled", false, "Turn on forwarding events to BigTable")
 	bigTableGCPProject = NodeCmd.Flags().String("bigTableGCPProject", "", "Google Cloud project ID for storing events")
 	bigTableInstanceName = NodeCmd.Flags().String("bigTableInstanceName", "", "BigTable instance name for storing events")
 	bigTableTableName = NodeCmd.Flags().String("bigTableTableName", "", "BigTable table name to store events in")
 	bigTableTopicName = NodeCmd.Flags().String("bigTableTopicName", "", "GCP topic name to publish to")
 	bigTableKeyPath = NodeCmd.Flags().String("bigTableKeyPath", "", "Path to json Service Account key")
+
+	eventSinkFileDir = NodeCmd.Flags().String("eventSinkFileDir", "", "Directory to write events to as rotating JSON-lines files (optional)")
+	eventSinkFileMaxBytes = NodeCmd.Flags().Int64("eventSinkFileMaxBytes", 100<<20, "Size after which the event file is rotated")
+	eventSinkFileMaxFiles = NodeCmd.Flags().Int("eventSinkFileMaxFiles", 10, "Number of rotated event files to keep (0 keeps all)")
+	eventSinkWebhookURL = NodeCmd.Flags().String("eventSinkWebhookURL", "", "URL to POST events to (optional)")
+	eventSinkWebhookTimeout = NodeCmd.Flags().Duration("eventSinkWebhookTimeout", 10*time.Second, "Timeout of a single webhook request")
+	eventSinkWebhookHeaders = NodeCmd.Flags().StringSlice("eventSinkWebhookHeader", []string{}, "Header added to webhook requests, as 'Name: value'")
+	eventSinkSQLDriver = NodeCmd.Flags().String("eventSinkSQLDriver", "", "database/sql driver to store events with, e.g. sqlite3 or postgres (optional, the driver must be linked in)")
+	eventSinkSQLDSN = NodeCmd.Flags().String("eventSinkSQLDSN", "", "Data source name of the event database")
+	eventSinkSQLTable = NodeCmd.Flags().String("eventSinkSQLTable", "attestation_events", "Table to store events in")
+	eventSinkQueueSize = NodeCmd.Flags().Int("eventSinkQueueSize", 1000, "Number of events buffered per event sink")
+	eventSinkMaxAttempts = NodeCmd.Flags().Int("eventSinkMaxAttempts", 5, "Number of attempts to deliver an event to a sink before it is dead-lettered")
+	eventSinkDeadLetterDir = NodeCmd.Flags().String("eventSinkDeadLetterDir", "", "Directory for the dead-letter files of the event sinks, undeliverable events are only logged if empty")
+	eventSinkReplay = NodeCmd.Flags().Bool("eventSinkReplay", true, "Feed the event sinks with quorum events from the database, so that they catch up on the VAAs they missed")
 }
 
 var (
@@ -300,25 +329,40 @@ func runNode(cmd *cobra.Command, args []string) {
 	// Override the default go-log config, which uses a magic environment variable.
 	ipfslog.SetAllLoggers(lvl)
 
+	// Load and verify the chain entries. In devnet mode, the EVM contract addresses are
+	// overridden with the deterministic devnet address.
+	chains, err := loadChainConfigs(viper.GetViper(), cmd.Flags(), *unsafeDevMode, *testnetMode)
+	if err != nil {
+		logger.Fatal("failed to load chain config", zap.Error(err))
+	}
+	if errs := validateChainConfigs(chains, *unsafeDevMode, *testnetMode); len(errs) != 0 {
+		for _, err := range errs {
+			logger.Error("invalid chain config", zap.Error(err))
+		}
+		logger.Fatal("invalid chain config", zap.Int("errors", len(errs)))
+	}
+
 	// Register components for readiness checks.
-	readiness.RegisterComponent(common.ReadinessEthSyncing)
-	// readiness.RegisterComponent(common.ReadinessSolanaSyncing)
-	readiness.RegisterComponent(common.ReadinessTerraSyncing)
-	if *unsafeDevMode {
-		// readiness.RegisterComponent(common.ReadinessAlgorandSyncing)
-		readiness.RegisterComponent(common.ReadinessAlephiumSyncing)
+	for _, c := range chains {
+		if c.IsEnabled() {
+			readiness.RegisterComponent(common.ChainReadiness(c.ChainID))
+		}
 	}
-	readiness.RegisterComponent(common.ReadinessBSCSyncing)
-	readiness.RegisterComponent(common.ReadinessPolygonSyncing)
-	readiness.RegisterComponent(common.ReadinessAvalancheSyncing)
-	readiness.RegisterComponent(common.ReadinessOasisSyncing)
-	readiness.RegisterComponent(common.ReadinessFantomSyncing)
 
-	if *testnetMode {
-		readiness.RegisterComponent(common.ReadinessEthRopstenSyncing)
-		readiness.RegisterComponent(common.ReadinessKaruraSyncing)
-		readiness.RegisterComponent(common.ReadinessAcalaSyncing)
+	if *watcherStallThreshold < watchers.MinStallThreshold {
+		logger.Fatal("--watcherStallThreshold must be at least " + watchers.MinStallThreshold.String())
 	}
+	// notifier stays a nil interface if alerting is disabled
+	var notifier notify.Notifier
+	liveness := watchers.NewLivenessMonitor(*watcherStallThreshold, func(h watchers.Health, stalledFor time.Duration) {
+		if notifier != nil {
+			notifier.Notify(notify.WatcherStalled(h.ChainID, h.Height, stalledFor))
+		}
+	})
+
+	// Guardian set state managed by processor
+	gst := common.NewGuardianSetState()
+	healthChecker := health.NewChecker(gst)
 
 	if *statusAddr != "" {
 		// Use a custom routing instead of using http.DefaultServeMux directly to avoid accidentally exposing packages
@@ -336,6 +380,11 @@ func runNode(cmd *cobra.Command, args []string) {
 		// Simple endpoint exposing node readiness (safe to expose to untrusted clients)
 		router.HandleFunc("/readyz", readiness.Handler)
 
+		// Current node and chain watcher health as JSON (safe to expose to untrusted clients)
+		router.HandleFunc("/health/live", healthChecker.LiveHandler)
+		router.HandleFunc("/health/ready", healthChecker.ReadyHandler)
+		router.Handle("/healthz", liveness)
+
 		// Prometheus metrics (safe to expose to untrusted clients)
 		router.Handle("/metrics", promhttp.Handler())
 
@@ -354,16 +403,6 @@ func runNode(cmd *cobra.Command, args []string) {
 
 		// Use the first guardian node as bootstrap
 		*p2pBootstrap = fmt.Sprintf("/dns4/guardian-0.guardian/udp/%d/quic/p2p/%s", *p2pPort, g0key.String())
-
-		// Deterministic ganache ETH devnet address.
-		*ethContract = devnet.GanacheWormholeContractAddress.Hex()
-		*bscContract = devnet.GanacheWormholeContractAddress.Hex()
-		*polygonContract = devnet.GanacheWormholeContractAddress.Hex()
-		*avalancheContract = devnet.GanacheWormholeContractAddress.Hex()
-		*oasisContract = devnet.GanacheWormholeContractAddress.Hex()
-		*fantomContract = devnet.GanacheWormholeContractAddress.Hex()
-		*karuraContract = devnet.GanacheWormholeContractAddress.Hex()
-		*acalaContract = devnet.GanacheWormholeContractAddress.Hex()
 	}
 
 	// Verify flags
@@ -380,111 +419,10 @@ func runNode(cmd *cobra.Command, args []string) {
 	if *dataDir == "" {
 		logger.Fatal("Please specify --dataDir")
 	}
-	if *ethRPC == "" {
-		logger.Fatal("Please specify --ethRPC")
-	}
-	if *ethContract == "" {
-		logger.Fatal("Please specify --ethContract")
-	}
-	if *bscRPC == "" {
-		logger.Fatal("Please specify --bscRPC")
-	}
-	if *bscContract == "" {
-		logger.Fatal("Please specify --bscContract")
-	}
-	if *polygonRPC == "" {
-		logger.Fatal("Please specify --polygonRPC")
-	}
-	if *polygonContract == "" {
-		logger.Fatal("Please specify --polygonContract")
-	}
-	if *avalancheRPC == "" {
-		logger.Fatal("Please specify --avalancheRPC")
-	}
-	if *oasisRPC == "" {
-		logger.Fatal("Please specify --oasisRPC")
-	}
-	if *fantomRPC == "" {
-		logger.Fatal("Please specify --fantomRPC")
-	}
-	if *fantomContract == "" && !*unsafeDevMode {
-		logger.Fatal("Please specify --fantomContract")
-	}
-	if *testnetMode {
-		if *ethRopstenRPC == "" {
-			logger.Fatal("Please specify --ethRopstenRPC")
-		}
-		if *ethRopstenContract == "" {
-			logger.Fatal("Please specify --ethRopstenContract")
-		}
-		if *karuraRPC == "" {
-			logger.Fatal("Please specify --karuraRPC")
-		}
-		if *karuraContract == "" {
-			logger.Fatal("Please specify --karuraContract")
-		}
-		if *acalaRPC == "" {
-			logger.Fatal("Please specify --acalaRPC")
-		}
-		if *acalaContract == "" {
-			logger.Fatal("Please specify --acalaContract")
-		}
-	} else {
-		if *ethRopstenRPC != "" {
-			logger.Fatal("Please do not specify --ethRopstenRPC in non-testnet mode")
-		}
-		if *ethRopstenContract != "" {
-			logger.Fatal("Please do not specify --ethRopstenContract in non-testnet mode")
-		}
-		if *karuraRPC != "" && !*unsafeDevMode {
-			logger.Fatal("Please do not specify --karuraRPC")
-		}
-		if *karuraContract != "" && !*unsafeDevMode {
-			logger.Fatal("Please do not specify --karuraContract")
-		}
-		if *acalaRPC != "" && !*unsafeDevMode {
-			logger.Fatal("Please do not specify --acalaRPC")
-		}
-		if *acalaContract != "" && !*unsafeDevMode {
-			logger.Fatal("Please do not specify --acalaContract")
-		}
-	}
 	if *nodeName == "" {
 		logger.Fatal("Please specify --nodeName")
 	}
 
-	if *solanaContract == "" {
-		logger.Fatal("Please specify --solanaContract")
-	}
-	if *solanaWsRPC == "" {
-		logger.Fatal("Please specify --solanaWsUrl")
-	}
-	if *solanaRPC == "" {
-		logger.Fatal("Please specify --solanaUrl")
-	}
-
-	if *terraWS == "" {
-		logger.Fatal("Please specify --terraWS")
-	}
-	if *terraLCD == "" {
-		logger.Fatal("Please specify --terraLCD")
-	}
-	if *terraContract == "" {
-		logger.Fatal("Please specify --terraContract")
-	}
-
-	if *unsafeDevMode {
-		if *algorandRPC == "" {
-			logger.Fatal("Please specify --algorandRPC")
-		}
-		if *algorandToken == "" {
-			logger.Fatal("Please specify --algorandToken")
-		}
-		if *algorandContract == "" {
-			logger.Fatal("Please specify --algorandContract")
-		}
-	}
-
 	if *bigTablePersistenceEnabled {
 		if *bigTableGCPProject == "" {
 			logger.Fatal("Please specify --bigTableGCPProject")
@@ -503,44 +441,52 @@ func runNode(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	// Complain about Infura on mainnet.
-	//
-	// As it turns out, Infura has a bug where it would sometimes incorrectly round
-	// block timestamps, which causes consensus issues - the timestamp is part of
-	// the VAA and nodes using Infura would sometimes derive an incorrect VAA,
-	// accidentally attacking the network by signing a conflicting VAA.
-	//
-	// Node operators do not usually rely on Infura in the first place - doing
-	// so is insecure, since nodes blindly trust the connected nodes to verify
-	// on-chain message proofs. However, node operators sometimes used
-	// Infura during migrations where their primary node was offline, causing
-	// the aforementioned consensus oddities which were eventually found to
-	// be Infura-related. This is generally to the detriment of network security
-	// and a judgement call made by individual operators. In the case of Infura,
-	// we know it's actively dangerous so let's make an opinionated argument.
-	//
-	// Insert "I'm a sign, not a cop" meme.
-	//
-	if strings.Contains(*ethRPC, "mainnet.infura.io") ||
-		strings.Contains(*polygonRPC, "polygon-mainnet.infura.io") {
-		logger.Fatal("Infura is known to send incorrect blocks - please use your own nodes")
-	}
-
-	ethContractAddr := eth_common.HexToAddress(*ethContract)
-	bscContractAddr := eth_common.HexToAddress(*bscContract)
-	polygonContractAddr := eth_common.HexToAddress(*polygonContract)
-	ethRopstenContractAddr := eth_common.HexToAddress(*ethRopstenContract)
-	avalancheContractAddr := eth_common.HexToAddress(*avalancheContract)
-	oasisContractAddr := eth_common.HexToAddress(*oasisContract)
-	fantomContractAddr := eth_common.HexToAddress(*fantomContract)
-	karuraContractAddr := eth_common.HexToAddress(*karuraContract)
-	acalaContractAddr := eth_common.HexToAddress(*acalaContract)
-	/*
-		solAddress, err := solana_types.PublicKeyFromBase58(*solanaContract)
+	if *eventSinkSQLDriver != "" && *eventSinkSQLDSN == "" {
+		logger.Fatal("Please specify --eventSinkSQLDSN")
+	}
+	if *eventSinkQueueSize <= 0 {
+		logger.Fatal("--eventSinkQueueSize must be positive")
+	}
+	if *eventSinkMaxAttempts <= 0 {
+		logger.Fatal("--eventSinkMaxAttempts must be positive")
+	}
+	if *publicRPCStreamBuffer == 0 {
+		logger.Fatal("--publicRPCStreamBuffer must be positive")
+	}
+	webhookHeaders := make(map[string]string)
+	for _, h := range *eventSinkWebhookHeaders {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 {
+			logger.Fatal("invalid --eventSinkWebhookHeader, expected 'Name: value'", zap.String("header", h))
+		}
+		webhookHeaders[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	var eventSinks []reporter.Sink
+	if *eventSinkFileDir != "" {
+		eventSinks = append(eventSinks, reporter.NewFileSink(reporter.FileSinkConfig{
+			Dir:      *eventSinkFileDir,
+			MaxBytes: *eventSinkFileMaxBytes,
+			MaxFiles: *eventSinkFileMaxFiles,
+		}))
+	}
+	if *eventSinkWebhookURL != "" {
+		eventSinks = append(eventSinks, reporter.NewWebhookSink(reporter.WebhookSinkConfig{
+			URL:     *eventSinkWebhookURL,
+			Timeout: *eventSinkWebhookTimeout,
+			Headers: webhookHeaders,
+		}))
+	}
+	if *eventSinkSQLDriver != "" {
+		sink, err := reporter.NewSQLSink(reporter.SQLSinkConfig{
+			Driver: *eventSinkSQLDriver,
+			DSN:    *eventSinkSQLDSN,
+			Table:  *eventSinkSQLTable,
+		})
 		if err != nil {
-			logger.Fatal("invalid Solana contract address", zap.Error(err))
+			logger.Fatal("invalid SQL event sink", zap.Error(err))
 		}
-	*/
+		eventSinks = append(eventSinks, sink)
+	}
 
 	// In devnet mode, we generate a deterministic guardian key and write it to disk.
 	if *unsafeDevMode {
@@ -560,6 +506,13 @@ func runNode(cmd *cobra.Command, args []string) {
 	if err := os.MkdirAll(dbPath, 0700); err != nil {
 		logger.Fatal("failed to create database directory", zap.Error(err))
 	}
+	retention := db.RetentionPolicy{KeepSequences: *dbRetentionSequences, MaxAge: *dbRetentionAge}
+	if err := retention.Validate(); err != nil {
+		logger.Fatal("invalid database retention policy", zap.Error(err))
+	}
+	if *dbMaintenanceInterval <= 0 {
+		logger.Fatal("Please specify a positive --dbMaintenanceInterval")
+	}
 	db, err := db.Open(dbPath)
 	if err != nil {
 		logger.Fatal("failed to open database", zap.Error(err))
@@ -617,28 +570,14 @@ func runNode(cmd *cobra.Command, args []string) {
 	// Injected VAAs (manually generated rather than created via observation)
 	injectC := make(chan *vaa.VAA)
 
-	// Guardian set state managed by processor
-	gst := common.NewGuardianSetState()
-
 	// Per-chain observation requests
 	chainObsvReqC := make(map[vaa.ChainID]chan *gossipv1.ObservationRequest)
 
-	// Observation request channel for each chain supporting observation requests.
-	chainObsvReqC[vaa.ChainIDSolana] = make(chan *gossipv1.ObservationRequest)
-	chainObsvReqC[vaa.ChainIDEthereum] = make(chan *gossipv1.ObservationRequest)
-	chainObsvReqC[vaa.ChainIDTerra] = make(chan *gossipv1.ObservationRequest)
-	chainObsvReqC[vaa.ChainIDBSC] = make(chan *gossipv1.ObservationRequest)
-	chainObsvReqC[vaa.ChainIDPolygon] = make(chan *gossipv1.ObservationRequest)
-	chainObsvReqC[vaa.ChainIDAvalanche] = make(chan *gossipv1.ObservationRequest)
-	chainObsvReqC[vaa.ChainIDOasis] = make(chan *gossipv1.ObservationRequest)
-	if *testnetMode {
-		chainObsvReqC[vaa.ChainIDFantom] = make(chan *gossipv1.ObservationRequest)
-		chainObsvReqC[vaa.ChainIDKarura] = make(chan *gossipv1.ObservationRequest)
-		chainObsvReqC[vaa.ChainIDAcala] = make(chan *gossipv1.ObservationRequest)
-		chainObsvReqC[vaa.ChainIDEthereumRopsten] = make(chan *gossipv1.ObservationRequest)
-	}
-	if *unsafeDevMode {
-		chainObsvReqC[vaa.ChainIDAlephium] = make(chan *gossipv1.ObservationRequest)
+	// Observation request channel for each enabled chain.
+	for _, c := range chains {
+		if c.IsEnabled() {
+			chainObsvReqC[c.ChainID] = make(chan *gossipv1.ObservationRequest)
+		}
 	}
 
 	// Multiplex observation requests to the appropriate chain
@@ -659,12 +598,53 @@ func runNode(cmd *cobra.Command, args []string) {
 		}
 	}()
 
-	var notifier *discord.DiscordNotifier
+	var alertBackends []notify.Backend
 	if *discordToken != "" {
-		notifier, err = discord.NewDiscordNotifier(*discordToken, *discordChannel, logger)
+		discordNotifier, err := discord.NewDiscordNotifier(*discordToken, *discordChannel, logger)
 		if err != nil {
 			logger.Error("failed to initialize Discord bot", zap.Error(err))
+		} else {
+			alertBackends = append(alertBackends, discordNotifier)
+		}
+	}
+	if *slackWebhookURL != "" {
+		alertBackends = append(alertBackends, notify.NewSlackBackend(*slackWebhookURL))
+	}
+	if *alertWebhookURL != "" {
+		alertBackends = append(alertBackends, notify.NewWebhookBackend(*alertWebhookURL))
+	}
+	if *smtpAddr != "" {
+		emailBackend, err := notify.NewEmailBackend(notify.EmailConfig{
+			Addr:     *smtpAddr,
+			Username: *smtpUsername,
+			Password: *smtpPassword,
+			From:     *smtpFrom,
+			To:       *smtpTo,
+		})
+		if err != nil {
+			logger.Fatal("invalid email alert settings", zap.Error(err))
+		}
+		alertBackends = append(alertBackends, emailBackend)
+	}
+	var alertDispatcher *notify.Dispatcher
+	if len(alertBackends) > 0 {
+		config := notify.DispatcherConfig{
+			DedupWindow: *alertDedupWindow,
+			RateLimit:   *alertRateLimit,
+			SendTimeout: 30 * time.Second,
+		}
+		for _, r := range *alertRoutes {
+			route, err := notify.ParseRoute(r)
+			if err != nil {
+				logger.Fatal("invalid --alertRoute", zap.Error(err))
+			}
+			config.Routes = append(config.Routes, route)
 		}
+		alertDispatcher, err = notify.NewDispatcher(alertBackends, config)
+		if err != nil {
+			logger.Fatal("invalid alert settings", zap.Error(err))
+		}
+		notifier = alertDispatcher
 	}
 
 	// Load p2p private key
@@ -724,14 +704,17 @@ func runNode(cmd *cobra.Command, args []string) {
 	// provides methods for reporting progress toward message attestation, and channels for receiving attestation lifecyclye events.
 	attestationEvents := reporter.EventListener(logger)
 
-	publicrpcService, publicrpcServer, err := publicrpcServiceRunnable(logger, *publicRPC, db, gst)
+	// streams the VAAs which reach quorum to the SubscribeSignedVAA clients of the public RPC
+	signedVAAStream := publicrpc.NewSignedVAAStream(attestationEvents, int(*publicRPCStreamBuffer), int(*publicRPCMaxStreams))
+
+	publicrpcService, publicrpcServer, err := publicrpcServiceRunnable(logger, *publicRPC, db, gst, signedVAAStream)
 
 	if err != nil {
 		log.Fatal("failed to create publicrpc service socket", zap.Error(err))
 	}
 
 	// local admin service socket
-	adminService, err := adminServiceRunnable(logger, *adminSocketPath, injectC, signedInC, obsvReqSendC, db, alphDb, gst)
+	adminService, err := adminServiceRunnable(logger, *adminSocketPath, injectC, attestationEvents, obsvReqSendC, db, alphDb, gst)
 	if err != nil {
 		logger.Fatal("failed to create admin service socket", zap.Error(err))
 	}
@@ -743,118 +726,67 @@ func runNode(cmd *cobra.Command, args []string) {
 	}
 
 	// Run supervisor.
-	supervisor.New(rootCtx, logger, func(ctx context.Context) error {
+	sup := supervisor.New(rootCtx, logger, func(ctx context.Context) error {
 		if err := supervisor.Run(ctx, "p2p", p2p.Run(
 			obsvC, obsvReqC, obsvReqSendC, sendC, signedInC, priv, gk, gst, *p2pPort, *p2pNetworkID, *p2pBootstrap, *nodeName, *disableHeartbeatVerify, rootCtxCancel)); err != nil {
 			return err
 		}
 
-		if err := supervisor.Run(ctx, "ethwatch",
-			ethereum.NewEthWatcher(*ethRPC, ethContractAddr, "eth", common.ReadinessEthSyncing, vaa.ChainIDEthereum, lockC, setC, 1, chainObsvReqC[vaa.ChainIDEthereum]).Run); err != nil {
-			return err
-		}
-
-		if err := supervisor.Run(ctx, "bscwatch",
-			ethereum.NewEthWatcher(*bscRPC, bscContractAddr, "bsc", common.ReadinessBSCSyncing, vaa.ChainIDBSC, lockC, nil, 1, chainObsvReqC[vaa.ChainIDBSC]).Run); err != nil {
-			return err
-		}
-
-		if err := supervisor.Run(ctx, "polygonwatch",
-			ethereum.NewEthWatcher(*polygonRPC, polygonContractAddr, "polygon", common.ReadinessPolygonSyncing, vaa.ChainIDPolygon, lockC, nil, 512, chainObsvReqC[vaa.ChainIDPolygon]).Run); err != nil {
-			// Special case: Polygon can fork like PoW Ethereum, and it's not clear what the safe number of blocks is
-			//
-			// Hardcode the minimum number of confirmations to 512 regardless of what the smart contract specifies to protect
-			// developers from accidentally specifying an unsafe number of confirmations. We can remove this restriction as soon
-			// as specific public guidance exists for Polygon developers.
-			return err
-		}
-		if err := supervisor.Run(ctx, "avalanchewatch",
-			ethereum.NewEthWatcher(*avalancheRPC, avalancheContractAddr, "avalanche", common.ReadinessAvalancheSyncing, vaa.ChainIDAvalanche, lockC, nil, 1, chainObsvReqC[vaa.ChainIDAvalanche]).Run); err != nil {
-			return err
-		}
-		if err := supervisor.Run(ctx, "oasiswatch",
-			ethereum.NewEthWatcher(*oasisRPC, oasisContractAddr, "oasis", common.ReadinessOasisSyncing, vaa.ChainIDOasis, lockC, nil, 1, chainObsvReqC[vaa.ChainIDOasis]).Run); err != nil {
-			return err
-		}
-		if err := supervisor.Run(ctx, "fantomwatch",
-			ethereum.NewEthWatcher(*fantomRPC, fantomContractAddr, "fantom", common.ReadinessFantomSyncing, vaa.ChainIDFantom, lockC, nil, 1, chainObsvReqC[vaa.ChainIDFantom]).Run); err != nil {
-			return err
+		registry := newWatcherRegistry(alphDb)
+		web := &publicWebDeps{
+			logger:      logger,
+			tlsHostname: *tlsHostname,
+			tlsProdEnv:  *tlsProdEnv,
+			autocertDir: path.Join(*dataDir, "autocert"),
 		}
-
-		if *testnetMode {
-			if err := supervisor.Run(ctx, "ethropstenwatch",
-				ethereum.NewEthWatcher(*ethRopstenRPC, ethRopstenContractAddr, "ethropsten", common.ReadinessEthRopstenSyncing, vaa.ChainIDEthereumRopsten, lockC, nil, 1, chainObsvReqC[vaa.ChainIDEthereumRopsten]).Run); err != nil {
-				return err
+		for _, c := range chains {
+			if !c.IsEnabled() {
+				continue
 			}
-			if err := supervisor.Run(ctx, "karurawatch",
-				ethereum.NewEthWatcher(*karuraRPC, karuraContractAddr, "karura", common.ReadinessKaruraSyncing, vaa.ChainIDKarura, lockC, nil, 1, chainObsvReqC[vaa.ChainIDKarura]).Run); err != nil {
+			logger.Info("Starting watcher", zap.String("chain", c.Name), zap.Stringer("chain_id", c.ChainID))
+			watcher, err := registry.Create(c, &watchers.Deps{
+				MsgC:     lockC,
+				SetC:     setC,
+				ObsvReqC: chainObsvReqC[c.ChainID],
+				DB:       db,
+				Notifier: notifier,
+			})
+			if err != nil {
+				logger.Error("failed to create watcher", zap.String("chain", c.Name), zap.Error(err))
 				return err
 			}
-			if err := supervisor.Run(ctx, "acalawatch",
-				ethereum.NewEthWatcher(*acalaRPC, acalaContractAddr, "acala", common.ReadinessAcalaSyncing, vaa.ChainIDAcala, lockC, nil, 1, chainObsvReqC[vaa.ChainIDAcala]).Run); err != nil {
+			runnables, err := chainRunnables(c, watcher, web)
+			if err != nil {
+				logger.Error("failed to create watcher services", zap.String("chain", c.Name), zap.Error(err))
 				return err
 			}
-		}
-
-		// Start Terra watcher only if configured
-		logger.Info("Starting Terra watcher")
-		if err := supervisor.Run(ctx, "terrawatch",
-			terra.NewWatcher(*terraWS, *terraLCD, *terraContract, lockC, setC, chainObsvReqC[vaa.ChainIDTerra]).Run); err != nil {
-			return err
-		}
-
-		if *unsafeDevMode {
-			/*
-				if err := supervisor.Run(ctx, "algorandwatch",
-					algorand.NewWatcher(*algorandRPC, *algorandToken, *algorandContract, lockC, setC).Run); err != nil {
+			for _, r := range runnables {
+				if err := supervisor.Run(ctx, r.name, r.runnable); err != nil {
 					return err
 				}
-			*/
-
-			alphWatcher, err := alephium.NewAlephiumWatcher(
-				*alphRPC, *alphApiKey, *alphGroupIndex, *alphGroupIndex, *alphContractIds,
-				common.ReadinessAlephiumSyncing, lockC, uint64(*alphMinConfirmations), chainObsvReqC[vaa.ChainIDAlephium], alphDb,
-			)
-			if err != nil {
-				logger.Error("failed to create alephium watcher", zap.Error(err))
-				return err
 			}
+			liveness.Add(watcher, c.StallThreshold)
+		}
 
-			contractServer, contractGrpcServer, err := alphWatcher.ContractServer(logger, *alphContractServerRPC)
-			if err != nil {
-				logger.Error("failed to create alephium contract server", zap.Error(err))
-				return err
-			}
-			contractWebServer, err := publicwebServiceRunnable(logger, *alphContractWebServer, *alphContractServerRPC, contractGrpcServer,
-				*tlsHostname, *tlsProdEnv, path.Join(*dataDir, "autocert"), alephiumv1.RegisterContractServiceHandler)
-			if err != nil {
-				log.Fatal("failed to create alephium contract web server socket", zap.Error(err))
-			}
-			if err := supervisor.Run(ctx, "alph-contract-server", contractServer); err != nil {
-				logger.Error("failed to run alephium contract server", zap.Error(err))
-				return err
-			}
-			if err := supervisor.Run(ctx, "alph-contract-web-server", contractWebServer); err != nil {
-				logger.Error("failed to run alephium contract web server")
-				return err
-			}
-			if err := supervisor.Run(ctx, "alph-watcher", alphWatcher.Run); err != nil {
-				logger.Error("failed to run alephium watcher", zap.Error((err)))
-				return err
-			}
+		if err := supervisor.Run(ctx, "watcherliveness", liveness.Run); err != nil {
+			return err
 		}
 
-		/*
-			if err := supervisor.Run(ctx, "solwatch-confirmed",
-				solana.NewSolanaWatcher(*solanaWsRPC, *solanaRPC, solAddress, lockC, nil, rpc.CommitmentConfirmed).Run); err != nil {
+		if alertDispatcher != nil {
+			if err := supervisor.Run(ctx, "alerts", alertDispatcher.Run); err != nil {
 				return err
 			}
+		}
 
-			if err := supervisor.Run(ctx, "solwatch-finalized",
-				solana.NewSolanaWatcher(*solanaWsRPC, *solanaRPC, solAddress, lockC, chainObsvReqC[vaa.ChainIDSolana], rpc.CommitmentFinalized).Run); err != nil {
-				return err
-			}
-		*/
+		// The processor queries Ethereum and Terra directly
+		var ethRPC, terraLCD, terraContract string
+		if c := enabledChain(chains, vaa.ChainIDEthereum); c != nil {
+			ethRPC = c.PrimaryRPC()
+		}
+		if c := enabledChain(chains, vaa.ChainIDTerra); c != nil {
+			terraLCD = c.PrimaryRPC()
+			terraContract = c.PrimaryContract()
+		}
 
 		p := processor.NewProcessor(ctx,
 			db,
@@ -868,20 +800,28 @@ func runNode(cmd *cobra.Command, args []string) {
 			gst,
 			*unsafeDevMode,
 			*devNumGuardians,
-			*ethRPC,
-			*terraLCD,
-			*terraContract,
+			ethRPC,
+			terraLCD,
+			terraContract,
 			attestationEvents,
 			notifier,
+			*alertQuorumTimeout,
 		)
 		if err := supervisor.Run(ctx, "processor", p.Run); err != nil {
 			return err
 		}
 
+		if err := supervisor.Run(ctx, "db", db.Maintenance(retention, *dbMaintenanceInterval)); err != nil {
+			return err
+		}
+
 		if err := supervisor.Run(ctx, "admin", adminService); err != nil {
 			return err
 		}
 		if *publicRPC != "" {
+			if err := supervisor.Run(ctx, "vaastream", signedVAAStream.Run); err != nil {
+				return err
+			}
 			if err := supervisor.Run(ctx, "publicrpc", publicrpcService); err != nil {
 				return err
 			}
@@ -904,6 +844,20 @@ func runNode(cmd *cobra.Command, args []string) {
 				return err
 			}
 		}
+		for _, sink := range eventSinks {
+			config := reporter.DefaultSinkConfig()
+			config.QueueSize = *eventSinkQueueSize
+			config.MaxAttempts = *eventSinkMaxAttempts
+			if *eventSinkDeadLetterDir != "" {
+				config.DeadLetterPath = path.Join(*eventSinkDeadLetterDir, sink.Name()+".jsonl")
+			}
+			if *eventSinkReplay {
+				config.ReplayDB = db
+			}
+			if err := supervisor.Run(ctx, "eventsink-"+sink.Name(), reporter.RunSink(attestationEvents, sink, config)); err != nil {
+				return err
+			}
+		}
 
 		logger.Info("Started internal services")
 
@@ -913,6 +867,7 @@ func runNode(cmd *cobra.Command, args []string) {
 		// It's safer to crash and restart the process in case we encounter a panic,
 		// rather than attempting to reschedule the runnable.
 		supervisor.WithPropagatePanic)
+	healthChecker.SetSupervisor(sup)
 
 	<-rootCtx.Done()
 	logger.Info("root context cancelled, exiting...")
@@ -71,9 +71,13 @@ func publicwebServiceRunnable(
 		}
 
 		mux := http.NewServeMux()
-		grpcWebServer := grpcweb.WrapServer(grpcServer)
+		// Server streams like SubscribeSignedVAA work over plain grpc-web, websockets are for
+		// clients which can't read streaming HTTP responses. Like everywhere else, any origin is allowed.
+		grpcWebServer := grpcweb.WrapServer(grpcServer,
+			grpcweb.WithWebsockets(true),
+			grpcweb.WithWebsocketOriginFunc(func(req *http.Request) bool { return true }))
 		mux.Handle("/", allowCORSWrapper(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
-			if grpcWebServer.IsGrpcWebRequest(req) {
+			if grpcWebServer.IsGrpcWebRequest(req) || grpcWebServer.IsGrpcWebSocketRequest(req) {
 				grpcWebServer.ServeHTTP(resp, req)
 			} else {
 				gwmux.ServeHTTP(resp, req)
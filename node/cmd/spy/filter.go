@@ -0,0 +1,55 @@
+package spy
+
+import (
+	publicrpcv1 "github.com/certusone/wormhole/node/pkg/proto/publicrpc/v1"
+	"github.com/certusone/wormhole/node/pkg/proto/spy/v1"
+	"github.com/certusone/wormhole/node/pkg/publicrpc"
+)
+
+// toPublicrpcFilter converts a spy filter entry to the equivalent public RPC filter entry, so that both
+// services share the filter implementation while the spy API keeps its own messages.
+func toPublicrpcFilter(f *spyv1.FilterEntry) *publicrpcv1.FilterEntry {
+	switch t := f.Filter.(type) {
+	case *spyv1.FilterEntry_EmitterFilter:
+		return &publicrpcv1.FilterEntry{Filter: &publicrpcv1.FilterEntry_EmitterFilter{
+			EmitterFilter: &publicrpcv1.EmitterFilter{
+				ChainId:        t.EmitterFilter.ChainId,
+				EmitterAddress: t.EmitterFilter.EmitterAddress,
+			},
+		}}
+	case *spyv1.FilterEntry_EmitterChainFilter:
+		return &publicrpcv1.FilterEntry{Filter: &publicrpcv1.FilterEntry_EmitterChainFilter{
+			EmitterChainFilter: &publicrpcv1.EmitterChainFilter{ChainId: t.EmitterChainFilter.ChainId},
+		}}
+	case *spyv1.FilterEntry_PayloadTypeFilter:
+		return &publicrpcv1.FilterEntry{Filter: &publicrpcv1.FilterEntry_PayloadTypeFilter{
+			PayloadTypeFilter: &publicrpcv1.PayloadTypeFilter{
+				PayloadType: publicrpcv1.TokenBridgePayloadType(t.PayloadTypeFilter.PayloadType),
+			},
+		}}
+	case *spyv1.FilterEntry_TargetChainFilter:
+		return &publicrpcv1.FilterEntry{Filter: &publicrpcv1.FilterEntry_TargetChainFilter{
+			TargetChainFilter: &publicrpcv1.TargetChainFilter{ChainId: t.TargetChainFilter.ChainId},
+		}}
+	case *spyv1.FilterEntry_SequenceFilter:
+		return &publicrpcv1.FilterEntry{Filter: &publicrpcv1.FilterEntry_SequenceFilter{
+			SequenceFilter: &publicrpcv1.SequenceFilter{
+				ChainId:        t.SequenceFilter.ChainId,
+				EmitterAddress: t.SequenceFilter.EmitterAddress,
+				MinSequence:    t.SequenceFilter.MinSequence,
+			},
+		}}
+	default:
+		// rejected as an unsupported filter type by publicrpc.ParseFilter
+		return &publicrpcv1.FilterEntry{}
+	}
+}
+
+// parseFilters converts the filter entries of a subscription request, the errors are gRPC status errors
+func parseFilters(entries []*spyv1.FilterEntry) ([]publicrpc.Filter, error) {
+	converted := make([]*publicrpcv1.FilterEntry, len(entries))
+	for i, f := range entries {
+		converted[i] = toPublicrpcFilter(f)
+	}
+	return publicrpc.ParseFilters(converted)
+}
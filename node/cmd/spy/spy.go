@@ -2,12 +2,12 @@ package spy
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
 	"github.com/certusone/wormhole/node/pkg/common"
 	"github.com/certusone/wormhole/node/pkg/p2p"
 	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
 	"github.com/certusone/wormhole/node/pkg/proto/spy/v1"
+	"github.com/certusone/wormhole/node/pkg/publicrpc"
 	"github.com/certusone/wormhole/node/pkg/supervisor"
 	"github.com/certusone/wormhole/node/pkg/vaa"
 	"github.com/google/uuid"
@@ -18,8 +18,6 @@ import (
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 	"net"
 	"net/http"
 	"os"
@@ -77,13 +75,8 @@ type message struct {
 	vaaBytes []byte
 }
 
-type filter struct {
-	chainId     vaa.ChainID
-	emitterAddr vaa.Address
-}
-
 type subscription struct {
-	filters []filter
+	filters []publicrpc.Filter
 	ch      chan message
 }
 
@@ -91,21 +84,6 @@ func subscriptionId() string {
 	return uuid.New().String()
 }
 
-func decodeEmitterAddr(hexAddr string) (vaa.Address, error) {
-	address, err := hex.DecodeString(hexAddr)
-	if err != nil {
-		return vaa.Address{}, status.Error(codes.InvalidArgument, fmt.Sprintf("failed to decode address: %v", err))
-	}
-	if len(address) != 32 {
-		return vaa.Address{}, status.Error(codes.InvalidArgument, "address must be 32 bytes")
-	}
-
-	addr := vaa.Address{}
-	copy(addr[:], address)
-
-	return addr, nil
-}
-
 func (s *spyServer) Publish(vaaBytes []byte) error {
 	s.subsMu.Lock()
 	defer s.subsMu.Unlock()
@@ -124,10 +102,9 @@ func (s *spyServer) Publish(vaaBytes []byte) error {
 				}
 			}
 
-			for _, fi := range sub.filters {
-				if fi.chainId == v.EmitterChain && fi.emitterAddr == v.EmitterAddress {
-					sub.ch <- message{vaaBytes: vaaBytes}
-				}
+			// Filters are OR'ed, the VAA is sent once if any of them matches
+			if publicrpc.MatchesAny(sub.filters, v) {
+				sub.ch <- message{vaaBytes: vaaBytes}
 			}
 		}
 	}
@@ -136,23 +113,9 @@ func (s *spyServer) Publish(vaaBytes []byte) error {
 }
 
 func (s *spyServer) SubscribeSignedVAA(req *spyv1.SubscribeSignedVAARequest, resp spyv1.SpyRPCService_SubscribeSignedVAAServer) error {
-	var fi []filter
-	if req.Filters != nil {
-		for _, f := range req.Filters {
-			switch t := f.Filter.(type) {
-			case *spyv1.FilterEntry_EmitterFilter:
-				addr, err := decodeEmitterAddr(t.EmitterFilter.EmitterAddress)
-				if err != nil {
-					return status.Error(codes.InvalidArgument, fmt.Sprintf("failed to decode emitter address: %v", err))
-				}
-				fi = append(fi, filter{
-					chainId:     vaa.ChainID(t.EmitterFilter.ChainId),
-					emitterAddr: addr,
-				})
-			default:
-				return status.Error(codes.InvalidArgument, "unsupported filter type")
-			}
-		}
+	fi, err := parseFilters(req.Filters)
+	if err != nil {
+		return err
 	}
 
 	s.subsMu.Lock()
@@ -0,0 +1,101 @@
+package spy
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	publicrpcv1 "github.com/certusone/wormhole/node/pkg/proto/publicrpc/v1"
+	"github.com/certusone/wormhole/node/pkg/proto/spy/v1"
+	"github.com/certusone/wormhole/node/pkg/publicrpc"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testEmitter = vaa.Address{1, 2, 3}
+
+func testVAA(chain vaa.ChainID, sequence uint64) *vaa.VAA {
+	return &vaa.VAA{
+		Version:          vaa.SupportedVAAVersion,
+		Timestamp:        time.Unix(0, 0),
+		EmitterChain:     chain,
+		EmitterAddress:   testEmitter,
+		Sequence:         sequence,
+		ConsistencyLevel: 1,
+		Payload:          make([]byte, 100),
+	}
+}
+
+func TestPublishWithFilters(t *testing.T) {
+	filters, err := parseFilters([]*spyv1.FilterEntry{
+		{Filter: &spyv1.FilterEntry_EmitterChainFilter{
+			EmitterChainFilter: &spyv1.EmitterChainFilter{ChainId: publicrpcv1.ChainID_CHAIN_ID_ETHEREUM},
+		}},
+		{Filter: &spyv1.FilterEntry_EmitterFilter{
+			EmitterFilter: &spyv1.EmitterFilter{
+				ChainId:        publicrpcv1.ChainID_CHAIN_ID_ETHEREUM,
+				EmitterAddress: hex.EncodeToString(testEmitter[:]),
+			},
+		}},
+	})
+	assert.Nil(t, err)
+
+	s := newSpyServer(zap.NewNop())
+	sub := &subscription{
+		ch:      make(chan message, 2),
+		filters: filters,
+	}
+	s.subs["test"] = sub
+
+	ethBytes, err := testVAA(vaa.ChainIDEthereum, 10).Marshal()
+	assert.Nil(t, err)
+	terraBytes, err := testVAA(vaa.ChainIDTerra, 5).Marshal()
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Publish(terraBytes))
+	assert.Nil(t, s.Publish(ethBytes))
+
+	// the Ethereum VAA matches both filters, but is sent only once
+	assert.Equal(t, 1, len(sub.ch))
+	assert.Equal(t, ethBytes, (<-sub.ch).vaaBytes)
+}
+
+func TestParseFilters(t *testing.T) {
+	emitter := hex.EncodeToString(testEmitter[:])
+	transfer := make([]byte, 133)
+	transfer[0] = 1
+	binary.BigEndian.PutUint16(transfer[99:], uint16(vaa.ChainIDSolana))
+	v := testVAA(vaa.ChainIDEthereum, 10)
+	v.Payload = transfer
+
+	// every spy filter is converted to the matching public RPC filter
+	for _, f := range []*spyv1.FilterEntry{
+		{Filter: &spyv1.FilterEntry_EmitterFilter{EmitterFilter: &spyv1.EmitterFilter{
+			ChainId: publicrpcv1.ChainID_CHAIN_ID_ETHEREUM, EmitterAddress: emitter,
+		}}},
+		{Filter: &spyv1.FilterEntry_EmitterChainFilter{EmitterChainFilter: &spyv1.EmitterChainFilter{
+			ChainId: publicrpcv1.ChainID_CHAIN_ID_ETHEREUM,
+		}}},
+		{Filter: &spyv1.FilterEntry_PayloadTypeFilter{PayloadTypeFilter: &spyv1.PayloadTypeFilter{
+			PayloadType: spyv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_TRANSFER,
+		}}},
+		{Filter: &spyv1.FilterEntry_TargetChainFilter{TargetChainFilter: &spyv1.TargetChainFilter{
+			ChainId: publicrpcv1.ChainID_CHAIN_ID_SOLANA,
+		}}},
+		{Filter: &spyv1.FilterEntry_SequenceFilter{SequenceFilter: &spyv1.SequenceFilter{
+			ChainId: publicrpcv1.ChainID_CHAIN_ID_ETHEREUM, EmitterAddress: emitter, MinSequence: 10,
+		}}},
+	} {
+		filters, err := parseFilters([]*spyv1.FilterEntry{f})
+		assert.Nil(t, err, f.String())
+		assert.True(t, publicrpc.MatchesAny(filters, v), f.String())
+		assert.False(t, publicrpc.MatchesAny(filters, testVAA(vaa.ChainIDTerra, 5)), f.String())
+	}
+
+	_, err := parseFilters([]*spyv1.FilterEntry{{}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
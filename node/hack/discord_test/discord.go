@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
 	"flag"
+	"github.com/certusone/wormhole/node/pkg/notify"
 	"github.com/certusone/wormhole/node/pkg/notify/discord"
 	"github.com/certusone/wormhole/node/pkg/vaa"
 	"go.uber.org/zap"
@@ -46,13 +48,13 @@ func main() {
 		logger.Fatal("failed to initialize notifier", zap.Error(err))
 	}
 
-	if err := d.MissingSignaturesOnTransaction(v, 14, 13, true, []string{
-		"Certus One", "Not Certus One"}); err != nil {
+	if err := d.Send(context.Background(), notify.MissingSignatures(v, 14, 13, true, []string{
+		"Certus One", "Not Certus One"})); err != nil {
 		logger.Fatal("failed to send test message", zap.Error(err))
 	}
 
-	if err := d.MissingSignaturesOnTransaction(v, 14, 13, true, []string{
-		"Certus One"}); err != nil {
+	if err := d.Send(context.Background(), notify.MissingSignatures(v, 14, 13, true, []string{
+		"Certus One"})); err != nil {
 		logger.Fatal("failed to send test message", zap.Error(err))
 	}
 }
@@ -0,0 +1,421 @@
+package alephium
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Batch wraps a badger transaction. All writes of a batch are applied atomically when the batch
+// is committed, and reads from the batch observe the writes which have not been committed yet.
+type Batch struct {
+	txn      *badger.Txn
+	onCommit []func()
+
+	// the undo logs of the blocks whose writes are tracked by this batch
+	undoLogs   []*undoLog
+	currentLog *undoLog
+}
+
+func (db *Database) NewBatch() *Batch {
+	return &Batch{
+		txn: db.NewTransaction(true),
+	}
+}
+
+// OnCommit registers a callback which will be called after the batch is committed successfully,
+// it's used to update the in-memory caches only if the writes have been persisted
+func (b *Batch) OnCommit(f func()) {
+	b.onCommit = append(b.onCommit, f)
+}
+
+// trackBlock records the previous values of all keys written from now on into the undo log of
+// the block, so that the writes can be rolled back if the block leaves the main chain later
+func (b *Batch) trackBlock(header *BlockHeader) {
+	if b.currentLog != nil && b.currentLog.BlockHash == header.Hash {
+		return
+	}
+	for _, log := range b.undoLogs {
+		if log.BlockHash == header.Hash {
+			b.currentLog = log
+			return
+		}
+	}
+	b.currentLog = &undoLog{
+		BlockHash: header.Hash,
+		Height:    header.Height,
+		Entries:   make([]*undoEntry, 0),
+		Messages:  make([]uint64, 0),
+	}
+	b.undoLogs = append(b.undoLogs, b.currentLog)
+}
+
+// untrack stops recording the writes into the undo log
+func (b *Batch) untrack() {
+	b.currentLog = nil
+}
+
+func (b *Batch) recordMessage(sequence uint64) {
+	if b.currentLog != nil {
+		b.currentLog.Messages = append(b.currentLog.Messages, sequence)
+	}
+}
+
+func (b *Batch) Commit() error {
+	b.untrack()
+	for _, log := range b.undoLogs {
+		if err := b.putUndoLog(log); err != nil {
+			return err
+		}
+	}
+	if err := b.txn.Commit(); err != nil {
+		return err
+	}
+	for _, f := range b.onCommit {
+		f()
+	}
+	b.onCommit = nil
+	return nil
+}
+
+// Discard drops all pending writes, it's safe to call it after the batch has been committed
+func (b *Batch) Discard() {
+	b.txn.Discard()
+	b.onCommit = nil
+}
+
+func (db *Database) update(f func(*Batch) error) error {
+	batch := db.NewBatch()
+	defer batch.Discard()
+
+	if err := f(batch); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+func (db *Database) view(f func(*Batch) error) error {
+	return db.View(func(txn *badger.Txn) error {
+		return f(&Batch{txn: txn})
+	})
+}
+
+func (b *Batch) put(key []byte, value []byte) error {
+	if b.currentLog != nil {
+		if err := b.recordUndo(key); err != nil {
+			return err
+		}
+	}
+	return b.txn.Set(key, value)
+}
+
+func (b *Batch) delete(key []byte) error {
+	return b.txn.Delete(key)
+}
+
+func (b *Batch) recordUndo(key []byte) error {
+	for _, entry := range b.currentLog.Entries {
+		if bytes.Equal(entry.Key, key) {
+			return nil
+		}
+	}
+	value, err := b.get(key)
+	if err == badger.ErrKeyNotFound {
+		b.currentLog.Entries = append(b.currentLog.Entries, &undoEntry{Key: key, Exist: false})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	b.currentLog.Entries = append(b.currentLog.Entries, &undoEntry{Key: key, Value: value, Exist: true})
+	return nil
+}
+
+func (b *Batch) putUndoLog(log *undoLog) error {
+	if len(log.Entries) == 0 && len(log.Messages) == 0 {
+		return nil
+	}
+	// the block may already have an undo log if its events have been confirmed in different batches
+	prev, err := b.getUndoLog(log.BlockHash)
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+	if prev != nil {
+		log.Entries = append(prev.Entries, log.Entries...)
+		log.Messages = append(prev.Messages, log.Messages...)
+	}
+	value, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+	return b.txn.Set(undoLogKey(log.BlockHash), value)
+}
+
+func (b *Batch) getUndoLog(blockHash string) (*undoLog, error) {
+	value, err := b.get(undoLogKey(blockHash))
+	if err != nil {
+		return nil, err
+	}
+	var log undoLog
+	if err := json.Unmarshal(value, &log); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// rollback restores the previous values of all keys written by the block and removes the undo log
+func (b *Batch) rollback(log *undoLog) error {
+	for i := len(log.Entries) - 1; i >= 0; i-- {
+		entry := log.Entries[i]
+		if key := decodeUndoneSequenceKey(entry.Key); key != nil {
+			transition := &undoneSequenceTransition{
+				Status: sequenceRemoved,
+				Reason: fmt.Sprintf("block %s left the main chain", log.BlockHash),
+			}
+			if entry.Exist {
+				transition.Status = entry.Value[0]
+			}
+			if err := b.appendUndoneSequenceTransition(key, transition); err != nil {
+				return err
+			}
+		}
+		if entry.Exist {
+			if err := b.put(entry.Key, entry.Value); err != nil {
+				return err
+			}
+		} else if err := b.delete(entry.Key); err != nil {
+			return err
+		}
+	}
+	return b.delete(undoLogKey(log.BlockHash))
+}
+
+func (b *Batch) get(key []byte) ([]byte, error) {
+	item, err := b.txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (b *Batch) addRemoteTokenWrapper(tokenId Byte32, tokenWrapperId Byte32) error {
+	return b.put(remoteTokenWrapperKey(tokenId), tokenWrapperId[:])
+}
+
+func (b *Batch) getRemoteTokenWrapper(tokenId Byte32) (*Byte32, error) {
+	value, err := b.get(remoteTokenWrapperKey(tokenId))
+	if err != nil {
+		return nil, err
+	}
+	return toByte32(value)
+}
+
+func (b *Batch) addLocalTokenWrapper(key *LocalTokenWrapperKey, tokenWrapperId Byte32) error {
+	return b.put(key.encode(), tokenWrapperId[:])
+}
+
+func (b *Batch) getLocalTokenWrapper(tokenId Byte32, remoteChainId uint16) (*Byte32, error) {
+	key := &LocalTokenWrapperKey{
+		localTokenId:  tokenId,
+		remoteChainId: remoteChainId,
+	}
+	value, err := b.get(key.encode())
+	if err != nil {
+		return nil, err
+	}
+	return toByte32(value)
+}
+
+func (b *Batch) localTokenWrapperExist(key *LocalTokenWrapperKey) (bool, error) {
+	_, err := b.get(key.encode())
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *Batch) addRemoteChain(tokenBridgeForChainId Byte32, remoteChainId uint16) error {
+	if err := b.put(tokenBridgeForChainKey(remoteChainId), tokenBridgeForChainId[:]); err != nil {
+		return err
+	}
+	return b.put(remoteChainIdKey(tokenBridgeForChainId), Uint16ToBytes(remoteChainId))
+}
+
+func (b *Batch) getTokenBridgeForChain(chainId uint16) (*Byte32, error) {
+	value, err := b.get(tokenBridgeForChainKey(chainId))
+	if err != nil {
+		return nil, err
+	}
+	return toByte32(value)
+}
+
+func (b *Batch) getRemoteChainId(tokenBridgeForChainId Byte32) (*uint16, error) {
+	value, err := b.get(remoteChainIdKey(tokenBridgeForChainId))
+	if err != nil {
+		return nil, err
+	}
+	remoteChainId := binary.BigEndian.Uint16(value)
+	return &remoteChainId, nil
+}
+
+func (b *Batch) updateLastEventIndex(index uint64) error {
+	return b.put(lastEventIndexKey, Uint64ToBytes(index))
+}
+
+func (b *Batch) setSequenceExecuted(remoteChainId uint16, sequence uint64, reason string) error {
+	key := &UndoneSequenceKey{
+		remoteChainId: remoteChainId,
+		sequence:      sequence,
+	}
+	keyBytes := key.encode()
+	// we need to make sure the sequence exist
+	value, err := b.get(keyBytes)
+	if err != nil {
+		return err
+	}
+	if err := b.put(keyBytes, []byte{sequenceExecuted}); err != nil {
+		return err
+	}
+
+	transition := &undoneSequenceTransition{Status: sequenceExecuted, Reason: reason}
+	if bytes.Equal(value, []byte{sequenceInit}) {
+		transition.Reason += ", without a local execution"
+	}
+	// the sequence is executed by the governance VAA which has been created when it was set executing
+	history, err := b.getUndoneSequenceHistory(key)
+	if err != nil {
+		return err
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Status == sequenceExecuting {
+			transition.VaaId = history[i].VaaId
+			transition.Digest = history[i].Digest
+			break
+		}
+	}
+	return b.appendUndoneSequenceTransition(key, transition)
+}
+
+func (b *Batch) hasUndoneSequence(remoteChainId uint16, sequence uint64) (bool, error) {
+	key := &UndoneSequenceKey{
+		remoteChainId: remoteChainId,
+		sequence:      sequence,
+	}
+	_, err := b.get(key.encode())
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *Batch) addUndoneSequence(remoteChainId uint16, sequence uint64, reason string) error {
+	key := &UndoneSequenceKey{
+		remoteChainId: remoteChainId,
+		sequence:      sequence,
+	}
+	keyBytes := key.encode()
+	_, err := b.get(keyBytes)
+	if err == badger.ErrKeyNotFound {
+		if err := b.put(keyBytes, []byte{sequenceInit}); err != nil {
+			return err
+		}
+		return b.appendUndoneSequenceTransition(key, &undoneSequenceTransition{Status: sequenceInit, Reason: reason})
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("sequence %v from remote chain %v already exist", sequence, remoteChainId)
+}
+
+func (b *Batch) setSequenceExecuting(remoteChainId uint16, sequence uint64, execution *UndoneSequenceExecution) error {
+	key := &UndoneSequenceKey{
+		remoteChainId: remoteChainId,
+		sequence:      sequence,
+	}
+	keyBytes := key.encode()
+	value, err := b.get(keyBytes)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(value, []byte{sequenceInit}) {
+		return fmt.Errorf("failed to set sequence executing, status %v, sequence %d, remoteChainId %d", value, sequence, remoteChainId)
+	}
+	if err := b.allocateGovernanceSequence(execution.GovSequence, &governanceSequenceAllocation{
+		RemoteChainId: remoteChainId,
+		Sequence:      sequence,
+		Digest:        execution.Digest,
+	}); err != nil {
+		return err
+	}
+	if err := b.put(keyBytes, []byte{sequenceExecuting}); err != nil {
+		return err
+	}
+	return b.appendUndoneSequenceTransition(key, &undoneSequenceTransition{
+		Status:   sequenceExecuting,
+		VaaId:    execution.VaaId,
+		Digest:   execution.Digest,
+		Operator: execution.Operator,
+		Reason:   "governance VAA created by ExecuteUndoneSequence",
+	})
+}
+
+// allocateGovernanceSequence fails if the governance sequence has already been allocated, so that
+// two different governance VAAs with the same sequence are never created
+func (b *Batch) allocateGovernanceSequence(govSequence uint64, allocation *governanceSequenceAllocation) error {
+	key := governanceSequenceKey(govSequence)
+	value, err := b.get(key)
+	if err == nil {
+		var existing governanceSequenceAllocation
+		if err := json.Unmarshal(value, &existing); err != nil {
+			return fmt.Errorf("failed to decode governance sequence allocation, err %v", err)
+		}
+		return fmt.Errorf("governance sequence %d already allocated to sequence %d from remote chain %d, digest %s",
+			govSequence, existing.Sequence, existing.RemoteChainId, existing.Digest)
+	}
+	if err != badger.ErrKeyNotFound {
+		return err
+	}
+	value, err = json.Marshal(allocation)
+	if err != nil {
+		return err
+	}
+	return b.put(key, value)
+}
+
+func (b *Batch) getUndoneSequenceHistory(key *UndoneSequenceKey) ([]*undoneSequenceTransition, error) {
+	value, err := b.get(key.historyKey())
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var history []*undoneSequenceTransition
+	if err := json.Unmarshal(value, &history); err != nil {
+		return nil, fmt.Errorf("failed to decode undone sequence history, err %v", err)
+	}
+	return history, nil
+}
+
+// appendUndoneSequenceTransition adds the transition to the history of the undone sequence.
+// The history is not tracked by the undo logs, the rollback of a block appends new transitions instead.
+func (b *Batch) appendUndoneSequenceTransition(key *UndoneSequenceKey, transition *undoneSequenceTransition) error {
+	history, err := b.getUndoneSequenceHistory(key)
+	if err != nil {
+		return err
+	}
+	transition.Timestamp = time.Now().Unix()
+	value, err := json.Marshal(append(history, transition))
+	if err != nil {
+		return err
+	}
+	return b.txn.Set(key.historyKey(), value)
+}
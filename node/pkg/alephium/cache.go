@@ -0,0 +1,130 @@
+package alephium
+
+import (
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	blockHeaderCacheSize      = 1024
+	canonicalCacheSize        = 1024
+	shallowCanonicalCacheSize = 1024
+)
+
+var (
+	blockCacheHits = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_alephium_block_cache_hits_total",
+			Help: "Total number of Alephium block cache hits",
+		}, []string{"cache"})
+	blockCacheMisses = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_alephium_block_cache_misses_total",
+			Help: "Total number of Alephium block cache misses",
+		}, []string{"cache"})
+	blockCacheInvalidations = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_alephium_block_cache_invalidations_total",
+			Help: "Total number of Alephium block cache invalidations caused by forks",
+		})
+)
+
+// blockCache caches block headers and the canonicality of blocks.
+// Block headers never change for a given hash, so they can be cached until evicted.
+// A block can only be orphaned within MaxForkHeight blocks, so the canonicality of blocks which
+// are at least MaxForkHeight blocks below the current height is cached until evicted. The
+// canonicality of shallower blocks is only cached until the height of their chain changes, which
+// saves the repeated lookups of pending blocks between two blocks. The whole canonicality cache
+// is dropped once a fork is detected.
+type blockCache struct {
+	headers   *lru.Cache // block hash -> *BlockHeader
+	canonical *lru.Cache // block hash -> struct{}
+	shallow   *lru.Cache // block hash -> chain height at the time the block was canonical
+
+	heightLock    sync.RWMutex
+	currentHeight map[ChainIndex]uint32
+}
+
+func newBlockCache() *blockCache {
+	headers, err := lru.New(blockHeaderCacheSize)
+	assume(err == nil)
+	canonical, err := lru.New(canonicalCacheSize)
+	assume(err == nil)
+	shallow, err := lru.New(shallowCanonicalCacheSize)
+	assume(err == nil)
+	return &blockCache{
+		headers:       headers,
+		canonical:     canonical,
+		shallow:       shallow,
+		currentHeight: make(map[ChainIndex]uint32),
+	}
+}
+
+func (c *blockCache) getHeader(hash string) (*BlockHeader, bool) {
+	if value, ok := c.headers.Get(hash); ok {
+		blockCacheHits.WithLabelValues("header").Inc()
+		return value.(*BlockHeader), true
+	}
+	blockCacheMisses.WithLabelValues("header").Inc()
+	return nil, false
+}
+
+func (c *blockCache) addHeader(hash string, header *BlockHeader) {
+	c.headers.Add(hash, header)
+}
+
+// chainHeight returns the current height of the chain of the block, if both are known.
+func (c *blockCache) chainHeight(hash string) (*BlockHeader, uint32, bool) {
+	value, ok := c.headers.Peek(hash)
+	if !ok {
+		return nil, 0, false
+	}
+	header := value.(*BlockHeader)
+	c.heightLock.RLock()
+	currentHeight, ok := c.currentHeight[ChainIndex{FromGroup: header.ChainFrom, ToGroup: header.ChainTo}]
+	c.heightLock.RUnlock()
+	return header, currentHeight, ok
+}
+
+func (c *blockCache) isCanonical(hash string) bool {
+	if _, ok := c.canonical.Get(hash); ok {
+		blockCacheHits.WithLabelValues("canonical").Inc()
+		return true
+	}
+	if value, ok := c.shallow.Get(hash); ok {
+		if _, currentHeight, ok := c.chainHeight(hash); ok && currentHeight == value.(uint32) {
+			blockCacheHits.WithLabelValues("canonical").Inc()
+			return true
+		}
+		c.shallow.Remove(hash)
+	}
+	blockCacheMisses.WithLabelValues("canonical").Inc()
+	return false
+}
+
+func (c *blockCache) setCanonical(hash string) {
+	header, currentHeight, ok := c.chainHeight(hash)
+	if !ok {
+		return
+	}
+	if header.Height+MaxForkHeight <= currentHeight {
+		c.canonical.Add(hash, struct{}{})
+	} else {
+		c.shallow.Add(hash, currentHeight)
+	}
+}
+
+func (c *blockCache) updateHeight(chainIndex *ChainIndex, height uint32) {
+	c.heightLock.Lock()
+	defer c.heightLock.Unlock()
+	c.currentHeight[*chainIndex] = height
+}
+
+func (c *blockCache) invalidate() {
+	c.canonical.Purge()
+	c.shallow.Purge()
+	blockCacheInvalidations.Inc()
+}
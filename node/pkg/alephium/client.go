@@ -6,7 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
 const (
@@ -16,27 +19,73 @@ const (
 
 var ErrInvalidContract error = errors.New("invalid contract")
 
+// ErrQuorumMismatch is returned if the full nodes return different results for a quorum read
+var ErrQuorumMismatch error = errors.New("quorum mismatch")
+
 type Client struct {
-	endpoint string
-	apiKey   string
-	timeout  int // seconds
-	impl     *http.Client
+	endpoints []*endpoint
+	apiKey    string
+	timeout   int // seconds
+	impl      *http.Client
+	// the number of full nodes which must return the same result for quorum reads,
+	// the quorum read is disabled if it's less than 2
+	quorum int
+
+	cache *blockCache
+	// coalesces the concurrent requests for the same block
+	requests singleflight.Group
 }
 
 func NewClient(endpoint string, apiKey string, timeout int) *Client {
-	return &Client{
-		endpoint: endpoint,
-		apiKey:   apiKey,
-		timeout:  timeout,
-		impl:     &http.Client{},
+	client, err := NewMultiEndpointClient([]string{endpoint}, apiKey, timeout, 0)
+	assume(err == nil)
+	return client
+}
+
+// NewMultiEndpointClient creates a client which fails over between the full nodes in the order
+// of the endpoints, and cross-checks the events and the canonicality of blocks across `quorum` nodes
+func NewMultiEndpointClient(endpoints []string, apiKey string, timeout int, quorum int) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no alephium endpoint specified")
 	}
+	if quorum > len(endpoints) {
+		return nil, fmt.Errorf("quorum %d is larger than the number of endpoints %d", quorum, len(endpoints))
+	}
+	client := &Client{
+		endpoints: make([]*endpoint, len(endpoints)),
+		apiKey:    apiKey,
+		timeout:   timeout,
+		impl:      &http.Client{},
+		quorum:    quorum,
+		cache:     newBlockCache(),
+	}
+	for i, url := range endpoints {
+		client.endpoints[i] = newEndpoint(url)
+	}
+	return client, nil
 }
 
-func (c *Client) get(ctx context.Context, path string, result interface{}) error {
+// orderedEndpoints returns the healthy endpoints first, the unhealthy endpoints are
+// only used as a last resort
+func (c *Client) orderedEndpoints() []*endpoint {
+	now := time.Now()
+	healthy := make([]*endpoint, 0, len(c.endpoints))
+	unhealthy := make([]*endpoint, 0)
+	for _, e := range c.endpoints {
+		if e.isHealthy(now) {
+			healthy = append(healthy, e)
+		} else {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (c *Client) request(ctx context.Context, e *endpoint, path string, result interface{}) error {
 	timeout, cancel := context.WithTimeout(ctx, time.Duration(c.timeout)*time.Second)
 	defer cancel()
 
-	url := c.endpoint + path
+	url := e.url + path
 	request, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return err
@@ -50,15 +99,70 @@ func (c *Client) get(ctx context.Context, path string, result interface{}) error
 
 	response, err := c.impl.Do(request)
 	if err != nil {
+		e.onFailure(time.Now())
 		return err
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
+		// the client errors are caused by the request rather than the full node
+		if response.StatusCode >= http.StatusInternalServerError {
+			e.onFailure(time.Now())
+		}
 		return fmt.Errorf("request error, url: %s, status code: %d", url, response.StatusCode)
 	}
 
-	return json.NewDecoder(response.Body).Decode(result)
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		e.onFailure(time.Now())
+		return err
+	}
+	e.onSuccess()
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, path string, result interface{}) error {
+	var lastErr error
+	for _, e := range c.orderedEndpoints() {
+		lastErr = c.request(ctx, e, path, result)
+		if lastErr == nil || ctx.Err() != nil {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+// getWithQuorum returns the result only if `quorum` full nodes return the same result
+func (c *Client) getWithQuorum(ctx context.Context, path string, result interface{}) error {
+	if c.quorum < 2 {
+		return c.get(ctx, path, result)
+	}
+
+	resultType := reflect.TypeOf(result).Elem()
+	var agreed interface{}
+	count := 0
+	var lastErr error
+	for _, e := range c.orderedEndpoints() {
+		value := reflect.New(resultType).Interface()
+		if err := c.request(ctx, e, path, value); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			lastErr = err
+			continue
+		}
+		if agreed == nil {
+			agreed = value
+		} else if !reflect.DeepEqual(agreed, value) {
+			quorumMismatches.Inc()
+			return fmt.Errorf("%w, url: %s, endpoint: %s", ErrQuorumMismatch, path, e.url)
+		}
+		count += 1
+		if count == c.quorum {
+			reflect.ValueOf(result).Elem().Set(reflect.ValueOf(agreed).Elem())
+			return nil
+		}
+	}
+	return fmt.Errorf("not enough responses for quorum read, url: %s, have %d, expect %d, err %v", path, count, c.quorum, lastErr)
 }
 
 func (c *Client) GetCurrentHeight(ctx context.Context, chainIndex *ChainIndex) (uint32, error) {
@@ -68,6 +172,9 @@ func (c *Client) GetCurrentHeight(ctx context.Context, chainIndex *ChainIndex) (
 	}{}
 
 	err := c.get(ctx, path, &result)
+	if err == nil {
+		c.cache.updateHeight(chainIndex, result.CurrentHeight)
+	}
 	return result.CurrentHeight, err
 }
 
@@ -92,23 +199,63 @@ func (c *Client) GetHashByHeight(ctx context.Context, chainIndex *ChainIndex, he
 }
 
 func (c *Client) GetBlockHeader(ctx context.Context, hash string) (*BlockHeader, error) {
+	if header, ok := c.cache.getHeader(hash); ok {
+		return header, nil
+	}
+
 	path := fmt.Sprintf("/blockflow/blocks/%s", hash)
-	var header BlockHeader
-	err := c.get(ctx, path, &header)
-	return &header, err
+	result, err, _ := c.requests.Do(path, func() (interface{}, error) {
+		var header BlockHeader
+		if err := c.getWithQuorum(ctx, path, &header); err != nil {
+			return nil, err
+		}
+		c.cache.addHeader(hash, &header)
+		return &header, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*BlockHeader), nil
 }
 
 func (c *Client) IsBlockInMainChain(ctx context.Context, hash string) (bool, error) {
-	path := fmt.Sprintf("/blockflow/is-block-in-main-chain?blockHash=%s", hash)
+	if c.cache.isCanonical(hash) {
+		return true, nil
+	}
+
+	result, err, _ := c.requests.Do(isBlockInMainChainURI(hash), func() (interface{}, error) {
+		isCanonical, err := c.isBlockInMainChain(ctx, hash)
+		if err != nil {
+			return false, err
+		}
+		if isCanonical {
+			c.cache.setCanonical(hash)
+		}
+		return isCanonical, nil
+	})
+	return result.(bool), err
+}
+
+func isBlockInMainChainURI(hash string) string {
+	return fmt.Sprintf("/blockflow/is-block-in-main-chain?blockHash=%s", hash)
+}
+
+// isBlockInMainChain always queries the full node, it's used to detect the reorgs of the cached blocks
+func (c *Client) isBlockInMainChain(ctx context.Context, hash string) (bool, error) {
 	var result bool
-	err := c.get(ctx, path, &result)
+	err := c.getWithQuorum(ctx, isBlockInMainChainURI(hash), &result)
 	return result, err
 }
 
+// InvalidateCache drops all cached canonicality of blocks, it should be called once a fork is detected
+func (c *Client) InvalidateCache() {
+	c.cache.invalidate()
+}
+
 func (c *Client) GetContractEventsByRange(ctx context.Context, contractAddress string, from, to uint64) (*Events, error) {
 	var result Events
 	path := fmt.Sprintf("/events/contract?start=%d&end=%d&contractAddress=%s", from, to, contractAddress)
-	err := c.get(ctx, path, &result)
+	err := c.getWithQuorum(ctx, path, &result)
 	return &result, err
 }
 
@@ -0,0 +1,250 @@
+package alephium
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockHeaderCache(t *testing.T) {
+	header := &BlockHeader{
+		Hash:   randomByte32().ToHex(),
+		Height: 10,
+	}
+	requestCount := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.RequestURI, "/blockflow/blocks") {
+			atomic.AddInt32(&requestCount, 1)
+			// make sure the concurrent requests overlap
+			time.Sleep(200 * time.Millisecond)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(header)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "", 10)
+	hits := testutil.ToFloat64(blockCacheHits.WithLabelValues("header"))
+	misses := testutil.ToFloat64(blockCacheMisses.WithLabelValues("header"))
+
+	concurrency := 8
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := client.GetBlockHeader(context.Background(), header.Hash)
+			assert.Nil(t, err)
+			assert.Equal(t, *result, *header)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, atomic.LoadInt32(&requestCount), int32(1))
+	assert.Equal(t, testutil.ToFloat64(blockCacheMisses.WithLabelValues("header"))-misses, float64(concurrency))
+
+	result, err := client.GetBlockHeader(context.Background(), header.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, *result, *header)
+	assert.Equal(t, atomic.LoadInt32(&requestCount), int32(1))
+	assert.Equal(t, testutil.ToFloat64(blockCacheHits.WithLabelValues("header"))-hits, float64(1))
+}
+
+func TestCanonicalCache(t *testing.T) {
+	chainIndex := &ChainIndex{FromGroup: 0, ToGroup: 0}
+	currentHeight := MaxForkHeight + 20
+	deepBlock := &BlockHeader{Hash: randomByte32().ToHex(), Height: 10}
+	shallowBlock := &BlockHeader{Hash: randomByte32().ToHex(), Height: 30}
+	requests := make(map[string]int)
+	var lock sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.RequestURI, "/blockflow/chain-info") {
+			json.NewEncoder(w).Encode(map[string]uint32{"currentHeight": currentHeight})
+			return
+		}
+		if strings.HasPrefix(r.RequestURI, "/blockflow/blocks") {
+			parts := strings.Split(r.URL.Path, "/")
+			for _, header := range []*BlockHeader{deepBlock, shallowBlock} {
+				if header.Hash == parts[3] {
+					json.NewEncoder(w).Encode(header)
+					return
+				}
+			}
+		}
+		if strings.HasPrefix(r.RequestURI, "/blockflow/is-block-in-main-chain") {
+			lock.Lock()
+			requests[r.URL.Query()["blockHash"][0]] += 1
+			lock.Unlock()
+			json.NewEncoder(w).Encode(true)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(server.URL, "", 10)
+	height, err := client.GetCurrentHeight(ctx, chainIndex)
+	assert.Nil(t, err)
+	assert.Equal(t, height, currentHeight)
+
+	isCanonical := func(hash string) {
+		result, err := client.IsBlockInMainChain(ctx, hash)
+		assert.Nil(t, err)
+		assert.True(t, result)
+	}
+
+	for _, header := range []*BlockHeader{deepBlock, shallowBlock} {
+		_, err := client.GetBlockHeader(ctx, header.Hash)
+		assert.Nil(t, err)
+		isCanonical(header.Hash)
+		isCanonical(header.Hash)
+	}
+	assert.Equal(t, requests[deepBlock.Hash], 1)
+	assert.Equal(t, requests[shallowBlock.Hash], 1)
+
+	// the canonicality of blocks above MaxForkHeight is only cached until the height changes
+	currentHeight += 1
+	_, err = client.GetCurrentHeight(ctx, chainIndex)
+	assert.Nil(t, err)
+	isCanonical(deepBlock.Hash)
+	isCanonical(shallowBlock.Hash)
+	isCanonical(shallowBlock.Hash)
+	assert.Equal(t, requests[deepBlock.Hash], 1)
+	assert.Equal(t, requests[shallowBlock.Hash], 2)
+
+	client.InvalidateCache()
+	isCanonical(deepBlock.Hash)
+	isCanonical(deepBlock.Hash)
+	assert.Equal(t, requests[deepBlock.Hash], 2)
+}
+
+func TestClientFailover(t *testing.T) {
+	var primaryRequests, backupRequests int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryRequests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&backupRequests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(10)
+	}))
+	defer backup.Close()
+
+	client, err := NewMultiEndpointClient([]string{primary.URL, backup.URL}, "", 10, 0)
+	assert.Nil(t, err)
+	count, err := client.GetContractEventsCount(context.Background(), randomAddress())
+	assert.Nil(t, err)
+	assert.Equal(t, *count, uint64(10))
+	assert.Equal(t, atomic.LoadInt32(&primaryRequests), int32(1))
+	assert.Equal(t, atomic.LoadInt32(&backupRequests), int32(1))
+
+	// the unhealthy primary node is skipped during the backoff period
+	_, err = client.GetContractEventsCount(context.Background(), randomAddress())
+	assert.Nil(t, err)
+	assert.Equal(t, atomic.LoadInt32(&primaryRequests), int32(1))
+	assert.Equal(t, atomic.LoadInt32(&backupRequests), int32(2))
+	assert.Equal(t, testutil.ToFloat64(endpointHealthy.WithLabelValues(primary.URL)), float64(0))
+	assert.Equal(t, testutil.ToFloat64(endpointHealthy.WithLabelValues(backup.URL)), float64(1))
+
+	// the unhealthy node is retried after the backoff period
+	client.endpoints[0].retryAt = time.Now().Add(-time.Second)
+	_, err = client.GetContractEventsCount(context.Background(), randomAddress())
+	assert.Nil(t, err)
+	assert.Equal(t, atomic.LoadInt32(&primaryRequests), int32(2))
+	assert.Equal(t, client.endpoints[0].failures, uint(2))
+
+	_, err = NewMultiEndpointClient([]string{primary.URL}, "", 10, 2)
+	assert.NotNil(t, err)
+}
+
+func TestClientQuorumRead(t *testing.T) {
+	contractAddress := randomAddress()
+	newEvent := func() *Event {
+		return &Event{
+			BlockHash:       randomByte32().ToHex(),
+			ContractAddress: contractAddress,
+			TxId:            randomByte32().ToHex(),
+			EventIndex:      0,
+			Fields:          []*Field{{Type: "U256", Value: "1"}},
+		}
+	}
+	event := newEvent()
+	newServer := func(events func() []*Event) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&Events{Events: events()})
+		}))
+	}
+	honest0 := newServer(func() []*Event { return []*Event{event} })
+	defer honest0.Close()
+	honest1 := newServer(func() []*Event { return []*Event{event} })
+	defer honest1.Close()
+	malicious := newServer(func() []*Event { return []*Event{newEvent()} })
+	defer malicious.Close()
+
+	client, err := NewMultiEndpointClient([]string{honest0.URL, honest1.URL}, "", 10, 2)
+	assert.Nil(t, err)
+	events, err := client.GetContractEventsByRange(context.Background(), contractAddress, 0, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, len(events.Events), 1)
+	assert.Equal(t, *events.Events[0], *event)
+
+	mismatches := testutil.ToFloat64(quorumMismatches)
+	client, err = NewMultiEndpointClient([]string{honest0.URL, malicious.URL}, "", 10, 2)
+	assert.Nil(t, err)
+	_, err = client.GetContractEventsByRange(context.Background(), contractAddress, 0, 1)
+	assert.True(t, errors.Is(err, ErrQuorumMismatch))
+	assert.Equal(t, testutil.ToFloat64(quorumMismatches)-mismatches, float64(1))
+
+	// not enough nodes are available
+	malicious.Close()
+	_, err = client.GetContractEventsByRange(context.Background(), contractAddress, 0, 1)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrQuorumMismatch))
+}
+
+func TestClientQuorumBlockHeader(t *testing.T) {
+	header := &BlockHeader{Hash: randomByte32().ToHex(), Height: 10}
+	newServer := func(height uint32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&BlockHeader{Hash: header.Hash, Height: height})
+		}))
+	}
+	honest := newServer(header.Height)
+	defer honest.Close()
+	malicious := newServer(header.Height + 1)
+	defer malicious.Close()
+
+	client, err := NewMultiEndpointClient([]string{honest.URL, malicious.URL}, "", 10, 2)
+	assert.Nil(t, err)
+	_, err = client.GetBlockHeader(context.Background(), header.Hash)
+	assert.True(t, errors.Is(err, ErrQuorumMismatch))
+	// the header is not cached if the full nodes disagree
+	_, ok := client.cache.getHeader(header.Hash)
+	assert.False(t, ok)
+
+	other := newServer(header.Height)
+	defer other.Close()
+	client, err = NewMultiEndpointClient([]string{honest.URL, other.URL}, "", 10, 2)
+	assert.Nil(t, err)
+	result, err := client.GetBlockHeader(context.Background(), header.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, *result, *header)
+}
@@ -3,6 +3,7 @@ package alephium
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 
 	nodev1 "github.com/certusone/wormhole/node/pkg/proto/node/v1"
@@ -19,14 +20,21 @@ var (
 	tokenBridgeForChainPrefix = []byte("token-bridge-for-chain")
 	remoteChainIdPrefix       = []byte("remote-chain-id")
 	undoneSequencePrefix      = []byte("undone-sequence")
+	undoneHistoryPrefix       = []byte("undone-history")
+	governanceSequencePrefix  = []byte("governance-sequence")
+	pendingEventsPrefix       = []byte("pending-events")
+	undoLogPrefix             = []byte("undo-log")
 
 	lastEventIndexKey = []byte("last-event-index")
+	nextEventIndexKey = []byte("next-event-index")
 )
 
 const (
 	sequenceInit      byte = 1
 	sequenceExecuting byte = 2
 	sequenceExecuted  byte = 3
+	// only used in the undone sequence history, the sequence has no status after it's removed
+	sequenceRemoved byte = 4
 )
 
 func toProtoStatus(status byte) nodev1.UndoneSequenceStatus {
@@ -37,6 +45,8 @@ func toProtoStatus(status byte) nodev1.UndoneSequenceStatus {
 		return nodev1.UndoneSequenceStatus_UNDONE_SEQUENCE_STATUS_EXECUTING
 	case sequenceExecuted:
 		return nodev1.UndoneSequenceStatus_UNDONE_SEQUENCE_STATUS_EXECUTED
+	case sequenceRemoved:
+		return nodev1.UndoneSequenceStatus_UNDONE_SEQUENCE_STATUS_REMOVED
 	}
 	panic("invalid undone sequence status")
 }
@@ -104,10 +114,6 @@ func (db *Database) GetUndoneSequences(remoteChainId uint16) ([]*nodev1.UndoneSe
 	return sequences, err
 }
 
-func (db *Database) addRemoteTokenWrapper(tokenId Byte32, tokenWrapperId Byte32) error {
-	return db.put(remoteTokenWrapperKey(tokenId), tokenWrapperId[:])
-}
-
 func toByte32(data []byte) (*Byte32, error) {
 	if len(data) != 32 {
 		return nil, fmt.Errorf("invalid bytes size, expect 32, have %d", len(data))
@@ -117,16 +123,24 @@ func toByte32(data []byte) (*Byte32, error) {
 	return &byte32, nil
 }
 
-func (db *Database) GetRemoteTokenWrapper(tokenId Byte32) (*Byte32, error) {
-	value, err := db.get(remoteTokenWrapperKey(tokenId))
-	if err != nil {
-		return nil, err
-	}
-	return toByte32(value)
+func (db *Database) addRemoteTokenWrapper(tokenId Byte32, tokenWrapperId Byte32) error {
+	return db.update(func(batch *Batch) error {
+		return batch.addRemoteTokenWrapper(tokenId, tokenWrapperId)
+	})
+}
+
+func (db *Database) GetRemoteTokenWrapper(tokenId Byte32) (tokenWrapperId *Byte32, err error) {
+	err = db.view(func(batch *Batch) error {
+		tokenWrapperId, err = batch.getRemoteTokenWrapper(tokenId)
+		return err
+	})
+	return
 }
 
 func (db *Database) addLocalTokenWrapper(key *LocalTokenWrapperKey, tokenWrapperId Byte32) error {
-	return db.put(key.encode(), tokenWrapperId[:])
+	return db.update(func(batch *Batch) error {
+		return batch.addLocalTokenWrapper(key, tokenWrapperId)
+	})
 }
 
 func (db *Database) AddLocalTokenWrapper(tokenId Byte32, remoteChainId uint16, tokenWrapperId Byte32) error {
@@ -137,50 +151,40 @@ func (db *Database) AddLocalTokenWrapper(tokenId Byte32, remoteChainId uint16, t
 	return db.addLocalTokenWrapper(key, tokenWrapperId)
 }
 
-func (db *Database) GetLocalTokenWrapper(tokenId Byte32, remoteChainId uint16) (*Byte32, error) {
-	key := &LocalTokenWrapperKey{
-		localTokenId:  tokenId,
-		remoteChainId: remoteChainId,
-	}
-	value, err := db.get(key.encode())
-	if err != nil {
-		return nil, err
-	}
-	return toByte32(value)
+func (db *Database) GetLocalTokenWrapper(tokenId Byte32, remoteChainId uint16) (tokenWrapperId *Byte32, err error) {
+	err = db.view(func(batch *Batch) error {
+		tokenWrapperId, err = batch.getLocalTokenWrapper(tokenId, remoteChainId)
+		return err
+	})
+	return
 }
 
 func (db *Database) addRemoteChain(tokenBridgeForChainId Byte32, remoteChainId uint16) error {
-	batch := db.NewWriteBatch()
-	defer batch.Cancel()
-
-	if err := batch.Set(tokenBridgeForChainKey(remoteChainId), tokenBridgeForChainId[:]); err != nil {
-		return err
-	}
-	if err := batch.Set(remoteChainIdKey(tokenBridgeForChainId), Uint16ToBytes(remoteChainId)); err != nil {
-		return err
-	}
-	return batch.Flush()
+	return db.update(func(batch *Batch) error {
+		return batch.addRemoteChain(tokenBridgeForChainId, remoteChainId)
+	})
 }
 
-func (db *Database) getTokenBridgeForChain(chainId uint16) (*Byte32, error) {
-	value, err := db.get(tokenBridgeForChainKey(chainId))
-	if err != nil {
-		return nil, err
-	}
-	return toByte32(value)
+func (db *Database) getTokenBridgeForChain(chainId uint16) (contractId *Byte32, err error) {
+	err = db.view(func(batch *Batch) error {
+		contractId, err = batch.getTokenBridgeForChain(chainId)
+		return err
+	})
+	return
 }
 
-func (db *Database) getRemoteChainId(tokenBridgeForChainId Byte32) (*uint16, error) {
-	value, err := db.get(remoteChainIdKey(tokenBridgeForChainId))
-	if err != nil {
-		return nil, err
-	}
-	remoteChainId := binary.BigEndian.Uint16(value)
-	return &remoteChainId, nil
+func (db *Database) getRemoteChainId(tokenBridgeForChainId Byte32) (remoteChainId *uint16, err error) {
+	err = db.view(func(batch *Batch) error {
+		remoteChainId, err = batch.getRemoteChainId(tokenBridgeForChainId)
+		return err
+	})
+	return
 }
 
 func (db *Database) updateLastEventIndex(index uint64) error {
-	return db.put(lastEventIndexKey, Uint64ToBytes(index))
+	return db.update(func(batch *Batch) error {
+		return batch.updateLastEventIndex(index)
+	})
 }
 
 func (db *Database) getLastEventIndex() (*uint64, error) {
@@ -192,52 +196,243 @@ func (db *Database) getLastEventIndex() (*uint64, error) {
 	return &index, nil
 }
 
-// TODO: store the vaa id
-func (db *Database) SetSequenceExecuting(remoteChainId uint16, sequence uint64) error {
-	key := &UndoneSequenceKey{
-		remoteChainId: remoteChainId,
-		sequence:      sequence,
-	}
-	keyBytes := key.encode()
-	value, err := db.get(keyBytes)
-	if err != nil {
-		return err
-	}
+type pendingEvent struct {
+	BlockHeader   *BlockHeader `json:"blockHeader"`
+	Event         *Event       `json:"event"`
+	Confirmations uint8        `json:"confirmations"`
+}
 
-	if !bytes.Equal(value, []byte{sequenceInit}) {
-		return fmt.Errorf("failed to set sequence executing, status %v, sequence %d, remoteChainId %d", value, sequence, remoteChainId)
-	}
-	return db.put(keyBytes, []byte{sequenceExecuting})
+type pendingBlockEvents struct {
+	EventIndex uint64          `json:"eventIndex"`
+	Events     []*pendingEvent `json:"events"`
 }
 
-func (db *Database) setSequenceExecuted(remoteChainId uint16, sequence uint64) error {
-	key := &UndoneSequenceKey{
-		remoteChainId: remoteChainId,
-		sequence:      sequence,
+func encodePendingEvents(events *UnconfirmedEvents) ([]byte, error) {
+	pending := &pendingBlockEvents{
+		EventIndex: events.eventIndex,
+		Events:     make([]*pendingEvent, len(events.events)),
 	}
-	keyBytes := key.encode()
-	// we need to make sure the sequence exist
-	_, err := db.get(keyBytes)
-	if err != nil {
-		return err
+	for i, e := range events.events {
+		pending.Events[i] = &pendingEvent{
+			BlockHeader:   e.blockHeader,
+			Event:         e.event,
+			Confirmations: e.confirmations,
+		}
 	}
-	return db.put(keyBytes, []byte{sequenceExecuted})
+	return json.Marshal(pending)
 }
 
-func (db *Database) addUndoneSequence(remoteChainId uint16, sequence uint64) error {
-	key := &UndoneSequenceKey{
-		remoteChainId: remoteChainId,
-		sequence:      sequence,
+func decodePendingEvents(data []byte) (*UnconfirmedEvents, error) {
+	var pending pendingBlockEvents
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, err
 	}
-	keyBytes := key.encode()
-	_, err := db.get(keyBytes)
-	if err == badger.ErrKeyNotFound {
-		return db.put(key.encode(), []byte{sequenceInit})
+	events := &UnconfirmedEvents{
+		eventIndex: pending.EventIndex,
+		events:     make([]*UnconfirmedEvent, len(pending.Events)),
 	}
+	for i, e := range pending.Events {
+		if e.BlockHeader == nil || e.Event == nil {
+			return nil, fmt.Errorf("invalid pending event, index %d", pending.EventIndex)
+		}
+		events.events[i] = &UnconfirmedEvent{
+			blockHeader:   e.BlockHeader,
+			event:         e.Event,
+			confirmations: e.Confirmations,
+		}
+	}
+	return events, nil
+}
+
+// updatePendingEvents persists the pending events of the updated blocks, removes the pending
+// events of the removed blocks and saves the next event index, all in a single transaction
+func (db *Database) updatePendingEvents(nextIndex *uint64, updated map[string]*UnconfirmedEvents, removed []string) error {
+	return db.Update(func(txn *badger.Txn) error {
+		for blockHash, events := range updated {
+			value, err := encodePendingEvents(events)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(pendingEventsKey(blockHash), value); err != nil {
+				return err
+			}
+		}
+		for _, blockHash := range removed {
+			if err := txn.Delete(pendingEventsKey(blockHash)); err != nil {
+				return err
+			}
+		}
+		if nextIndex != nil {
+			return txn.Set(nextEventIndexKey, Uint64ToBytes(*nextIndex))
+		}
+		return nil
+	})
+}
+
+func (db *Database) getPendingEvents() (map[string]*UnconfirmedEvents, error) {
+	pendingEvents := make(map[string]*UnconfirmedEvents)
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(pendingEventsPrefix); it.ValidForPrefix(pendingEventsPrefix); it.Next() {
+			item := it.Item()
+			blockHash := string(item.Key()[len(pendingEventsPrefix):])
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			events, err := decodePendingEvents(value)
+			if err != nil {
+				return fmt.Errorf("failed to decode pending events, block hash %s, err %v", blockHash, err)
+			}
+			pendingEvents[blockHash] = events
+		}
+		return nil
+	})
+	return pendingEvents, err
+}
+
+func (db *Database) getNextEventIndex() (*uint64, error) {
+	value, err := db.get(nextEventIndexKey)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	index := binary.BigEndian.Uint64(value)
+	return &index, nil
+}
+
+type undoEntry struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+	// the key will be deleted when rollback if it does not exist before
+	Exist bool `json:"exist"`
+}
+
+// undoLog records the state changes applied by the confirmed events of a block
+type undoLog struct {
+	BlockHash string       `json:"blockHash"`
+	Height    uint32       `json:"height"`
+	Entries   []*undoEntry `json:"entries"`
+	// the sequences of the published wormhole messages, they can't be rolled back
+	Messages []uint64 `json:"messages"`
+}
+
+func (db *Database) getUndoLogs() ([]*undoLog, error) {
+	logs := make([]*undoLog, 0)
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(undoLogPrefix); it.ValidForPrefix(undoLogPrefix); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var log undoLog
+			if err := json.Unmarshal(value, &log); err != nil {
+				return fmt.Errorf("failed to decode undo log, key %s, err %v", string(it.Item().Key()), err)
+			}
+			logs = append(logs, &log)
+		}
+		return nil
+	})
+	return logs, err
+}
+
+func (db *Database) removeUndoLogs(blockHashes []string) error {
+	return db.Update(func(txn *badger.Txn) error {
+		for _, blockHash := range blockHashes {
+			if err := txn.Delete(undoLogKey(blockHash)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UndoneSequenceExecution identifies the governance VAA which executes an undone sequence, and who requested it
+type UndoneSequenceExecution struct {
+	GovSequence uint64
+	VaaId       string
+	Digest      string
+	Operator    string
+}
+
+// SetSequenceExecuting sets the undone sequence executing and allocates the governance sequence of the execution
+// to it. It fails if the governance sequence has been allocated to another undone sequence.
+func (db *Database) SetSequenceExecuting(remoteChainId uint16, sequence uint64, execution *UndoneSequenceExecution) error {
+	return db.update(func(batch *Batch) error {
+		return batch.setSequenceExecuting(remoteChainId, sequence, execution)
+	})
+}
+
+// CheckSequenceExecuting returns the error SetSequenceExecuting would return, without changing anything
+func (db *Database) CheckSequenceExecuting(remoteChainId uint16, sequence uint64, execution *UndoneSequenceExecution) error {
+	batch := db.NewBatch()
+	defer batch.Discard()
+	return batch.setSequenceExecuting(remoteChainId, sequence, execution)
+}
+
+func (db *Database) setSequenceExecuted(remoteChainId uint16, sequence uint64, reason string) error {
+	return db.update(func(batch *Batch) error {
+		return batch.setSequenceExecuted(remoteChainId, sequence, reason)
+	})
+}
+
+func (db *Database) addUndoneSequence(remoteChainId uint16, sequence uint64, reason string) error {
+	return db.update(func(batch *Batch) error {
+		return batch.addUndoneSequence(remoteChainId, sequence, reason)
+	})
+}
+
+// undoneSequenceTransition is an entry of the history of an undone sequence
+type undoneSequenceTransition struct {
+	Status    byte   `json:"status"`
+	Timestamp int64  `json:"timestamp"`
+	VaaId     string `json:"vaaId,omitempty"`
+	Digest    string `json:"digest,omitempty"`
+	Operator  string `json:"operator,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+func (t *undoneSequenceTransition) toProto() *nodev1.UndoneSequenceTransition {
+	return &nodev1.UndoneSequenceTransition{
+		Status:    toProtoStatus(t.Status),
+		Timestamp: t.Timestamp,
+		VaaId:     t.VaaId,
+		Digest:    t.Digest,
+		Operator:  t.Operator,
+		Reason:    t.Reason,
 	}
-	return fmt.Errorf("sequence %v from remote chain %v already exist", sequence, remoteChainId)
+}
+
+// GetUndoneSequenceHistory returns the state transitions of all undone sequences of the remote chain, including
+// the sequences which have been removed
+func (db *Database) GetUndoneSequenceHistory(remoteChainId uint16) (map[uint64][]*nodev1.UndoneSequenceTransition, error) {
+	histories := make(map[uint64][]*nodev1.UndoneSequenceTransition)
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := append(undoneHistoryPrefix, Uint16ToBytes(remoteChainId)...)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			sequence := binary.BigEndian.Uint64(item.Key()[len(prefix):])
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var history []*undoneSequenceTransition
+			if err := json.Unmarshal(value, &history); err != nil {
+				return fmt.Errorf("failed to decode undone sequence history, sequence %d, err %v", sequence, err)
+			}
+			transitions := make([]*nodev1.UndoneSequenceTransition, len(history))
+			for i, t := range history {
+				transitions[i] = t.toProto()
+			}
+			histories[sequence] = transitions
+		}
+		return nil
+	})
+	return histories, err
 }
 
 func (db *Database) getUndoneSequence(remoteChainId uint16, sequence uint64) ([]byte, error) {
@@ -248,12 +443,20 @@ func (db *Database) getUndoneSequence(remoteChainId uint16, sequence uint64) ([]
 	return db.get(key.encode())
 }
 
-func (db *Database) localTokenWrapperExist(key *LocalTokenWrapperKey) (bool, error) {
-	_, err := db.get(key.encode())
-	if err == badger.ErrKeyNotFound {
-		return false, nil
-	}
-	return err == nil, err
+func (db *Database) localTokenWrapperExist(key *LocalTokenWrapperKey) (exist bool, err error) {
+	err = db.view(func(batch *Batch) error {
+		exist, err = batch.localTokenWrapperExist(key)
+		return err
+	})
+	return
+}
+
+func pendingEventsKey(blockHash string) []byte {
+	return append(pendingEventsPrefix, []byte(blockHash)...)
+}
+
+func undoLogKey(blockHash string) []byte {
+	return append(undoLogPrefix, []byte(blockHash)...)
 }
 
 func remoteTokenWrapperKey(tokenId Byte32) []byte {
@@ -285,9 +488,40 @@ type UndoneSequenceKey struct {
 	sequence      uint64
 }
 
-func (k *UndoneSequenceKey) encode() []byte {
+func (k *UndoneSequenceKey) suffix() []byte {
 	bytes := make([]byte, 10)
 	binary.BigEndian.PutUint16(bytes, k.remoteChainId)
 	binary.BigEndian.PutUint64(bytes[2:], k.sequence)
-	return append(undoneSequencePrefix, bytes...)
+	return bytes
+}
+
+func (k *UndoneSequenceKey) encode() []byte {
+	return append(undoneSequencePrefix, k.suffix()...)
+}
+
+func (k *UndoneSequenceKey) historyKey() []byte {
+	return append(undoneHistoryPrefix, k.suffix()...)
+}
+
+func governanceSequenceKey(govSequence uint64) []byte {
+	return append(governanceSequencePrefix, Uint64ToBytes(govSequence)...)
+}
+
+// governanceSequenceAllocation records the undone sequence which a governance sequence has been allocated to
+type governanceSequenceAllocation struct {
+	RemoteChainId uint16 `json:"remoteChainId"`
+	Sequence      uint64 `json:"sequence"`
+	Digest        string `json:"digest"`
+}
+
+// decodeUndoneSequenceKey returns nil if the key is not an undone sequence key
+func decodeUndoneSequenceKey(key []byte) *UndoneSequenceKey {
+	if len(key) != len(undoneSequencePrefix)+10 || !bytes.HasPrefix(key, undoneSequencePrefix) {
+		return nil
+	}
+	suffix := key[len(undoneSequencePrefix):]
+	return &UndoneSequenceKey{
+		remoteChainId: binary.BigEndian.Uint16(suffix),
+		sequence:      binary.BigEndian.Uint64(suffix[2:]),
+	}
 }
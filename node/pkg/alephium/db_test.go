@@ -1,6 +1,7 @@
 package alephium
 
 import (
+	"errors"
 	"math/rand"
 	"testing"
 
@@ -77,29 +78,29 @@ func TestUpdateUndoneSequenceStatus(t *testing.T) {
 
 	sequence := uint64(10)
 	remoteChainId := uint16(2)
-	err = db.addUndoneSequence(remoteChainId, sequence)
+	err = db.addUndoneSequence(remoteChainId, sequence, "test")
 	assert.Nil(t, err)
 
-	err = db.addUndoneSequence(remoteChainId, sequence)
+	err = db.addUndoneSequence(remoteChainId, sequence, "test")
 	assert.Equal(t, err.Error(), "sequence 10 from remote chain 2 already exist")
 
-	err = db.SetSequenceExecuting(remoteChainId, sequence)
+	err = db.SetSequenceExecuting(remoteChainId, sequence, &UndoneSequenceExecution{})
 	assert.Nil(t, err)
 	status, err := db.getUndoneSequence(remoteChainId, sequence)
 	assert.Nil(t, err)
 	assert.Equal(t, status, []byte{sequenceExecuting})
 
-	err = db.SetSequenceExecuting(remoteChainId, sequence)
+	err = db.SetSequenceExecuting(remoteChainId, sequence, &UndoneSequenceExecution{})
 	assert.Equal(t, err.Error(), "failed to set sequence executing, status [2], sequence 10, remoteChainId 2")
-	err = db.SetSequenceExecuting(remoteChainId, sequence+1)
+	err = db.SetSequenceExecuting(remoteChainId, sequence+1, &UndoneSequenceExecution{})
 	assert.Equal(t, err, badger.ErrKeyNotFound)
 
-	err = db.setSequenceExecuted(remoteChainId, sequence)
+	err = db.setSequenceExecuted(remoteChainId, sequence, "test")
 	assert.Nil(t, err)
-	err = db.setSequenceExecuted(remoteChainId, sequence+1)
+	err = db.setSequenceExecuted(remoteChainId, sequence+1, "test")
 	assert.Equal(t, err, badger.ErrKeyNotFound)
 
-	err = db.SetSequenceExecuting(remoteChainId, sequence)
+	err = db.SetSequenceExecuting(remoteChainId, sequence, &UndoneSequenceExecution{})
 	assert.Equal(t, err.Error(), "failed to set sequence executing, status [3], sequence 10, remoteChainId 2")
 }
 
@@ -165,3 +166,122 @@ func TestAddRemoteChain(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, *chainId, remoteChainId)
 }
+
+func TestBatchDiscard(t *testing.T) {
+	db, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	defer db.Close()
+
+	remoteChainId := uint16(100)
+	contractId := randomByte32()
+	committed := false
+	injectedErr := errors.New("injected error")
+	err = db.update(func(batch *Batch) error {
+		batch.OnCommit(func() { committed = true })
+		if err := batch.addRemoteChain(contractId, remoteChainId); err != nil {
+			return err
+		}
+		// the pending writes are visible within the batch
+		chainId, err := batch.getRemoteChainId(contractId)
+		assert.Nil(t, err)
+		assert.Equal(t, *chainId, remoteChainId)
+		if err := batch.updateLastEventIndex(10); err != nil {
+			return err
+		}
+		return injectedErr
+	})
+	assert.Equal(t, err, injectedErr)
+	assert.False(t, committed)
+
+	_, err = db.getTokenBridgeForChain(remoteChainId)
+	assert.Equal(t, err, badger.ErrKeyNotFound)
+	_, err = db.getRemoteChainId(contractId)
+	assert.Equal(t, err, badger.ErrKeyNotFound)
+	_, err = db.getLastEventIndex()
+	assert.Equal(t, err, badger.ErrKeyNotFound)
+
+	err = db.update(func(batch *Batch) error {
+		batch.OnCommit(func() { committed = true })
+		return batch.addRemoteChain(contractId, remoteChainId)
+	})
+	assert.Nil(t, err)
+	assert.True(t, committed)
+	chainId, err := db.getRemoteChainId(contractId)
+	assert.Nil(t, err)
+	assert.Equal(t, *chainId, remoteChainId)
+}
+
+func TestUndoneSequenceHistory(t *testing.T) {
+	db, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	defer db.Close()
+
+	remoteChainId := uint16(2)
+	assert.Nil(t, db.addUndoneSequence(remoteChainId, 10, "added"))
+	assert.Nil(t, db.addUndoneSequence(remoteChainId, 11, "added"))
+	assert.Nil(t, db.addUndoneSequence(remoteChainId+1, 10, "added"))
+
+	execution := &UndoneSequenceExecution{VaaId: "1/0000000000000000000000000000000000000000000000000000000000000004/3", Digest: "abcd", Operator: "alice"}
+	assert.Nil(t, db.SetSequenceExecuting(remoteChainId, 10, execution))
+	// a failed transition is not recorded
+	assert.NotNil(t, db.SetSequenceExecuting(remoteChainId, 10, execution))
+	assert.Nil(t, db.setSequenceExecuted(remoteChainId, 10, "completed"))
+	assert.Nil(t, db.setSequenceExecuted(remoteChainId, 11, "completed"))
+
+	histories, err := db.GetUndoneSequenceHistory(remoteChainId)
+	assert.Nil(t, err)
+	assert.Equal(t, len(histories), 2)
+
+	history := histories[10]
+	assert.Equal(t, len(history), 3)
+	assert.Equal(t, history[0].Status, nodev1.UndoneSequenceStatus_UNDONE_SEQUENCE_STATUS_INIT)
+	assert.Equal(t, history[0].Reason, "added")
+	assert.NotZero(t, history[0].Timestamp)
+	assert.Equal(t, history[1].Status, nodev1.UndoneSequenceStatus_UNDONE_SEQUENCE_STATUS_EXECUTING)
+	assert.Equal(t, history[1].VaaId, execution.VaaId)
+	assert.Equal(t, history[1].Digest, execution.Digest)
+	assert.Equal(t, history[1].Operator, execution.Operator)
+	// the executed transition refers to the governance VAA of the executing transition
+	assert.Equal(t, history[2].Status, nodev1.UndoneSequenceStatus_UNDONE_SEQUENCE_STATUS_EXECUTED)
+	assert.Equal(t, history[2].VaaId, execution.VaaId)
+	assert.Equal(t, history[2].Reason, "completed")
+
+	history = histories[11]
+	assert.Equal(t, len(history), 2)
+	assert.Equal(t, history[1].Reason, "completed, without a local execution")
+	assert.Empty(t, history[1].VaaId)
+}
+
+func TestGovernanceSequenceAllocation(t *testing.T) {
+	db, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	defer db.Close()
+
+	remoteChainId := uint16(2)
+	assert.Nil(t, db.addUndoneSequence(remoteChainId, 10, "added"))
+	assert.Nil(t, db.addUndoneSequence(remoteChainId, 11, "added"))
+
+	// a dry run doesn't change anything
+	execution := &UndoneSequenceExecution{GovSequence: 5, Digest: "abcd"}
+	assert.Nil(t, db.CheckSequenceExecuting(remoteChainId, 10, execution))
+	status, err := db.getUndoneSequence(remoteChainId, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, status, []byte{sequenceInit})
+	_, err = db.get(governanceSequenceKey(5))
+	assert.Equal(t, err, badger.ErrKeyNotFound)
+
+	assert.Nil(t, db.SetSequenceExecuting(remoteChainId, 10, execution))
+
+	// the governance sequence can't be reused for another undone sequence
+	conflicting := &UndoneSequenceExecution{GovSequence: 5, Digest: "ef01"}
+	expected := "governance sequence 5 already allocated to sequence 10 from remote chain 2, digest abcd"
+	err = db.CheckSequenceExecuting(remoteChainId, 11, conflicting)
+	assert.Equal(t, err.Error(), expected)
+	err = db.SetSequenceExecuting(remoteChainId, 11, conflicting)
+	assert.Equal(t, err.Error(), expected)
+	status, err = db.getUndoneSequence(remoteChainId, 11)
+	assert.Nil(t, err)
+	assert.Equal(t, status, []byte{sequenceInit})
+
+	assert.Nil(t, db.SetSequenceExecuting(remoteChainId, 11, &UndoneSequenceExecution{GovSequence: 6}))
+}
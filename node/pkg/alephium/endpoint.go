@@ -0,0 +1,74 @@
+package alephium
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	endpointMinBackoff = 5 * time.Second
+	endpointMaxBackoff = 5 * time.Minute
+)
+
+var (
+	endpointHealthy = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "wormhole_alephium_endpoint_healthy",
+			Help: "Whether the Alephium full node endpoint is healthy (1) or backing off after failures (0)",
+		}, []string{"endpoint"})
+	endpointFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_alephium_endpoint_failures_total",
+			Help: "Total number of failed requests to the Alephium full node endpoint",
+		}, []string{"endpoint"})
+	quorumMismatches = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_alephium_quorum_mismatches_total",
+			Help: "Total number of quorum reads with conflicting responses from Alephium full nodes",
+		})
+)
+
+// endpoint tracks the health of a full node. After a failure the endpoint is skipped
+// for an exponentially increasing backoff period, unless all endpoints are unhealthy.
+type endpoint struct {
+	url string
+
+	lock     sync.Mutex
+	failures uint
+	retryAt  time.Time
+}
+
+func newEndpoint(url string) *endpoint {
+	endpointHealthy.WithLabelValues(url).Set(1)
+	return &endpoint{url: url}
+}
+
+func (e *endpoint) isHealthy(now time.Time) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return !now.Before(e.retryAt)
+}
+
+func (e *endpoint) onSuccess() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.failures = 0
+	e.retryAt = time.Time{}
+	endpointHealthy.WithLabelValues(e.url).Set(1)
+}
+
+func (e *endpoint) onFailure(now time.Time) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	backoff := endpointMinBackoff << e.failures
+	if backoff > endpointMaxBackoff || backoff <= 0 {
+		backoff = endpointMaxBackoff
+	}
+	e.failures += 1
+	e.retryAt = now.Add(backoff)
+	endpointHealthy.WithLabelValues(e.url).Set(0)
+	endpointFailures.WithLabelValues(e.url).Inc()
+}
@@ -0,0 +1,21 @@
+package alephium
+
+import (
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/watchers"
+)
+
+// NewFactory returns the factory of Alephium chain entries. The watchers keep their
+// contract state in the given database.
+func NewFactory(db *Database) watchers.Factory {
+	return func(c *watchers.ChainConfig, deps *watchers.Deps) (watchers.Watcher, error) {
+		watcher, err := NewAlephiumWatcher(
+			c.RPC, c.APIKey, c.Quorum, c.GroupIndex, c.GroupIndex, c.Contracts,
+			common.ChainReadiness(c.ChainID), deps.MsgC, c.MinConfirmations, deps.ObsvReqC, db, deps.Notifier,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return watcher, nil
+	}
+}
@@ -0,0 +1,45 @@
+package alephium
+
+import (
+	"testing"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactory(t *testing.T) {
+	db, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	defer db.Close()
+
+	contracts := make([]string, 4)
+	for i := range contracts {
+		contracts[i] = ToContractAddress(randomByte32())
+	}
+	c := &watchers.ChainConfig{
+		Name:              "alephium",
+		Type:              watchers.ChainTypeAlephium,
+		ChainID:           vaa.ChainIDAlephium,
+		RPC:               []string{"http://alph:12973"},
+		Contracts:         contracts,
+		Quorum:            1,
+		GroupIndex:        3,
+		ContractServerRPC: "[::]:31102",
+	}
+	deps := &watchers.Deps{MsgC: make(chan *common.MessagePublication)}
+
+	w, err := NewFactory(db)(c, deps)
+	assert.Nil(t, err)
+	watcher := w.(*Watcher)
+	assert.Equal(t, contracts[0], watcher.governanceContractAddress)
+	assert.Equal(t, toContractId(contracts[1]), watcher.eventEmitterId)
+	assert.Equal(t, toContractId(contracts[2]), watcher.tokenBridgeContractId)
+	assert.Equal(t, toContractId(contracts[3]), watcher.tokenWrapperFactoryContractId)
+	assert.Equal(t, uint8(3), watcher.chainIndex.FromGroup)
+
+	c.Contracts = contracts[:3]
+	_, err = NewFactory(db)(c, deps)
+	assert.NotNil(t, err)
+}
@@ -7,6 +7,38 @@ import (
 	"go.uber.org/zap"
 )
 
+// recoverPendingEvents restores the unconfirmed events persisted by the previous run, so that we can
+// resume confirmation tracking without re-downloading the events. If there is nothing persisted yet,
+// we fallback to fetch all events since the last handled event index from the full node.
+func (w *Watcher) recoverPendingEvents(
+	ctx context.Context,
+	logger *zap.Logger,
+	client *Client,
+	contractAddress string,
+) (*uint64, map[string]*UnconfirmedEvents, error) {
+	nextIndex, err := w.db.getNextEventIndex()
+	if err == badger.ErrKeyNotFound {
+		nextIndex, err := w.fetchEvents(ctx, logger, client, contractAddress)
+		if err != nil {
+			logger.Error("failed to fetch events when recovery", zap.Error(err))
+			return nil, nil, err
+		}
+		return nextIndex, map[string]*UnconfirmedEvents{}, nil
+	}
+	if err != nil {
+		logger.Error("failed to get next event index", zap.Error(err))
+		return nil, nil, err
+	}
+
+	pendingEvents, err := w.db.getPendingEvents()
+	if err != nil {
+		logger.Error("failed to load pending events", zap.Error(err))
+		return nil, nil, err
+	}
+	logger.Info("replay pending events", zap.Uint64("nextIndex", *nextIndex), zap.Int("blocks", len(pendingEvents)))
+	return nextIndex, pendingEvents, nil
+}
+
 func (w *Watcher) fetchEvents(
 	ctx context.Context,
 	logger *zap.Logger,
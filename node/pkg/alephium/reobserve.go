@@ -4,99 +4,113 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"sync/atomic"
 
-	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/common"
+	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
 	"go.uber.org/zap"
 )
 
-func (w *Watcher) handleObsvRequest(ctx context.Context, logger *zap.Logger, client *Client) {
+// HandleObservationRequest re-observes the governance message of the requested event. The tx hash of
+// Alephium observation requests is the tx id followed by the big-endian index of the event.
+func (w *Watcher) HandleObservationRequest(ctx context.Context, req *gossipv1.ObservationRequest) error {
+	w.clientMu.Lock()
+	client, logger := w.client, w.logger
+	w.clientMu.Unlock()
+	if client == nil {
+		return errors.New("watcher is not connected")
+	}
+
+	if len(req.TxHash) != 40 { // txId + eventIndex
+		return fmt.Errorf("invalid tx hash length %d", len(req.TxHash))
+	}
+	txId := hex.EncodeToString(req.TxHash[0:32])
+	eventIndex := binary.BigEndian.Uint64(req.TxHash[32:])
+	txStatus, err := client.GetTransactionStatus(ctx, txId)
+	if err != nil {
+		return fmt.Errorf("failed to get transaction status of %s: %w", txId, err)
+	}
+
+	blockHash := txStatus.BlockHash
+	isCanonical, err := client.IsBlockInMainChain(ctx, blockHash)
+	if err != nil {
+		return fmt.Errorf("failed to check mainchain block %s: %w", blockHash, err)
+	}
+	if !isCanonical {
+		logger.Info("ignore orphan block", zap.String("blockHash", blockHash))
+		return nil
+	}
+
+	currentHeight := atomic.LoadUint32(&w.currentHeight)
+
 	eventEmitterAddress := ToContractAddress(w.eventEmitterId)
+	unconfirmedEvents, err := w.getGovernanceEventsByIndex(ctx, client, eventEmitterAddress, blockHash, txId, eventIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get events from block %s: %w", blockHash, err)
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case req := <-w.obsvReqC:
-			assume(req.ChainId == uint32(vaa.ChainIDAlephium))
-			assume(len(req.TxHash) == 40) // txId + eventIndex
-			txId := hex.EncodeToString(req.TxHash[0:32])
-			eventIndex := binary.BigEndian.Uint64(req.TxHash[32:])
-			txStatus, err := client.GetTransactionStatus(ctx, txId)
-			if err != nil {
-				logger.Error("failed to get transaction status", zap.String("txId", txId), zap.Error(err))
-				continue
-			}
+	confirmedEvents := make([]*UnconfirmedEvent, 0)
+	for _, event := range unconfirmedEvents {
+		if event.blockHeader.Height+uint32(event.confirmations) <= currentHeight {
+			logger.Info("re-boserve event",
+				zap.String("txId", txId),
+				zap.String("blockHash", blockHash),
+				zap.Uint32("blockHeight", event.blockHeader.Height),
+				zap.Uint32("currentHeight", currentHeight),
+				zap.Uint8("confirmations", event.confirmations),
+			)
+			confirmedEvents = append(confirmedEvents, event)
+		} else {
+			logger.Info("ignore unconfirmed re-observed event",
+				zap.String("txId", txId),
+				zap.String("blockHash", blockHash),
+				zap.Uint32("blockHeight", event.blockHeader.Height),
+				zap.Uint32("currentHeight", currentHeight),
+				zap.Uint8("confirmations", event.confirmations),
+			)
+		}
+	}
 
-			blockHash := txStatus.BlockHash
-			isCanonical, err := client.IsBlockInMainChain(ctx, blockHash)
-			if err != nil {
-				logger.Error("failed to check mainchain block", zap.String("blockHash", blockHash), zap.Error(err))
-				continue
-			}
-			if !isCanonical {
-				logger.Info("ignore orphan block", zap.String("blockHash", blockHash))
-				continue
-			}
+	if len(confirmedEvents) == 0 {
+		return nil
+	}
 
-			currentHeight := atomic.LoadUint32(&w.currentHeight)
+	if err := w.handleGovernanceMessages(logger, confirmedEvents); err != nil {
+		return fmt.Errorf("failed to reobserve transfer message: %w", err)
+	}
+	return nil
+}
 
-			unconfirmedEvents, err := w.getGovernanceEventsByIndex(ctx, client, eventEmitterAddress, blockHash, txId, eventIndex)
+func (w *Watcher) handleGovernanceMessages(logger *zap.Logger, confirmed []*UnconfirmedEvent) error {
+	messages := make([]*common.MessagePublication, 0)
+	err := w.db.view(func(batch *Batch) error {
+		for _, e := range confirmed {
+			wormholeMsg, err := e.event.ToWormholeMessage()
 			if err != nil {
-				logger.Info("failed to get events from block", zap.String("blockHash", blockHash), zap.Error(err))
-				continue
-			}
-
-			confirmedEvents := make([]*UnconfirmedEvent, 0)
-			for _, event := range unconfirmedEvents {
-				if event.blockHeader.Height+uint32(event.confirmations) <= currentHeight {
-					logger.Info("re-boserve event",
-						zap.String("txId", txId),
-						zap.String("blockHash", blockHash),
-						zap.Uint32("blockHeight", event.blockHeader.Height),
-						zap.Uint32("currentHeight", currentHeight),
-						zap.Uint8("confirmations", event.confirmations),
-					)
-					confirmedEvents = append(confirmedEvents, event)
-				} else {
-					logger.Info("ignore unconfirmed re-observed event",
-						zap.String("txId", txId),
-						zap.String("blockHash", blockHash),
-						zap.Uint32("blockHeight", event.blockHeader.Height),
-						zap.Uint32("currentHeight", currentHeight),
-						zap.Uint8("confirmations", event.confirmations),
-					)
-				}
+				logger.Error("invalid wormhole message", zap.Error(err), zap.String("event", e.event.ToString()))
+				return err
 			}
-
-			if len(confirmedEvents) == 0 {
+			skipIfError, err := w.validateGovernanceMessages(batch, wormholeMsg)
+			if err != nil && skipIfError {
+				logger.Error("ignore invalid governance message", zap.Error(err))
 				continue
 			}
-
-			if err := w.handleGovernanceMessages(logger, confirmedEvents); err != nil {
-				logger.Error("failed to reobserve transfer message", zap.Error(err))
+			if err != nil && !skipIfError {
+				logger.Error("failed to validate governance message", zap.Error(err))
+				return err
 			}
+			messages = append(messages, wormholeMsg.toMessagePublication(e.blockHeader))
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-}
 
-func (w *Watcher) handleGovernanceMessages(logger *zap.Logger, confirmed []*UnconfirmedEvent) error {
-	for _, e := range confirmed {
-		wormholeMsg, err := e.event.ToWormholeMessage()
-		if err != nil {
-			logger.Error("invalid wormhole message", zap.Error(err), zap.String("event", e.event.ToString()))
-			return err
-		}
-		skipIfError, err := w.validateGovernanceMessages(wormholeMsg)
-		if err != nil && skipIfError {
-			logger.Error("ignore invalid governance message", zap.Error(err))
-			continue
-		}
-		if err != nil && !skipIfError {
-			logger.Error("failed to validate governance message", zap.Error(err))
-			return err
-		}
-		w.msgChan <- wormholeMsg.toMessagePublication(e.blockHeader)
+	for _, msg := range messages {
+		w.msgChan <- msg
 	}
 	return nil
 }
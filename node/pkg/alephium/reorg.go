@@ -0,0 +1,155 @@
+package alephium
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// We keep the undo logs of the confirmed blocks within MaxRollbackHeight blocks, so that
+// we can still roll back the state changes if a reorg deeper than MaxForkHeight happens
+const MaxRollbackHeight = 10 * MaxForkHeight
+
+var (
+	alephiumReorgedBlocks = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_alephium_confirmed_block_reorgs_total",
+			Help: "Total number of confirmed Alephium blocks which left the main chain",
+		})
+	alephiumReorgedMessages = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_alephium_reorged_messages_total",
+			Help: "Total number of published Alephium messages whose block left the main chain",
+		})
+	alephiumReorgCheckFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_alephium_confirmed_block_check_failures_total",
+			Help: "Total number of failed main chain checks of confirmed Alephium blocks",
+		})
+)
+
+func (w *Watcher) checkReorgs(ctx context.Context, logger *zap.Logger, client *Client, errC chan<- error) {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			// failed main chain checks are retried in the next round, only database errors are fatal
+			if err := w.checkConfirmedBlocks(ctx, logger, client); err != nil {
+				logger.Error("failed to check confirmed blocks", zap.Error(err))
+				errC <- err
+				return
+			}
+		}
+	}
+}
+
+// checkConfirmedBlocks re-checks whether the recently confirmed blocks are still in the main chain,
+// and rolls back the state changes of the blocks which left the main chain, from the highest one
+func (w *Watcher) checkConfirmedBlocks(ctx context.Context, logger *zap.Logger, client *Client) error {
+	logs, err := w.db.getUndoLogs()
+	if err != nil {
+		return err
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].Height > logs[j].Height
+	})
+
+	currentHeight := atomic.LoadUint32(&w.currentHeight)
+	expired := make([]string, 0)
+	for _, log := range logs {
+		if log.Height+MaxRollbackHeight < currentHeight {
+			expired = append(expired, log.BlockHash)
+			continue
+		}
+
+		isCanonical, err := client.isBlockInMainChain(ctx, log.BlockHash)
+		if err != nil {
+			// the blocks are rolled back from the highest one, so the lower blocks are checked in the next round
+			alephiumReorgCheckFailures.Inc()
+			logger.Warn("failed to check confirmed block, retry in the next round", zap.String("blockHash", log.BlockHash), zap.Error(err))
+			break
+		}
+		if isCanonical {
+			continue
+		}
+
+		alephiumReorgedBlocks.Inc()
+		logger.Error("confirmed block left the main chain, rollback the state changes",
+			zap.String("blockHash", log.BlockHash),
+			zap.Uint32("height", log.Height),
+			zap.Uint32("currentHeight", currentHeight),
+		)
+		client.InvalidateCache()
+		if err := w.rollback(logger, log.BlockHash); err != nil {
+			return err
+		}
+	}
+
+	if len(expired) == 0 {
+		return nil
+	}
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+	return w.db.removeUndoLogs(expired)
+}
+
+// rollback rolls back the state changes of the block. The undo log is read again with the state lock held,
+// because the block may have got more confirmed events since the undo logs were loaded.
+func (w *Watcher) rollback(logger *zap.Logger, blockHash string) error {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	var log *undoLog
+	err := w.db.update(func(batch *Batch) error {
+		var err error
+		log, err = batch.getUndoLog(blockHash)
+		if err == badger.ErrKeyNotFound {
+			// the undo log expired in the meantime
+			log = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return batch.rollback(log)
+	})
+	if err != nil {
+		logger.Error("failed to rollback block", zap.String("blockHash", blockHash), zap.Error(err))
+		return err
+	}
+	if log == nil {
+		return nil
+	}
+	// the caches will be reloaded from db
+	for _, cache := range []*sync.Map{
+		&w.tokenBridgeForChainCache,
+		&w.remoteTokenWrapperCache,
+		&w.localTokenWrapperCache,
+		&w.remoteChainIdCache,
+	} {
+		cache.Range(func(key, _ interface{}) bool {
+			cache.Delete(key)
+			return true
+		})
+	}
+
+	if len(log.Messages) != 0 {
+		alephiumReorgedMessages.Add(float64(len(log.Messages)))
+		logger.Error("published wormhole messages can not be rolled back",
+			zap.String("blockHash", log.BlockHash),
+			zap.Uint64s("sequences", log.Messages),
+		)
+	}
+	return nil
+}
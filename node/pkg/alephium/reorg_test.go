@@ -0,0 +1,187 @@
+package alephium
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	nodev1 "github.com/certusone/wormhole/node/pkg/proto/node/v1"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRollbackConfirmedBlocks(t *testing.T) {
+	db, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	defer db.Close()
+
+	watcher := &Watcher{
+		tokenBridgeContractId: randomByte32(),
+		db:                    db,
+		msgChan:               make(chan *common.MessagePublication, 1),
+	}
+	logger := zap.NewNop()
+
+	remoteChainId := uint16(2)
+	tokenBridgeForChainId := randomByte32()
+	blockA := &BlockHeader{Hash: randomByte32().ToHex(), Height: 10}
+	blockB := &BlockHeader{Hash: randomByte32().ToHex(), Height: 11}
+	expiredBlock := &BlockHeader{Hash: randomByte32().ToHex(), Height: 1}
+
+	undoneSequencesRemoved := func(header *BlockHeader, sequence uint64) *UnconfirmedEvent {
+		return &UnconfirmedEvent{
+			blockHeader: header,
+			event: &Event{
+				BlockHash:  header.Hash,
+				EventIndex: UndoneSequencesRemovedEventIndex,
+				Fields: []*Field{
+					{Type: "ByteVec", Value: tokenBridgeForChainId.ToHex()},
+					{Type: "ByteVec", Value: hex.EncodeToString(Uint64ToBytes(sequence))},
+				},
+			},
+		}
+	}
+	tokenBridgeForChainCreated := &UnconfirmedEvent{
+		blockHeader: blockA,
+		event: &Event{
+			BlockHash:  blockA.Hash,
+			EventIndex: TokenBridgeForChainCreatedEventIndex,
+			Fields: []*Field{
+				{Type: "ByteVec", Value: watcher.tokenBridgeContractId.ToHex()},
+				{Type: "ByteVec", Value: tokenBridgeForChainId.ToHex()},
+				{Type: "U256", Value: strconv.Itoa(int(remoteChainId))},
+			},
+		},
+	}
+	wormholeMessage := &UnconfirmedEvent{
+		blockHeader: blockA,
+		event: &Event{
+			BlockHash:  blockA.Hash,
+			TxId:       randomByte32().ToHex(),
+			EventIndex: WormholeMessageEventIndex,
+			Fields: []*Field{
+				{Type: "ByteVec", Value: watcher.tokenBridgeContractId.ToHex()},
+				{Type: "U256", Value: "7"},
+				{Type: "ByteVec", Value: "00000001"},
+				{Type: "ByteVec", Value: "02"},
+				{Type: "U256", Value: "0"},
+			},
+		},
+	}
+
+	err = watcher.handleEvents(logger, &ConfirmedEvents{[]*UnconfirmedEvents{
+		{eventIndex: 0, events: []*UnconfirmedEvent{tokenBridgeForChainCreated, undoneSequencesRemoved(blockA, 3), wormholeMessage}},
+		{eventIndex: 1, events: []*UnconfirmedEvent{undoneSequencesRemoved(blockB, 5)}},
+		{eventIndex: 2, events: []*UnconfirmedEvent{undoneSequencesRemoved(expiredBlock, 9)}},
+	}}, false)
+	assert.Nil(t, err)
+	<-watcher.msgChan
+	// the undo log of block B is extended by the events confirmed in a later batch
+	err = watcher.handleEvents(logger, &ConfirmedEvents{[]*UnconfirmedEvents{
+		{eventIndex: 3, events: []*UnconfirmedEvent{undoneSequencesRemoved(blockB, 6)}},
+	}}, false)
+	assert.Nil(t, err)
+
+	logs, err := db.getUndoLogs()
+	assert.Nil(t, err)
+	assert.Equal(t, len(logs), 3)
+
+	var canonicalA, canonicalB, unavailable uint32 = 1, 0, 0
+	queried := sync.Map{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.RequestURI, "/blockflow/is-block-in-main-chain") {
+			blockHash := r.URL.Query()["blockHash"][0]
+			queried.Store(blockHash, true)
+			if atomic.LoadUint32(&unavailable) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			switch blockHash {
+			case blockA.Hash:
+				json.NewEncoder(w).Encode(atomic.LoadUint32(&canonicalA) == 1)
+			case blockB.Hash:
+				json.NewEncoder(w).Encode(atomic.LoadUint32(&canonicalB) == 1)
+			default:
+				json.NewEncoder(w).Encode(true)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "", 10)
+
+	checkUndoneSequences := func(expected ...uint64) {
+		sequences, err := db.GetUndoneSequences(remoteChainId)
+		assert.Nil(t, err)
+		assert.Equal(t, len(sequences), len(expected))
+		for i, sequence := range sequences {
+			assert.Equal(t, sequence.Sequence, expected[i])
+		}
+	}
+	checkUndoneSequences(3, 5, 6, 9)
+
+	// block B left the main chain, the undo log of the expired block is removed
+	reorgedBlocks := testutil.ToFloat64(alephiumReorgedBlocks)
+	reorgedMessages := testutil.ToFloat64(alephiumReorgedMessages)
+	atomic.StoreUint32(&watcher.currentHeight, expiredBlock.Height+MaxRollbackHeight+1)
+	err = watcher.checkConfirmedBlocks(context.Background(), logger, client)
+	assert.Nil(t, err)
+	checkUndoneSequences(3, 9)
+	// the rollback is recorded in the history of the removed sequence
+	histories, err := db.GetUndoneSequenceHistory(remoteChainId)
+	assert.Nil(t, err)
+	assert.Equal(t, len(histories[5]), 2)
+	assert.Equal(t, histories[5][1].Status, nodev1.UndoneSequenceStatus_UNDONE_SEQUENCE_STATUS_REMOVED)
+	assert.Contains(t, histories[5][1].Reason, blockB.Hash)
+	_, ok := queried.Load(expiredBlock.Hash)
+	assert.False(t, ok)
+	logs, err = db.getUndoLogs()
+	assert.Nil(t, err)
+	assert.Equal(t, len(logs), 1)
+	assert.Equal(t, logs[0].BlockHash, blockA.Hash)
+	assert.Equal(t, testutil.ToFloat64(alephiumReorgedBlocks)-reorgedBlocks, float64(1))
+	assert.Equal(t, testutil.ToFloat64(alephiumReorgedMessages)-reorgedMessages, float64(0))
+
+	// failed checks are retried in the next round rather than failing the watcher
+	checkFailures := testutil.ToFloat64(alephiumReorgCheckFailures)
+	atomic.StoreUint32(&canonicalA, 0)
+	atomic.StoreUint32(&unavailable, 1)
+	err = watcher.checkConfirmedBlocks(context.Background(), logger, client)
+	assert.Nil(t, err)
+	assert.Equal(t, testutil.ToFloat64(alephiumReorgCheckFailures)-checkFailures, float64(1))
+	checkUndoneSequences(3, 9)
+
+	// block A left the main chain
+	atomic.StoreUint32(&unavailable, 0)
+	err = watcher.checkConfirmedBlocks(context.Background(), logger, client)
+	assert.Nil(t, err)
+	checkUndoneSequences(9)
+	_, err = db.getTokenBridgeForChain(remoteChainId)
+	assert.Equal(t, err, badger.ErrKeyNotFound)
+	_, err = db.getRemoteChainId(tokenBridgeForChainId)
+	assert.Equal(t, err, badger.ErrKeyNotFound)
+	_, ok = watcher.tokenBridgeForChainCache.Load(remoteChainId)
+	assert.False(t, ok)
+	logs, err = db.getUndoLogs()
+	assert.Nil(t, err)
+	assert.Equal(t, len(logs), 0)
+	assert.Equal(t, testutil.ToFloat64(alephiumReorgedBlocks)-reorgedBlocks, float64(2))
+	assert.Equal(t, testutil.ToFloat64(alephiumReorgedMessages)-reorgedMessages, float64(1))
+
+	// the event index is not rolled back
+	index, err := db.getLastEventIndex()
+	assert.Nil(t, err)
+	assert.Equal(t, *index, uint64(3))
+}
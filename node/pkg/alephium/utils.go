@@ -309,11 +309,13 @@ type tokenBridgeForChainCreated struct {
 }
 
 type undoneSequencesRemoved struct {
+	txId      string
 	senderId  Byte32
 	sequences []byte
 }
 
 type undoneSequenceCompleted struct {
+	txId          string
 	senderId      Byte32
 	remoteChainId uint16
 	sequence      uint64
@@ -370,6 +372,7 @@ func (e *Event) toUndoneSequencesRemoved() (*undoneSequencesRemoved, error) {
 	}
 	sequences := e.Fields[1].ToByteVec()
 	return &undoneSequencesRemoved{
+		txId:      e.TxId,
 		senderId:  *senderId,
 		sequences: sequences,
 	}, nil
@@ -390,6 +393,7 @@ func (e *Event) toUndoneSequenceCompleted() (*undoneSequenceCompleted, error) {
 		return nil, err
 	}
 	return &undoneSequenceCompleted{
+		txId:          e.TxId,
 		senderId:      *senderId,
 		remoteChainId: remoteChainId,
 		sequence:      sequence,
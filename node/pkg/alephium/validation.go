@@ -5,59 +5,88 @@ import (
 	"encoding/binary"
 	"fmt"
 
+	"github.com/certusone/wormhole/node/pkg/notify"
 	"github.com/dgraph-io/badger/v3"
 	// We should not rely on ETH, but some data structures of wormhole use ETH hash
 )
 
-func (w *Watcher) validateTokenBridgeForChainCreatedEvents(event *tokenBridgeForChainCreated) (bool, error) {
+func (w *Watcher) validateTokenBridgeForChainCreatedEvents(batch *Batch, event *tokenBridgeForChainCreated) (bool, error) {
 	if !event.senderId.equalWith(w.tokenBridgeContractId) {
 		return true, fmt.Errorf("invalid sender for token bridge for chain created event, expected %v, have %v", w.tokenBridgeContractId.ToHex(), event.senderId.ToHex())
 	}
-	if err := w.db.addRemoteChain(event.contractId, event.remoteChainId); err != nil {
+	if err := batch.addRemoteChain(event.contractId, event.remoteChainId); err != nil {
 		return false, fmt.Errorf("failed to persist remote chain to db, err %v", err)
 	}
-	w.tokenBridgeForChainCache.Store(event.remoteChainId, &event.contractId)
-	w.remoteChainIdCache.Store(event.contractId, &event.remoteChainId)
+	batch.OnCommit(func() {
+		w.tokenBridgeForChainCache.Store(event.remoteChainId, &event.contractId)
+		w.remoteChainIdCache.Store(event.contractId, &event.remoteChainId)
+	})
 	return false, nil
 }
 
 func (w *Watcher) validateUndoneSequencesRemovedEvents(
+	batch *Batch,
 	event *undoneSequencesRemoved,
-	remoteChainIdGetter func(Byte32) (*uint16, error),
+	remoteChainIdGetter func(*Batch, Byte32) (*uint16, error),
 ) (bool, error) {
-	remoteChainId, err := remoteChainIdGetter(event.senderId)
+	remoteChainId, err := remoteChainIdGetter(batch, event.senderId)
 	if err != nil {
 		return true, err
 	}
-	length := len(event.sequences)
-	for i := 0; i < length; i += 8 {
-		data := event.sequences[i : i+8]
-		sequence := binary.BigEndian.Uint64(data)
-		if err := w.db.addUndoneSequence(*remoteChainId, sequence); err != nil {
-			return true, err
+	if len(event.sequences)%8 != 0 {
+		return true, fmt.Errorf("invalid undone sequences length %d in tx %s", len(event.sequences), event.txId)
+	}
+	// check all sequences before writing any of them, so that an invalid event leaves nothing in the batch
+	sequences := make([]uint64, 0, len(event.sequences)/8)
+	removed := make(map[uint64]struct{})
+	for i := 0; i < len(event.sequences); i += 8 {
+		sequence := binary.BigEndian.Uint64(event.sequences[i : i+8])
+		if _, ok := removed[sequence]; ok {
+			return true, fmt.Errorf("duplicate undone sequence %v from remote chain %v", sequence, *remoteChainId)
+		}
+		exists, err := batch.hasUndoneSequence(*remoteChainId, sequence)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return true, fmt.Errorf("sequence %v from remote chain %v already exist", sequence, *remoteChainId)
+		}
+		removed[sequence] = struct{}{}
+		sequences = append(sequences, sequence)
+	}
+
+	reason := fmt.Sprintf("UndoneSequencesRemoved event in tx %s", event.txId)
+	for _, sequence := range sequences {
+		if err := batch.addUndoneSequence(*remoteChainId, sequence, reason); err != nil {
+			return false, err
+		}
+		if w.notifier != nil {
+			alert := notify.UndoneSequenceAdded(*remoteChainId, sequence, reason)
+			batch.OnCommit(func() { w.notifier.Notify(alert) })
 		}
 	}
 	return false, nil
 }
 
-func (w *Watcher) validateUndoneSequenceCompletedEvents(event *undoneSequenceCompleted) (bool, error) {
+func (w *Watcher) validateUndoneSequenceCompletedEvents(batch *Batch, event *undoneSequenceCompleted) (bool, error) {
 	if !event.senderId.equalWith(w.tokenBridgeContractId) {
 		return true, fmt.Errorf("invalid sender for undone sequence completed event, expected %v, have %v", w.tokenBridgeContractId.ToHex(), event.senderId.ToHex())
 	}
-	if err := w.db.setSequenceExecuted(event.remoteChainId, event.sequence); err != nil {
+	reason := fmt.Sprintf("UndoneSequenceCompleted event in tx %s", event.txId)
+	if err := batch.setSequenceExecuted(event.remoteChainId, event.sequence, reason); err != nil {
 		return false, fmt.Errorf("failed to set undone sequence executing, err %v", err)
 	}
 	return false, nil
 }
 
 // return skipIfError, error
-func (w *Watcher) validateTokenWrapperCreatedEvent(event *tokenWrapperCreated) (bool, error) {
+func (w *Watcher) validateTokenWrapperCreatedEvent(batch *Batch, event *tokenWrapperCreated) (bool, error) {
 	if !event.senderId.equalWith(w.tokenWrapperFactoryContractId) {
 		err := fmt.Errorf("invalid sender for token wrapper created event, expected %s, have %s", w.tokenWrapperFactoryContractId.ToHex(), event.senderId.ToHex())
 		return true, err
 	}
 
-	contractId, err := w.getTokenBridgeForChain(event.remoteChainId)
+	contractId, err := w.getTokenBridgeForChain(batch, event.remoteChainId)
 	if err == badger.ErrKeyNotFound {
 		err := fmt.Errorf("token bridge for chain does not exist: %v", event.remoteChainId)
 		return true, err
@@ -80,7 +109,7 @@ func (w *Watcher) validateTokenWrapperCreatedEvent(event *tokenWrapperCreated) (
 			localTokenId:  event.tokenId,
 			remoteChainId: event.remoteChainId,
 		}
-		exist, err := w.db.localTokenWrapperExist(&key)
+		exist, err := batch.localTokenWrapperExist(&key)
 		if err != nil {
 			err := fmt.Errorf("failed to check if local token wrapper already exist, err %v", err)
 			return false, err
@@ -91,16 +120,24 @@ func (w *Watcher) validateTokenWrapperCreatedEvent(event *tokenWrapperCreated) (
 			return true, err
 		}
 
-		w.localTokenWrapperCache.Store(key, &event.tokenWrapperId)
-		w.db.addLocalTokenWrapper(&key, event.tokenWrapperId)
+		if err := batch.addLocalTokenWrapper(&key, event.tokenWrapperId); err != nil {
+			return false, fmt.Errorf("failed to persist local token wrapper to db, err %v", err)
+		}
+		batch.OnCommit(func() {
+			w.localTokenWrapperCache.Store(key, &event.tokenWrapperId)
+		})
 	} else {
-		w.remoteTokenWrapperCache.Store(event.tokenId, &event.tokenWrapperId)
-		w.db.addRemoteTokenWrapper(event.tokenId, event.tokenWrapperId)
+		if err := batch.addRemoteTokenWrapper(event.tokenId, event.tokenWrapperId); err != nil {
+			return false, fmt.Errorf("failed to persist remote token wrapper to db, err %v", err)
+		}
+		batch.OnCommit(func() {
+			w.remoteTokenWrapperCache.Store(event.tokenId, &event.tokenWrapperId)
+		})
 	}
 	return false, nil
 }
 
-func (w *Watcher) validateGovernanceMessages(event *WormholeMessage) (bool, error) {
+func (w *Watcher) validateGovernanceMessages(batch *Batch, event *WormholeMessage) (bool, error) {
 	if !event.senderId.equalWith(w.tokenBridgeContractId) {
 		err := fmt.Errorf("invalid sender for wormhole message, expect %v, have %v", w.tokenBridgeContractId.ToHex(), event.senderId.ToHex())
 		return true, err
@@ -110,16 +147,16 @@ func (w *Watcher) validateGovernanceMessages(event *WormholeMessage) (bool, erro
 		return false, nil
 	}
 	transferMsg := TransferMessageFromBytes(event.payload)
-	return w.validateTransferMessage(transferMsg)
+	return w.validateTransferMessage(batch, transferMsg)
 }
 
-func (w *Watcher) validateTransferMessage(transferMsg *TransferMessage) (bool, error) {
+func (w *Watcher) validateTransferMessage(batch *Batch, transferMsg *TransferMessage) (bool, error) {
 	var contractId *Byte32
 	var err error
 	if transferMsg.isLocalToken {
-		contractId, err = w.getLocalTokenWrapper(transferMsg.tokenId, transferMsg.toChainId)
+		contractId, err = w.getLocalTokenWrapper(batch, transferMsg.tokenId, transferMsg.toChainId)
 	} else {
-		contractId, err = w.getRemoteTokenWrapper(transferMsg.tokenId)
+		contractId, err = w.getRemoteTokenWrapper(batch, transferMsg.tokenId)
 	}
 
 	if err != nil {
@@ -131,43 +168,51 @@ func (w *Watcher) validateTransferMessage(transferMsg *TransferMessage) (bool, e
 	return false, nil
 }
 
-func (w *Watcher) getRemoteChainId(contractId Byte32) (*uint16, error) {
+// the getters read through the batch so that the writes which are not committed yet are visible,
+// and the values are cached only after the batch has been committed
+func (w *Watcher) getRemoteChainId(batch *Batch, contractId Byte32) (*uint16, error) {
 	if value, ok := w.remoteChainIdCache.Load(contractId); ok {
 		return (value).(*uint16), nil
 	}
-	remoteChainId, err := w.db.getRemoteChainId(contractId)
+	remoteChainId, err := batch.getRemoteChainId(contractId)
 	if err != nil {
 		return nil, err
 	}
-	w.remoteChainIdCache.Store(contractId, remoteChainId)
+	batch.OnCommit(func() {
+		w.remoteChainIdCache.Store(contractId, remoteChainId)
+	})
 	return remoteChainId, nil
 }
 
-func (w *Watcher) getTokenBridgeForChain(chainId uint16) (*Byte32, error) {
+func (w *Watcher) getTokenBridgeForChain(batch *Batch, chainId uint16) (*Byte32, error) {
 	if value, ok := w.tokenBridgeForChainCache.Load(chainId); ok {
 		return value.(*Byte32), nil
 	}
-	contractId, err := w.db.getTokenBridgeForChain(chainId)
+	contractId, err := batch.getTokenBridgeForChain(chainId)
 	if err != nil {
 		return nil, err
 	}
-	w.tokenBridgeForChainCache.Store(chainId, contractId)
+	batch.OnCommit(func() {
+		w.tokenBridgeForChainCache.Store(chainId, contractId)
+	})
 	return contractId, nil
 }
 
-func (w *Watcher) getRemoteTokenWrapper(tokenId Byte32) (*Byte32, error) {
+func (w *Watcher) getRemoteTokenWrapper(batch *Batch, tokenId Byte32) (*Byte32, error) {
 	if value, ok := w.remoteTokenWrapperCache.Load(tokenId); ok {
 		return value.(*Byte32), nil
 	}
-	contractId, err := w.db.GetRemoteTokenWrapper(tokenId)
+	contractId, err := batch.getRemoteTokenWrapper(tokenId)
 	if err != nil {
 		return nil, err
 	}
-	w.remoteTokenWrapperCache.Store(tokenId, contractId)
+	batch.OnCommit(func() {
+		w.remoteTokenWrapperCache.Store(tokenId, contractId)
+	})
 	return contractId, err
 }
 
-func (w *Watcher) getLocalTokenWrapper(tokenId Byte32, remoteChainId uint16) (*Byte32, error) {
+func (w *Watcher) getLocalTokenWrapper(batch *Batch, tokenId Byte32, remoteChainId uint16) (*Byte32, error) {
 	key := LocalTokenWrapperKey{
 		localTokenId:  tokenId,
 		remoteChainId: remoteChainId,
@@ -175,10 +220,12 @@ func (w *Watcher) getLocalTokenWrapper(tokenId Byte32, remoteChainId uint16) (*B
 	if value, ok := w.localTokenWrapperCache.Load(key); ok {
 		return value.(*Byte32), nil
 	}
-	contractId, err := w.db.GetLocalTokenWrapper(tokenId, remoteChainId)
+	contractId, err := batch.getLocalTokenWrapper(tokenId, remoteChainId)
 	if err != nil {
 		return nil, err
 	}
-	w.localTokenWrapperCache.Store(key, contractId)
+	batch.OnCommit(func() {
+		w.localTokenWrapperCache.Store(key, contractId)
+	})
 	return contractId, err
 }
@@ -3,12 +3,15 @@ package alephium
 import (
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"math/big"
 	"sync"
 	"testing"
 
 	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/notify"
 	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/dgraph-io/badger/v3"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -112,6 +115,14 @@ func TestTransferEvent(t *testing.T) {
 	assert.Equal(t, transferMessage.tokenWrapperId.ToHex(), "4244dbdc1b82dd39336865f969c8d02f75642aed3ae2718dcb3256ceca8b7634")
 }
 
+func withBatch(t *testing.T, db *Database, validate func(*Batch) (bool, error)) (bool, error) {
+	batch := db.NewBatch()
+	defer batch.Discard()
+	skipIfError, err := validate(batch)
+	assert.Nil(t, batch.Commit())
+	return skipIfError, err
+}
+
 func TestValidateTokenWrapperCreatedEvent(t *testing.T) {
 	db, err := Open(t.TempDir())
 	assert.Nil(t, err)
@@ -134,60 +145,70 @@ func TestValidateTokenWrapperCreatedEvent(t *testing.T) {
 	remoteTokenId := randomByte32()
 	remoteTokenWrapperId := randomByte32()
 
-	skipIfError, err := watcher.validateTokenWrapperCreatedEvent(&tokenWrapperCreated{
-		senderId:              watcher.tokenWrapperFactoryContractId,
-		tokenBridgeForChainId: tokenBridgeForChainId,
-		tokenWrapperId:        localTokenWrapperId,
-		isLocalToken:          true,
-		tokenId:               localTokenId,
-		remoteChainId:         remoteChainId,
+	skipIfError, err := withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTokenWrapperCreatedEvent(batch, &tokenWrapperCreated{
+			senderId:              watcher.tokenWrapperFactoryContractId,
+			tokenBridgeForChainId: tokenBridgeForChainId,
+			tokenWrapperId:        localTokenWrapperId,
+			isLocalToken:          true,
+			tokenId:               localTokenId,
+			remoteChainId:         remoteChainId,
+		})
 	})
 	assert.False(t, skipIfError)
 	assert.Nil(t, err)
 
-	skipIfError, err = watcher.validateTokenWrapperCreatedEvent(&tokenWrapperCreated{
-		senderId:              watcher.tokenWrapperFactoryContractId,
-		tokenBridgeForChainId: tokenBridgeForChainId,
-		tokenWrapperId:        remoteTokenWrapperId,
-		isLocalToken:          false,
-		tokenId:               remoteTokenId,
-		remoteChainId:         remoteChainId,
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTokenWrapperCreatedEvent(batch, &tokenWrapperCreated{
+			senderId:              watcher.tokenWrapperFactoryContractId,
+			tokenBridgeForChainId: tokenBridgeForChainId,
+			tokenWrapperId:        remoteTokenWrapperId,
+			isLocalToken:          false,
+			tokenId:               remoteTokenId,
+			remoteChainId:         remoteChainId,
+		})
 	})
 	assert.False(t, skipIfError)
 	assert.Nil(t, err)
 
 	// invalid sender contract
-	skipIfError, err = watcher.validateTokenWrapperCreatedEvent(&tokenWrapperCreated{
-		senderId:              randomByte32(),
-		tokenBridgeForChainId: tokenBridgeForChainId,
-		tokenWrapperId:        remoteTokenWrapperId,
-		isLocalToken:          false,
-		tokenId:               remoteTokenId,
-		remoteChainId:         remoteChainId,
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTokenWrapperCreatedEvent(batch, &tokenWrapperCreated{
+			senderId:              randomByte32(),
+			tokenBridgeForChainId: tokenBridgeForChainId,
+			tokenWrapperId:        remoteTokenWrapperId,
+			isLocalToken:          false,
+			tokenId:               remoteTokenId,
+			remoteChainId:         remoteChainId,
+		})
 	})
 	assert.True(t, skipIfError)
 	assert.NotNil(t, err)
 
 	// invalid token bridge for chain
-	skipIfError, err = watcher.validateTokenWrapperCreatedEvent(&tokenWrapperCreated{
-		senderId:              watcher.tokenWrapperFactoryContractId,
-		tokenBridgeForChainId: tokenBridgeForChainId,
-		tokenWrapperId:        remoteTokenWrapperId,
-		isLocalToken:          false,
-		tokenId:               remoteTokenId,
-		remoteChainId:         remoteChainId + 1,
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTokenWrapperCreatedEvent(batch, &tokenWrapperCreated{
+			senderId:              watcher.tokenWrapperFactoryContractId,
+			tokenBridgeForChainId: tokenBridgeForChainId,
+			tokenWrapperId:        remoteTokenWrapperId,
+			isLocalToken:          false,
+			tokenId:               remoteTokenId,
+			remoteChainId:         remoteChainId + 1,
+		})
 	})
 	assert.True(t, skipIfError)
 	assert.NotNil(t, err)
 
 	// local token wrapper already exist
-	skipIfError, err = watcher.validateTokenWrapperCreatedEvent(&tokenWrapperCreated{
-		senderId:              watcher.tokenWrapperFactoryContractId,
-		tokenBridgeForChainId: tokenBridgeForChainId,
-		tokenWrapperId:        randomByte32(),
-		isLocalToken:          true,
-		tokenId:               localTokenId,
-		remoteChainId:         remoteChainId,
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTokenWrapperCreatedEvent(batch, &tokenWrapperCreated{
+			senderId:              watcher.tokenWrapperFactoryContractId,
+			tokenBridgeForChainId: tokenBridgeForChainId,
+			tokenWrapperId:        randomByte32(),
+			isLocalToken:          true,
+			tokenId:               localTokenId,
+			remoteChainId:         remoteChainId,
+		})
 	})
 	assert.True(t, skipIfError)
 	assert.NotNil(t, err)
@@ -220,16 +241,25 @@ func TestValidateTokenWrapperCreatedEvent(t *testing.T) {
 	checkRemoteTokenWrapperId(remoteTokenId, remoteTokenWrapperId)
 }
 
+type testNotifier struct {
+	alerts []*notify.Alert
+}
+
+func (n *testNotifier) Notify(a *notify.Alert) {
+	n.alerts = append(n.alerts, a)
+}
+
 func TestValidateUndoneSequencesRemovedEvents(t *testing.T) {
 	db, err := Open(t.TempDir())
 	assert.Nil(t, err)
 	defer db.Close()
 
-	watcher := &Watcher{db: db}
+	notifier := &testNotifier{}
+	watcher := &Watcher{db: db, notifier: notifier}
 	remoteChainId := uint16(2)
 	removedSequences := []uint64{1, 3, 5, 8}
 
-	remoteChainIdGetter := func(tokenBridgeForChainId Byte32) (*uint16, error) {
+	remoteChainIdGetter := func(batch *Batch, tokenBridgeForChainId Byte32) (*uint16, error) {
 		return &remoteChainId, nil
 	}
 
@@ -238,10 +268,12 @@ func TestValidateUndoneSequencesRemovedEvents(t *testing.T) {
 		encodedSequences = append(encodedSequences, Uint64ToBytes(seq)...)
 	}
 
-	skipIfError, err := watcher.validateUndoneSequencesRemovedEvents(&undoneSequencesRemoved{
-		senderId:  randomByte32(),
-		sequences: encodedSequences,
-	}, remoteChainIdGetter)
+	skipIfError, err := withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateUndoneSequencesRemovedEvents(batch, &undoneSequencesRemoved{
+			senderId:  randomByte32(),
+			sequences: encodedSequences,
+		}, remoteChainIdGetter)
+	})
 	assert.False(t, skipIfError)
 	assert.Nil(t, err)
 	for _, seq := range removedSequences {
@@ -249,15 +281,44 @@ func TestValidateUndoneSequencesRemovedEvents(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, status, []byte{sequenceInit})
 	}
+	assert.Equal(t, len(removedSequences), len(notifier.alerts))
+	for i, alert := range notifier.alerts {
+		assert.Equal(t, notify.AlertUndoneSequence, alert.Type)
+		assert.Equal(t, fmt.Sprintf("%d/%d", remoteChainId, removedSequences[i]), alert.DedupKey)
+	}
+
+	// an event with an existing sequence doesn't add any of its sequences
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateUndoneSequencesRemovedEvents(batch, &undoneSequencesRemoved{
+			senderId:  randomByte32(),
+			sequences: append(Uint64ToBytes(20), Uint64ToBytes(removedSequences[0])...),
+		}, remoteChainIdGetter)
+	})
+	assert.True(t, skipIfError)
+	assert.NotNil(t, err)
+	_, err = watcher.db.getUndoneSequence(remoteChainId, 20)
+	assert.Equal(t, badger.ErrKeyNotFound, err)
+	assert.Equal(t, len(removedSequences), len(notifier.alerts))
+
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateUndoneSequencesRemovedEvents(batch, &undoneSequencesRemoved{
+			senderId:  randomByte32(),
+			sequences: Uint64ToBytes(21)[:6],
+		}, remoteChainIdGetter)
+	})
+	assert.True(t, skipIfError)
+	assert.NotNil(t, err)
 
-	remoteChainIdGetter = func(tokenBridgeForChainId Byte32) (*uint16, error) {
+	remoteChainIdGetter = func(batch *Batch, tokenBridgeForChainId Byte32) (*uint16, error) {
 		return nil, errors.New("error")
 	}
 	invalidSequences := uint64(10)
-	skipIfError, err = watcher.validateUndoneSequencesRemovedEvents(&undoneSequencesRemoved{
-		senderId:  randomByte32(),
-		sequences: Uint64ToBytes(invalidSequences),
-	}, remoteChainIdGetter)
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateUndoneSequencesRemovedEvents(batch, &undoneSequencesRemoved{
+			senderId:  randomByte32(),
+			sequences: Uint64ToBytes(invalidSequences),
+		}, remoteChainIdGetter)
+	})
 	assert.True(t, skipIfError)
 	assert.NotNil(t, err)
 }
@@ -274,40 +335,48 @@ func TestValidateUndoneSequenceCompletedEvents(t *testing.T) {
 	remoteChainId := uint16(2)
 	sequence := uint64(0)
 
-	skipIfError, err := watcher.validateUndoneSequenceCompletedEvents(&undoneSequenceCompleted{
-		senderId:      watcher.tokenBridgeContractId,
-		remoteChainId: remoteChainId,
-		sequence:      sequence,
+	skipIfError, err := withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateUndoneSequenceCompletedEvents(batch, &undoneSequenceCompleted{
+			senderId:      watcher.tokenBridgeContractId,
+			remoteChainId: remoteChainId,
+			sequence:      sequence,
+		})
 	})
 	assert.False(t, skipIfError)
 	assert.NotNil(t, err)
 
-	err = watcher.db.addUndoneSequence(remoteChainId, sequence)
+	err = watcher.db.addUndoneSequence(remoteChainId, sequence, "test")
 	assert.Nil(t, err)
 
-	skipIfError, err = watcher.validateUndoneSequenceCompletedEvents(&undoneSequenceCompleted{
-		senderId:      watcher.tokenBridgeContractId,
-		remoteChainId: remoteChainId,
-		sequence:      sequence,
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateUndoneSequenceCompletedEvents(batch, &undoneSequenceCompleted{
+			senderId:      watcher.tokenBridgeContractId,
+			remoteChainId: remoteChainId,
+			sequence:      sequence,
+		})
 	})
 	assert.False(t, skipIfError)
 	assert.Nil(t, err)
 
-	err = watcher.db.SetSequenceExecuting(remoteChainId, sequence)
+	err = watcher.db.SetSequenceExecuting(remoteChainId, sequence, &UndoneSequenceExecution{})
 	assert.NotNil(t, err)
 
-	skipIfError, err = watcher.validateUndoneSequenceCompletedEvents(&undoneSequenceCompleted{
-		senderId:      watcher.tokenBridgeContractId,
-		remoteChainId: remoteChainId,
-		sequence:      sequence,
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateUndoneSequenceCompletedEvents(batch, &undoneSequenceCompleted{
+			senderId:      watcher.tokenBridgeContractId,
+			remoteChainId: remoteChainId,
+			sequence:      sequence,
+		})
 	})
 	assert.False(t, skipIfError)
 	assert.Nil(t, err)
 
-	skipIfError, err = watcher.validateUndoneSequenceCompletedEvents(&undoneSequenceCompleted{
-		senderId:      randomByte32(),
-		remoteChainId: remoteChainId,
-		sequence:      sequence + 1,
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateUndoneSequenceCompletedEvents(batch, &undoneSequenceCompleted{
+			senderId:      randomByte32(),
+			remoteChainId: remoteChainId,
+			sequence:      sequence + 1,
+		})
 	})
 	assert.True(t, skipIfError)
 	assert.NotNil(t, err)
@@ -349,12 +418,16 @@ func TestValidateTransferMessages(t *testing.T) {
 		isLocalToken:   true,
 		tokenWrapperId: localTokenWrapperId,
 	}
-	skipIfError, err := watcher.validateTransferMessage(transferMessage)
+	skipIfError, err := withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTransferMessage(batch, transferMessage)
+	})
 	assert.False(t, skipIfError)
 	assert.Nil(t, err)
 
 	transferMessage.tokenWrapperId = randomByte32()
-	skipIfError, err = watcher.validateTransferMessage(transferMessage)
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTransferMessage(batch, transferMessage)
+	})
 	assert.True(t, skipIfError)
 	assert.NotNil(t, err)
 
@@ -368,12 +441,16 @@ func TestValidateTransferMessages(t *testing.T) {
 		isLocalToken:   false,
 		tokenWrapperId: remoteTokenWrapperId,
 	}
-	skipIfError, err = watcher.validateTransferMessage(transferMessage)
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTransferMessage(batch, transferMessage)
+	})
 	assert.False(t, skipIfError)
 	assert.Nil(t, err)
 
 	transferMessage.tokenWrapperId = randomByte32()
-	skipIfError, err = watcher.validateTransferMessage(transferMessage)
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTransferMessage(batch, transferMessage)
+	})
 	assert.True(t, skipIfError)
 	assert.NotNil(t, err)
 }
@@ -394,18 +471,22 @@ func TestValidateTokenBridgeForChainCreatedEvent(t *testing.T) {
 	err = db.addRemoteChain(tokenBridgeForChainId, remoteChainId)
 	assert.Nil(t, err)
 
-	skipIfError, err := watcher.validateTokenBridgeForChainCreatedEvents(&tokenBridgeForChainCreated{
-		senderId:      watcher.tokenBridgeContractId,
-		contractId:    tokenBridgeForChainId,
-		remoteChainId: remoteChainId,
+	skipIfError, err := withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTokenBridgeForChainCreatedEvents(batch, &tokenBridgeForChainCreated{
+			senderId:      watcher.tokenBridgeContractId,
+			contractId:    tokenBridgeForChainId,
+			remoteChainId: remoteChainId,
+		})
 	})
 	assert.False(t, skipIfError)
 	assert.Nil(t, err)
 
-	skipIfError, err = watcher.validateTokenBridgeForChainCreatedEvents(&tokenBridgeForChainCreated{
-		senderId:      randomByte32(),
-		contractId:    randomByte32(),
-		remoteChainId: remoteChainId,
+	skipIfError, err = withBatch(t, db, func(batch *Batch) (bool, error) {
+		return watcher.validateTokenBridgeForChainCreatedEvents(batch, &tokenBridgeForChainCreated{
+			senderId:      randomByte32(),
+			contractId:    randomByte32(),
+			remoteChainId: remoteChainId,
+		})
 	})
 	assert.True(t, skipIfError)
 	assert.NotNil(t, err)
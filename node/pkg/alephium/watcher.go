@@ -2,6 +2,7 @@ package alephium
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
@@ -9,11 +10,13 @@ import (
 	"time"
 
 	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/notify"
 	"github.com/certusone/wormhole/node/pkg/p2p"
 	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
 	"github.com/certusone/wormhole/node/pkg/readiness"
 	"github.com/certusone/wormhole/node/pkg/supervisor"
 	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
@@ -21,8 +24,9 @@ import (
 const MaxForkHeight = uint32(100)
 
 type Watcher struct {
-	url    string
+	urls   []string
 	apiKey string
+	quorum int
 
 	governanceContractAddress     string
 	eventEmitterId                Byte32
@@ -43,7 +47,20 @@ type Watcher struct {
 	minConfirmations uint8
 	currentHeight    uint32
 
-	db *Database
+	// serializes the state changes of confirmed events and rollbacks
+	stateLock sync.Mutex
+	db        *Database
+
+	// Set once Run has recovered the pending events, HandleObservationRequest rejects
+	// requests until then and fetches the re-observed events with the client.
+	clientMu sync.Mutex
+	client   *Client
+	logger   *zap.Logger
+
+	health *watchers.HealthTracker
+
+	// notifier raises operator alerts, nil if alerting is disabled.
+	notifier notify.Notifier
 }
 
 type UnconfirmedEvent struct {
@@ -62,8 +79,9 @@ type ConfirmedEvents struct {
 }
 
 func NewAlephiumWatcher(
-	url string,
+	urls []string,
 	apiKey string,
+	quorum int,
 	fromGroup uint8,
 	toGroup uint8,
 	contracts []string,
@@ -72,14 +90,17 @@ func NewAlephiumWatcher(
 	minConfirmations uint64,
 	obsvReqC chan *gossipv1.ObservationRequest,
 	db *Database,
+	notifier notify.Notifier,
 ) (*Watcher, error) {
-	if len(contracts) != 3 {
-		return nil, fmt.Errorf("invalid contract ids")
+	// governance, event emitter, token bridge and token wrapper factory
+	if len(contracts) != 4 {
+		return nil, fmt.Errorf("expected 4 contract ids, got %d", len(contracts))
 	}
 
 	return &Watcher{
-		url:                           url,
+		urls:                          urls,
 		apiKey:                        apiKey,
+		quorum:                        quorum,
 		governanceContractAddress:     contracts[0],
 		eventEmitterId:                toContractId(contracts[1]),
 		tokenBridgeContractId:         toContractId(contracts[2]),
@@ -101,9 +122,20 @@ func NewAlephiumWatcher(
 
 		minConfirmations: uint8(minConfirmations),
 		db:               db,
+		logger:           zap.NewNop(),
+		health:           watchers.NewHealthTracker(vaa.ChainIDAlephium),
+		notifier:         notifier,
 	}, nil
 }
 
+func (w *Watcher) ChainID() vaa.ChainID {
+	return vaa.ChainIDAlephium
+}
+
+func (w *Watcher) Health() watchers.Health {
+	return w.health.Health()
+}
+
 func (w *Watcher) ContractServer(logger *zap.Logger, listenAddr string) (supervisor.Runnable, *grpc.Server, error) {
 	return contractServiceRunnable(w.db, listenAddr, logger)
 }
@@ -114,32 +146,44 @@ func (w *Watcher) Run(ctx context.Context) error {
 	})
 
 	logger := supervisor.Logger(ctx)
-	client := NewClient(w.url, w.apiKey, 10)
+	client, err := NewMultiEndpointClient(w.urls, w.apiKey, 10, w.quorum)
+	if err != nil {
+		logger.Error("failed to create client", zap.Error(err))
+		return err
+	}
 	nodeInfo, err := client.GetNodeInfo(ctx)
 	if err != nil {
 		logger.Error("failed to get node info", zap.Error(err))
+		w.health.AddError(err)
 		return err
 	}
-	logger.Info("alephium watcher started", zap.String("url", w.url), zap.String("version", nodeInfo.BuildInfo.ReleaseVersion))
+	logger.Info("alephium watcher started", zap.Strings("urls", w.urls), zap.Int("quorum", w.quorum), zap.String("version", nodeInfo.BuildInfo.ReleaseVersion))
 
 	eventEmitterAddress := ToContractAddress(w.eventEmitterId)
-	nextEventIndex, err := w.fetchEvents(ctx, logger, client, eventEmitterAddress)
+	nextEventIndex, pendingEvents, err := w.recoverPendingEvents(ctx, logger, client, eventEmitterAddress)
 	if err != nil {
-		logger.Error("failed to fetch events when recovery", zap.Error(err))
+		logger.Error("failed to recover pending events", zap.Error(err))
 		return err
 	}
 
 	readiness.SetReady(w.readiness)
 	errC := make(chan error)
 
-	go w.handleObsvRequest(ctx, logger, client)
+	w.clientMu.Lock()
+	w.client = client
+	w.logger = logger
+	w.clientMu.Unlock()
+
+	go watchers.HandleObservationRequests(ctx, logger, w, w.obsvReqC)
 	go w.fetchHeight(ctx, logger, client, errC)
-	go w.subscribe(ctx, logger, client, eventEmitterAddress, *nextEventIndex, w.toUnconfirmedEvent, w.handleEvents, errC)
+	go w.checkReorgs(ctx, logger, client, errC)
+	go w.subscribe(ctx, logger, client, eventEmitterAddress, *nextEventIndex, pendingEvents, w.toUnconfirmedEvent, w.handleEvents, errC)
 
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	case err := <-errC:
+		w.health.AddError(err)
 		return err
 	}
 }
@@ -161,6 +205,7 @@ func (w *Watcher) fetchHeight(ctx context.Context, logger *zap.Logger, client *C
 			}
 
 			atomic.StoreUint32(&w.currentHeight, height)
+			w.health.SetHeight(uint64(height))
 		}
 	}
 }
@@ -174,84 +219,111 @@ func (w *Watcher) handleEvents(logger *zap.Logger, confirmed *ConfirmedEvents, s
 		return confirmed.events[i].eventIndex < confirmed.events[j].eventIndex
 	})
 
-	// TODO: batch write to db
 	for _, events := range confirmed.events {
-		for _, e := range events.events {
-			logger.Debug("new confirmed event received", zap.String("event", e.event.ToString()))
-
-			var skipIfError bool
-			var validateErr error
-			switch e.event.EventIndex {
-			case WormholeMessageEventIndex:
-				if skipWormholeMessage {
-					continue
-				}
-				event, err := e.event.ToWormholeMessage()
-				if err != nil {
-					logger.Error("ignore invalid wormhole message", zap.Error(err), zap.String("event", e.event.ToString()))
-					continue
-				}
-				skipIfError, validateErr = w.validateGovernanceMessages(event)
-				if validateErr == nil {
-					w.msgChan <- event.toMessagePublication(e.blockHeader)
-				}
-
-			case TokenBridgeForChainCreatedEventIndex:
-				event, err := e.event.toTokenBridgeForChainCreatedEvent()
-				if err != nil {
-					logger.Error("ignore invalid token bridge for chain created event", zap.Error(err), zap.String("event", e.event.ToString()))
-					continue
-				}
-				skipIfError, validateErr = w.validateTokenBridgeForChainCreatedEvents(event)
-
-			case TokenWrapperCreatedEventIndex:
-				event, err := e.event.toTokenWrapperCreatedEvent()
-				if err != nil {
-					logger.Error("ignore invalid token wrapper created event", zap.Error(err), zap.String("event", e.event.ToString()))
-					continue
-				}
-				skipIfError, validateErr = w.validateTokenWrapperCreatedEvent(event)
+		if err := w.handleEventsOfBlock(logger, events, skipWormholeMessage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			case UndoneSequencesRemovedEventIndex:
-				event, err := e.event.toUndoneSequencesRemoved()
-				if err != nil {
-					logger.Error("ignore invalid undone sequences removed event", zap.Error(err), zap.String("event", e.event.ToString()))
-					continue
-				}
-				skipIfError, validateErr = w.validateUndoneSequencesRemovedEvents(event, w.getRemoteChainId)
+// handleEventsOfBlock commits all state changes of the confirmed events together with
+// the new event index in a single batch, so either all of them are applied or none.
+// The wormhole messages are published only after the batch has been committed.
+func (w *Watcher) handleEventsOfBlock(logger *zap.Logger, events *UnconfirmedEvents, skipWormholeMessage bool) error {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	batch := w.db.NewBatch()
+	defer batch.Discard()
+
+	messages := make([]*common.MessagePublication, 0)
+	for _, e := range events.events {
+		logger.Debug("new confirmed event received", zap.String("event", e.event.ToString()))
+		batch.trackBlock(e.blockHeader)
+
+		var skipIfError bool
+		var validateErr error
+		switch e.event.EventIndex {
+		case WormholeMessageEventIndex:
+			if skipWormholeMessage {
+				continue
+			}
+			event, err := e.event.ToWormholeMessage()
+			if err != nil {
+				logger.Error("ignore invalid wormhole message", zap.Error(err), zap.String("event", e.event.ToString()))
+				continue
+			}
+			skipIfError, validateErr = w.validateGovernanceMessages(batch, event)
+			if validateErr == nil {
+				batch.recordMessage(event.Sequence)
+				messages = append(messages, event.toMessagePublication(e.blockHeader))
+			}
 
-			case UndoneSequenceCompletedEventIndex:
-				event, err := e.event.toUndoneSequenceCompleted()
-				if err != nil {
-					logger.Error("ignore invalid undone sequence completed event", zap.Error(err), zap.String("event", e.event.ToString()))
-					continue
-				}
-				skipIfError, validateErr = w.validateUndoneSequenceCompletedEvents(event)
+		case TokenBridgeForChainCreatedEventIndex:
+			event, err := e.event.toTokenBridgeForChainCreatedEvent()
+			if err != nil {
+				logger.Error("ignore invalid token bridge for chain created event", zap.Error(err), zap.String("event", e.event.ToString()))
+				continue
+			}
+			skipIfError, validateErr = w.validateTokenBridgeForChainCreatedEvents(batch, event)
 
-			default:
-				return fmt.Errorf("unknown event index %v", e.event.EventIndex)
+		case TokenWrapperCreatedEventIndex:
+			event, err := e.event.toTokenWrapperCreatedEvent()
+			if err != nil {
+				logger.Error("ignore invalid token wrapper created event", zap.Error(err), zap.String("event", e.event.ToString()))
+				continue
 			}
+			skipIfError, validateErr = w.validateTokenWrapperCreatedEvent(batch, event)
 
-			if validateErr != nil && skipIfError {
-				logger.Error("ignore invalid event", zap.Error(validateErr))
+		case UndoneSequencesRemovedEventIndex:
+			event, err := e.event.toUndoneSequencesRemoved()
+			if err != nil {
+				logger.Error("ignore invalid undone sequences removed event", zap.Error(err), zap.String("event", e.event.ToString()))
 				continue
 			}
-			if validateErr != nil && !skipIfError {
-				logger.Error("failed to validate event", zap.Error(validateErr))
-				return validateErr
+			skipIfError, validateErr = w.validateUndoneSequencesRemovedEvents(batch, event, w.getRemoteChainId)
+
+		case UndoneSequenceCompletedEventIndex:
+			event, err := e.event.toUndoneSequenceCompleted()
+			if err != nil {
+				logger.Error("ignore invalid undone sequence completed event", zap.Error(err), zap.String("event", e.event.ToString()))
+				continue
 			}
+			skipIfError, validateErr = w.validateUndoneSequenceCompletedEvents(batch, event)
+
+		default:
+			return fmt.Errorf("unknown event index %v", e.event.EventIndex)
 		}
 
-		if err := w.db.updateLastEventIndex(events.eventIndex); err != nil {
-			logger.Error("failed to save last event index", zap.Error(err))
-			return err
+		if validateErr != nil && skipIfError {
+			logger.Error("ignore invalid event", zap.Error(validateErr))
+			continue
+		}
+		if validateErr != nil && !skipIfError {
+			logger.Error("failed to validate event", zap.Error(validateErr))
+			return validateErr
 		}
 	}
+
+	// the event index is not rolled back, the events from the new main chain blocks have new indexes
+	batch.untrack()
+	if err := batch.updateLastEventIndex(events.eventIndex); err != nil {
+		logger.Error("failed to save last event index", zap.Error(err))
+		return err
+	}
+	if err := batch.Commit(); err != nil {
+		logger.Error("failed to commit confirmed events", zap.Error(err), zap.Uint64("eventIndex", events.eventIndex))
+		return err
+	}
+
+	for _, msg := range messages {
+		w.msgChan <- msg
+	}
 	return nil
 }
 
 func (w *Watcher) toUnconfirmedEvent(ctx context.Context, client *Client, event *Event) (*UnconfirmedEvent, error) {
-	// TODO: LRU cache
 	header, err := client.GetBlockHeader(ctx, event.BlockHash)
 	if err != nil {
 		return nil, err
@@ -279,11 +351,12 @@ func (w *Watcher) subscribe(
 	client *Client,
 	contractAddress string,
 	fromIndex uint64,
+	pendingEvents map[string]*UnconfirmedEvents,
 	toUnconfirmed func(context.Context, *Client, *Event) (*UnconfirmedEvent, error),
 	handler func(*zap.Logger, *ConfirmedEvents, bool) error,
 	errC chan<- error,
 ) {
-	w.subscribe_(ctx, logger, client, contractAddress, fromIndex, toUnconfirmed, handler, 30*time.Second, errC)
+	w.subscribe_(ctx, logger, client, contractAddress, fromIndex, pendingEvents, toUnconfirmed, handler, 30*time.Second, errC)
 }
 
 func (w *Watcher) subscribe_(
@@ -292,12 +365,12 @@ func (w *Watcher) subscribe_(
 	client *Client,
 	contractAddress string,
 	fromIndex uint64,
+	pendingEvents map[string]*UnconfirmedEvents,
 	toUnconfirmed func(context.Context, *Client, *Event) (*UnconfirmedEvent, error),
 	handler func(*zap.Logger, *ConfirmedEvents, bool) error,
 	tickDuration time.Duration,
 	errC chan<- error,
 ) {
-	pendingEvents := map[string]*UnconfirmedEvents{}
 	nextIndex := fromIndex
 	lastHeight := atomic.LoadUint32(&w.currentHeight)
 
@@ -311,15 +384,25 @@ func (w *Watcher) subscribe_(
 		}
 
 		confirmedEvents := make([]*UnconfirmedEvents, 0)
+		updated := make(map[string]*UnconfirmedEvents)
+		removed := make([]string, 0)
 		for blockHash, unconfirmedEvents := range pendingEvents {
 			isCanonical, err := client.IsBlockInMainChain(ctx, blockHash)
+			if errors.Is(err, ErrQuorumMismatch) {
+				// the full nodes might not be synced, check it again in the next round
+				logger.Warn("full nodes disagree on mainchain block", zap.String("blockHash", blockHash), zap.Error(err))
+				continue
+			}
 			if err != nil {
 				logger.Error("failed to check mainchain block", zap.Error(err))
 				return err
 			}
 			if !isCanonical {
+				logger.Info("fork detected, remove events from orphan block", zap.String("blockHash", blockHash))
+				client.InvalidateCache()
 				// it's safe to update map in range loop
 				delete(pendingEvents, blockHash)
+				removed = append(removed, blockHash)
 				continue
 			}
 
@@ -327,7 +410,7 @@ func (w *Watcher) subscribe_(
 			remain := make([]*UnconfirmedEvent, 0)
 			for _, event := range unconfirmedEvents.events {
 				if event.blockHeader.Height+uint32(event.confirmations) > height {
-					remain = append(confirmed, event)
+					remain = append(remain, event)
 					continue
 				}
 				confirmed = append(confirmed, event)
@@ -339,11 +422,13 @@ func (w *Watcher) subscribe_(
 
 			if len(remain) == 0 {
 				delete(pendingEvents, blockHash)
+				removed = append(removed, blockHash)
 			} else {
 				pendingEvents[blockHash] = &UnconfirmedEvents{
 					events:     remain,
 					eventIndex: unconfirmedEvents.eventIndex,
 				}
+				updated[blockHash] = pendingEvents[blockHash]
 			}
 
 			confirmedEvents = append(confirmedEvents, &UnconfirmedEvents{
@@ -351,12 +436,20 @@ func (w *Watcher) subscribe_(
 				eventIndex: unconfirmedEvents.eventIndex,
 			})
 		}
-		if len(confirmedEvents) == 0 {
+		if len(confirmedEvents) != 0 {
+			confirmed := &ConfirmedEvents{confirmedEvents}
+			if err := handler(logger, confirmed, false); err != nil {
+				logger.Error("failed to handle confirmed events", zap.Error(err), zap.String("contractAddress", contractAddress))
+				return err
+			}
+		}
+		if len(updated) == 0 && len(removed) == 0 {
 			return nil
 		}
-		confirmed := &ConfirmedEvents{confirmedEvents}
-		if err := handler(logger, confirmed, false); err != nil {
-			logger.Error("failed to handle confirmed events", zap.Error(err), zap.String("contractAddress", contractAddress))
+		// the confirmed events are removed from db only after they have been handled,
+		// so events will be handled again rather than lost if we crash in between
+		if err := w.db.updatePendingEvents(nil, updated, removed); err != nil {
+			logger.Error("failed to update pending events", zap.Error(err), zap.String("contractAddress", contractAddress))
 			return err
 		}
 		return nil
@@ -384,20 +477,42 @@ func (w *Watcher) subscribe_(
 			}
 
 			events, err := client.GetContractEventsByRange(ctx, contractAddress, nextIndex, *count)
+			if errors.Is(err, ErrQuorumMismatch) {
+				// the full nodes might not be synced, fetch the events again in the next round
+				logger.Warn("full nodes disagree on contract events", zap.Uint64("from", nextIndex), zap.Uint64("to", *count), zap.Error(err))
+				continue
+			}
 			if err != nil {
 				logger.Error("failed to get contract events", zap.Uint64("from", nextIndex), zap.Uint64("to", *count), zap.Error(err))
 				errC <- err
 				return
 			}
 
-			eventIndex := nextIndex
+			// convert all events before updating the pending events, so that the events can be fetched again
+			// in the next round if the full nodes disagree on a block header
+			unconfirmedEvents := make([]*UnconfirmedEvent, 0, len(events.Events))
+			var mismatch error
 			for _, event := range events.Events {
 				unconfirmed, err := toUnconfirmed(ctx, client, event)
+				if errors.Is(err, ErrQuorumMismatch) {
+					mismatch = err
+					break
+				}
 				if err != nil {
 					logger.Error("failed to convert to unconfirmed event", zap.Error(err))
 					errC <- err
 					return
 				}
+				unconfirmedEvents = append(unconfirmedEvents, unconfirmed)
+			}
+			if mismatch != nil {
+				logger.Warn("full nodes disagree on block header", zap.Uint64("from", nextIndex), zap.Uint64("to", *count), zap.Error(mismatch))
+				continue
+			}
+
+			eventIndex := nextIndex
+			updated := make(map[string]*UnconfirmedEvents)
+			for _, unconfirmed := range unconfirmedEvents {
 				blockHash := unconfirmed.event.BlockHash
 				if lst, ok := pendingEvents[blockHash]; ok {
 					lst.events = append(lst.events, unconfirmed)
@@ -408,8 +523,14 @@ func (w *Watcher) subscribe_(
 					}
 					eventIndex += 1
 				}
+				updated[blockHash] = pendingEvents[blockHash]
 			}
 
+			if err := w.db.updatePendingEvents(count, updated, nil); err != nil {
+				logger.Error("failed to persist pending events", zap.Error(err), zap.String("contractAddress", contractAddress))
+				errC <- err
+				return
+			}
 			nextIndex = *count
 			if err := process(); err != nil {
 				errC <- err
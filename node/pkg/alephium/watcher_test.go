@@ -2,16 +2,20 @@ package alephium
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/dgraph-io/badger/v3"
 	"github.com/go-test/deep"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
@@ -36,10 +40,25 @@ func TestSubscribeEvents(t *testing.T) {
 	// event from orphan block
 	event2 := randomEvent(0)
 
+	// events is read by the server and confirmedEvents is written by the handler, both outside of the test goroutine
+	var mu sync.Mutex
 	events := make([]*Event, 0)
+	confirmedEvents := make([]*UnconfirmedEvents, 0)
+	addEvent := func(event *Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	getConfirmed := func() []*UnconfirmedEvents {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*UnconfirmedEvents{}, confirmedEvents...)
+	}
 	isCanonicalBlock := uint32(1)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
 		if r.RequestURI == eventCountURI(contractAddress) {
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(len(events))
@@ -74,8 +93,12 @@ func TestSubscribeEvents(t *testing.T) {
 	logger := zap.NewNop()
 	client := NewClient(server.URL, "", 10)
 	errC := make(chan error)
+	db, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	defer db.Close()
 	watcher := &Watcher{
 		currentHeight: 0,
+		db:            db,
 	}
 
 	toUnconfirmed := func(ctx context.Context, client *Client, event *Event) (*UnconfirmedEvent, error) {
@@ -90,44 +113,411 @@ func TestSubscribeEvents(t *testing.T) {
 		}, nil
 	}
 
-	confirmedEvents := make([]*UnconfirmedEvents, 0)
 	handler := func(logger *zap.Logger, confirmed *ConfirmedEvents, b bool) error {
+		mu.Lock()
+		defer mu.Unlock()
 		confirmedEvents = append(confirmedEvents, confirmed.events...)
 		return nil
 	}
 
-	go watcher.subscribe_(ctx, logger, client, contractAddress, eventCount, toUnconfirmed, handler, 500*time.Millisecond, errC)
+	pendingEvents := map[string]*UnconfirmedEvents{}
+	go watcher.subscribe_(ctx, logger, client, contractAddress, eventCount, pendingEvents, toUnconfirmed, handler, 500*time.Millisecond, errC)
 
 	time.Sleep(1 * time.Second)
-	assert.True(t, len(confirmedEvents) == 0)
+	assert.True(t, len(getConfirmed()) == 0)
 
 	// event0 confirmed
 	atomic.StoreUint32(&watcher.currentHeight, 1)
-	events = append(events, event0)
+	addEvent(event0)
 	time.Sleep(1 * time.Second)
-	assert.True(t, len(confirmedEvents) == 1)
-	assert.True(t, len(confirmedEvents[0].events) == 1)
-	assert.Equal(t, confirmedEvents[0].eventIndex, uint64(0))
-	diff := deep.Equal(confirmedEvents[0].events[0].event, event0)
+	confirmed := getConfirmed()
+	assert.True(t, len(confirmed) == 1)
+	assert.True(t, len(confirmed[0].events) == 1)
+	assert.Equal(t, confirmed[0].eventIndex, uint64(0))
+	diff := deep.Equal(confirmed[0].events[0].event, event0)
 	assert.Nil(t, diff)
 
 	// event1 not confirmed
-	events = append(events, event1)
+	addEvent(event1)
 	time.Sleep(1 * time.Second)
-	assert.True(t, len(confirmedEvents) == 1)
+	assert.True(t, len(getConfirmed()) == 1)
+	persisted, err := db.getPendingEvents()
+	assert.Nil(t, err)
+	assert.Equal(t, len(persisted), 1)
+	assert.Nil(t, deep.Equal(persisted[event1.BlockHash].events[0].event, event1))
+	nextIndex, err := db.getNextEventIndex()
+	assert.Nil(t, err)
+	assert.Equal(t, *nextIndex, uint64(2))
 
 	// event1 confirmed
 	atomic.StoreUint32(&watcher.currentHeight, 3)
 	time.Sleep(1 * time.Second)
-	assert.True(t, len(confirmedEvents) == 2)
-	assert.True(t, len(confirmedEvents[1].events) == 1)
-	assert.Equal(t, confirmedEvents[1].eventIndex, uint64(1))
-	diff = deep.Equal(confirmedEvents[1].events[0].event, event1)
+	confirmed = getConfirmed()
+	assert.True(t, len(confirmed) == 2)
+	assert.True(t, len(confirmed[1].events) == 1)
+	assert.Equal(t, confirmed[1].eventIndex, uint64(1))
+	diff = deep.Equal(confirmed[1].events[0].event, event1)
 	assert.Nil(t, diff)
+	persisted, err = db.getPendingEvents()
+	assert.Nil(t, err)
+	assert.Equal(t, len(persisted), 0)
 
 	// event2
 	atomic.StoreUint32(&isCanonicalBlock, 0)
-	events = append(events, event2)
+	addEvent(event2)
 	time.Sleep(1 * time.Second)
-	assert.True(t, len(confirmedEvents) == 2)
+	assert.True(t, len(getConfirmed()) == 2)
+	persisted, err = db.getPendingEvents()
+	assert.Nil(t, err)
+	assert.Equal(t, len(persisted), 0)
+}
+
+// replayServer serves the event count and reports every block as canonical. It records the requests, so that tests
+// can check that no events or blocks were fetched.
+type replayServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+func startReplayServer(t *testing.T, contractAddress string, eventCount uint64) *replayServer {
+	s := &replayServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.RequestURI)
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.RequestURI == eventCountURI(contractAddress) {
+			json.NewEncoder(w).Encode(eventCount)
+			return
+		}
+		if strings.HasPrefix(r.RequestURI, "/blockflow/is-block-in-main-chain") {
+			json.NewEncoder(w).Encode(true)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *replayServer) polled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests) != 0
+}
+
+func (s *replayServer) fetchedEventsOrBlocks() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, request := range s.requests {
+		if strings.HasPrefix(request, "/events/contract?start=") || strings.HasPrefix(request, "/blockflow/blocks") {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribeConfirmed runs the subscription until the end of the test and returns the channel of confirmed events.
+func subscribeConfirmed(t *testing.T, watcher *Watcher, client *Client, contractAddress string, fromIndex uint64, pendingEvents map[string]*UnconfirmedEvents) <-chan *UnconfirmedEvents {
+	confirmedC := make(chan *UnconfirmedEvents, 10)
+	handler := func(logger *zap.Logger, confirmed *ConfirmedEvents, b bool) error {
+		for _, events := range confirmed.events {
+			confirmedC <- events
+		}
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	errC := make(chan error, 1)
+	go watcher.subscribe_(ctx, zap.NewNop(), client, contractAddress, fromIndex, pendingEvents, nil, handler, 100*time.Millisecond, errC)
+	return confirmedC
+}
+
+func TestReplayPendingEvents(t *testing.T) {
+	contractAddress := randomAddress()
+	db, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	defer db.Close()
+
+	event := &Event{
+		BlockHash:       randomByte32().ToHex(),
+		ContractAddress: contractAddress,
+		TxId:            randomByte32().ToHex(),
+		EventIndex:      0,
+		Fields:          []*Field{fieldFromBigInt(big.NewInt(2))},
+	}
+	header := &BlockHeader{
+		Hash:      event.BlockHash,
+		Timestamp: 1000,
+		Height:    10,
+	}
+	nextIndex := uint64(4)
+	err = db.updatePendingEvents(&nextIndex, map[string]*UnconfirmedEvents{
+		event.BlockHash: {
+			eventIndex: 3,
+			events: []*UnconfirmedEvent{{
+				blockHeader:   header,
+				event:         event,
+				confirmations: 2,
+			}},
+		},
+	}, nil)
+	assert.Nil(t, err)
+
+	server := startReplayServer(t, contractAddress, nextIndex)
+	watcher := &Watcher{db: db}
+	client := NewClient(server.URL, "", 10)
+	fromIndex, pendingEvents, err := watcher.recoverPendingEvents(context.Background(), zap.NewNop(), client, contractAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, *fromIndex, nextIndex)
+	assert.Equal(t, len(pendingEvents), 1)
+	assert.False(t, server.fetchedEventsOrBlocks())
+
+	replayed := pendingEvents[event.BlockHash]
+	assert.Equal(t, replayed.eventIndex, uint64(3))
+	assert.Nil(t, deep.Equal(replayed.events[0].event, event))
+	assert.Equal(t, *replayed.events[0].blockHeader, *header)
+	assert.Equal(t, replayed.events[0].confirmations, uint8(2))
+
+	confirmedC := subscribeConfirmed(t, watcher, client, contractAddress, *fromIndex, pendingEvents)
+	select {
+	case <-confirmedC:
+		t.Fatal("event confirmed before the block had enough confirmations")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// the replayed event is confirmed without fetching it again
+	atomic.StoreUint32(&watcher.currentHeight, 12)
+	select {
+	case confirmed := <-confirmedC:
+		assert.Nil(t, deep.Equal(confirmed.events[0].event, event))
+	case <-time.After(5 * time.Second):
+		t.Fatal("replayed event wasn't confirmed")
+	}
+	assert.False(t, server.fetchedEventsOrBlocks())
+	assert.Eventually(t, func() bool {
+		persisted, err := db.getPendingEvents()
+		return err == nil && len(persisted) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPartiallyConfirmedBlock(t *testing.T) {
+	contractAddress := randomAddress()
+	db, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	defer db.Close()
+
+	blockHash := randomByte32().ToHex()
+	header := &BlockHeader{Hash: blockHash, Timestamp: 1000, Height: 10}
+	pendingEvent := func(confirmations uint8) *UnconfirmedEvent {
+		return &UnconfirmedEvent{
+			blockHeader: header,
+			event: &Event{
+				BlockHash:       blockHash,
+				ContractAddress: contractAddress,
+				TxId:            randomByte32().ToHex(),
+				Fields:          []*Field{fieldFromBigInt(big.NewInt(int64(confirmations)))},
+			},
+			confirmations: confirmations,
+		}
+	}
+	// the events of a block can require different numbers of confirmations
+	first, confirmed, last := pendingEvent(4), pendingEvent(1), pendingEvent(5)
+	nextIndex := uint64(1)
+	pendingEvents := map[string]*UnconfirmedEvents{
+		blockHash: {eventIndex: 0, events: []*UnconfirmedEvent{first, confirmed, last}},
+	}
+	assert.Nil(t, db.updatePendingEvents(&nextIndex, pendingEvents, nil))
+
+	server := startReplayServer(t, contractAddress, nextIndex)
+	watcher := &Watcher{db: db}
+	atomic.StoreUint32(&watcher.currentHeight, 11)
+	confirmedC := subscribeConfirmed(t, watcher, NewClient(server.URL, "", 10), contractAddress, nextIndex, pendingEvents)
+	// the subscription has loaded the start height once it polls the event count
+	assert.Eventually(t, server.polled, 5*time.Second, 10*time.Millisecond)
+	atomic.StoreUint32(&watcher.currentHeight, 12)
+
+	select {
+	case events := <-confirmedC:
+		assert.Equal(t, 1, len(events.events))
+		assert.Nil(t, deep.Equal(events.events[0].event, confirmed.event))
+	case <-time.After(5 * time.Second):
+		t.Fatal("event wasn't confirmed")
+	}
+
+	// both unconfirmed events stay pending
+	assert.Eventually(t, func() bool {
+		persisted, err := db.getPendingEvents()
+		if err != nil || len(persisted[blockHash].events) != 2 {
+			return false
+		}
+		remain := persisted[blockHash].events
+		return deep.Equal(remain[0].event, first.event) == nil && deep.Equal(remain[1].event, last.event) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSubscribeCachesPendingBlocks(t *testing.T) {
+	contractAddress := randomAddress()
+	chainIndex := &ChainIndex{FromGroup: 0, ToGroup: 0}
+	header := &BlockHeader{Hash: randomByte32().ToHex(), Height: 10}
+	event := &Event{
+		BlockHash:       header.Hash,
+		ContractAddress: contractAddress,
+		TxId:            randomByte32().ToHex(),
+		EventIndex:      0,
+	}
+	currentHeight := uint32(11)
+	requests := int32(0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.RequestURI == eventCountURI(contractAddress):
+			json.NewEncoder(w).Encode(1)
+		case strings.HasPrefix(r.RequestURI, "/events/contract?start="):
+			json.NewEncoder(w).Encode(&Events{Events: []*Event{event}})
+		case strings.HasPrefix(r.RequestURI, "/blockflow/chain-info"):
+			json.NewEncoder(w).Encode(map[string]uint32{"currentHeight": atomic.LoadUint32(&currentHeight)})
+		case strings.HasPrefix(r.RequestURI, "/blockflow/blocks"):
+			json.NewEncoder(w).Encode(header)
+		case strings.HasPrefix(r.RequestURI, "/blockflow/is-block-in-main-chain"):
+			atomic.AddInt32(&requests, 1)
+			json.NewEncoder(w).Encode(true)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	defer db.Close()
+	client := NewClient(server.URL, "", 10)
+	watcher := &Watcher{db: db}
+	updateHeight := func() {
+		height, err := client.GetCurrentHeight(ctx, chainIndex)
+		assert.Nil(t, err)
+		atomic.StoreUint32(&watcher.currentHeight, height)
+	}
+
+	// the event stays pending for the whole test
+	toUnconfirmed := func(ctx context.Context, client *Client, event *Event) (*UnconfirmedEvent, error) {
+		header, err := client.GetBlockHeader(ctx, event.BlockHash)
+		assert.Nil(t, err)
+		return &UnconfirmedEvent{blockHeader: header, event: event, confirmations: 50}, nil
+	}
+	handler := func(logger *zap.Logger, confirmed *ConfirmedEvents, b bool) error {
+		assert.Fail(t, "unexpected confirmed events")
+		return nil
+	}
+
+	errC := make(chan error)
+	go watcher.subscribe_(ctx, zap.NewNop(), client, contractAddress, 0, map[string]*UnconfirmedEvents{}, toUnconfirmed, handler, 200*time.Millisecond, errC)
+	updateHeight()
+
+	// the pending block is checked once while the height doesn't change
+	time.Sleep(700 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// and checked again on the next ticks once it changed
+	atomic.StoreUint32(&currentHeight, 12)
+	updateHeight()
+	time.Sleep(700 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestHandleEventsAtomically(t *testing.T) {
+	db, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	defer db.Close()
+
+	watcher := &Watcher{
+		tokenBridgeContractId: randomByte32(),
+		db:                    db,
+		msgChan:               make(chan *common.MessagePublication, 1),
+	}
+	lastEventIndex := uint64(1)
+	err = db.updateLastEventIndex(lastEventIndex)
+	assert.Nil(t, err)
+
+	remoteChainId := uint16(2)
+	tokenBridgeForChainId := randomByte32()
+	sequences := append(Uint64ToBytes(3), Uint64ToBytes(4)...)
+	header := &BlockHeader{Hash: randomByte32().ToHex()}
+	tokenBridgeForChainCreated := &UnconfirmedEvent{
+		blockHeader: header,
+		event: &Event{
+			BlockHash:  header.Hash,
+			EventIndex: TokenBridgeForChainCreatedEventIndex,
+			Fields: []*Field{
+				{Type: "ByteVec", Value: watcher.tokenBridgeContractId.ToHex()},
+				{Type: "ByteVec", Value: tokenBridgeForChainId.ToHex()},
+				{Type: "U256", Value: strconv.Itoa(int(remoteChainId))},
+			},
+		},
+	}
+	undoneSequencesRemoved := &UnconfirmedEvent{
+		blockHeader: header,
+		event: &Event{
+			BlockHash:  header.Hash,
+			EventIndex: UndoneSequencesRemovedEventIndex,
+			Fields: []*Field{
+				{Type: "ByteVec", Value: tokenBridgeForChainId.ToHex()},
+				{Type: "ByteVec", Value: hex.EncodeToString(sequences)},
+			},
+		},
+	}
+	// the unknown event fails the batch after the previous events have been written to the batch
+	invalidEvent := &UnconfirmedEvent{
+		blockHeader: header,
+		event: &Event{
+			BlockHash:  header.Hash,
+			EventIndex: 99,
+		},
+	}
+
+	confirmed := &ConfirmedEvents{[]*UnconfirmedEvents{{
+		eventIndex: 2,
+		events:     []*UnconfirmedEvent{tokenBridgeForChainCreated, undoneSequencesRemoved, invalidEvent},
+	}}}
+	err = watcher.handleEvents(zap.NewNop(), confirmed, false)
+	assert.Equal(t, err.Error(), "unknown event index 99")
+
+	_, err = db.getTokenBridgeForChain(remoteChainId)
+	assert.Equal(t, err, badger.ErrKeyNotFound)
+	_, err = db.getRemoteChainId(tokenBridgeForChainId)
+	assert.Equal(t, err, badger.ErrKeyNotFound)
+	undoneSequences, err := db.GetUndoneSequences(remoteChainId)
+	assert.Nil(t, err)
+	assert.Equal(t, len(undoneSequences), 0)
+	index, err := db.getLastEventIndex()
+	assert.Nil(t, err)
+	assert.Equal(t, *index, lastEventIndex)
+	_, ok := watcher.tokenBridgeForChainCache.Load(remoteChainId)
+	assert.False(t, ok)
+	_, ok = watcher.remoteChainIdCache.Load(tokenBridgeForChainId)
+	assert.False(t, ok)
+
+	confirmed = &ConfirmedEvents{[]*UnconfirmedEvents{{
+		eventIndex: 2,
+		events:     []*UnconfirmedEvent{tokenBridgeForChainCreated, undoneSequencesRemoved},
+	}}}
+	err = watcher.handleEvents(zap.NewNop(), confirmed, false)
+	assert.Nil(t, err)
+
+	contractId, err := db.getTokenBridgeForChain(remoteChainId)
+	assert.Nil(t, err)
+	assert.Equal(t, *contractId, tokenBridgeForChainId)
+	undoneSequences, err = db.GetUndoneSequences(remoteChainId)
+	assert.Nil(t, err)
+	assert.Equal(t, len(undoneSequences), 2)
+	index, err = db.getLastEventIndex()
+	assert.Nil(t, err)
+	assert.Equal(t, *index, uint64(2))
+	cached, ok := watcher.tokenBridgeForChainCache.Load(remoteChainId)
+	assert.True(t, ok)
+	assert.Equal(t, *cached.(*Byte32), tokenBridgeForChainId)
 }
@@ -0,0 +1,22 @@
+package algorand
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/certusone/wormhole/node/pkg/watchers"
+)
+
+// Factory creates the watcher of an Algorand chain entry. The contract is the application ID of the
+// core contract.
+func Factory(c *watchers.ChainConfig, deps *watchers.Deps) (watchers.Watcher, error) {
+	if c.IndexerRPC == "" {
+		return nil, errors.New("missing indexer endpoint")
+	}
+	appID, err := strconv.ParseUint(c.PrimaryContract(), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contract %s, expected an application ID", c.PrimaryContract())
+	}
+	return NewWatcher(c.PrimaryRPC(), c.APIKey, c.IndexerRPC, c.IndexerAPIKey, appID, deps.MsgC, deps.SetC, deps.ObsvReqC), nil
+}
@@ -2,29 +2,469 @@ package algorand
 
 import (
 	"context"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
 	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/p2p"
+	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
 	"github.com/certusone/wormhole/node/pkg/readiness"
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
+	eth_common "github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+const (
+	// publishMessageMethod is the first application argument of the core contract calls which publish a message
+	publishMessageMethod = "publishMessage"
+
+	algodTokenHeader   = "X-Algo-API-Token"
+	indexerTokenHeader = "X-Indexer-API-Token"
+
+	addressChecksumSize = 4
+)
+
+var (
+	algorandConnectionErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_algorand_connection_errors_total",
+			Help: "Total number of Algorand connection errors",
+		}, []string{"reason"})
+	algorandMessagesConfirmed = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_algorand_messages_confirmed_total",
+			Help: "Total number of verified algorand messages found",
+		})
+	currentAlgorandHeight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "wormhole_algorand_current_height",
+			Help: "Current algorand round reported by algod",
+		})
+	processedAlgorandHeight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "wormhole_algorand_processed_height",
+			Help: "Last algorand round which has been scanned for messages",
+		})
+	queryLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "wormhole_algorand_query_latency",
+			Help: "Latency histogram for algorand RPC calls",
+		}, []string{"operation"})
 )
 
 type (
 	// Watcher is responsible for looking over Algorand blockchain and reporting new transactions to the contract
 	Watcher struct {
-		urlRPC   string
-		urlToken string
-		contract string
+		algodRPC     string
+		algodToken   string
+		indexerRPC   string
+		indexerToken string
+		appID        uint64
 
 		msgChan chan *common.MessagePublication
 		setChan chan *common.GuardianSet
+
+		// Incoming re-observation requests from the network. Pre-filtered to only
+		// include requests for our chainID.
+		obsvReqC chan *gossipv1.ObservationRequest
+
+		client       *http.Client
+		pollInterval time.Duration
+
+		// Set by Run. HandleObservationRequest publishes the messages of the requested
+		// transaction with it, and may run before Run has started.
+		clientMu sync.Mutex
+		logger   *zap.Logger
+
+		health *watchers.HealthTracker
+	}
+
+	// transaction is the indexer representation of a transaction, inner transactions
+	// have neither an id nor a round time, they are inherited from the root transaction
+	transaction struct {
+		ID                     string                  `json:"id"`
+		Sender                 string                  `json:"sender"`
+		TxType                 string                  `json:"tx-type"`
+		ConfirmedRound         uint64                  `json:"confirmed-round"`
+		RoundTime              int64                   `json:"round-time"`
+		ApplicationTransaction *applicationTransaction `json:"application-transaction"`
+		Logs                   [][]byte                `json:"logs"`
+		InnerTxns              []*transaction          `json:"inner-txns"`
+	}
+
+	applicationTransaction struct {
+		ApplicationID   uint64   `json:"application-id"`
+		ApplicationArgs [][]byte `json:"application-args"`
 	}
 )
 
 // NewWatcher creates a new Algorand contract watcher
-func NewWatcher(urlRPC string, urlToken string, contract string, lockEvents chan *common.MessagePublication, setEvents chan *common.GuardianSet) *Watcher {
-	return &Watcher{urlRPC: urlRPC, urlToken: urlToken, contract: contract, msgChan: lockEvents, setChan: setEvents}
+func NewWatcher(
+	algodRPC string,
+	algodToken string,
+	indexerRPC string,
+	indexerToken string,
+	appID uint64,
+	lockEvents chan *common.MessagePublication,
+	setEvents chan *common.GuardianSet,
+	obsvReqC chan *gossipv1.ObservationRequest) *Watcher {
+	return &Watcher{
+		algodRPC:     algodRPC,
+		algodToken:   algodToken,
+		indexerRPC:   indexerRPC,
+		indexerToken: indexerToken,
+		appID:        appID,
+		msgChan:      lockEvents,
+		setChan:      setEvents,
+		obsvReqC:     obsvReqC,
+		client:       &http.Client{Timeout: 15 * time.Second},
+		pollInterval: time.Second,
+		logger:       zap.NewNop(),
+		health:       watchers.NewHealthTracker(vaa.ChainIDAlgorand),
+	}
+}
+
+func (e *Watcher) ChainID() vaa.ChainID {
+	return vaa.ChainIDAlgorand
+}
+
+func (e *Watcher) Health() watchers.Health {
+	return e.health.Health()
 }
 
 func (e *Watcher) Run(ctx context.Context) error {
+	contract := fmt.Sprintf("%d", e.appID)
+	p2p.DefaultRegistry.SetNetworkStats(vaa.ChainIDAlgorand, &gossipv1.Heartbeat_Network{
+		ContractAddress: contract,
+	})
+
+	logger := supervisor.Logger(ctx)
+	e.clientMu.Lock()
+	e.logger = logger
+	e.clientMu.Unlock()
+	logger.Info("starting algorand watcher",
+		zap.String("algod", e.algodRPC),
+		zap.String("indexer", e.indexerRPC),
+		zap.Uint64("app_id", e.appID))
+
+	lastRound, err := e.getLastRound(ctx)
+	if err != nil {
+		p2p.DefaultRegistry.AddErrorCount(vaa.ChainIDAlgorand, 1)
+		algorandConnectionErrors.WithLabelValues("status_error").Inc()
+		err = fmt.Errorf("failed to get algorand status: %w", err)
+		e.health.AddError(err)
+		return err
+	}
+	// Algorand has instant finality, so we start from the latest round and rely on
+	// re-observation requests for the messages published before the guardian started
+	nextRound := lastRound + 1
+	logger.Info("current algorand round", zap.Uint64("round", lastRound))
+
 	readiness.SetReady(common.ReadinessAlgorandSyncing)
 
-	select {}
+	go watchers.HandleObservationRequests(ctx, logger, e, e.obsvReqC)
+
+	t := time.NewTicker(e.pollInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			next, err := e.poll(ctx, logger, nextRound)
+			if err != nil {
+				p2p.DefaultRegistry.AddErrorCount(vaa.ChainIDAlgorand, 1)
+				e.health.AddError(err)
+				logger.Error("failed to poll algorand", zap.Uint64("nextRound", nextRound), zap.Error(err))
+				continue
+			}
+			nextRound = next
+		}
+	}
+}
+
+// poll publishes the messages of all rounds starting at nextRound which are both final
+// according to algod and ingested by the indexer, and returns the next round to scan
+func (e *Watcher) poll(ctx context.Context, logger *zap.Logger, nextRound uint64) (uint64, error) {
+	lastRound, err := e.getLastRound(ctx)
+	if err != nil {
+		algorandConnectionErrors.WithLabelValues("status_error").Inc()
+		return nextRound, err
+	}
+	currentAlgorandHeight.Set(float64(lastRound))
+	e.health.SetHeight(lastRound)
+	p2p.DefaultRegistry.SetNetworkStats(vaa.ChainIDAlgorand, &gossipv1.Heartbeat_Network{
+		Height:          int64(lastRound),
+		ContractAddress: fmt.Sprintf("%d", e.appID),
+	})
+
+	indexerRound, err := e.getIndexerRound(ctx)
+	if err != nil {
+		algorandConnectionErrors.WithLabelValues("indexer_health_error").Inc()
+		return nextRound, err
+	}
+
+	// the indexer can lag behind algod, we must not skip the rounds it hasn't ingested yet
+	toRound := lastRound
+	if indexerRound < toRound {
+		toRound = indexerRound
+	}
+	if toRound < nextRound {
+		return nextRound, nil
+	}
+
+	txns, err := e.getTransactions(ctx, nextRound, toRound)
+	if err != nil {
+		algorandConnectionErrors.WithLabelValues("transactions_error").Inc()
+		return nextRound, err
+	}
+
+	for _, txn := range txns {
+		e.publish(logger, MessagePublications(e.appID, txn, logger))
+	}
+
+	logger.Debug("scanned algorand rounds", zap.Uint64("from", nextRound), zap.Uint64("to", toRound), zap.Int("transactions", len(txns)))
+	processedAlgorandHeight.Set(float64(toRound))
+	return toRound + 1, nil
+}
+
+// HandleObservationRequest queries the requested transaction from the indexer and publishes
+// its messages. Algorand has instant finality, so no confirmations need to be checked.
+func (e *Watcher) HandleObservationRequest(ctx context.Context, r *gossipv1.ObservationRequest) error {
+	e.clientMu.Lock()
+	logger := e.logger
+	e.clientMu.Unlock()
+
+	txID := TxHashToID(r.TxHash)
+	logger.Info("received observation request for algorand",
+		zap.String("tx_hash", hex.EncodeToString(r.TxHash)),
+		zap.String("tx_id", txID))
+
+	txn, err := e.getTransaction(ctx, txID)
+	if err != nil {
+		algorandConnectionErrors.WithLabelValues("transaction_error").Inc()
+		return fmt.Errorf("failed to query algorand transaction %s: %w", txID, err)
+	}
+	e.publish(logger, MessagePublications(e.appID, txn, logger))
+	return nil
+}
+
+func (e *Watcher) publish(logger *zap.Logger, msgs []*common.MessagePublication) {
+	for _, msg := range msgs {
+		logger.Info("new message detected on algorand",
+			zap.Stringer("txHash", msg.TxHash),
+			zap.Stringer("emitter", msg.EmitterAddress),
+			zap.Uint32("nonce", msg.Nonce),
+			zap.Uint64("sequence", msg.Sequence),
+			zap.Time("timestamp", msg.Timestamp),
+		)
+		e.msgChan <- msg
+		algorandMessagesConfirmed.Inc()
+	}
+}
+
+func (e *Watcher) get(ctx context.Context, operation, baseURL, tokenHeader, token, path string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("accept", "application/json")
+	if token != "" {
+		req.Header.Set(tokenHeader, token)
+	}
+
+	msm := time.Now()
+	resp, err := e.client.Do(req)
+	queryLatency.WithLabelValues(operation).Observe(time.Since(msm).Seconds())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request error, url: %s, status code: %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (e *Watcher) getLastRound(ctx context.Context) (uint64, error) {
+	var status struct {
+		LastRound uint64 `json:"last-round"`
+	}
+	err := e.get(ctx, "status", e.algodRPC, algodTokenHeader, e.algodToken, "/v2/status", &status)
+	return status.LastRound, err
+}
+
+func (e *Watcher) getIndexerRound(ctx context.Context) (uint64, error) {
+	var health struct {
+		Round uint64 `json:"round"`
+	}
+	err := e.get(ctx, "health", e.indexerRPC, indexerTokenHeader, e.indexerToken, "/health", &health)
+	return health.Round, err
+}
+
+// getTransactions returns all transactions calling the core contract between the two rounds (inclusive),
+// the calls made by inner transactions are returned as part of their root transaction
+func (e *Watcher) getTransactions(ctx context.Context, fromRound, toRound uint64) ([]*transaction, error) {
+	txns := make([]*transaction, 0)
+	nextToken := ""
+	for {
+		query := url.Values{}
+		query.Set("application-id", fmt.Sprintf("%d", e.appID))
+		query.Set("min-round", fmt.Sprintf("%d", fromRound))
+		query.Set("max-round", fmt.Sprintf("%d", toRound))
+		if nextToken != "" {
+			query.Set("next", nextToken)
+		}
+
+		var result struct {
+			NextToken    string         `json:"next-token"`
+			Transactions []*transaction `json:"transactions"`
+		}
+		if err := e.get(ctx, "transactions", e.indexerRPC, indexerTokenHeader, e.indexerToken, "/v2/transactions?"+query.Encode(), &result); err != nil {
+			return nil, err
+		}
+		txns = append(txns, result.Transactions...)
+
+		if result.NextToken == "" || len(result.Transactions) == 0 {
+			return txns, nil
+		}
+		nextToken = result.NextToken
+	}
+}
+
+func (e *Watcher) getTransaction(ctx context.Context, txID string) (*transaction, error) {
+	var result struct {
+		Transaction *transaction `json:"transaction"`
+	}
+	if err := e.get(ctx, "transaction", e.indexerRPC, indexerTokenHeader, e.indexerToken, "/v2/transactions/"+txID, &result); err != nil {
+		return nil, err
+	}
+	if result.Transaction == nil {
+		return nil, fmt.Errorf("transaction %s not found", txID)
+	}
+	return result.Transaction, nil
+}
+
+// MessagePublications decodes the messages published by the core contract in the
+// transaction and all of its inner transactions
+func MessagePublications(appID uint64, root *transaction, logger *zap.Logger) []*common.MessagePublication {
+	txHash, err := IDToTxHash(root.ID)
+	if err != nil {
+		logger.Error("cannot decode tx id", zap.String("tx_id", root.ID), zap.Error(err))
+		return nil
+	}
+
+	msgs := make([]*common.MessagePublication, 0)
+	var visit func(txn *transaction)
+	visit = func(txn *transaction) {
+		if msg := messagePublication(appID, root, txn, txHash, logger); msg != nil {
+			msgs = append(msgs, msg)
+		}
+		for _, inner := range txn.InnerTxns {
+			visit(inner)
+		}
+	}
+	visit(root)
+	return msgs
+}
+
+func messagePublication(appID uint64, root *transaction, txn *transaction, txHash eth_common.Hash, logger *zap.Logger) *common.MessagePublication {
+	if txn.TxType != "appl" || txn.ApplicationTransaction == nil || txn.ApplicationTransaction.ApplicationID != appID {
+		return nil
+	}
+	args := txn.ApplicationTransaction.ApplicationArgs
+	if len(args) == 0 || string(args[0]) != publishMessageMethod {
+		return nil
+	}
+
+	if len(args) < 3 {
+		logger.Error("publish message call has too few arguments", zap.String("tx_id", root.ID), zap.Int("args", len(args)))
+		return nil
+	}
+	if len(args[2]) != 8 {
+		logger.Error("publish message call has invalid nonce", zap.String("tx_id", root.ID), zap.String("nonce", hex.EncodeToString(args[2])))
+		return nil
+	}
+	nonce := binary.BigEndian.Uint64(args[2])
+	if nonce > math.MaxUint32 {
+		logger.Error("publish message nonce is out of range", zap.String("tx_id", root.ID), zap.Uint64("nonce", nonce))
+		return nil
+	}
+	// the core contract logs the sequence of the message
+	if len(txn.Logs) == 0 || len(txn.Logs[0]) != 8 {
+		logger.Error("publish message call does not log the sequence", zap.String("tx_id", root.ID))
+		return nil
+	}
+	sequence := binary.BigEndian.Uint64(txn.Logs[0])
+
+	emitter, err := DecodeAddress(txn.Sender)
+	if err != nil {
+		logger.Error("cannot decode emitter address", zap.String("tx_id", root.ID), zap.String("sender", txn.Sender), zap.Error(err))
+		return nil
+	}
+
+	return &common.MessagePublication{
+		TxHash:           txHash,
+		Timestamp:        time.Unix(root.RoundTime, 0),
+		Nonce:            uint32(nonce),
+		Sequence:         sequence,
+		EmitterChain:     vaa.ChainIDAlgorand,
+		EmitterAddress:   emitter,
+		Payload:          args[1],
+		ConsistencyLevel: 0, // Instant finality
+	}
+}
+
+var base32Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// DecodeAddress converts an algorand address into the public key of the account
+func DecodeAddress(value string) (vaa.Address, error) {
+	var address vaa.Address
+	decoded, err := base32Encoding.DecodeString(value)
+	if err != nil {
+		return address, err
+	}
+	if len(decoded) != len(address)+addressChecksumSize {
+		return address, fmt.Errorf("invalid address length %d", len(decoded))
+	}
+	copy(address[:], decoded[:len(address)])
+	checksum := sha512.Sum512_256(address[:])
+	if string(checksum[len(checksum)-addressChecksumSize:]) != string(decoded[len(address):]) {
+		return address, fmt.Errorf("invalid address checksum")
+	}
+	return address, nil
+}
+
+// IDToTxHash converts an algorand transaction id into the tx hash of the message publication
+func IDToTxHash(id string) (eth_common.Hash, error) {
+	var hash eth_common.Hash
+	decoded, err := base32Encoding.DecodeString(id)
+	if err != nil {
+		return hash, err
+	}
+	if len(decoded) != len(hash) {
+		return hash, fmt.Errorf("invalid tx id length %d", len(decoded))
+	}
+	copy(hash[:], decoded)
+	return hash, nil
+}
+
+// TxHashToID converts the tx hash of a message publication back into the algorand transaction id
+func TxHashToID(txHash []byte) string {
+	return base32Encoding.EncodeToString(txHash)
 }
@@ -0,0 +1,279 @@
+package algorand
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testAppID = 4
+
+func encodeAddress(publicKey []byte) string {
+	checksum := sha512.Sum512_256(publicKey)
+	return base32Encoding.EncodeToString(append(publicKey, checksum[len(checksum)-addressChecksumSize:]...))
+}
+
+func testAddress(b byte) (vaa.Address, string) {
+	var address vaa.Address
+	for i := range address {
+		address[i] = b
+	}
+	return address, encodeAddress(address[:])
+}
+
+func testTxID(b byte) string {
+	id := make([]byte, 32)
+	for i := range id {
+		id[i] = b
+	}
+	return TxHashToID(id)
+}
+
+func uint64Bytes(value uint64) []byte {
+	var bytes [8]byte
+	binary.BigEndian.PutUint64(bytes[:], value)
+	return bytes[:]
+}
+
+func publishMessageTxn(sender string, payload []byte, nonce, sequence uint64) map[string]interface{} {
+	return map[string]interface{}{
+		"sender":  sender,
+		"tx-type": "appl",
+		"application-transaction": map[string]interface{}{
+			"application-id": testAppID,
+			"application-args": []string{
+				base64.StdEncoding.EncodeToString([]byte(publishMessageMethod)),
+				base64.StdEncoding.EncodeToString(payload),
+				base64.StdEncoding.EncodeToString(uint64Bytes(nonce)),
+			},
+		},
+		"logs": []string{base64.StdEncoding.EncodeToString(uint64Bytes(sequence))},
+	}
+}
+
+// recordedTransactions returns the indexer transactions of rounds 100 to 103: a direct call of the core
+// contract, a token bridge transfer which calls the core contract through an inner transaction, and
+// a core contract call which doesn't publish any message
+func recordedTransactions() []map[string]interface{} {
+	_, user := testAddress(1)
+	_, tokenBridge := testAddress(2)
+
+	direct := publishMessageTxn(user, []byte("direct"), 1, 10)
+	direct["id"] = testTxID(1)
+	direct["confirmed-round"] = 100
+	direct["round-time"] = 1650000000
+
+	transfer := map[string]interface{}{
+		"id":              testTxID(2),
+		"sender":          user,
+		"tx-type":         "appl",
+		"confirmed-round": 102,
+		"round-time":      1650000010,
+		"application-transaction": map[string]interface{}{
+			"application-id":   5,
+			"application-args": []string{base64.StdEncoding.EncodeToString([]byte("sendTransfer"))},
+		},
+		"inner-txns": []interface{}{publishMessageTxn(tokenBridge, []byte("transfer"), 2, 11)},
+	}
+
+	governance := map[string]interface{}{
+		"id":              testTxID(3),
+		"sender":          user,
+		"tx-type":         "appl",
+		"confirmed-round": 103,
+		"round-time":      1650000020,
+		"application-transaction": map[string]interface{}{
+			"application-id":   testAppID,
+			"application-args": []string{base64.StdEncoding.EncodeToString([]byte("governance"))},
+		},
+	}
+	return []map[string]interface{}{direct, transfer, governance}
+}
+
+type standIn struct {
+	lastRound    uint64
+	indexerRound uint64
+	txns         []map[string]interface{}
+	requests     []string
+}
+
+func (s *standIn) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/status", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "algod-token", r.Header.Get(algodTokenHeader))
+		json.NewEncoder(w).Encode(map[string]interface{}{"last-round": s.lastRound})
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"round": s.indexerRound})
+	})
+	mux.HandleFunc("/v2/transactions", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "indexer-token", r.Header.Get(indexerTokenHeader))
+		assert.Equal(t, fmt.Sprintf("%d", testAppID), r.URL.Query().Get("application-id"))
+		s.requests = append(s.requests, r.URL.RawQuery)
+
+		// serve one transaction per page to exercise the pagination
+		page := 0
+		if next := r.URL.Query().Get("next"); next != "" {
+			fmt.Sscanf(next, "%d", &page)
+		}
+		result := map[string]interface{}{"current-round": s.indexerRound, "transactions": []interface{}{}}
+		if page < len(s.txns) {
+			result["transactions"] = []interface{}{s.txns[page]}
+			result["next-token"] = fmt.Sprintf("%d", page+1)
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+	mux.HandleFunc("/v2/transactions/", func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Path[len("/v2/transactions/"):]
+		for _, txn := range s.txns {
+			if txn["id"] == id {
+				json.NewEncoder(w).Encode(map[string]interface{}{"current-round": s.indexerRound, "transaction": txn})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	return mux
+}
+
+func newTestWatcher(url string, msgC chan *common.MessagePublication, obsvReqC chan *gossipv1.ObservationRequest) *Watcher {
+	return NewWatcher(url, "algod-token", url, "indexer-token", testAppID, msgC, nil, obsvReqC)
+}
+
+func checkRecordedMessages(t *testing.T, msgs []*common.MessagePublication) {
+	user, _ := testAddress(1)
+	tokenBridge, _ := testAddress(2)
+	txHash1, _ := IDToTxHash(testTxID(1))
+	txHash2, _ := IDToTxHash(testTxID(2))
+
+	assert.Equal(t, 2, len(msgs))
+	assert.Equal(t, &common.MessagePublication{
+		TxHash:           txHash1,
+		Timestamp:        time.Unix(1650000000, 0),
+		Nonce:            1,
+		Sequence:         10,
+		ConsistencyLevel: 0,
+		EmitterChain:     vaa.ChainIDAlgorand,
+		EmitterAddress:   user,
+		Payload:          []byte("direct"),
+	}, msgs[0])
+	assert.Equal(t, &common.MessagePublication{
+		TxHash:           txHash2,
+		Timestamp:        time.Unix(1650000010, 0),
+		Nonce:            2,
+		Sequence:         11,
+		ConsistencyLevel: 0,
+		EmitterChain:     vaa.ChainIDAlgorand,
+		EmitterAddress:   tokenBridge,
+		Payload:          []byte("transfer"),
+	}, msgs[1])
+}
+
+func TestDecodeAddress(t *testing.T) {
+	expected, encoded := testAddress(7)
+	address, err := DecodeAddress(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, address)
+
+	// corrupt the checksum
+	invalid := []byte(encoded)
+	invalid[len(invalid)-1] = 'A'
+	if string(invalid) == encoded {
+		invalid[len(invalid)-1] = 'B'
+	}
+	_, err = DecodeAddress(string(invalid))
+	assert.NotNil(t, err)
+
+	_, err = DecodeAddress(base32Encoding.EncodeToString(expected[:]))
+	assert.NotNil(t, err)
+}
+
+func TestTxHashConversion(t *testing.T) {
+	id := testTxID(9)
+	assert.Equal(t, 52, len(id))
+	hash, err := IDToTxHash(id)
+	assert.Nil(t, err)
+	assert.Equal(t, id, TxHashToID(hash.Bytes()))
+}
+
+func TestPollWaitsForIndexer(t *testing.T) {
+	s := &standIn{lastRound: 105, indexerRound: 103, txns: recordedTransactions()}
+	server := httptest.NewServer(s.handler(t))
+	defer server.Close()
+
+	msgC := make(chan *common.MessagePublication, 8)
+	watcher := newTestWatcher(server.URL, msgC, nil)
+
+	next, err := watcher.poll(context.Background(), zap.NewNop(), 100)
+	assert.Nil(t, err)
+	// only the rounds ingested by the indexer are scanned
+	assert.Equal(t, uint64(104), next)
+	assert.Equal(t, 4, len(s.requests))
+	assert.Equal(t, "application-id=4&max-round=103&min-round=100", s.requests[0])
+	assert.Equal(t, "application-id=4&max-round=103&min-round=100&next=1", s.requests[1])
+
+	close(msgC)
+	msgs := make([]*common.MessagePublication, 0)
+	for msg := range msgC {
+		msgs = append(msgs, msg)
+	}
+	checkRecordedMessages(t, msgs)
+
+	// nothing to do until algod and the indexer produce new rounds
+	s.requests = nil
+	next, err = watcher.poll(context.Background(), zap.NewNop(), 104)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(104), next)
+	assert.Equal(t, 0, len(s.requests))
+
+	// the round is not advanced if the indexer is unavailable
+	server.Close()
+	next, err = watcher.poll(context.Background(), zap.NewNop(), 104)
+	assert.NotNil(t, err)
+	assert.Equal(t, uint64(104), next)
+}
+
+func TestHandleObservationRequests(t *testing.T) {
+	s := &standIn{lastRound: 105, indexerRound: 105, txns: recordedTransactions()}
+	server := httptest.NewServer(s.handler(t))
+	defer server.Close()
+
+	msgC := make(chan *common.MessagePublication, 8)
+	obsvReqC := make(chan *gossipv1.ObservationRequest)
+	watcher := newTestWatcher(server.URL, msgC, obsvReqC)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchers.HandleObservationRequests(ctx, zap.NewNop(), watcher, obsvReqC)
+
+	for _, id := range []string{testTxID(1), testTxID(4), testTxID(2)} {
+		txHash, err := IDToTxHash(id)
+		assert.Nil(t, err)
+		obsvReqC <- &gossipv1.ObservationRequest{ChainId: uint32(vaa.ChainIDAlgorand), TxHash: txHash.Bytes()}
+	}
+
+	msgs := make([]*common.MessagePublication, 0)
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-msgC:
+			msgs = append(msgs, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for reobserved messages")
+		}
+	}
+	checkRecordedMessages(t, msgs)
+}
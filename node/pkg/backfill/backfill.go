@@ -0,0 +1,454 @@
+// Package backfill fetches signed VAAs missing from the local store from the public RPC of other nodes.
+package backfill
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/db"
+	"github.com/certusone/wormhole/node/pkg/processor"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"go.uber.org/zap"
+)
+
+// ErrNotFound is returned by Fetch if all nodes replied that they don't have the VAA.
+var ErrNotFound = errors.New("VAA not found on any node")
+
+type Status uint8
+
+const (
+	// StatusStored means that a valid VAA was fetched and stored.
+	StatusStored Status = iota + 1
+	// StatusNotFound means that none of the nodes has the VAA.
+	StatusNotFound
+	// StatusFailed means that all attempts failed, for example because the nodes were unreachable.
+	StatusFailed
+	// StatusRejected means that the nodes served a VAA which failed verification.
+	StatusRejected
+	// StatusMismatched means that the nodes served a VAA for a different emitter or sequence.
+	StatusMismatched
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusStored:
+		return "stored"
+	case StatusNotFound:
+		return "not found"
+	case StatusFailed:
+		return "failed"
+	case StatusRejected:
+		return "rejected"
+	case StatusMismatched:
+		return "mismatched"
+	default:
+		return fmt.Sprintf("unknown (%d)", s)
+	}
+}
+
+// InvalidVAAError is returned by Fetch if no node served a valid VAA, and at least one node served an invalid one.
+type InvalidVAAError struct {
+	// Status is StatusRejected or StatusMismatched.
+	Status Status
+	Node   string
+	Err    error
+}
+
+func (e *InvalidVAAError) Error() string {
+	return fmt.Sprintf("%s served %s VAA: %v", e.Node, e.Status, e.Err)
+}
+
+// FetchedVAA is a verified VAA and the node which served it.
+type FetchedVAA struct {
+	VAA   *vaa.VAA
+	Bytes []byte
+	Node  string
+}
+
+// Outcome is the result of backfilling a single sequence.
+type Outcome struct {
+	Sequence uint64 `json:"sequence"`
+	Status   Status `json:"status"`
+	// Node which served the VAA, if it was stored.
+	Node     string `json:"node,omitempty"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+	// Resumed is set for outcomes of an earlier, interrupted run.
+	Resumed bool `json:"-"`
+}
+
+type Config struct {
+	// Parallelism is the maximum number of sequences fetched concurrently.
+	Parallelism int
+	// NodeTimeout is the timeout of a single request to a node.
+	NodeTimeout time.Duration
+	// MaxAttempts is the number of times a sequence is tried on all nodes before giving up.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt. It doubles with every attempt, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// GuardianSets are historical guardian sets, used to verify VAAs signed before a guardian set update.
+	GuardianSets []*common.GuardianSet
+}
+
+var DefaultConfig = Config{
+	Parallelism:    8,
+	NodeTimeout:    5 * time.Second,
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// Node health scores are bounded, so that a node which recovers is preferred again after a few requests.
+const (
+	maxScore = 10
+	minScore = -10
+)
+
+type node struct {
+	url   string
+	score int
+}
+
+// Fetcher fetches signed VAAs from a list of public RPC endpoints.
+//
+// Every node has a health score which goes up when it serves a valid VAA and down when it fails, times out
+// or serves an invalid VAA. Nodes are tried in order of their score, so unhealthy nodes don't slow down the backfill.
+//
+// Fetched VAAs are verified against the guardian set they are signed by before they are returned. Besides the
+// current guardian set, only the historical sets given in the Config are known.
+type Fetcher struct {
+	logger *zap.Logger
+	client *http.Client
+	gst    *common.GuardianSetState
+	config Config
+
+	mu    sync.Mutex
+	nodes []*node
+}
+
+func NewFetcher(logger *zap.Logger, nodes []string, gst *common.GuardianSetState, config Config) *Fetcher {
+	f := &Fetcher{
+		logger: logger,
+		client: &http.Client{},
+		gst:    gst,
+		config: config,
+	}
+	for _, url := range nodes {
+		f.nodes = append(f.nodes, &node{url: url})
+	}
+	return f
+}
+
+// orderedNodes returns the nodes ordered by health score. Nodes with the same score are shuffled to spread the load.
+func (f *Fetcher) orderedNodes() []*node {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	nodes := make([]*node, len(f.nodes))
+	copy(nodes, f.nodes)
+	rand.Shuffle(len(nodes), func(i, j int) {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	})
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].score > nodes[j].score
+	})
+	return nodes
+}
+
+func (f *Fetcher) adjustScore(n *node, delta int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n.score += delta
+	if n.score > maxScore {
+		n.score = maxScore
+	} else if n.score < minScore {
+		n.score = minScore
+	}
+}
+
+// Scores returns the current health score of every node.
+func (f *Fetcher) Scores() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	scores := make(map[string]int, len(f.nodes))
+	for _, n := range f.nodes {
+		scores[n.url] = n.score
+	}
+	return scores
+}
+
+// fetchFromNode fetches a signed VAA from a single node. found is false if the node doesn't have the VAA.
+func (f *Fetcher) fetchFromNode(ctx context.Context, n *node, chain vaa.ChainID, addr vaa.Address, seq uint64) (b []byte, found bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, f.config.NodeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(
+		"%s/v1/signed_vaa/%d/%s/%d", n.url, chain, addr, seq), nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, false, nil
+	case http.StatusOK:
+		var respBody struct {
+			VaaBytes string `json:"vaaBytes"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+			return nil, false, fmt.Errorf("failed to decode VAA response: %w", err)
+		}
+		b, err := base64.StdEncoding.DecodeString(respBody.VaaBytes)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decode VAA body: %w", err)
+		}
+		return b, true, nil
+	default:
+		return nil, false, fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+}
+
+// guardianSets returns the known guardian sets by index. The current set takes precedence over a historical set
+// with the same index.
+func (f *Fetcher) guardianSets() (map[uint32]*common.GuardianSet, error) {
+	current := f.gst.Get()
+	if current == nil {
+		return nil, errors.New("guardian set not initialized yet")
+	}
+	sets := make(map[uint32]*common.GuardianSet, len(f.config.GuardianSets)+1)
+	for _, gs := range f.config.GuardianSets {
+		sets[gs.Index] = gs
+	}
+	sets[current.Index] = current
+	return sets, nil
+}
+
+// verify checks that b is a VAA of the requested emitter and sequence, signed by a quorum of its guardian set.
+func verify(sets map[uint32]*common.GuardianSet, b []byte, chain vaa.ChainID, addr vaa.Address, seq uint64) (*vaa.VAA, Status, error) {
+	v, err := vaa.Unmarshal(b)
+	if err != nil {
+		return nil, StatusRejected, fmt.Errorf("failed to unmarshal VAA: %w", err)
+	}
+	if v.EmitterChain != chain || v.EmitterAddress != addr || v.Sequence != seq {
+		return nil, StatusMismatched, fmt.Errorf("requested %d/%s/%d, got %s", chain, addr, seq, v.MessageID())
+	}
+
+	gs, ok := sets[v.GuardianSetIndex]
+	if !ok {
+		return nil, StatusRejected, fmt.Errorf("VAA is signed by unknown guardian set %d", v.GuardianSetIndex)
+	}
+	if err := processor.VerifyQuorum(v, gs); err != nil {
+		return nil, StatusRejected, err
+	}
+	return v, StatusStored, nil
+}
+
+// Fetch tries all nodes once, in order of their health score, and returns the first valid VAA.
+//
+// ErrNotFound is returned if every node replied that it doesn't have the VAA. If any node failed,
+// its last error is returned instead, since the VAA might still be available from that node.
+// Otherwise, if any node served an invalid VAA, an *InvalidVAAError is returned.
+func (f *Fetcher) Fetch(ctx context.Context, chain vaa.ChainID, addr vaa.Address, seq uint64) (*FetchedVAA, error) {
+	var (
+		lastErr     error
+		lastInvalid *InvalidVAAError
+	)
+	sets, err := f.guardianSets()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range f.orderedNodes() {
+		b, found, err := f.fetchFromNode(ctx, n, chain, addr, seq)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			f.logger.Warn("failed to fetch missing VAA",
+				zap.String("node", n.url),
+				zap.String("chain", chain.String()),
+				zap.String("address", addr.String()),
+				zap.Uint64("sequence", seq),
+				zap.Error(err),
+			)
+			f.adjustScore(n, -2)
+			lastErr = fmt.Errorf("%s: %w", n.url, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+
+		v, st, err := verify(sets, b, chain, addr, seq)
+		if err != nil {
+			f.logger.Warn("node served invalid VAA",
+				zap.String("node", n.url),
+				zap.String("chain", chain.String()),
+				zap.String("address", addr.String()),
+				zap.Uint64("sequence", seq),
+				zap.Stringer("status", st),
+				zap.Error(err),
+			)
+			f.adjustScore(n, -2)
+			lastInvalid = &InvalidVAAError{Status: st, Node: n.url, Err: err}
+			continue
+		}
+
+		f.adjustScore(n, 1)
+		return &FetchedVAA{VAA: v, Bytes: b, Node: n.url}, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	if lastInvalid != nil {
+		return nil, lastInvalid
+	}
+	return nil, ErrNotFound
+}
+
+// fetchWithRetry fetches and stores a single sequence, retrying with exponential backoff until the VAA is stored,
+// the nodes gave a definite answer or MaxAttempts is reached. Invalid VAAs aren't retried, since the nodes
+// would most likely serve them again.
+func (f *Fetcher) fetchWithRetry(ctx context.Context, chain vaa.ChainID, addr vaa.Address, seq uint64, store func(*FetchedVAA) error) Outcome {
+	o := Outcome{Sequence: seq}
+	backoff := f.config.InitialBackoff
+	for {
+		o.Attempts++
+		fetched, err := f.Fetch(ctx, chain, addr, seq)
+		if err == nil {
+			err = store(fetched)
+			if err == nil {
+				o.Status = StatusStored
+				o.Node = fetched.Node
+				o.Error = ""
+				return o
+			}
+		} else if err == ErrNotFound {
+			o.Status = StatusNotFound
+			o.Error = ""
+			return o
+		} else if invalid, ok := err.(*InvalidVAAError); ok {
+			o.Status = invalid.Status
+			o.Node = invalid.Node
+			o.Error = invalid.Err.Error()
+			return o
+		}
+
+		o.Status = StatusFailed
+		o.Error = err.Error()
+		if ctx.Err() != nil || o.Attempts >= f.config.MaxAttempts {
+			return o
+		}
+
+		select {
+		case <-ctx.Done():
+			return o
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > f.config.MaxBackoff {
+			backoff = f.config.MaxBackoff
+		}
+	}
+}
+
+// Run backfills the given sequences of the emitter and returns their outcomes, ordered by sequence.
+// store is called for every verified VAA, from multiple goroutines. It returns once the VAA is stored.
+//
+// The outcome of every sequence is saved as soon as it is known. If a previous run for the same emitter
+// was interrupted, the sequences it already finished are not fetched again and their saved outcome is
+// returned with Resumed set. The saved progress is discarded once a run completes. If ctx is cancelled,
+// the outcomes known so far are returned along with the context error, and the next run resumes from there.
+func (f *Fetcher) Run(ctx context.Context, d *db.Database, emitter db.VAAID, seqs []uint64, store func(*FetchedVAA) error) ([]Outcome, error) {
+	saved, err := d.GetBackfillProgress(emitter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load backfill progress: %w", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		outcomes []Outcome
+		saveErr  error
+	)
+	todo := make(chan uint64)
+	go func() {
+		defer close(todo)
+		for _, seq := range seqs {
+			if b, ok := saved[seq]; ok {
+				var o Outcome
+				if err := json.Unmarshal(b, &o); err == nil && o.Status != StatusFailed {
+					o.Resumed = true
+					mu.Lock()
+					outcomes = append(outcomes, o)
+					mu.Unlock()
+					continue
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case todo <- seq:
+			}
+		}
+	}()
+
+	parallelism := f.config.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seq := range todo {
+				o := f.fetchWithRetry(ctx, emitter.EmitterChain, emitter.EmitterAddress, seq, store)
+				if ctx.Err() != nil && o.Status == StatusFailed {
+					// Interrupted - the sequence is fetched again when the backfill is resumed.
+					continue
+				}
+
+				id := emitter
+				id.Sequence = seq
+				b, _ := json.Marshal(o)
+				err := d.StoreBackfillProgress(id, b)
+
+				mu.Lock()
+				outcomes = append(outcomes, o)
+				if err != nil && saveErr == nil {
+					saveErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(outcomes, func(i, j int) bool {
+		return outcomes[i].Sequence < outcomes[j].Sequence
+	})
+	if ctx.Err() != nil {
+		return outcomes, ctx.Err()
+	}
+	if saveErr != nil {
+		return outcomes, fmt.Errorf("failed to save backfill progress: %w", saveErr)
+	}
+	if err := d.DeleteBackfillProgress(emitter); err != nil {
+		return outcomes, err
+	}
+	return outcomes, nil
+}
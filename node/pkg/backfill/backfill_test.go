@@ -0,0 +1,306 @@
+package backfill
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/db"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var testEmitter = db.VAAID{EmitterChain: vaa.ChainIDEthereum, EmitterAddress: vaa.Address{1, 2, 3}}
+
+var testKeys []*ecdsa.PrivateKey
+
+func init() {
+	for i := 0; i < 4; i++ {
+		k, err := crypto.GenerateKey()
+		if err != nil {
+			panic(err)
+		}
+		testKeys = append(testKeys, k)
+	}
+}
+
+func testGuardianSetState() *common.GuardianSetState {
+	gs := &common.GuardianSet{Index: 1}
+	for _, k := range testKeys {
+		gs.Keys = append(gs.Keys, crypto.PubkeyToAddress(k.PublicKey))
+	}
+	gst := common.NewGuardianSetState()
+	gst.Set(gs)
+	return gst
+}
+
+func signedTestVAA(seq uint64, keys []*ecdsa.PrivateKey) *vaa.VAA {
+	v := &vaa.VAA{
+		Version:          vaa.SupportedVAAVersion,
+		GuardianSetIndex: 1,
+		Timestamp:        time.Unix(1650000000, 0),
+		Sequence:         seq,
+		ConsistencyLevel: 1,
+		EmitterChain:     testEmitter.EmitterChain,
+		EmitterAddress:   testEmitter.EmitterAddress,
+		Payload:          make([]byte, 100),
+	}
+	for i, k := range keys {
+		v.AddSignature(k, uint8(i))
+	}
+	return v
+}
+
+var testConfig = Config{
+	Parallelism:    4,
+	NodeTimeout:    time.Second,
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     time.Millisecond,
+}
+
+// testNode serves a VAA signed by all test keys for the given sequences, unless vaas overrides it.
+type testNode struct {
+	mu       sync.Mutex
+	has      map[uint64]bool
+	vaas     map[uint64]*vaa.VAA
+	requests int
+	// failures is the number of requests which fail before the node starts working.
+	failures int
+}
+
+func (n *testNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var chain, seq uint64
+	var addr string
+	if _, err := fmt.Sscanf(r.URL.Path, "/v1/signed_vaa/%d/%64s/%d", &chain, &addr, &seq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	n.requests++
+	fail := n.failures > 0
+	if fail {
+		n.failures--
+	}
+	n.mu.Unlock()
+
+	switch {
+	case fail:
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	case n.vaas[seq] != nil || n.has[seq]:
+		v := n.vaas[seq]
+		if v == nil {
+			v = signedTestVAA(seq, testKeys)
+		}
+		b, _ := v.Marshal()
+		fmt.Fprintf(w, `{"vaaBytes": "%s"}`, base64.StdEncoding.EncodeToString(b))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func startNode(t *testing.T, n *testNode) string {
+	s := httptest.NewServer(n)
+	t.Cleanup(s.Close)
+	return s.URL
+}
+
+func openTestDB(t *testing.T) *db.Database {
+	d, err := db.Open(t.TempDir())
+	assert.Nil(t, err)
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestFetch(t *testing.T) {
+	good := startNode(t, &testNode{has: map[uint64]bool{1: true}})
+	empty := startNode(t, &testNode{})
+	broken := startNode(t, &testNode{failures: 1000})
+	f := NewFetcher(zap.NewNop(), []string{broken, empty, good}, testGuardianSetState(), testConfig)
+
+	// the broken node failed, so the VAA might exist
+	_, err := f.Fetch(context.Background(), testEmitter.EmitterChain, testEmitter.EmitterAddress, 2)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrNotFound, err)
+
+	// the broken node is tried last now
+	fetched, err := f.Fetch(context.Background(), testEmitter.EmitterChain, testEmitter.EmitterAddress, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), fetched.VAA.Sequence)
+	assert.Equal(t, good, fetched.Node)
+
+	scores := f.Scores()
+	assert.Equal(t, -2, scores[broken])
+	assert.Equal(t, 0, scores[empty])
+	assert.Equal(t, 1, scores[good])
+	assert.Equal(t, good, f.orderedNodes()[0].url)
+	assert.Equal(t, broken, f.orderedNodes()[2].url)
+
+	f = NewFetcher(zap.NewNop(), []string{empty}, testGuardianSetState(), testConfig)
+	_, err = f.Fetch(context.Background(), testEmitter.EmitterChain, testEmitter.EmitterAddress, 1)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestRun(t *testing.T) {
+	d := openTestDB(t)
+	flaky := &testNode{has: map[uint64]bool{1: true, 2: true, 3: true}, failures: 2}
+	f := NewFetcher(zap.NewNop(), []string{startNode(t, flaky)}, testGuardianSetState(), testConfig)
+
+	var mu sync.Mutex
+	var stored []uint64
+	outcomes, err := f.Run(context.Background(), d, testEmitter, []uint64{1, 2, 3, 4}, func(fetched *FetchedVAA) error {
+		mu.Lock()
+		defer mu.Unlock()
+		stored = append(stored, fetched.VAA.Sequence)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []uint64{1, 2, 3}, stored)
+
+	assert.Equal(t, 4, len(outcomes))
+	for i, o := range outcomes[:3] {
+		assert.Equal(t, uint64(i+1), o.Sequence)
+		assert.Equal(t, StatusStored, o.Status)
+	}
+	assert.Equal(t, StatusNotFound, outcomes[3].Status)
+
+	// the progress of a completed run is discarded
+	progress, err := d.GetBackfillProgress(testEmitter)
+	assert.Nil(t, err)
+	assert.Empty(t, progress)
+}
+
+func TestRunGivesUp(t *testing.T) {
+	d := openTestDB(t)
+	n := &testNode{has: map[uint64]bool{1: true}, failures: 1000}
+	f := NewFetcher(zap.NewNop(), []string{startNode(t, n)}, testGuardianSetState(), testConfig)
+
+	outcomes, err := f.Run(context.Background(), d, testEmitter, []uint64{1}, func(*FetchedVAA) error { return nil })
+	assert.Nil(t, err)
+	assert.Equal(t, StatusFailed, outcomes[0].Status)
+	assert.Equal(t, 3, outcomes[0].Attempts)
+	assert.Equal(t, 3, n.requests)
+	assert.NotEmpty(t, outcomes[0].Error)
+}
+
+func TestRunResumes(t *testing.T) {
+	d := openTestDB(t)
+	n := &testNode{has: map[uint64]bool{1: true, 2: true, 3: true}}
+	f := NewFetcher(zap.NewNop(), []string{startNode(t, n)}, testGuardianSetState(), testConfig)
+
+	// interrupt the backfill after the first VAA
+	ctx, cancel := context.WithCancel(context.Background())
+	f.config.Parallelism = 1
+	_, err := f.Run(ctx, d, testEmitter, []uint64{1, 2, 3}, func(*FetchedVAA) error {
+		cancel()
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+
+	progress, err := d.GetBackfillProgress(testEmitter)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(progress))
+	assert.Contains(t, progress, uint64(1))
+
+	n.requests = 0
+	outcomes, err := f.Run(context.Background(), d, testEmitter, []uint64{1, 2, 3}, func(*FetchedVAA) error { return nil })
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n.requests)
+	assert.Equal(t, 3, len(outcomes))
+	assert.True(t, outcomes[0].Resumed)
+	assert.Equal(t, StatusStored, outcomes[0].Status)
+	assert.False(t, outcomes[1].Resumed)
+	assert.False(t, outcomes[2].Resumed)
+}
+
+func TestRunVerifies(t *testing.T) {
+	d := openTestDB(t)
+	other, _ := crypto.GenerateKey()
+	mismatched := signedTestVAA(5, testKeys)
+	oldSet := signedTestVAA(6, testKeys)
+	oldSet.GuardianSetIndex = 0
+	n := &testNode{vaas: map[uint64]*vaa.VAA{
+		1: signedTestVAA(1, testKeys[:3]),
+		2: signedTestVAA(2, testKeys[:2]),
+		3: signedTestVAA(3, []*ecdsa.PrivateKey{other, testKeys[1], testKeys[2]}),
+		4: mismatched,
+		6: oldSet,
+	}}
+	f := NewFetcher(zap.NewNop(), []string{startNode(t, n)}, testGuardianSetState(), testConfig)
+
+	outcomes, err := f.Run(context.Background(), d, testEmitter, []uint64{1, 2, 3, 4, 6}, func(*FetchedVAA) error { return nil })
+	assert.Nil(t, err)
+	// invalid VAAs aren't retried
+	assert.Equal(t, 5, n.requests)
+
+	expected := []Status{StatusStored, StatusRejected, StatusRejected, StatusMismatched, StatusRejected}
+	for i, o := range outcomes {
+		assert.Equal(t, expected[i], o.Status, o.Sequence)
+	}
+	assert.Contains(t, outcomes[1].Error, "3 required")
+	assert.Contains(t, outcomes[4].Error, "unknown guardian set 0")
+
+	// a valid VAA from another node is used instead
+	good := startNode(t, &testNode{has: map[uint64]bool{4: true}})
+	f = NewFetcher(zap.NewNop(), []string{startNode(t, n), good}, testGuardianSetState(), testConfig)
+	fetched, err := f.Fetch(context.Background(), testEmitter.EmitterChain, testEmitter.EmitterAddress, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, good, fetched.Node)
+}
+
+func TestFetchHistoricalGuardianSet(t *testing.T) {
+	var oldKeys []*ecdsa.PrivateKey
+	oldSet := &common.GuardianSet{Index: 0}
+	for i := 0; i < 4; i++ {
+		k, err := crypto.GenerateKey()
+		assert.Nil(t, err)
+		oldKeys = append(oldKeys, k)
+		oldSet.Keys = append(oldSet.Keys, crypto.PubkeyToAddress(k.PublicKey))
+	}
+	signedByOldSet := func(seq uint64, keys []*ecdsa.PrivateKey) *vaa.VAA {
+		v := signedTestVAA(seq, nil)
+		v.GuardianSetIndex = 0
+		for i, k := range keys {
+			v.AddSignature(k, uint8(i))
+		}
+		return v
+	}
+	// a single guardian's signature repeated up to the quorum
+	repeated := signedByOldSet(3, nil)
+	for i := 0; i < 3; i++ {
+		repeated.AddSignature(oldKeys[0], 0)
+	}
+	n := &testNode{has: map[uint64]bool{1: true}, vaas: map[uint64]*vaa.VAA{
+		2: signedByOldSet(2, oldKeys),
+		3: repeated,
+		4: signedByOldSet(4, testKeys),
+	}}
+
+	config := testConfig
+	config.GuardianSets = []*common.GuardianSet{oldSet}
+	f := NewFetcher(zap.NewNop(), []string{startNode(t, n)}, testGuardianSetState(), config)
+
+	// VAAs signed by the current and the historical guardian set are both accepted
+	for seq, index := range map[uint64]uint32{1: 1, 2: 0} {
+		fetched, err := f.Fetch(context.Background(), testEmitter.EmitterChain, testEmitter.EmitterAddress, seq)
+		assert.Nil(t, err)
+		assert.Equal(t, index, fetched.VAA.GuardianSetIndex)
+	}
+
+	for _, seq := range []uint64{3, 4} {
+		_, err := f.Fetch(context.Background(), testEmitter.EmitterChain, testEmitter.EmitterAddress, seq)
+		var invalid *InvalidVAAError
+		assert.ErrorAs(t, err, &invalid)
+		assert.Equal(t, StatusRejected, invalid.Status)
+	}
+}
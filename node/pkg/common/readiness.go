@@ -1,6 +1,11 @@
 package common
 
-import "github.com/certusone/wormhole/node/pkg/readiness"
+import (
+	"fmt"
+
+	"github.com/certusone/wormhole/node/pkg/readiness"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+)
 
 const (
 	ReadinessEthSyncing        readiness.Component = "ethSyncing"
@@ -17,3 +22,27 @@ const (
 	ReadinessAcalaSyncing      readiness.Component = "acalaSyncing"
 	ReadinessAlephiumSyncing   readiness.Component = "alephiumSyncing"
 )
+
+var chainReadiness = map[vaa.ChainID]readiness.Component{
+	vaa.ChainIDSolana:          ReadinessSolanaSyncing,
+	vaa.ChainIDEthereum:        ReadinessEthSyncing,
+	vaa.ChainIDTerra:           ReadinessTerraSyncing,
+	vaa.ChainIDBSC:             ReadinessBSCSyncing,
+	vaa.ChainIDPolygon:         ReadinessPolygonSyncing,
+	vaa.ChainIDAvalanche:       ReadinessAvalancheSyncing,
+	vaa.ChainIDOasis:           ReadinessOasisSyncing,
+	vaa.ChainIDAlgorand:        ReadinessAlgorandSyncing,
+	vaa.ChainIDFantom:          ReadinessFantomSyncing,
+	vaa.ChainIDKarura:          ReadinessKaruraSyncing,
+	vaa.ChainIDAcala:           ReadinessAcalaSyncing,
+	vaa.ChainIDAlephium:        ReadinessAlephiumSyncing,
+	vaa.ChainIDEthereumRopsten: ReadinessEthRopstenSyncing,
+}
+
+// ChainReadiness returns the readiness component which the watcher of the given chain reports to.
+func ChainReadiness(chainID vaa.ChainID) readiness.Component {
+	if component, ok := chainReadiness[chainID]; ok {
+		return component
+	}
+	return readiness.Component(fmt.Sprintf("chain%dSyncing", chainID))
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+const aggregationStatePrefix = "aggregation/"
+
+func aggregationStateKey(digest string) []byte {
+	return []byte(aggregationStatePrefix + digest)
+}
+
+// StoreAggregationState stores the serialized aggregation state of the VAA with the given signing digest.
+// The processor owns the serialization format.
+func (d *Database) StoreAggregationState(digest string, state []byte) error {
+	if err := d.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(aggregationStateKey(digest), state)
+	}); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// DeleteAggregationState removes the aggregation state of the VAA with the given signing digest.
+func (d *Database) DeleteAggregationState(digest string) error {
+	if err := d.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(aggregationStateKey(digest))
+	}); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetAggregationStates returns all stored aggregation states by signing digest.
+func (d *Database) GetAggregationStates() (map[string][]byte, error) {
+	states := make(map[string][]byte)
+	if err := d.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(aggregationStatePrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			states[strings.TrimPrefix(string(item.Key()), aggregationStatePrefix)] = val
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
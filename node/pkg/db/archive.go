@@ -0,0 +1,214 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// A VAA archive is a portable export of signed VAAs:
+//
+//	magic (8 bytes) | record* | 0 (uint32) | record count (uint64) | sha256 (32 bytes)
+//
+// Each record is the length of the VAA (uint32) followed by the serialized VAA. All integers are
+// big-endian. The checksum covers everything before it, so truncated or corrupted archives are detected.
+const archiveMagic = "WHVAAS01"
+
+// maxArchiveRecordSize bounds the allocation for a single record of an untrusted archive.
+const maxArchiveRecordSize = 1 << 20
+
+var ErrArchiveChecksum = errors.New("archive checksum mismatch")
+
+// ArchiveWriter writes signed VAAs to a VAA archive. Close must be called to write the trailer.
+type ArchiveWriter struct {
+	w     *bufio.Writer
+	h     hash.Hash
+	count uint64
+}
+
+func NewArchiveWriter(w io.Writer) (*ArchiveWriter, error) {
+	a := &ArchiveWriter{w: bufio.NewWriter(w), h: sha256.New()}
+	if err := a.write([]byte(archiveMagic)); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *ArchiveWriter) write(b []byte) error {
+	a.h.Write(b)
+	_, err := a.w.Write(b)
+	return err
+}
+
+// Write appends a serialized signed VAA to the archive.
+func (a *ArchiveWriter) Write(vaaBytes []byte) error {
+	if len(vaaBytes) == 0 || len(vaaBytes) > maxArchiveRecordSize {
+		return fmt.Errorf("invalid VAA size: %d", len(vaaBytes))
+	}
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(vaaBytes)))
+	if err := a.write(l[:]); err != nil {
+		return err
+	}
+	if err := a.write(vaaBytes); err != nil {
+		return err
+	}
+	a.count++
+	return nil
+}
+
+// Count returns the number of VAAs written so far.
+func (a *ArchiveWriter) Count() uint64 {
+	return a.count
+}
+
+// Close writes the trailer and flushes the archive. It doesn't close the underlying writer.
+func (a *ArchiveWriter) Close() error {
+	var trailer [12]byte
+	binary.BigEndian.PutUint64(trailer[4:], a.count)
+	if err := a.write(trailer[:]); err != nil {
+		return err
+	}
+	if _, err := a.w.Write(a.h.Sum(nil)); err != nil {
+		return err
+	}
+	return a.w.Flush()
+}
+
+// ArchiveReader reads the signed VAAs of a VAA archive.
+type ArchiveReader struct {
+	r     *bufio.Reader
+	h     hash.Hash
+	count uint64
+}
+
+func NewArchiveReader(r io.Reader) (*ArchiveReader, error) {
+	a := &ArchiveReader{r: bufio.NewReader(r), h: sha256.New()}
+	magic := make([]byte, len(archiveMagic))
+	if err := a.read(magic); err != nil {
+		return nil, fmt.Errorf("failed to read archive header: %w", err)
+	}
+	if string(magic) != archiveMagic {
+		return nil, errors.New("not a VAA archive")
+	}
+	return a, nil
+}
+
+func (a *ArchiveReader) read(b []byte) error {
+	if _, err := io.ReadFull(a.r, b); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	a.h.Write(b)
+	return nil
+}
+
+// Next returns the next serialized VAA. At the end of the archive, it verifies the trailer and returns
+// io.EOF if the archive is intact, or ErrArchiveChecksum if it isn't. Since the checksum is only known at the
+// end, callers must not trust the returned VAAs before reaching io.EOF.
+func (a *ArchiveReader) Next() ([]byte, error) {
+	var l [4]byte
+	if err := a.read(l[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(l[:])
+	if size == 0 {
+		return nil, a.verifyTrailer()
+	}
+	if size > maxArchiveRecordSize {
+		return nil, fmt.Errorf("invalid VAA size: %d", size)
+	}
+	b := make([]byte, size)
+	if err := a.read(b); err != nil {
+		return nil, err
+	}
+	a.count++
+	return b, nil
+}
+
+func (a *ArchiveReader) verifyTrailer() error {
+	var count [8]byte
+	if err := a.read(count[:]); err != nil {
+		return err
+	}
+	sum := a.h.Sum(nil)
+	expected := make([]byte, len(sum))
+	if _, err := io.ReadFull(a.r, expected); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if !bytes.Equal(sum, expected) {
+		return ErrArchiveChecksum
+	}
+	if binary.BigEndian.Uint64(count[:]) != a.count {
+		return fmt.Errorf("archive contains %d VAAs, trailer claims %d", a.count, binary.BigEndian.Uint64(count[:]))
+	}
+	return io.EOF
+}
+
+// ExportFilter selects the signed VAAs to export. The zero value selects all of them.
+type ExportFilter struct {
+	// EmitterChain restricts the export to the chain, 0 for all chains.
+	EmitterChain vaa.ChainID
+	// EmitterAddress restricts the export to the emitter, nil for all emitters. Requires EmitterChain.
+	EmitterAddress *vaa.Address
+	// MinSequence and MaxSequence restrict the export to the inclusive sequence range, MaxSequence 0 for no upper bound.
+	MinSequence uint64
+	MaxSequence uint64
+}
+
+func (f *ExportFilter) prefix() []byte {
+	switch {
+	case f.EmitterAddress != nil:
+		return []byte(fmt.Sprintf("signed/%d/%s/", f.EmitterChain, f.EmitterAddress))
+	case f.EmitterChain != 0:
+		return []byte(fmt.Sprintf("signed/%d/", f.EmitterChain))
+	default:
+		return []byte("signed/")
+	}
+}
+
+func (f *ExportFilter) matches(id *VAAID) bool {
+	return id.Sequence >= f.MinSequence && (f.MaxSequence == 0 || id.Sequence <= f.MaxSequence)
+}
+
+// ExportSignedVAAs writes the signed VAAs selected by the filter to the archive and returns their number.
+func (d *Database) ExportSignedVAAs(a *ArchiveWriter, filter ExportFilter) (int, error) {
+	if filter.EmitterAddress != nil && filter.EmitterChain == 0 {
+		return 0, errors.New("filtering by emitter address requires an emitter chain")
+	}
+
+	n := 0
+	prefix := filter.prefix()
+	if err := d.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			id, err := vaaIDFromKey(item.Key())
+			if err != nil {
+				return err
+			}
+			if !filter.matches(id) {
+				continue
+			}
+			if err := item.Value(a.Write); err != nil {
+				return fmt.Errorf("failed to export %s: %w", id.String(), err)
+			}
+			n++
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
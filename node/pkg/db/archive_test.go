@@ -0,0 +1,112 @@
+package db
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/stretchr/testify/assert"
+)
+
+func readAllArchive(t *testing.T, b []byte) ([][]byte, error) {
+	a, err := NewArchiveReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	var vaas [][]byte
+	for {
+		v, err := a.Next()
+		if err == io.EOF {
+			return vaas, nil
+		} else if err != nil {
+			return vaas, err
+		}
+		vaas = append(vaas, v)
+	}
+}
+
+func exportTestArchive(t *testing.T, d *Database, filter ExportFilter) ([]byte, int) {
+	var buf bytes.Buffer
+	a, err := NewArchiveWriter(&buf)
+	assert.Nil(t, err)
+	n, err := d.ExportSignedVAAs(a, filter)
+	assert.Nil(t, err)
+	assert.Nil(t, a.Close())
+	return buf.Bytes(), n
+}
+
+func TestArchiveRoundtrip(t *testing.T) {
+	d := openTestDB(t)
+	start := time.Unix(1650000000, 0)
+	for seq := uint64(0); seq < 12; seq++ {
+		assert.Nil(t, d.StoreSignedVAA(testVAA(seq, start)))
+	}
+	other := testVAA(1, start)
+	other.EmitterChain = vaa.ChainIDSolana
+	assert.Nil(t, d.StoreSignedVAA(other))
+
+	b, n := exportTestArchive(t, d, ExportFilter{})
+	assert.Equal(t, 13, n)
+	vaas, err := readAllArchive(t, b)
+	assert.Nil(t, err)
+	assert.Equal(t, 13, len(vaas))
+
+	// import into an empty database
+	imported := openTestDB(t)
+	for _, b := range vaas {
+		v, err := vaa.Unmarshal(b)
+		assert.Nil(t, err)
+		assert.Nil(t, imported.StoreSignedVAA(v))
+	}
+	seqs, err := imported.GetEmitterSequences(*VaaIDFromVAA(testVAA(0, start)))
+	assert.Nil(t, err)
+	assert.Equal(t, 12, len(seqs))
+}
+
+func TestExportFilter(t *testing.T) {
+	d := openTestDB(t)
+	start := time.Unix(1650000000, 0)
+	for seq := uint64(0); seq < 12; seq++ {
+		assert.Nil(t, d.StoreSignedVAA(testVAA(seq, start)))
+	}
+	other := testVAA(5, start)
+	other.EmitterChain = vaa.ChainIDSolana
+	assert.Nil(t, d.StoreSignedVAA(other))
+
+	_, n := exportTestArchive(t, d, ExportFilter{EmitterChain: vaa.ChainIDSolana})
+	assert.Equal(t, 1, n)
+
+	emitter := vaa.Address{1, 2, 3}
+	b, n := exportTestArchive(t, d, ExportFilter{EmitterChain: vaa.ChainIDEthereum, EmitterAddress: &emitter, MinSequence: 2, MaxSequence: 10})
+	assert.Equal(t, 9, n)
+	vaas, err := readAllArchive(t, b)
+	assert.Nil(t, err)
+	assert.Equal(t, 9, len(vaas))
+
+	var buf bytes.Buffer
+	a, err := NewArchiveWriter(&buf)
+	assert.Nil(t, err)
+	_, err = d.ExportSignedVAAs(a, ExportFilter{EmitterAddress: &emitter})
+	assert.NotNil(t, err)
+}
+
+func TestArchiveCorruption(t *testing.T) {
+	d := openTestDB(t)
+	for seq := uint64(0); seq < 3; seq++ {
+		assert.Nil(t, d.StoreSignedVAA(testVAA(seq, time.Unix(1650000000, 0))))
+	}
+	b, _ := exportTestArchive(t, d, ExportFilter{})
+
+	corrupted := append([]byte{}, b...)
+	corrupted[len(archiveMagic)+20] ^= 0xff
+	_, err := readAllArchive(t, corrupted)
+	assert.Equal(t, ErrArchiveChecksum, err)
+
+	_, err = readAllArchive(t, b[:len(b)-10])
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = readAllArchive(t, []byte("not an archive"))
+	assert.NotNil(t, err)
+}
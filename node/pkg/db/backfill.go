@@ -0,0 +1,78 @@
+package db
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Progress of RPC backfills, stored per sequence so that an interrupted backfill can be resumed:
+//
+//	backfill/<chain>/<emitter>/<big-endian sequence>
+const backfillProgressPrefix = "backfill/"
+
+func backfillProgressPrefixBytes(emitter VAAID) []byte {
+	return []byte(fmt.Sprintf("%s%d/%s/", backfillProgressPrefix, emitter.EmitterChain, emitter.EmitterAddress))
+}
+
+func backfillProgressKey(id VAAID) []byte {
+	return append(backfillProgressPrefixBytes(id), uint64Bytes(id.Sequence)...)
+}
+
+// StoreBackfillProgress stores the serialized backfill progress of a single sequence.
+// The backfill package owns the serialization format.
+func (d *Database) StoreBackfillProgress(id VAAID, progress []byte) error {
+	if err := d.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(backfillProgressKey(id), progress)
+	}); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetBackfillProgress returns the stored backfill progress of the emitter by sequence.
+func (d *Database) GetBackfillProgress(emitter VAAID) (map[uint64][]byte, error) {
+	progress := make(map[uint64][]byte)
+	prefix := backfillProgressPrefixBytes(emitter)
+	if err := d.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			key := item.Key()
+			if len(key) != len(prefix)+8 {
+				return fmt.Errorf("invalid backfill progress key: %x", key)
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			progress[binary.BigEndian.Uint64(key[len(prefix):])] = val
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// DeleteBackfillProgress removes the stored backfill progress of the emitter.
+func (d *Database) DeleteBackfillProgress(emitter VAAID) error {
+	prefix := backfillProgressPrefixBytes(emitter)
+	if err := d.db.Update(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := txn.Delete(it.Item().KeyCopy(nil)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
@@ -7,10 +7,15 @@ import (
 	"github.com/dgraph-io/badger/v3"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type Database struct {
 	db *badger.DB
+
+	// vaaLogMu serializes the appends to the VAA log. vaaLogPosition is the position of its last entry.
+	vaaLogMu       sync.Mutex
+	vaaLogPosition uint64
 }
 
 type VAAID struct {
@@ -75,9 +80,18 @@ func Open(path string) (*Database, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-	return &Database{
+	d := &Database{
 		db: db,
-	}, nil
+	}
+	if err := d.migrateIndexes(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if d.vaaLogPosition, err = d.loadVAALogPosition(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read VAA log: %w", err)
+	}
+	return d, nil
 }
 
 func (d *Database) Close() error {
@@ -98,14 +112,23 @@ func (d *Database) StoreSignedVAA(v *vaa.VAA) error {
 	//
 	// TODO: panic on non-identical signing digest?
 
+	d.vaaLogMu.Lock()
+	defer d.vaaLogMu.Unlock()
+	position := d.vaaLogPosition
 	err := d.db.Update(func(txn *badger.Txn) error {
-		if err := txn.Set(VaaIDFromVAA(v).Bytes(), b); err != nil {
+		key := VaaIDFromVAA(v).Bytes()
+		if err := txn.Set(key, b); err != nil {
 			return err
 		}
-		return nil
+		if err := setVAAIndexes(txn.Set, v); err != nil {
+			return err
+		}
+		return d.appendVAALog(txn, key)
 	})
 
 	if err != nil {
+		// the position wasn't used
+		d.vaaLogPosition = position
 		return fmt.Errorf("failed to commit tx: %w", err)
 	}
 
@@ -135,57 +158,28 @@ func (d *Database) GetSignedVAABytes(id VAAID) (b []byte, err error) {
 
 func (d *Database) FindEmitterSequenceGap(prefix VAAID) (resp []uint64, firstSeq uint64, lastSeq uint64, err error) {
 	resp = make([]uint64, 0)
+	seqs, err := d.GetEmitterSequences(prefix)
+	if err != nil || len(seqs) == 0 {
+		return
+	}
 	if err = d.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-		prefix := prefix.EmitterPrefixBytes()
-
-		// Find all sequence numbers (the message IDs are ordered lexicographically,
-		// rather than numerically, so we need to sort them in-memory).
-		seqs := make(map[uint64]bool)
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			key := item.Key()
-			err := item.Value(func(val []byte) error {
-				v, err := vaa.Unmarshal(val)
-				if err != nil {
-					return fmt.Errorf("failed to unmarshal VAA for %s: %v", string(key), err)
-				}
-
-				seqs[v.Sequence] = true
-				return nil
-			})
-			if err != nil {
-				return err
-			}
-		}
+		firstSeq, err = pruningLowWaterMark(txn, prefix)
+		return err
+	}); err != nil {
+		return
+	}
 
-		// Find min/max (yay lack of Go generics)
-		first := false
-		for k := range seqs {
-			if first {
-				firstSeq = k
-				first = false
-			}
-			if k < firstSeq {
-				firstSeq = k
-			}
-			if k > lastSeq {
-				lastSeq = k
-			}
+	// Emitter sequences start at zero, so every sequence up to the last stored one is expected, except
+	// for the ones below the pruning low-water mark which may have been pruned by the retention policy.
+	lastSeq = seqs[len(seqs)-1]
+	next := firstSeq
+	for _, seq := range seqs {
+		for ; next < seq; next++ {
+			resp = append(resp, next)
 		}
-
-		// Figure out gaps.
-		for i := firstSeq; i <= lastSeq; i++ {
-			if !seqs[i] {
-				fmt.Printf("missing: %d\n", i)
-				resp = append(resp, i)
-			}
+		if seq+1 > next {
+			next = seq + 1
 		}
-
-		return nil
-	}); err != nil {
-		return
 	}
 	return
 }
@@ -0,0 +1,391 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Secondary indexes of the signed VAAs. Every index entry points to the primary key of the VAA (see VAAID.Bytes).
+//
+//	index/digest/<hex signing digest>
+//	index/tx/<hex source tx hash>/<chain>/<emitter>/<sequence>
+//	index/seq/<chain>/<emitter>/<big-endian sequence>
+//	index/time/<big-endian unix timestamp>/<chain>/<emitter>/<sequence>
+//
+// The sequence and timestamp keys are fixed-width big-endian integers, so they sort numerically.
+//
+// The source transaction hashes aren't part of the VAAs, so every tx index entry has a reverse entry which points
+// back to it. Pruning finds the tx index entries of a VAA through them:
+//
+//	index/vaa-tx/<chain>/<emitter>/<sequence>/<hex source tx hash>	-> tx index key
+const (
+	digestIndexPrefix = "index/digest/"
+	txIndexPrefix     = "index/tx/"
+	vaaTxIndexPrefix  = "index/vaa-tx/"
+	seqIndexPrefix    = "index/seq/"
+	timeIndexPrefix   = "index/time/"
+
+	indexVersionKey = "meta/index-version"
+	// indexVersion is the version of the index layout. Databases with an older version are migrated on open.
+	indexVersion = 3
+	// reverseIndexVersion is the index version which introduced the reverse entries of the tx index and the VAA log.
+	reverseIndexVersion = 3
+)
+
+func uint64Bytes(i uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, i)
+	return b
+}
+
+func digestIndexKey(digest []byte) []byte {
+	return []byte(digestIndexPrefix + hex.EncodeToString(digest))
+}
+
+func txIndexPrefixBytes(txHash []byte) []byte {
+	return []byte(fmt.Sprintf("%s%s/", txIndexPrefix, hex.EncodeToString(txHash)))
+}
+
+func txIndexKey(txHash []byte, id VAAID) []byte {
+	return append(txIndexPrefixBytes(txHash), id.String()...)
+}
+
+func vaaTxIndexPrefixBytes(id VAAID) []byte {
+	return []byte(fmt.Sprintf("%s%s/", vaaTxIndexPrefix, id.String()))
+}
+
+func vaaTxIndexKey(id VAAID, txHash []byte) []byte {
+	return append(vaaTxIndexPrefixBytes(id), hex.EncodeToString(txHash)...)
+}
+
+func seqIndexPrefixBytes(id VAAID) []byte {
+	return []byte(fmt.Sprintf("%s%d/%s/", seqIndexPrefix, id.EmitterChain, id.EmitterAddress))
+}
+
+func seqIndexKey(id VAAID) []byte {
+	return append(seqIndexPrefixBytes(id), uint64Bytes(id.Sequence)...)
+}
+
+func timeIndexPrefixBytes(t time.Time) []byte {
+	return append([]byte(timeIndexPrefix), uint64Bytes(uint64(t.Unix()))...)
+}
+
+func timeIndexKey(t time.Time, id VAAID) []byte {
+	return append(append(timeIndexPrefixBytes(t), '/'), id.String()...)
+}
+
+// String returns the <chain>/<address>/<sequence> representation parsed by VaaIDFromString.
+func (i *VAAID) String() string {
+	return fmt.Sprintf("%d/%s/%d", i.EmitterChain, i.EmitterAddress, i.Sequence)
+}
+
+// vaaIDFromKey parses the primary key of a signed VAA.
+func vaaIDFromKey(key []byte) (*VAAID, error) {
+	s := string(key)
+	if !strings.HasPrefix(s, "signed/") {
+		return nil, fmt.Errorf("invalid VAA key: %s", s)
+	}
+	return VaaIDFromString(strings.TrimPrefix(s, "signed/"))
+}
+
+// setVAAIndexes writes the index entries of the VAA which can be derived from the VAA itself.
+func setVAAIndexes(set func(key, val []byte) error, v *vaa.VAA) error {
+	id := VaaIDFromVAA(v)
+	key := id.Bytes()
+	if err := set(digestIndexKey(v.SigningMsg().Bytes()), key); err != nil {
+		return err
+	}
+	if err := set(seqIndexKey(*id), key); err != nil {
+		return err
+	}
+	return set(timeIndexKey(v.Timestamp, *id), key)
+}
+
+// StoreSourceTxHash records the transaction on the emitter chain in which the message of the VAA was published.
+// The entry can be written before the VAA reaches quorum, so lookups by transaction may return
+// VAAs which aren't stored (yet).
+func (d *Database) StoreSourceTxHash(id VAAID, txHash []byte) error {
+	if err := d.db.Update(func(txn *badger.Txn) error {
+		key := txIndexKey(txHash, id)
+		if err := txn.Set(key, id.Bytes()); err != nil {
+			return err
+		}
+		return txn.Set(vaaTxIndexKey(id, txHash), key)
+	}); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetSignedVAABytesByDigest returns the signed VAA with the given signing digest.
+func (d *Database) GetSignedVAABytesByDigest(digest []byte) (b []byte, err error) {
+	if err := d.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(digestIndexKey(digest))
+		if err != nil {
+			return err
+		}
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		item, err = txn.Get(key)
+		if err != nil {
+			return err
+		}
+		b, err = item.ValueCopy(nil)
+		return err
+	}); err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrVAANotFound
+		}
+		return nil, err
+	}
+	return
+}
+
+// findIndexedVAAIDs returns the VAA ids which the index entries with the given prefix point to, in key order.
+// The iteration stops at the first key for which done returns true.
+func (d *Database) findIndexedVAAIDs(prefix []byte, start []byte, done func(key []byte) bool) (ids []VAAID, err error) {
+	ids = make([]VAAID, 0)
+	if err := d.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if done != nil && done(item.Key()) {
+				break
+			}
+			err := item.Value(func(val []byte) error {
+				id, err := vaaIDFromKey(val)
+				if err != nil {
+					return err
+				}
+				ids = append(ids, *id)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// FindVAAIDsByTxHash returns the ids of the VAAs whose messages were published in the given transaction.
+func (d *Database) FindVAAIDsByTxHash(txHash []byte) ([]VAAID, error) {
+	prefix := txIndexPrefixBytes(txHash)
+	return d.findIndexedVAAIDs(prefix, prefix, nil)
+}
+
+// FindVAAIDsByTimestamp returns the ids of the VAAs with a timestamp in [from, to), ordered by timestamp.
+func (d *Database) FindVAAIDsByTimestamp(from time.Time, to time.Time) ([]VAAID, error) {
+	end := timeIndexPrefixBytes(to)
+	return d.findIndexedVAAIDs([]byte(timeIndexPrefix), timeIndexPrefixBytes(from), func(key []byte) bool {
+		return bytes.Compare(key, end) >= 0
+	})
+}
+
+// GetEmitterSequences returns the sequences of the stored VAAs of the emitter in ascending order.
+func (d *Database) GetEmitterSequences(emitter VAAID) (seqs []uint64, err error) {
+	seqs = make([]uint64, 0)
+	prefix := seqIndexPrefixBytes(emitter)
+	if err := d.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			key := it.Item().Key()
+			if len(key) != len(prefix)+8 {
+				return fmt.Errorf("invalid sequence index key: %x", key)
+			}
+			seqs = append(seqs, binary.BigEndian.Uint64(key[len(prefix):]))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return seqs, nil
+}
+
+// migrateIndexes builds the secondary indexes of the VAAs stored by an older version. Source transaction
+// hashes aren't part of the VAAs, so they can't be recovered for existing entries. VAAs stored before the
+// VAA log existed are logged in key order. The reverse entries are built from the existing tx index and log.
+func (d *Database) migrateIndexes() error {
+	var version uint64
+	if err := d.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(indexVersionKey))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if len(val) != 8 {
+				return fmt.Errorf("invalid index version length: %d", len(val))
+			}
+			version = binary.BigEndian.Uint64(val)
+			return nil
+		})
+	}); err != nil {
+		return fmt.Errorf("failed to read index version: %w", err)
+	}
+	if version >= indexVersion {
+		return nil
+	}
+
+	var position uint64
+	logVAAs := version < vaaLogIndexVersion
+	if logVAAs {
+		last, err := d.lastVAALogPosition()
+		if err != nil {
+			return fmt.Errorf("failed to read VAA log: %w", err)
+		}
+		logVAAs = last == 0
+	}
+
+	wb := d.db.NewWriteBatch()
+	defer wb.Cancel()
+	if err := d.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte("signed/")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			key := item.KeyCopy(nil)
+			err := item.Value(func(val []byte) error {
+				v, err := vaa.Unmarshal(val)
+				if err != nil {
+					return fmt.Errorf("failed to unmarshal VAA for %s: %v", string(key), err)
+				}
+				if err := setVAAIndexes(wb.Set, v); err != nil {
+					return err
+				}
+				if logVAAs {
+					position++
+					return setVAALogEntry(wb.Set, position, key)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if version < reverseIndexVersion {
+			if err := setTxIndexReverseEntries(txn, wb); err != nil {
+				return err
+			}
+			return setVAALogReverseEntries(txn, wb)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to index stored VAAs: %w", err)
+	}
+
+	if logVAAs {
+		if err := wb.Set([]byte(vaaLogPositionKey), uint64Bytes(position)); err != nil {
+			return err
+		}
+	}
+	if err := wb.Set([]byte(indexVersionKey), uint64Bytes(indexVersion)); err != nil {
+		return err
+	}
+	if err := wb.Flush(); err != nil {
+		return fmt.Errorf("failed to write indexes: %w", err)
+	}
+	return nil
+}
+
+// setTxIndexReverseEntries writes the reverse entries of the existing tx index entries.
+func setTxIndexReverseEntries(txn *badger.Txn, wb *badger.WriteBatch) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	prefix := []byte(txIndexPrefix)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		key := it.Item().KeyCopy(nil)
+		// index/tx/<hex tx hash>/<chain>/<emitter>/<sequence>
+		parts := strings.SplitN(strings.TrimPrefix(string(key), txIndexPrefix), "/", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid tx index key: %s", key)
+		}
+		txHash, err := hex.DecodeString(parts[0])
+		if err != nil {
+			return fmt.Errorf("invalid tx index key: %s", key)
+		}
+		id, err := VaaIDFromString(parts[1])
+		if err != nil {
+			return err
+		}
+		if err := wb.Set(vaaTxIndexKey(*id, txHash), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SequencedVAA is a signed VAA along with its sequence.
+type SequencedVAA struct {
+	Sequence uint64
+	Bytes    []byte
+}
+
+// ListSignedVAAs returns up to limit signed VAAs of the emitter with a sequence in [from, to], ordered by sequence.
+// to is ignored if it's 0. more is true if there are further VAAs in the range.
+func (d *Database) ListSignedVAAs(emitter VAAID, from uint64, to uint64, limit int) (vaas []SequencedVAA, more bool, err error) {
+	vaas = make([]SequencedVAA, 0)
+	emitter.Sequence = from
+	prefix := seqIndexPrefixBytes(emitter)
+	if err := d.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(seqIndexKey(emitter)); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			key := item.Key()
+			if len(key) != len(prefix)+8 {
+				return fmt.Errorf("invalid sequence index key: %x", key)
+			}
+			seq := binary.BigEndian.Uint64(key[len(prefix):])
+			if to != 0 && seq > to {
+				break
+			}
+			if len(vaas) == limit {
+				more = true
+				break
+			}
+
+			primary, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			vaaItem, err := txn.Get(primary)
+			if err == badger.ErrKeyNotFound {
+				// stale index entry
+				continue
+			} else if err != nil {
+				return err
+			}
+			b, err := vaaItem.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			vaas = append(vaas, SequencedVAA{Sequence: seq, Bytes: b})
+		}
+		return nil
+	}); err != nil {
+		return nil, false, err
+	}
+	return vaas, more, nil
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestDB(t *testing.T) *Database {
+	d, err := Open(t.TempDir())
+	assert.Nil(t, err)
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func testVAA(seq uint64, timestamp time.Time) *vaa.VAA {
+	return &vaa.VAA{
+		Version:          vaa.SupportedVAAVersion,
+		GuardianSetIndex: 0,
+		Signatures:       []*vaa.Signature{{Index: 0}},
+		Timestamp:        timestamp,
+		Nonce:            1,
+		Sequence:         seq,
+		ConsistencyLevel: 1,
+		EmitterChain:     vaa.ChainIDEthereum,
+		EmitterAddress:   vaa.Address{1, 2, 3},
+		Payload:          []byte{1, 2, 3},
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	d := openTestDB(t)
+	start := time.Unix(1650000000, 0)
+	// stored out of order, and with sequences which sort differently as strings
+	for _, seq := range []uint64{10, 2, 1, 0, 5} {
+		assert.Nil(t, d.StoreSignedVAA(testVAA(seq, start.Add(time.Duration(seq)*time.Minute))))
+	}
+	emitter := VAAID{EmitterChain: vaa.ChainIDEthereum, EmitterAddress: vaa.Address{1, 2, 3}}
+
+	seqs, err := d.GetEmitterSequences(emitter)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{0, 1, 2, 5, 10}, seqs)
+
+	missing, first, last, err := d.FindEmitterSequenceGap(emitter)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{3, 4, 6, 7, 8, 9}, missing)
+	assert.Equal(t, uint64(0), first)
+	assert.Equal(t, uint64(10), last)
+
+	v := testVAA(5, start.Add(5*time.Minute))
+	b, err := d.GetSignedVAABytesByDigest(v.SigningMsg().Bytes())
+	assert.Nil(t, err)
+	expected, _ := v.Marshal()
+	assert.Equal(t, expected, b)
+	_, err = d.GetSignedVAABytesByDigest(testVAA(3, start).SigningMsg().Bytes())
+	assert.Equal(t, ErrVAANotFound, err)
+
+	ids, err := d.FindVAAIDsByTimestamp(start.Add(time.Minute), start.Add(10*time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(ids))
+	for i, seq := range []uint64{1, 2, 5} {
+		assert.Equal(t, seq, ids[i].Sequence)
+	}
+
+	txHash := []byte{0xaa, 0xbb}
+	assert.Nil(t, d.StoreSourceTxHash(*VaaIDFromVAA(v), txHash))
+	ids, err = d.FindVAAIDsByTxHash(txHash)
+	assert.Nil(t, err)
+	assert.Equal(t, []VAAID{*VaaIDFromVAA(v)}, ids)
+	ids, err = d.FindVAAIDsByTxHash([]byte{0xaa})
+	assert.Nil(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestMigrateIndexes(t *testing.T) {
+	d := openTestDB(t)
+
+	// simulate a database written before the indexes existed
+	v := testVAA(3, time.Unix(1650000000, 0))
+	b, _ := v.Marshal()
+	assert.Nil(t, d.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(VaaIDFromVAA(v).Bytes(), b); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(indexVersionKey))
+	}))
+
+	seqs, err := d.GetEmitterSequences(*VaaIDFromVAA(v))
+	assert.Nil(t, err)
+	assert.Empty(t, seqs)
+
+	assert.Nil(t, d.migrateIndexes())
+
+	seqs, err = d.GetEmitterSequences(*VaaIDFromVAA(v))
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{3}, seqs)
+	stored, err := d.GetSignedVAABytesByDigest(v.SigningMsg().Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, b, stored)
+}
+
+func TestListSignedVAAs(t *testing.T) {
+	d := openTestDB(t)
+	for _, seq := range []uint64{0, 1, 2, 5, 10} {
+		assert.Nil(t, d.StoreSignedVAA(testVAA(seq, time.Unix(1650000000, 0))))
+	}
+	emitter := VAAID{EmitterChain: vaa.ChainIDEthereum, EmitterAddress: vaa.Address{1, 2, 3}}
+	sequences := func(vaas []SequencedVAA) []uint64 {
+		seqs := make([]uint64, len(vaas))
+		for i, v := range vaas {
+			seqs[i] = v.Sequence
+		}
+		return seqs
+	}
+
+	vaas, more, err := d.ListSignedVAAs(emitter, 1, 0, 2)
+	assert.Nil(t, err)
+	assert.True(t, more)
+	assert.Equal(t, []uint64{1, 2}, sequences(vaas))
+	expected, _ := testVAA(1, time.Unix(1650000000, 0)).Marshal()
+	assert.Equal(t, expected, vaas[0].Bytes)
+
+	vaas, more, err = d.ListSignedVAAs(emitter, 3, 0, 2)
+	assert.Nil(t, err)
+	assert.False(t, more)
+	assert.Equal(t, []uint64{5, 10}, sequences(vaas))
+
+	// the upper bound is inclusive, and a full last page doesn't report more results
+	vaas, more, err = d.ListSignedVAAs(emitter, 0, 5, 4)
+	assert.Nil(t, err)
+	assert.False(t, more)
+	assert.Equal(t, []uint64{0, 1, 2, 5}, sequences(vaas))
+
+	vaas, more, err = d.ListSignedVAAs(VAAID{EmitterChain: vaa.ChainIDSolana}, 0, 0, 10)
+	assert.Nil(t, err)
+	assert.False(t, more)
+	assert.Empty(t, vaas)
+}
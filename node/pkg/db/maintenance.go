@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	dbSize = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "wormhole_db_size_bytes",
+			Help: "Size of the guardian database on disk, by LSM tree and value log",
+		}, []string{"type"})
+	dbKeys = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "wormhole_db_keys",
+			Help: "Number of keys in the guardian database, by key prefix",
+		}, []string{"prefix"})
+	dbPrunedVAAs = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_db_pruned_vaas_total",
+			Help: "Total number of signed VAAs deleted by the retention policy",
+		})
+	dbValueLogGCRewrites = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_db_value_log_gc_rewrites_total",
+			Help: "Total number of value log files rewritten by the value log garbage collection",
+		})
+)
+
+// valueLogGCDiscardRatio is the fraction of a value log file which has to be garbage for it to be rewritten.
+const valueLogGCDiscardRatio = 0.5
+
+// Maintenance returns a runnable which prunes the signed VAAs according to the retention policy,
+// garbage collects the value log and updates the database metrics every interval.
+func (d *Database) Maintenance(policy RetentionPolicy, interval time.Duration) supervisor.Runnable {
+	return func(ctx context.Context) error {
+		logger := supervisor.Logger(ctx)
+		logger.Info("starting database maintenance",
+			zap.Stringer("retention", policy),
+			zap.Duration("interval", interval))
+
+		supervisor.Signal(ctx, supervisor.SignalHealthy)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			d.maintain(logger, policy)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+}
+
+func (d *Database) maintain(logger *zap.Logger, policy RetentionPolicy) {
+	if n, err := d.PruneSignedVAAs(policy, time.Now()); err != nil {
+		logger.Error("failed to prune signed VAAs", zap.Error(err))
+	} else if n > 0 {
+		logger.Info("pruned signed VAAs", zap.Int("count", n))
+		dbPrunedVAAs.Add(float64(n))
+	}
+
+	// Each run rewrites at most one value log file, so keep going until there is nothing left to reclaim.
+	for {
+		if err := d.db.RunValueLogGC(valueLogGCDiscardRatio); err != nil {
+			if err != badger.ErrNoRewrite {
+				logger.Error("failed to garbage collect value log", zap.Error(err))
+			}
+			break
+		}
+		dbValueLogGCRewrites.Inc()
+	}
+
+	lsm, vlog := d.db.Size()
+	dbSize.WithLabelValues("lsm").Set(float64(lsm))
+	dbSize.WithLabelValues("vlog").Set(float64(vlog))
+
+	counts, err := d.CountKeys()
+	if err != nil {
+		logger.Error("failed to count database keys", zap.Error(err))
+		return
+	}
+	for prefix, n := range counts {
+		dbKeys.WithLabelValues(prefix).Set(float64(n))
+	}
+}
+
+// CountKeys returns the number of keys by their first path segment, e.g. "signed" or "index".
+func (d *Database) CountKeys() (map[string]int, error) {
+	counts := make(map[string]int)
+	if err := d.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := string(it.Item().Key())
+			if i := strings.Index(key, "/"); i >= 0 {
+				key = key[:i]
+			}
+			counts[key]++
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,277 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// RetentionPolicy selects the signed VAAs which are pruned from the store. The zero value keeps everything.
+// Governance VAAs are always kept.
+type RetentionPolicy struct {
+	// KeepSequences keeps the VAAs with the N highest sequences of every emitter, 0 to disable.
+	KeepSequences uint64
+	// MaxAge keeps the VAAs with a timestamp newer than the given duration, 0 to disable.
+	MaxAge time.Duration
+}
+
+func (p RetentionPolicy) Validate() error {
+	if p.KeepSequences != 0 && p.MaxAge != 0 {
+		return errors.New("retention by sequences and by age are mutually exclusive")
+	}
+	if p.MaxAge < 0 {
+		return errors.New("retention age must not be negative")
+	}
+	return nil
+}
+
+func (p RetentionPolicy) KeepAll() bool {
+	return p.KeepSequences == 0 && p.MaxAge == 0
+}
+
+func (p RetentionPolicy) String() string {
+	switch {
+	case p.KeepSequences != 0:
+		return fmt.Sprintf("keep last %d sequences per emitter", p.KeepSequences)
+	case p.MaxAge != 0:
+		return fmt.Sprintf("keep VAAs newer than %s", p.MaxAge)
+	default:
+		return "keep all VAAs"
+	}
+}
+
+// Pruning low-water marks of the emitters. All VAAs of an emitter below its mark may have been pruned, so the
+// gap search starts at the mark rather than at sequence 0:
+//
+//	retention/low/<chain>/<emitter>	-> big-endian sequence following the highest pruned one
+const retentionLowPrefix = "retention/low/"
+
+func retentionLowKey(emitter VAAID) []byte {
+	return []byte(fmt.Sprintf("%s%d/%s", retentionLowPrefix, emitter.EmitterChain, emitter.EmitterAddress))
+}
+
+// pruningLowWaterMark returns the low-water mark of the emitter, 0 if none of its VAAs were pruned.
+func pruningLowWaterMark(txn *badger.Txn, emitter VAAID) (uint64, error) {
+	item, err := txn.Get(retentionLowKey(emitter))
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	var mark uint64
+	err = item.Value(func(val []byte) error {
+		if len(val) != 8 {
+			return fmt.Errorf("invalid pruning low-water mark: %x", val)
+		}
+		mark = binary.BigEndian.Uint64(val)
+		return nil
+	})
+	return mark, err
+}
+
+// raiseLowWaterMarks raises the low-water marks of the emitters of the pruned VAAs past their highest pruned sequence.
+func raiseLowWaterMarks(txn *badger.Txn, wb *badger.WriteBatch, pruned []VAAID) error {
+	marks := make(map[string]VAAID)
+	for _, id := range pruned {
+		key := string(retentionLowKey(id))
+		if m, ok := marks[key]; !ok || id.Sequence > m.Sequence {
+			marks[key] = id
+		}
+	}
+	for key, id := range marks {
+		current, err := pruningLowWaterMark(txn, id)
+		if err != nil {
+			return err
+		}
+		if id.Sequence+1 <= current {
+			continue
+		}
+		if err := wb.Set([]byte(key), uint64Bytes(id.Sequence+1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isGovernanceEmitter(id VAAID) bool {
+	return id.EmitterChain == vaa.GovernanceChain && id.EmitterAddress == vaa.GovernanceEmitter
+}
+
+// PruneSignedVAAs deletes the signed VAAs which aren't retained by the policy, along with their index
+// entries, and returns the number of deleted VAAs. now is the reference time of the age limit. The
+// pruning low-water marks of the emitters are raised past the deleted VAAs.
+func (d *Database) PruneSignedVAAs(policy RetentionPolicy, now time.Time) (int, error) {
+	if err := policy.Validate(); err != nil {
+		return 0, err
+	}
+	if policy.KeepAll() {
+		return 0, nil
+	}
+
+	wb := d.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	pruned := make(map[string]bool)
+	prunedIDs := make([]VAAID, 0)
+	if err := d.db.View(func(txn *badger.Txn) error {
+		var (
+			keys [][]byte
+			err  error
+		)
+		if policy.KeepSequences != 0 {
+			keys, err = expiredBySequence(txn, policy.KeepSequences)
+		} else {
+			keys, err = expiredByAge(txn, now.Add(-policy.MaxAge))
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			id, err := vaaIDFromKey(key)
+			if err != nil {
+				return err
+			}
+			if isGovernanceEmitter(*id) {
+				continue
+			}
+			if err := deleteSignedVAA(txn, wb, *id); err != nil {
+				return err
+			}
+			pruned[id.String()] = true
+			prunedIDs = append(prunedIDs, *id)
+		}
+
+		for _, id := range prunedIDs {
+			if err := deleteTxIndexEntries(txn, wb, id); err != nil {
+				return err
+			}
+			if err := deleteVAALogEntries(txn, wb, id); err != nil {
+				return err
+			}
+		}
+		return raiseLowWaterMarks(txn, wb, prunedIDs)
+	}); err != nil {
+		return 0, fmt.Errorf("failed to find expired VAAs: %w", err)
+	}
+
+	if err := wb.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to delete expired VAAs: %w", err)
+	}
+	return len(pruned), nil
+}
+
+// expiredBySequence returns the primary keys of all VAAs but the ones with the keep highest sequences of their emitter.
+func expiredBySequence(txn *badger.Txn, keep uint64) ([][]byte, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	keys := make([][]byte, 0)
+	prefix := []byte(seqIndexPrefix)
+	var emitter string
+	var seen uint64
+	// Reverse iteration starts at the last key with the prefix, which sorts before the prefix with 0xff appended.
+	for it.Seek(append(prefix, 0xff)); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		key := item.Key()
+		if len(key) < 8 {
+			return nil, fmt.Errorf("invalid sequence index key: %x", key)
+		}
+		// The key without the sequence identifies the emitter.
+		if e := string(key[:len(key)-8]); e != emitter {
+			emitter = e
+			seen = 0
+		}
+		seen++
+		if seen <= keep {
+			continue
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, val)
+	}
+	return keys, nil
+}
+
+// expiredByAge returns the primary keys of the VAAs with a timestamp before the cutoff.
+func expiredByAge(txn *badger.Txn, cutoff time.Time) ([][]byte, error) {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	keys := make([][]byte, 0)
+	prefix := []byte(timeIndexPrefix)
+	end := string(timeIndexPrefixBytes(cutoff))
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		if string(item.Key()) >= end {
+			break
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, val)
+	}
+	return keys, nil
+}
+
+// deleteSignedVAA deletes the VAA and the index entries derived from it.
+func deleteSignedVAA(txn *badger.Txn, wb *badger.WriteBatch, id VAAID) error {
+	item, err := txn.Get(id.Bytes())
+	if err == badger.ErrKeyNotFound {
+		// Stale index entries of a VAA which is already gone.
+		if err := wb.Delete(seqIndexKey(id)); err != nil {
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var v *vaa.VAA
+	if err := item.Value(func(val []byte) error {
+		v, err = vaa.Unmarshal(val)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to unmarshal VAA for %s: %w", id.String(), err)
+	}
+
+	if err := setVAAIndexes(func(key, _ []byte) error { return wb.Delete(key) }, v); err != nil {
+		return err
+	}
+	return wb.Delete(id.Bytes())
+}
+
+// deleteTxIndexEntries deletes the source transaction index entries of the VAA.
+func deleteTxIndexEntries(txn *badger.Txn, wb *badger.WriteBatch, id VAAID) error {
+	return deleteReverseEntries(txn, wb, vaaTxIndexPrefixBytes(id))
+}
+
+// deleteReverseEntries deletes the reverse entries with the given prefix along with the entries they point to.
+func deleteReverseEntries(txn *badger.Txn, wb *badger.WriteBatch, prefix []byte) error {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		target, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := wb.Delete(target); err != nil {
+			return err
+		}
+		if err := wb.Delete(item.KeyCopy(nil)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionPolicyValidate(t *testing.T) {
+	assert.Nil(t, RetentionPolicy{}.Validate())
+	assert.Nil(t, RetentionPolicy{KeepSequences: 10}.Validate())
+	assert.Nil(t, RetentionPolicy{MaxAge: time.Hour}.Validate())
+	assert.NotNil(t, RetentionPolicy{KeepSequences: 10, MaxAge: time.Hour}.Validate())
+	assert.NotNil(t, RetentionPolicy{MaxAge: -time.Hour}.Validate())
+}
+
+func storePruningTestVAAs(t *testing.T, d *Database, start time.Time) {
+	for seq := uint64(0); seq < 5; seq++ {
+		v := testVAA(seq, start.Add(time.Duration(seq)*time.Hour))
+		assert.Nil(t, d.StoreSignedVAA(v))
+		assert.Nil(t, d.StoreSourceTxHash(*VaaIDFromVAA(v), []byte{byte(seq)}))
+
+		gov := testVAA(seq, start.Add(time.Duration(seq)*time.Hour))
+		gov.EmitterChain = vaa.GovernanceChain
+		gov.EmitterAddress = vaa.GovernanceEmitter
+		assert.Nil(t, d.StoreSignedVAA(gov))
+	}
+}
+
+func TestPruneBySequence(t *testing.T) {
+	d := openTestDB(t)
+	start := time.Unix(1650000000, 0)
+	storePruningTestVAAs(t, d, start)
+
+	n, err := d.PruneSignedVAAs(RetentionPolicy{KeepSequences: 2}, start)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+
+	emitter := VAAID{EmitterChain: vaa.ChainIDEthereum, EmitterAddress: vaa.Address{1, 2, 3}}
+	seqs, err := d.GetEmitterSequences(emitter)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{3, 4}, seqs)
+
+	// governance VAAs are always kept
+	seqs, err = d.GetEmitterSequences(VAAID{EmitterChain: vaa.GovernanceChain, EmitterAddress: vaa.GovernanceEmitter})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(seqs))
+
+	// the index entries of the pruned VAAs are gone as well
+	_, err = d.GetSignedVAABytesByDigest(testVAA(0, start).SigningMsg().Bytes())
+	assert.Equal(t, ErrVAANotFound, err)
+	ids, err := d.FindVAAIDsByTxHash([]byte{0})
+	assert.Nil(t, err)
+	assert.Empty(t, ids)
+	ids, err = d.FindVAAIDsByTxHash([]byte{4})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ids))
+
+	// pruned sequences aren't reported as missing
+	missing, first, last, err := d.FindEmitterSequenceGap(emitter)
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, uint64(3), first)
+	assert.Equal(t, uint64(4), last)
+
+	// pruning is idempotent
+	n, err = d.PruneSignedVAAs(RetentionPolicy{KeepSequences: 2}, start)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestPruneByAge(t *testing.T) {
+	d := openTestDB(t)
+	start := time.Unix(1650000000, 0)
+	storePruningTestVAAs(t, d, start)
+
+	// VAAs 0 and 1 are older than 2 hours
+	n, err := d.PruneSignedVAAs(RetentionPolicy{MaxAge: 2 * time.Hour}, start.Add(4*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	ids, err := d.FindVAAIDsByTimestamp(start, start.Add(5*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 8, len(ids))
+
+	counts, err := d.CountKeys()
+	assert.Nil(t, err)
+	assert.Equal(t, 8, counts["signed"])
+
+	// a VAA stored again after it was pruned doesn't lower the low-water mark
+	assert.Nil(t, d.StoreSignedVAA(testVAA(0, start)))
+	missing, first, _, err := d.FindEmitterSequenceGap(VAAID{EmitterChain: vaa.ChainIDEthereum, EmitterAddress: vaa.Address{1, 2, 3}})
+	assert.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, uint64(2), first)
+}
+
+func TestPruneKeepAll(t *testing.T) {
+	d := openTestDB(t)
+	start := time.Unix(1650000000, 0)
+	storePruningTestVAAs(t, d, start)
+
+	n, err := d.PruneSignedVAAs(RetentionPolicy{}, start.Add(100*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func countPrefix(t *testing.T, d *Database, prefix string) (n int) {
+	assert.Nil(t, d.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			n++
+		}
+		return nil
+	}))
+	return
+}
+
+func TestPruneMigratedDatabase(t *testing.T) {
+	d := openTestDB(t)
+	start := time.Unix(1650000000, 0)
+	storePruningTestVAAs(t, d, start)
+
+	// simulate a database written before the reverse entries existed
+	assert.Nil(t, d.db.Update(func(txn *badger.Txn) error {
+		for _, prefix := range []string{vaaTxIndexPrefix, vaaLogPositionPrefix} {
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			keys := make([][]byte, 0)
+			for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+			it.Close()
+			for _, key := range keys {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+		}
+		return txn.Set([]byte(indexVersionKey), uint64Bytes(2))
+	}))
+	assert.Nil(t, d.migrateIndexes())
+	assert.Equal(t, 5, countPrefix(t, d, vaaTxIndexPrefix))
+	assert.Equal(t, 10, countPrefix(t, d, vaaLogPositionPrefix))
+
+	n, err := d.PruneSignedVAAs(RetentionPolicy{KeepSequences: 2}, start)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+
+	ids, err := d.FindVAAIDsByTxHash([]byte{0})
+	assert.Nil(t, err)
+	assert.Empty(t, ids)
+	assert.Equal(t, 2, countPrefix(t, d, txIndexPrefix))
+	assert.Equal(t, 2, countPrefix(t, d, vaaTxIndexPrefix))
+	assert.Equal(t, 7, countPrefix(t, d, vaaLogPrefix))
+	assert.Equal(t, 7, countPrefix(t, d, vaaLogPositionPrefix))
+}
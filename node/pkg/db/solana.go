@@ -0,0 +1,51 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+var (
+	ErrSolanaSlotNotFound = errors.New("solana slot cursor not found in store")
+)
+
+func solanaLastSlotKey(commitment string) []byte {
+	return []byte(fmt.Sprintf("solana/last-slot/%s", commitment))
+}
+
+// GetSolanaLastSlot returns the last slot which has been fully processed by the Solana watcher for the commitment.
+func (d *Database) GetSolanaLastSlot(commitment string) (slot uint64, err error) {
+	if err := d.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(solanaLastSlotKey(commitment))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if len(val) != 8 {
+				return fmt.Errorf("invalid solana slot cursor length: %d", len(val))
+			}
+			slot = binary.BigEndian.Uint64(val)
+			return nil
+		})
+	}); err != nil {
+		if err == badger.ErrKeyNotFound {
+			return 0, ErrSolanaSlotNotFound
+		}
+		return 0, err
+	}
+	return
+}
+
+func (d *Database) StoreSolanaLastSlot(commitment string, slot uint64) error {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], slot)
+	if err := d.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(solanaLastSlotKey(commitment), b[:])
+	}); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
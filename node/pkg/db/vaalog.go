@@ -0,0 +1,235 @@
+package db
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Log of the signed VAAs in the order they were stored, so that consumers of quorum events can replay the
+// ones they missed, and the durable cursors of these consumers:
+//
+//	log/vaa/<big-endian position>					-> primary key of the VAA (see VAAID.Bytes)
+//	log/vaa-position/<chain>/<emitter>/<sequence>/<big-endian position>	-> log key of the entry
+//	meta/vaa-log-position						-> big-endian position of the last entry ever appended
+//	cursor/<name>							-> big-endian position of the last processed entry
+//
+// Positions start at 1. A VAA which is stored again gets a new entry, so consumers may see it more than once.
+// The last position is persisted separately from the entries, since pruning may delete the newest entries and
+// positions must never be reused. Pruning finds the entries of a VAA through the log/vaa-position keys.
+const (
+	vaaLogPrefix         = "log/vaa/"
+	vaaLogPositionPrefix = "log/vaa-position/"
+	vaaLogPositionKey    = "meta/vaa-log-position"
+	cursorPrefix         = "cursor/"
+
+	// vaaLogIndexVersion is the index version which introduced the VAA log.
+	vaaLogIndexVersion = 2
+)
+
+func vaaLogKey(position uint64) []byte {
+	return append([]byte(vaaLogPrefix), uint64Bytes(position)...)
+}
+
+func vaaLogPositionPrefixBytes(id VAAID) []byte {
+	return []byte(fmt.Sprintf("%s%s/", vaaLogPositionPrefix, id.String()))
+}
+
+func cursorKey(name string) []byte {
+	return []byte(cursorPrefix + name)
+}
+
+// LoggedVAA is a signed VAA along with its position in the VAA log.
+type LoggedVAA struct {
+	Position uint64
+	Bytes    []byte
+}
+
+// appendVAALog assigns the next log position to the VAA with the given primary key. The caller must hold vaaLogMu
+// until the transaction is committed, so that entries become visible in the order of their positions.
+func (d *Database) appendVAALog(txn *badger.Txn, key []byte) error {
+	position := d.vaaLogPosition + 1
+	if err := setVAALogEntry(txn.Set, position, key); err != nil {
+		return err
+	}
+	if err := txn.Set([]byte(vaaLogPositionKey), uint64Bytes(position)); err != nil {
+		return err
+	}
+	d.vaaLogPosition = position
+	return nil
+}
+
+// setVAALogEntry writes the log entry of the VAA with the given primary key, along with its reverse entry.
+func setVAALogEntry(set func(key, val []byte) error, position uint64, key []byte) error {
+	id, err := vaaIDFromKey(key)
+	if err != nil {
+		return err
+	}
+	if err := set(vaaLogKey(position), key); err != nil {
+		return err
+	}
+	return set(append(vaaLogPositionPrefixBytes(*id), uint64Bytes(position)...), vaaLogKey(position))
+}
+
+// setVAALogReverseEntries writes the reverse entries of the existing VAA log entries.
+func setVAALogReverseEntries(txn *badger.Txn, wb *badger.WriteBatch) error {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := []byte(vaaLogPrefix)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		if len(item.Key()) != len(prefix)+8 {
+			return fmt.Errorf("invalid VAA log key: %x", item.Key())
+		}
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := setVAALogEntry(wb.Set, binary.BigEndian.Uint64(item.Key()[len(prefix):]), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadVAALogPosition returns the persisted position of the last entry appended to the VAA log. Databases which
+// don't have it yet fall back to the last remaining entry, which is then persisted.
+func (d *Database) loadVAALogPosition() (position uint64, err error) {
+	found := false
+	if err := d.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(vaaLogPositionKey))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if len(val) != 8 {
+				return fmt.Errorf("invalid VAA log position length: %d", len(val))
+			}
+			position = binary.BigEndian.Uint64(val)
+			found = true
+			return nil
+		})
+	}); err != nil {
+		return 0, err
+	}
+	if found {
+		return position, nil
+	}
+
+	if position, err = d.lastVAALogPosition(); err != nil {
+		return 0, err
+	}
+	if err := d.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(vaaLogPositionKey), uint64Bytes(position))
+	}); err != nil {
+		return 0, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return position, nil
+}
+
+// lastVAALogPosition returns the position of the last remaining entry of the VAA log, or 0 if it's empty.
+func (d *Database) lastVAALogPosition() (position uint64, err error) {
+	err = d.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		// seek to the end of the prefix, the position bytes are always < 0xff..ff
+		it.Seek(vaaLogKey(^uint64(0)))
+		if !it.ValidForPrefix([]byte(vaaLogPrefix)) {
+			return nil
+		}
+		key := it.Item().Key()
+		if len(key) != len(vaaLogPrefix)+8 {
+			return fmt.Errorf("invalid VAA log key: %x", key)
+		}
+		position = binary.BigEndian.Uint64(key[len(vaaLogPrefix):])
+		return nil
+	})
+	return
+}
+
+// LastVAALogPosition returns the position of the last entry of the VAA log, or 0 if it's empty.
+func (d *Database) LastVAALogPosition() uint64 {
+	d.vaaLogMu.Lock()
+	defer d.vaaLogMu.Unlock()
+	return d.vaaLogPosition
+}
+
+// ReadVAALog returns up to limit logged VAAs with a position >= from, ordered by position.
+// Entries of VAAs which were pruned in the meantime are skipped.
+func (d *Database) ReadVAALog(from uint64, limit int) (vaas []LoggedVAA, err error) {
+	vaas = make([]LoggedVAA, 0)
+	prefix := []byte(vaaLogPrefix)
+	if err := d.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(vaaLogKey(from)); it.ValidForPrefix(prefix) && len(vaas) < limit; it.Next() {
+			item := it.Item()
+			if len(item.Key()) != len(prefix)+8 {
+				return fmt.Errorf("invalid VAA log key: %x", item.Key())
+			}
+			position := binary.BigEndian.Uint64(item.Key()[len(prefix):])
+			key, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			vaaItem, err := txn.Get(key)
+			if err == badger.ErrKeyNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			b, err := vaaItem.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			vaas = append(vaas, LoggedVAA{Position: position, Bytes: b})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return vaas, nil
+}
+
+// deleteVAALogEntries deletes the VAA log entries of the VAA.
+func deleteVAALogEntries(txn *badger.Txn, wb *badger.WriteBatch, id VAAID) error {
+	return deleteReverseEntries(txn, wb, vaaLogPositionPrefixBytes(id))
+}
+
+// StoreCursor stores the position in the VAA log up to which the named consumer has processed it.
+func (d *Database) StoreCursor(name string, position uint64) error {
+	if err := d.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(cursorKey(name), uint64Bytes(position))
+	}); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetCursor returns the stored cursor of the named consumer. found is false if the consumer has no cursor yet.
+func (d *Database) GetCursor(name string) (position uint64, found bool, err error) {
+	err = d.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(cursorKey(name))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if len(val) != 8 {
+				return fmt.Errorf("invalid cursor length: %d", len(val))
+			}
+			position = binary.BigEndian.Uint64(val)
+			found = true
+			return nil
+		})
+	})
+	return
+}
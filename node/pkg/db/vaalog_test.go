@@ -0,0 +1,146 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func logSequences(t *testing.T, d *Database, from uint64) (positions []uint64, seqs []uint64) {
+	logged, err := d.ReadVAALog(from, 100)
+	assert.Nil(t, err)
+	for _, l := range logged {
+		v, err := vaa.Unmarshal(l.Bytes)
+		assert.Nil(t, err)
+		positions = append(positions, l.Position)
+		seqs = append(seqs, v.Sequence)
+	}
+	return
+}
+
+func TestVAALog(t *testing.T) {
+	dir := t.TempDir()
+	d, err := Open(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), d.LastVAALogPosition())
+
+	for _, seq := range []uint64{5, 1, 3} {
+		assert.Nil(t, d.StoreSignedVAA(testVAA(seq, time.Unix(1650000000, 0))))
+	}
+	// stored again
+	assert.Nil(t, d.StoreSignedVAA(testVAA(5, time.Unix(1650000000, 0))))
+
+	positions, seqs := logSequences(t, d, 0)
+	assert.Equal(t, []uint64{1, 2, 3, 4}, positions)
+	assert.Equal(t, []uint64{5, 1, 3, 5}, seqs)
+	_, seqs = logSequences(t, d, 3)
+	assert.Equal(t, []uint64{3, 5}, seqs)
+
+	logged, err := d.ReadVAALog(0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(logged))
+
+	// the position survives a restart
+	assert.Nil(t, d.Close())
+	d, err = Open(dir)
+	assert.Nil(t, err)
+	defer d.Close()
+	assert.Equal(t, uint64(4), d.LastVAALogPosition())
+	assert.Nil(t, d.StoreSignedVAA(testVAA(7, time.Unix(1650000000, 0))))
+	assert.Equal(t, uint64(5), d.LastVAALogPosition())
+
+	// pruned VAAs are removed from the log
+	n, err := d.PruneSignedVAAs(RetentionPolicy{KeepSequences: 2}, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	positions, seqs = logSequences(t, d, 0)
+	assert.Equal(t, []uint64{1, 4, 5}, positions)
+	assert.Equal(t, []uint64{5, 5, 7}, seqs)
+}
+
+func TestVAALogPositionAfterPruning(t *testing.T) {
+	dir := t.TempDir()
+	d, err := Open(dir)
+	assert.Nil(t, err)
+
+	now := time.Unix(1650000000, 0)
+	assert.Nil(t, d.StoreSignedVAA(testVAA(1, now)))
+	// the newest entries belong to old VAAs, e.g. backfilled ones
+	assert.Nil(t, d.StoreSignedVAA(testVAA(2, now.Add(-48*time.Hour))))
+	assert.Nil(t, d.StoreSignedVAA(testVAA(3, now.Add(-48*time.Hour))))
+	n, err := d.PruneSignedVAAs(RetentionPolicy{MaxAge: 24 * time.Hour}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	positions, _ := logSequences(t, d, 0)
+	assert.Equal(t, []uint64{1}, positions)
+
+	// the positions of the pruned entries aren't reused after a restart
+	assert.Nil(t, d.Close())
+	d, err = Open(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), d.LastVAALogPosition())
+	assert.Nil(t, d.StoreSignedVAA(testVAA(4, now)))
+	positions, seqs := logSequences(t, d, 0)
+	assert.Equal(t, []uint64{1, 4}, positions)
+	assert.Equal(t, []uint64{1, 4}, seqs)
+
+	// databases without a persisted position fall back to the last entry once
+	assert.Nil(t, d.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(vaaLogPositionKey))
+	}))
+	assert.Nil(t, d.Close())
+	d, err = Open(dir)
+	assert.Nil(t, err)
+	defer d.Close()
+	assert.Equal(t, uint64(4), d.LastVAALogPosition())
+	position, err := d.loadVAALogPosition()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4), position)
+}
+
+func TestMigrateVAALog(t *testing.T) {
+	d := openTestDB(t)
+
+	// simulate a database written before the VAA log existed
+	for _, seq := range []uint64{2, 1} {
+		assert.Nil(t, d.StoreSignedVAA(testVAA(seq, time.Unix(1650000000, 0))))
+	}
+	assert.Nil(t, d.db.Update(func(txn *badger.Txn) error {
+		for _, position := range []uint64{1, 2} {
+			if err := txn.Delete(vaaLogKey(position)); err != nil {
+				return err
+			}
+		}
+		return txn.Set([]byte(indexVersionKey), uint64Bytes(1))
+	}))
+
+	assert.Nil(t, d.migrateIndexes())
+	positions, seqs := logSequences(t, d, 0)
+	assert.Equal(t, []uint64{1, 2}, positions)
+	assert.Equal(t, []uint64{1, 2}, seqs)
+
+	// an existing log isn't rebuilt
+	assert.Nil(t, d.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(indexVersionKey))
+	}))
+	assert.Nil(t, d.migrateIndexes())
+	positions, _ = logSequences(t, d, 0)
+	assert.Equal(t, []uint64{1, 2}, positions)
+}
+
+func TestCursor(t *testing.T) {
+	d := openTestDB(t)
+
+	_, found, err := d.GetCursor("webhook")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, d.StoreCursor("webhook", 42))
+	position, found, err := d.GetCursor("webhook")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, uint64(42), position)
+}
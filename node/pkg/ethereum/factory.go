@@ -0,0 +1,29 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
+	eth_common "github.com/ethereum/go-ethereum/common"
+)
+
+// Factory creates the watcher of an EVM chain entry.
+func Factory(c *watchers.ChainConfig, deps *watchers.Deps) (watchers.Watcher, error) {
+	if c.PrimaryRPC() == "" {
+		return nil, errors.New("missing rpc endpoint")
+	}
+	if !eth_common.IsHexAddress(c.PrimaryContract()) {
+		return nil, fmt.Errorf("invalid contract address %s", c.PrimaryContract())
+	}
+
+	// The guardian set is read from Ethereum
+	var setC chan *common.GuardianSet
+	if c.ChainID == vaa.ChainIDEthereum {
+		setC = deps.SetC
+	}
+	return NewEthWatcher(c.PrimaryRPC(), eth_common.HexToAddress(c.PrimaryContract()), c.Name, common.ChainReadiness(c.ChainID),
+		c.ChainID, deps.MsgC, setC, c.MinConfirmations, deps.ObsvReqC), nil
+}
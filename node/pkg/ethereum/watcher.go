@@ -2,6 +2,7 @@ package ethereum
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/certusone/wormhole/node/pkg/p2p"
 	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
@@ -25,6 +26,7 @@ import (
 	"github.com/certusone/wormhole/node/pkg/readiness"
 	"github.com/certusone/wormhole/node/pkg/supervisor"
 	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
 )
 
 var (
@@ -100,6 +102,18 @@ type (
 
 		// Minimum number of confirmations to accept, regardless of what the contract specifies.
 		minConfirmations uint64
+
+		// Replaced on every (re)connect. HandleObservationRequest looks up the receipts
+		// of re-observed transactions through the current connection.
+		clientMu sync.Mutex
+		client   *ethclient.Client
+		logger   *zap.Logger
+
+		// Track the current block number so we can compare it to the block number of
+		// the message publication for observation requests.
+		currentBlockNumber uint64
+
+		health *watchers.HealthTracker
 	}
 
 	pendingKey struct {
@@ -135,7 +149,17 @@ func NewEthWatcher(
 		msgChan:          messageEvents,
 		setChan:          setEvents,
 		obsvReqC:         obsvReqC,
-		pending:          map[pendingKey]*pendingMessage{}}
+		pending:          map[pendingKey]*pendingMessage{},
+		logger:           zap.NewNop(),
+		health:           watchers.NewHealthTracker(chainID)}
+}
+
+func (e *Watcher) ChainID() vaa.ChainID {
+	return e.chainID
+}
+
+func (e *Watcher) Health() watchers.Health {
+	return e.health.Health()
 }
 
 func (e *Watcher) Run(ctx context.Context) error {
@@ -152,9 +176,16 @@ func (e *Watcher) Run(ctx context.Context) error {
 	if err != nil {
 		ethConnectionErrors.WithLabelValues(e.networkName, "dial_error").Inc()
 		p2p.DefaultRegistry.AddErrorCount(e.chainID, 1)
-		return fmt.Errorf("dialing eth client failed: %w", err)
+		err = fmt.Errorf("dialing eth client failed: %w", err)
+		e.health.AddError(err)
+		return err
 	}
 
+	e.clientMu.Lock()
+	e.client = c
+	e.logger = logger
+	e.clientMu.Unlock()
+
 	f, err := abi.NewAbiFilterer(e.contract, c)
 	if err != nil {
 		return fmt.Errorf("could not create wormhole contract filter: %w", err)
@@ -175,7 +206,9 @@ func (e *Watcher) Run(ctx context.Context) error {
 	if err != nil {
 		ethConnectionErrors.WithLabelValues(e.networkName, "subscribe_error").Inc()
 		p2p.DefaultRegistry.AddErrorCount(e.chainID, 1)
-		return fmt.Errorf("failed to subscribe to message publication events: %w", err)
+		err = fmt.Errorf("failed to subscribe to message publication events: %w", err)
+		e.health.AddError(err)
+		return err
 	}
 
 	// Fetch initial guardian set
@@ -200,92 +233,7 @@ func (e *Watcher) Run(ctx context.Context) error {
 		}
 	}()
 
-	// Track the current block number so we can compare it to the block number of
-	// the message publication for observation requests.
-	var currentBlockNumber uint64
-
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case r := <-e.obsvReqC:
-				// This can't happen unless there is a programming error - the caller
-				// is expected to send us only requests for our chainID.
-				if vaa.ChainID(r.ChainId) != e.chainID {
-					panic("invalid chain ID")
-				}
-
-				tx := eth_common.BytesToHash(r.TxHash)
-				logger.Info("received observation request",
-					zap.String("eth_network", e.networkName),
-					zap.String("tx_hash", tx.Hex()))
-
-				// SECURITY: Load the block number before requesting the transaction to avoid a
-				// race condition where requesting the tx succeeds and is then dropped due to a fork,
-				// but blockNumberU had already advanced beyond the required threshold.
-				//
-				// In the primary watcher flow, this is of no concern since we assume the node
-				// always sends the head before it sends the logs (implicit synchronization
-				// by relying on the same websocket connection).
-				blockNumberU := atomic.LoadUint64(&currentBlockNumber)
-				if blockNumberU == 0 {
-					logger.Error("no block number available, ignoring observation request",
-						zap.String("eth_network", e.networkName))
-					continue
-				}
-
-				timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
-				blockNumber, msgs, err := MessageEventsForTransaction(timeout, c, e.contract, e.chainID, tx)
-				cancel()
-
-				if err != nil {
-					logger.Error("failed to process observation request",
-						zap.Error(err), zap.String("eth_network", e.networkName))
-					continue
-				}
-
-				for _, msg := range msgs {
-					expectedConfirmations := uint64(msg.ConsistencyLevel)
-					if expectedConfirmations < e.minConfirmations {
-						expectedConfirmations = e.minConfirmations
-					}
-
-					// SECURITY: In the recovery flow, we already know which transaction to
-					// observe, and we can assume that it has reached the expected finality
-					// level a long time ago. Therefore, the logic is much simpler than the
-					// primary watcher, which has to wait for finality.
-					//
-					// Instead, we can simply check if the transaction's block number is in
-					// the past by more than the expected confirmation number.
-					//
-					// Ensure that the current block number is at least expectedConfirmations
-					// larger than the message observation's block number.
-					if blockNumber+expectedConfirmations <= blockNumberU {
-						logger.Info("re-observed message publication transaction",
-							zap.Stringer("tx", msg.TxHash),
-							zap.Stringer("emitter_address", msg.EmitterAddress),
-							zap.Uint64("sequence", msg.Sequence),
-							zap.Uint64("current_block", blockNumberU),
-							zap.Uint64("observed_block", blockNumber),
-							zap.String("eth_network", e.networkName),
-						)
-						e.msgChan <- msg
-					} else {
-						logger.Info("ignoring re-observed message publication transaction",
-							zap.Stringer("tx", msg.TxHash),
-							zap.Stringer("emitter_address", msg.EmitterAddress),
-							zap.Uint64("sequence", msg.Sequence),
-							zap.Uint64("current_block", blockNumberU),
-							zap.Uint64("observed_block", blockNumber),
-							zap.Uint64("expected_confirmations", expectedConfirmations),
-							zap.String("eth_network", e.networkName),
-						)
-					}
-				}
-			}
-		}
-	}()
+	go watchers.HandleObservationRequests(ctx, logger, e, e.obsvReqC)
 
 	errC := make(chan error)
 	go func() {
@@ -355,7 +303,9 @@ func (e *Watcher) Run(ctx context.Context) error {
 	if err != nil {
 		ethConnectionErrors.WithLabelValues(e.networkName, "header_subscribe_error").Inc()
 		p2p.DefaultRegistry.AddErrorCount(e.chainID, 1)
-		return fmt.Errorf("failed to subscribe to header events: %w", err)
+		err = fmt.Errorf("failed to subscribe to header events: %w", err)
+		e.health.AddError(err)
+		return err
 	}
 
 	go func() {
@@ -376,6 +326,7 @@ func (e *Watcher) Run(ctx context.Context) error {
 					zap.Stringer("current_blockhash", currentHash),
 					zap.String("eth_network", e.networkName))
 				currentEthHeight.WithLabelValues(e.networkName).Set(float64(ev.Number.Int64()))
+				e.health.SetHeight(ev.Number.Uint64())
 				readiness.SetReady(e.readiness)
 				p2p.DefaultRegistry.SetNetworkStats(e.chainID, &gossipv1.Heartbeat_Network{
 					Height:          ev.Number.Int64(),
@@ -385,7 +336,7 @@ func (e *Watcher) Run(ctx context.Context) error {
 				e.pendingMu.Lock()
 
 				blockNumberU := ev.Number.Uint64()
-				atomic.StoreUint64(&currentBlockNumber, blockNumberU)
+				atomic.StoreUint64(&e.currentBlockNumber, blockNumberU)
 
 				for key, pLock := range e.pending {
 					expectedConfirmations := uint64(pLock.message.ConsistencyLevel)
@@ -515,8 +466,86 @@ func (e *Watcher) Run(ctx context.Context) error {
 	case <-ctx.Done():
 		return ctx.Err()
 	case err := <-errC:
+		e.health.AddError(err)
+		// The watcher is ready again once it has reconnected and received a new header.
+		readiness.SetNotReady(e.readiness)
+		return err
+	}
+}
+
+// HandleObservationRequest re-observes the messages published by the requested transaction
+// and publishes the ones which have reached the expected number of confirmations.
+func (e *Watcher) HandleObservationRequest(ctx context.Context, r *gossipv1.ObservationRequest) error {
+	e.clientMu.Lock()
+	c, logger := e.client, e.logger
+	e.clientMu.Unlock()
+	if c == nil {
+		return errors.New("watcher is not connected")
+	}
+
+	tx := eth_common.BytesToHash(r.TxHash)
+	logger.Info("received observation request",
+		zap.String("eth_network", e.networkName),
+		zap.String("tx_hash", tx.Hex()))
+
+	// SECURITY: Load the block number before requesting the transaction to avoid a
+	// race condition where requesting the tx succeeds and is then dropped due to a fork,
+	// but blockNumberU had already advanced beyond the required threshold.
+	//
+	// In the primary watcher flow, this is of no concern since we assume the node
+	// always sends the head before it sends the logs (implicit synchronization
+	// by relying on the same websocket connection).
+	blockNumberU := atomic.LoadUint64(&e.currentBlockNumber)
+	if blockNumberU == 0 {
+		return errors.New("no block number available, ignoring observation request")
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	blockNumber, msgs, err := MessageEventsForTransaction(timeout, c, e.contract, e.chainID, tx)
+	cancel()
+	if err != nil {
 		return err
 	}
+
+	for _, msg := range msgs {
+		expectedConfirmations := uint64(msg.ConsistencyLevel)
+		if expectedConfirmations < e.minConfirmations {
+			expectedConfirmations = e.minConfirmations
+		}
+
+		// SECURITY: In the recovery flow, we already know which transaction to
+		// observe, and we can assume that it has reached the expected finality
+		// level a long time ago. Therefore, the logic is much simpler than the
+		// primary watcher, which has to wait for finality.
+		//
+		// Instead, we can simply check if the transaction's block number is in
+		// the past by more than the expected confirmation number.
+		//
+		// Ensure that the current block number is at least expectedConfirmations
+		// larger than the message observation's block number.
+		if blockNumber+expectedConfirmations <= blockNumberU {
+			logger.Info("re-observed message publication transaction",
+				zap.Stringer("tx", msg.TxHash),
+				zap.Stringer("emitter_address", msg.EmitterAddress),
+				zap.Uint64("sequence", msg.Sequence),
+				zap.Uint64("current_block", blockNumberU),
+				zap.Uint64("observed_block", blockNumber),
+				zap.String("eth_network", e.networkName),
+			)
+			e.msgChan <- msg
+		} else {
+			logger.Info("ignoring re-observed message publication transaction",
+				zap.Stringer("tx", msg.TxHash),
+				zap.Stringer("emitter_address", msg.EmitterAddress),
+				zap.Uint64("sequence", msg.Sequence),
+				zap.Uint64("current_block", blockNumberU),
+				zap.Uint64("observed_block", blockNumber),
+				zap.Uint64("expected_confirmations", expectedConfirmations),
+				zap.String("eth_network", e.networkName),
+			)
+		}
+	}
+	return nil
 }
 
 func (e *Watcher) fetchAndUpdateGuardianSet(
@@ -532,6 +561,7 @@ func (e *Watcher) fetchAndUpdateGuardianSet(
 	if err != nil {
 		ethConnectionErrors.WithLabelValues(e.networkName, "guardian_set_fetch_error").Inc()
 		p2p.DefaultRegistry.AddErrorCount(e.chainID, 1)
+		e.health.AddError(err)
 		return err
 	}
 
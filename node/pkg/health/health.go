@@ -0,0 +1,112 @@
+// Package health serves the current state of the node as JSON, for use as k8s liveness and readiness probes
+// and for monitoring. Unlike the plain text /readyz endpoint, the state isn't latched at startup.
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/p2p"
+	"github.com/certusone/wormhole/node/pkg/readiness"
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+)
+
+type GuardianSetStatus struct {
+	Loaded bool   `json:"loaded"`
+	Index  uint32 `json:"index"`
+	Keys   int    `json:"keys"`
+}
+
+// Status is a snapshot of the state of the node.
+type Status struct {
+	// Live is false if the node needs to be restarted, i.e. its root runnable died.
+	Live bool `json:"live"`
+	// Ready is true if the node is live, all readiness components are ready and the guardian set is loaded.
+	Ready       bool                        `json:"ready"`
+	Components  []readiness.ComponentStatus `json:"components"`
+	GuardianSet GuardianSetStatus           `json:"guardianSet"`
+	Peers       int                         `json:"peers"`
+	// Runnables is empty until the supervisor has been started.
+	Runnables []supervisor.RunnableStatus `json:"runnables"`
+}
+
+// Supervisor is implemented by the supervisor returned by supervisor.New.
+type Supervisor interface {
+	Runnables() []supervisor.RunnableStatus
+}
+
+type Checker struct {
+	gst       *common.GuardianSetState
+	peerCount func() int
+
+	mu  sync.Mutex
+	sup Supervisor
+}
+
+func NewChecker(gst *common.GuardianSetState) *Checker {
+	return &Checker{gst: gst, peerCount: p2p.DefaultRegistry.PeerCount}
+}
+
+// SetSupervisor adds the state of the supervision tree to the node's state.
+func (c *Checker) SetSupervisor(s Supervisor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sup = s
+}
+
+func (c *Checker) Status() Status {
+	status := Status{
+		Live:       true,
+		Components: readiness.Status(),
+		Peers:      c.peerCount(),
+		Runnables:  []supervisor.RunnableStatus{},
+	}
+
+	c.mu.Lock()
+	sup := c.sup
+	c.mu.Unlock()
+	if sup != nil {
+		status.Runnables = sup.Runnables()
+		for _, r := range status.Runnables {
+			if r.DN == "root" && r.Dead() {
+				status.Live = false
+			}
+		}
+	}
+
+	if gs := c.gst.Get(); gs != nil {
+		status.GuardianSet = GuardianSetStatus{Loaded: true, Index: gs.Index, Keys: len(gs.Keys)}
+	}
+
+	status.Ready = status.Live && status.GuardianSet.Loaded
+	for _, component := range status.Components {
+		if !component.Ready {
+			status.Ready = false
+		}
+	}
+	return status
+}
+
+func writeStatus(w http.ResponseWriter, status Status, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// LiveHandler serves the node's state. It returns 200 OK if the node is live, or 503 Service Unavailable otherwise.
+func (c *Checker) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	status := c.Status()
+	writeStatus(w, status, status.Live)
+}
+
+// ReadyHandler serves the node's state. It returns 200 OK if the node is ready, or 503 Service Unavailable otherwise.
+func (c *Checker) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	status := c.Status()
+	writeStatus(w, status, status.Ready)
+}
@@ -0,0 +1,81 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/readiness"
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSupervisor []supervisor.RunnableStatus
+
+func (s testSupervisor) Runnables() []supervisor.RunnableStatus {
+	return s
+}
+
+func get(t *testing.T, handler http.HandlerFunc) (int, Status) {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var status Status
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	return rec.Code, status
+}
+
+func TestChecker(t *testing.T) {
+	const component = readiness.Component("healthTestSyncing")
+	readiness.RegisterComponent(component)
+
+	gst := common.NewGuardianSetState()
+	c := NewChecker(gst)
+	c.peerCount = func() int { return 3 }
+
+	// not ready before the guardian set is loaded and the component is ready
+	code, status := get(t, c.LiveHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, status.Live)
+	assert.False(t, status.Ready)
+	assert.Equal(t, 3, status.Peers)
+	assert.False(t, status.GuardianSet.Loaded)
+	assert.Empty(t, status.Runnables)
+	code, _ = get(t, c.ReadyHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	gst.Set(&common.GuardianSet{Index: 2, Keys: []ethcommon.Address{{1}, {2}}})
+	readiness.SetReady(component)
+	code, status = get(t, c.ReadyHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, status.Ready)
+	assert.Equal(t, GuardianSetStatus{Loaded: true, Index: 2, Keys: 2}, status.GuardianSet)
+	var found bool
+	for _, s := range status.Components {
+		if s.Name == string(component) {
+			found = true
+			assert.True(t, s.Ready)
+			assert.False(t, s.LastChange.IsZero())
+		}
+	}
+	assert.True(t, found)
+
+	// components can become unready again
+	readiness.SetNotReady(component)
+	code, _ = get(t, c.ReadyHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	readiness.SetReady(component)
+
+	// a dead root runnable fails both probes
+	c.SetSupervisor(testSupervisor{
+		{DN: "root", State: "NODE_STATE_DEAD"},
+		{DN: "root.p2p", State: "NODE_STATE_CANCELED"},
+	})
+	code, status = get(t, c.LiveHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, status.Live)
+	assert.False(t, status.Ready)
+	assert.Equal(t, 2, len(status.Runnables))
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+)
+
+func code(in string) string {
+	return fmt.Sprintf("`%s`", in)
+}
+
+// MissingSignatures is raised for a settled message which not all guardians signed.
+func MissingSignatures(v *vaa.VAA, hasSigs, wantSigs int, quorum bool, missing []string) *Alert {
+	a := &Alert{
+		Type:     AlertMissingSignatures,
+		Severity: SeverityWarning,
+		Title:    "Message with missing signatures",
+		DedupKey: v.MessageID(),
+	}
+	quorumText := fmt.Sprintf("✔️ yes (%d/%d)", hasSigs, wantSigs)
+	if !quorum {
+		a.Severity = SeverityCritical
+		a.Text = "NO QUORUM - Wormhole likely failed to achieve consensus on this message"
+		quorumText = fmt.Sprintf("🚨️ NO (%d/%d)", hasSigs, wantSigs)
+	}
+	a.Fields = []Field{
+		{Name: "Message ID", Value: code(v.MessageID()), Inline: true},
+		{Name: "Digest", Value: code(v.HexDigest()), Inline: true},
+		{Name: "Quorum", Value: quorumText, Inline: true},
+		{Name: "Source Chain", Value: strings.Title(v.EmitterChain.String())},
+		{Name: "Missing Guardians", Guardians: missing},
+	}
+	return a
+}
+
+// QuorumTimeout is raised for a message which we observed, but which didn't reach quorum in time.
+func QuorumTimeout(v *vaa.VAA, hasSigs, wantSigs int, waited time.Duration) *Alert {
+	return &Alert{
+		Type:     AlertQuorumTimeout,
+		Severity: SeverityCritical,
+		Title:    "Quorum not reached",
+		Text:     fmt.Sprintf("The message didn't reach quorum within %s", waited.Round(time.Second)),
+		DedupKey: v.MessageID(),
+		Fields: []Field{
+			{Name: "Message ID", Value: code(v.MessageID()), Inline: true},
+			{Name: "Digest", Value: code(v.HexDigest()), Inline: true},
+			{Name: "Signatures", Value: fmt.Sprintf("%d/%d", hasSigs, wantSigs), Inline: true},
+			{Name: "Source Chain", Value: strings.Title(v.EmitterChain.String())},
+		},
+	}
+}
+
+// GuardianSetChange is raised when the active guardian set is replaced.
+func GuardianSetChange(old, new *common.GuardianSet) *Alert {
+	keys := &strings.Builder{}
+	for _, k := range new.KeysAsHexStrings() {
+		fmt.Fprintf(keys, "- %s\n", code(k))
+	}
+	return &Alert{
+		Type:     AlertGuardianSetChange,
+		Severity: SeverityInfo,
+		Title:    "Guardian set changed",
+		DedupKey: fmt.Sprint(new.Index),
+		Fields: []Field{
+			{Name: "Previous Index", Value: fmt.Sprint(old.Index), Inline: true},
+			{Name: "New Index", Value: fmt.Sprint(new.Index), Inline: true},
+			{Name: "Guardians", Value: fmt.Sprintf("%d (previously %d)", len(new.Keys), len(old.Keys)), Inline: true},
+			{Name: "Keys", Value: keys.String()},
+		},
+	}
+}
+
+// WatcherStalled is raised when a chain watcher didn't see a new block for too long.
+func WatcherStalled(chain vaa.ChainID, height uint64, stalledFor time.Duration) *Alert {
+	return &Alert{
+		Type:     AlertWatcherStalled,
+		Severity: SeverityCritical,
+		Title:    "Chain watcher stalled",
+		Text:     fmt.Sprintf("No new block for %s", stalledFor.Round(time.Second)),
+		DedupKey: fmt.Sprintf("%d/%d", chain, height),
+		Fields: []Field{
+			{Name: "Chain", Value: strings.Title(chain.String()), Inline: true},
+			{Name: "Height", Value: fmt.Sprint(height), Inline: true},
+		},
+	}
+}
+
+// UndoneSequenceAdded is raised when the Alephium token bridge marks a sequence of a remote chain as undone.
+func UndoneSequenceAdded(remoteChainId uint16, sequence uint64, reason string) *Alert {
+	return &Alert{
+		Type:     AlertUndoneSequence,
+		Severity: SeverityWarning,
+		Title:    "Alephium undone sequence added",
+		Text:     "The sequence has to be executed with an undone sequence VAA",
+		DedupKey: fmt.Sprintf("%d/%d", remoteChainId, sequence),
+		Fields: []Field{
+			{Name: "Remote Chain", Value: strings.Title(vaa.ChainID(remoteChainId).String()), Inline: true},
+			{Name: "Sequence", Value: fmt.Sprint(sequence), Inline: true},
+			{Name: "Reason", Value: reason},
+		},
+	}
+}
@@ -1,13 +1,12 @@
 package discord
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/notify"
 	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
 	"go.uber.org/zap"
-	"strings"
 	"sync"
 )
 
@@ -56,10 +55,6 @@ func NewDiscordNotifier(botToken string, channelName string, logger *zap.Logger)
 	}, nil
 }
 
-func wrapCode(in string) string {
-	return fmt.Sprintf("`%s`", in)
-}
-
 func (d *DiscordNotifier) LookupGroupID(groupName string) (string, error) {
 	d.groupToIDMu.RLock()
 	if id, ok := d.groupToID[groupName]; ok {
@@ -95,48 +90,41 @@ func (d *DiscordNotifier) LookupGroupID(groupName string) (string, error) {
 	return "", fmt.Errorf("failed to find group %s", groupName)
 }
 
-func (d *DiscordNotifier) MissingSignaturesOnTransaction(v *vaa.VAA, hasSigs, wantSigs int, quorum bool, missing []string) error {
-	if len(missing) == 0 {
-		panic("no missing nodes specified")
-	}
-	var quorumText string
-	if quorum {
-		quorumText = fmt.Sprintf("✔️ yes (%d/%d)", hasSigs, wantSigs)
-	} else {
-		quorumText = fmt.Sprintf("🚨️ **NO** (%d/%d)", hasSigs, wantSigs)
-	}
+func (d *DiscordNotifier) Name() string {
+	return "discord"
+}
 
-	var messageText string
-	if !quorum {
-		messageText = "**NO QUORUM** - Wormhole likely failed to achieve consensus on this message @here"
+// mention returns the role mention of the guardian's group, or its name if there is none.
+func (d *DiscordNotifier) mention(name string) string {
+	groupID, err := d.LookupGroupID(name)
+	if err != nil {
+		d.logger.Error("failed to lookup group id", zap.Error(err), zap.String("name", name))
+		return name
 	}
+	return fmt.Sprintf("<@&%s>", groupID)
+}
 
-	missingText := &bytes.Buffer{}
-	for _, m := range missing {
-		groupID, err := d.LookupGroupID(m)
-		if err != nil {
-			d.logger.Error("failed to lookup group id", zap.Error(err), zap.String("name", m))
-			groupID = m
-		} else {
-			groupID = fmt.Sprintf("<@&%s>", groupID)
+// Send posts the alert as an embed to the notification channels. Critical alerts mention @here.
+func (d *DiscordNotifier) Send(ctx context.Context, a *notify.Alert) error {
+	messageText := a.Text
+	if a.Severity == notify.SeverityCritical {
+		messageText = "@here"
+		if a.Text != "" {
+			messageText = fmt.Sprintf("**%s** @here", a.Text)
 		}
+	}
 
-		if _, err := fmt.Fprintf(missingText, "- %s\n", groupID); err != nil {
-			panic(err)
-		}
+	fields := make([]discord.EmbedField, 0, len(a.Fields))
+	for _, f := range a.Fields {
+		fields = append(fields, discord.EmbedField{Name: f.Name, Value: f.Text(d.mention), Inline: f.Inline})
 	}
 
+	c := d.c.WithContext(ctx)
 	for _, cn := range d.chans {
-		if _, err := d.c.SendMessage(cn.ID, messageText,
+		if _, err := c.SendMessage(cn.ID, messageText,
 			discord.Embed{
-				Title: "Message with missing signatures",
-				Fields: []discord.EmbedField{
-					{Name: "Message ID", Value: wrapCode(v.MessageID()), Inline: true},
-					{Name: "Digest", Value: wrapCode(v.HexDigest()), Inline: true},
-					{Name: "Quorum", Value: quorumText, Inline: true},
-					{Name: "Source Chain", Value: strings.Title(v.EmitterChain.String()), Inline: false},
-					{Name: "Missing Guardians", Value: missingText.String(), Inline: false},
-				},
+				Title:  a.Title,
+				Fields: fields,
 			},
 		); err != nil {
 			return err
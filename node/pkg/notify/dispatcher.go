@@ -0,0 +1,256 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+var (
+	alertsRaised = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_notify_alerts_total",
+			Help: "Total number of alerts raised",
+		}, []string{"type"})
+	alertsSuppressed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_notify_alerts_suppressed_total",
+			Help: "Total number of alerts which weren't sent to a backend",
+		}, []string{"type", "backend", "reason"})
+	alertsSent = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_notify_alerts_sent_total",
+			Help: "Total number of alerts sent to a backend",
+		}, []string{"type", "backend"})
+	alertSendErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_notify_send_errors_total",
+			Help: "Total number of alerts which failed to send to a backend",
+		}, []string{"type", "backend"})
+)
+
+// Route sends the alerts of the given types to the given backends.
+type Route struct {
+	// Types are the alert types the route applies to. An empty list matches all types.
+	Types    []AlertType
+	Backends []string
+}
+
+// ParseRoute parses a route of the form <types>:<backends>, with comma-separated lists of alert
+// types and backend names, e.g. "quorum_timeout,watcher_stalled:slack,email". The types "*" match all types.
+func ParseRoute(s string) (Route, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Route{}, fmt.Errorf("invalid route %q, expected <types>:<backends>", s)
+	}
+
+	var r Route
+	if parts[0] != "*" {
+		for _, t := range strings.Split(parts[0], ",") {
+			t := AlertType(strings.TrimSpace(t))
+			if !isAlertType(t) {
+				return Route{}, fmt.Errorf("unknown alert type %q in route %q", t, s)
+			}
+			r.Types = append(r.Types, t)
+		}
+	}
+	for _, b := range strings.Split(parts[1], ",") {
+		r.Backends = append(r.Backends, strings.TrimSpace(b))
+	}
+	return r, nil
+}
+
+func isAlertType(t AlertType) bool {
+	for _, known := range AlertTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Route) matches(t AlertType) bool {
+	if len(r.Types) == 0 {
+		return true
+	}
+	for _, rt := range r.Types {
+		if rt == t {
+			return true
+		}
+	}
+	return false
+}
+
+type DispatcherConfig struct {
+	// Routes select the backends of each alert. If there are no routes, every alert is sent to every backend.
+	Routes []Route
+	// DedupWindow is the time during which repeated alerts with the same type and DedupKey are dropped.
+	DedupWindow time.Duration
+	// RateLimit is the number of alerts per minute which are sent to a single backend, further alerts are dropped.
+	// Zero disables rate limiting.
+	RateLimit int
+	// SendTimeout bounds the delivery of a single alert to a backend.
+	SendTimeout time.Duration
+}
+
+const (
+	dispatcherQueueSize = 100
+	suppressedDuplicate = "duplicate"
+	suppressedRateLimit = "rate_limited"
+	suppressedQueueFull = "queue_full"
+)
+
+// Dispatcher is a Notifier which routes alerts to backends, dropping duplicates and excess alerts.
+type Dispatcher struct {
+	config   DispatcherConfig
+	backends map[string]Backend
+	limiters map[string]*rate.Limiter
+	queue    chan *Alert
+
+	mu sync.Mutex
+	// lastSent is the time at which an alert was last sent, by type and DedupKey.
+	lastSent map[string]time.Time
+}
+
+func NewDispatcher(backends []Backend, config DispatcherConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		config:   config,
+		backends: make(map[string]Backend),
+		limiters: make(map[string]*rate.Limiter),
+		queue:    make(chan *Alert, dispatcherQueueSize),
+		lastSent: make(map[string]time.Time),
+	}
+	for _, b := range backends {
+		if _, ok := d.backends[b.Name()]; ok {
+			return nil, fmt.Errorf("duplicate backend %s", b.Name())
+		}
+		d.backends[b.Name()] = b
+		if config.RateLimit > 0 {
+			d.limiters[b.Name()] = rate.NewLimiter(rate.Every(time.Minute/time.Duration(config.RateLimit)), config.RateLimit)
+		}
+	}
+	for _, r := range config.Routes {
+		for _, b := range r.Backends {
+			if _, ok := d.backends[b]; !ok {
+				return nil, fmt.Errorf("route to unknown backend %s", b)
+			}
+		}
+	}
+	return d, nil
+}
+
+// Notify queues the alert for dispatching. The alert is dropped if the queue is full.
+func (d *Dispatcher) Notify(a *Alert) {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	alertsRaised.WithLabelValues(string(a.Type)).Inc()
+	select {
+	case d.queue <- a:
+	default:
+		alertsSuppressed.WithLabelValues(string(a.Type), "", suppressedQueueFull).Inc()
+	}
+}
+
+// backendsFor returns the names of the backends the alert is routed to.
+func (d *Dispatcher) backendsFor(t AlertType) []string {
+	var names []string
+	if len(d.config.Routes) == 0 {
+		for name := range d.backends {
+			names = append(names, name)
+		}
+		return names
+	}
+
+	seen := make(map[string]bool)
+	for _, r := range d.config.Routes {
+		if !r.matches(t) {
+			continue
+		}
+		for _, name := range r.Backends {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// duplicate returns whether an alert with the same type and DedupKey was sent within the dedup window,
+// and records the alert otherwise.
+func (d *Dispatcher) duplicate(a *Alert) bool {
+	if d.config.DedupWindow <= 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := string(a.Type) + "/" + a.DedupKey
+	if last, ok := d.lastSent[key]; ok && a.Time.Sub(last) < d.config.DedupWindow {
+		return true
+	}
+	d.lastSent[key] = a.Time
+	for k, t := range d.lastSent {
+		if a.Time.Sub(t) >= d.config.DedupWindow {
+			delete(d.lastSent, k)
+		}
+	}
+	return false
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, logger *zap.Logger, a *Alert) {
+	if d.duplicate(a) {
+		alertsSuppressed.WithLabelValues(string(a.Type), "", suppressedDuplicate).Inc()
+		logger.Debug("dropping duplicate alert", zap.String("type", string(a.Type)), zap.String("key", a.DedupKey))
+		return
+	}
+
+	for _, name := range d.backendsFor(a.Type) {
+		if l, ok := d.limiters[name]; ok && !l.Allow() {
+			alertsSuppressed.WithLabelValues(string(a.Type), name, suppressedRateLimit).Inc()
+			logger.Warn("alert rate limit exceeded, dropping alert",
+				zap.String("backend", name),
+				zap.String("type", string(a.Type)),
+				zap.String("key", a.DedupKey))
+			continue
+		}
+
+		go func(b Backend) {
+			ctx, cancel := context.WithTimeout(ctx, d.config.SendTimeout)
+			defer cancel()
+			if err := b.Send(ctx, a); err != nil {
+				alertSendErrors.WithLabelValues(string(a.Type), b.Name()).Inc()
+				logger.Error("failed to send alert",
+					zap.String("backend", b.Name()),
+					zap.String("type", string(a.Type)),
+					zap.String("key", a.DedupKey),
+					zap.Error(err))
+				return
+			}
+			alertsSent.WithLabelValues(string(a.Type), b.Name()).Inc()
+		}(d.backends[name])
+	}
+}
+
+// Run dispatches the queued alerts until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	logger := supervisor.Logger(ctx)
+	supervisor.Signal(ctx, supervisor.SignalHealthy)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case a := <-d.queue:
+			d.dispatch(ctx, logger, a)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type testBackend struct {
+	name string
+
+	mu   sync.Mutex
+	sent []*Alert
+}
+
+func (b *testBackend) Name() string {
+	return b.name
+}
+
+func (b *testBackend) Send(ctx context.Context, a *Alert) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent = append(b.sent, a)
+	return nil
+}
+
+func (b *testBackend) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.sent)
+}
+
+func testAlert(t AlertType, key string, at time.Time) *Alert {
+	return &Alert{Type: t, Title: "test", DedupKey: key, Time: at}
+}
+
+func TestParseRoute(t *testing.T) {
+	r, err := ParseRoute("quorum_timeout, watcher_stalled:slack,email")
+	assert.Nil(t, err)
+	assert.Equal(t, []AlertType{AlertQuorumTimeout, AlertWatcherStalled}, r.Types)
+	assert.Equal(t, []string{"slack", "email"}, r.Backends)
+	assert.True(t, r.matches(AlertWatcherStalled))
+	assert.False(t, r.matches(AlertMissingSignatures))
+
+	r, err = ParseRoute("*:discord")
+	assert.Nil(t, err)
+	assert.True(t, r.matches(AlertUndoneSequence))
+
+	for _, invalid := range []string{"", "slack", ":slack", "quorum_timeout:", "unknown:slack"} {
+		_, err = ParseRoute(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestNewDispatcherValidatesBackends(t *testing.T) {
+	_, err := NewDispatcher([]Backend{&testBackend{name: "a"}, &testBackend{name: "a"}}, DispatcherConfig{})
+	assert.NotNil(t, err)
+
+	_, err = NewDispatcher([]Backend{&testBackend{name: "a"}}, DispatcherConfig{
+		Routes: []Route{{Backends: []string{"b"}}},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestDispatcherRoutes(t *testing.T) {
+	slack, email := &testBackend{name: "slack"}, &testBackend{name: "email"}
+	d, err := NewDispatcher([]Backend{slack, email}, DispatcherConfig{
+		Routes: []Route{
+			{Backends: []string{"slack"}},
+			{Types: []AlertType{AlertQuorumTimeout}, Backends: []string{"email", "slack"}},
+		},
+		SendTimeout: time.Second,
+	})
+	assert.Nil(t, err)
+
+	assert.ElementsMatch(t, []string{"slack"}, d.backendsFor(AlertMissingSignatures))
+	assert.ElementsMatch(t, []string{"slack", "email"}, d.backendsFor(AlertQuorumTimeout))
+
+	d.dispatch(context.Background(), zap.NewNop(), testAlert(AlertQuorumTimeout, "1", time.Now()))
+	d.dispatch(context.Background(), zap.NewNop(), testAlert(AlertWatcherStalled, "1", time.Now()))
+	assert.Eventually(t, func() bool { return slack.count() == 2 && email.count() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestDispatcherDeduplicates(t *testing.T) {
+	b := &testBackend{name: "webhook"}
+	d, err := NewDispatcher([]Backend{b}, DispatcherConfig{DedupWindow: time.Minute, SendTimeout: time.Second})
+	assert.Nil(t, err)
+
+	now := time.Now()
+	d.dispatch(context.Background(), zap.NewNop(), testAlert(AlertQuorumTimeout, "1", now))
+	// same type and key within the window
+	d.dispatch(context.Background(), zap.NewNop(), testAlert(AlertQuorumTimeout, "1", now.Add(30*time.Second)))
+	// different key or type
+	d.dispatch(context.Background(), zap.NewNop(), testAlert(AlertQuorumTimeout, "2", now.Add(30*time.Second)))
+	d.dispatch(context.Background(), zap.NewNop(), testAlert(AlertMissingSignatures, "1", now.Add(30*time.Second)))
+	// after the window
+	d.dispatch(context.Background(), zap.NewNop(), testAlert(AlertQuorumTimeout, "1", now.Add(2*time.Minute)))
+
+	assert.Eventually(t, func() bool { return b.count() == 4 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 4, b.count())
+}
+
+func TestDispatcherRateLimits(t *testing.T) {
+	b := &testBackend{name: "webhook"}
+	d, err := NewDispatcher([]Backend{b}, DispatcherConfig{RateLimit: 3, SendTimeout: time.Second})
+	assert.Nil(t, err)
+
+	for i := 0; i < 5; i++ {
+		d.dispatch(context.Background(), zap.NewNop(), testAlert(AlertQuorumTimeout, "1", time.Now()))
+	}
+	assert.Eventually(t, func() bool { return b.count() == 3 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 3, b.count())
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type EmailConfig struct {
+	// Addr is the host:port of the SMTP server.
+	Addr string
+	// Username and Password are used for PLAIN authentication, which is skipped if Username is empty.
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// EmailBackend sends the alerts as plain text emails via SMTP.
+type EmailBackend struct {
+	config EmailConfig
+}
+
+func NewEmailBackend(config EmailConfig) (*EmailBackend, error) {
+	if _, _, err := net.SplitHostPort(config.Addr); err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", config.Addr, err)
+	}
+	if config.From == "" || len(config.To) == 0 {
+		return nil, fmt.Errorf("email sender and recipients are required")
+	}
+	return &EmailBackend{config: config}, nil
+}
+
+func (e *EmailBackend) Name() string {
+	return "email"
+}
+
+// message returns the RFC 822 message of the alert.
+func (e *EmailBackend) message(a *Alert) []byte {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(b, "To: %s\r\n", strings.Join(e.config.To, ", "))
+	fmt.Fprintf(b, "Subject: [wormhole %s] %s\r\n", a.Severity, a.Title)
+	fmt.Fprintf(b, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(a.PlainText(), "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// Send delivers the alert. The connection is closed once the deadline of the context passes,
+// so that an unresponsive SMTP server can't block the dispatcher.
+func (e *EmailBackend) Send(ctx context.Context, a *Alert) error {
+	host, _, _ := net.SplitHostPort(e.config.Addr)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.config.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	// the deadline doesn't cover the cancellation of the context
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return e.send(c, host, a)
+}
+
+// send delivers the alert on the connected client, the same way as smtp.SendMail.
+func (e *EmailBackend) send(c *smtp.Client, host string, a *Alert) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.config.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", e.config.Username, e.config.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.config.From); err != nil {
+		return err
+	}
+	for _, to := range e.config.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(e.message(a)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
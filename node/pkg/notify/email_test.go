@@ -0,0 +1,42 @@
+package notify
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailSendTimeout(t *testing.T) {
+	// the server accepts connections but never sends its greeting
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	e, err := NewEmailBackend(EmailConfig{Addr: l.Addr().String(), From: "guardian@example.com", To: []string{"ops@example.com"}})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.NotNil(t, e.Send(ctx, &Alert{Title: "test", Time: time.Now()}))
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// a cancelled context aborts the delivery as well
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start = time.Now()
+	assert.NotNil(t, e.Send(ctx, &Alert{Title: "test", Time: time.Now()}))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,121 @@
+// Package notify raises operator alerts and dispatches them to the configured backends, like
+// Discord, Slack, generic webhooks and email.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AlertType identifies the kind of an alert. Routing rules and deduplication are based on it.
+type AlertType string
+
+const (
+	AlertMissingSignatures AlertType = "missing_signatures"
+	AlertQuorumTimeout     AlertType = "quorum_timeout"
+	AlertGuardianSetChange AlertType = "guardian_set_change"
+	AlertWatcherStalled    AlertType = "watcher_stalled"
+	AlertUndoneSequence    AlertType = "undone_sequence"
+)
+
+// AlertTypes are all known alert types.
+var AlertTypes = []AlertType{
+	AlertMissingSignatures,
+	AlertQuorumTimeout,
+	AlertGuardianSetChange,
+	AlertWatcherStalled,
+	AlertUndoneSequence,
+}
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	// SeverityCritical alerts get the attention of everyone in the channel, if the backend supports it.
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Field is a named detail of an alert.
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+	// Guardians is a list of guardian node names, used instead of Value. Backends which know
+	// the guardians' handles mention them.
+	Guardians []string `json:"guardians,omitempty"`
+	Inline    bool     `json:"inline,omitempty"`
+}
+
+// Text renders the field's value, using mention to render guardian names.
+func (f Field) Text(mention func(name string) string) string {
+	if len(f.Guardians) == 0 {
+		return f.Value
+	}
+	b := &bytes.Buffer{}
+	for _, g := range f.Guardians {
+		fmt.Fprintf(b, "- %s\n", mention(g))
+	}
+	return b.String()
+}
+
+type Alert struct {
+	Type     AlertType `json:"type"`
+	Severity Severity  `json:"severity"`
+	Title    string    `json:"title"`
+	Text     string    `json:"text,omitempty"`
+	Fields   []Field   `json:"fields,omitempty"`
+	// DedupKey identifies the subject of the alert, e.g. a message ID. Alerts of the same type
+	// and key are only sent once per deduplication window.
+	DedupKey string    `json:"dedupKey"`
+	Time     time.Time `json:"time"`
+}
+
+// PlainText renders the alert as plain text, for backends without rich formatting.
+func (a *Alert) PlainText() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "[%s] %s\n", a.Severity, a.Title)
+	if a.Text != "" {
+		fmt.Fprintf(b, "%s\n", a.Text)
+	}
+	for _, f := range a.Fields {
+		text := f.Text(func(name string) string { return name })
+		if strings.Contains(text, "\n") {
+			fmt.Fprintf(b, "%s:\n%s", f.Name, text)
+		} else {
+			fmt.Fprintf(b, "%s: %s\n", f.Name, text)
+		}
+	}
+	return b.String()
+}
+
+// Notifier raises alerts. Implementations must not block the caller.
+type Notifier interface {
+	Notify(a *Alert)
+}
+
+// Backend delivers alerts to a single channel.
+type Backend interface {
+	// Name identifies the backend in routing rules, logs and metrics.
+	Name() string
+	Send(ctx context.Context, a *Alert) error
+}
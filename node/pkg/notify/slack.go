@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SlackBackend posts the alerts to a Slack incoming webhook.
+type SlackBackend struct {
+	webhookURL string
+}
+
+func NewSlackBackend(webhookURL string) *SlackBackend {
+	return &SlackBackend{webhookURL: webhookURL}
+}
+
+func (s *SlackBackend) Name() string {
+	return "slack"
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// slackText renders the alert in Slack's mrkdwn format.
+func slackText(a *Alert) string {
+	b := &strings.Builder{}
+	if a.Severity == SeverityCritical {
+		b.WriteString("<!here> ")
+	}
+	fmt.Fprintf(b, "*%s*\n", a.Title)
+	if a.Text != "" {
+		fmt.Fprintf(b, "%s\n", a.Text)
+	}
+	for _, f := range a.Fields {
+		text := f.Text(func(name string) string { return name })
+		if strings.Contains(text, "\n") {
+			fmt.Fprintf(b, "*%s*\n%s", f.Name, text)
+		} else {
+			fmt.Fprintf(b, "*%s*: %s\n", f.Name, text)
+		}
+	}
+	return b.String()
+}
+
+func (s *SlackBackend) Send(ctx context.Context, a *Alert) error {
+	return postJSON(ctx, s.webhookURL, &slackMessage{Text: slackText(a)})
+}
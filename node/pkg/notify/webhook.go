@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// postJSON posts the JSON encoding of v and fails on responses other than 2xx.
+func postJSON(ctx context.Context, url string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %s", resp.Status)
+	}
+	return nil
+}
+
+// WebhookBackend posts the alerts as JSON objects to a URL.
+type WebhookBackend struct {
+	url string
+}
+
+func NewWebhookBackend(url string) *WebhookBackend {
+	return &WebhookBackend{url: url}
+}
+
+func (w *WebhookBackend) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookBackend) Send(ctx context.Context, a *Alert) error {
+	return postJSON(ctx, w.url, a)
+}
@@ -184,7 +184,8 @@ func Run(obsvC chan *gossipv1.SignedObservation, obsvReqC chan *gossipv1.Observa
 
 		bootTime := time.Now()
 
-		// Periodically run guardian state set cleanup.
+		// Periodically run guardian state set cleanup and record the number of peers.
+		DefaultRegistry.SetPeerCount(len(h.Network().Peers()))
 		go func() {
 			ticker := time.NewTicker(15 * time.Second)
 			defer ticker.Stop()
@@ -192,6 +193,7 @@ func Run(obsvC chan *gossipv1.SignedObservation, obsvReqC chan *gossipv1.Observa
 				select {
 				case <-ticker.C:
 					gst.Cleanup()
+					DefaultRegistry.SetPeerCount(len(h.Network().Peers()))
 				case <-ctx.Done():
 					return
 				}
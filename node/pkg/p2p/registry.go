@@ -20,6 +20,9 @@ type registry struct {
 
 	// Value of Heartbeat.guardian_addr.
 	guardianAddress string
+
+	// Number of peers the p2p host is connected to.
+	peerCount int
 }
 
 func NewRegistry() *registry {
@@ -61,3 +64,16 @@ func (r *registry) GetErrorCount(chain vaa.ChainID) uint64 {
 	defer r.errorCounterMu.Unlock()
 	return r.errorCounters[chain]
 }
+
+// SetPeerCount records the number of peers the p2p host is connected to.
+func (r *registry) SetPeerCount(n int) {
+	r.mu.Lock()
+	r.peerCount = n
+	r.mu.Unlock()
+}
+
+func (r *registry) PeerCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.peerCount
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"github.com/certusone/wormhole/node/pkg/common"
 	"github.com/certusone/wormhole/node/pkg/db"
+	"github.com/certusone/wormhole/node/pkg/notify"
 	"github.com/certusone/wormhole/node/pkg/vaa"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -53,6 +54,10 @@ var (
 
 const (
 	settlementTime = time.Second * 30
+	// cleanupInterval is the interval of handleCleanup runs, each of which retransmits our
+	// observations of the VAAs which haven't reached quorum after firstRetryDelay.
+	cleanupInterval = time.Second * 30
+	firstRetryDelay = time.Minute * 5
 )
 
 // handleCleanup handles periodic retransmissions and cleanup of VAAs
@@ -63,6 +68,18 @@ func (p *Processor) handleCleanup(ctx context.Context) {
 	for hash, s := range p.state.vaaSignatures {
 		delta := time.Since(s.firstObserved)
 
+		if p.notifier != nil && p.quorumAlertTimeout > 0 && !s.submitted && !s.quorumAlerted && s.ourVAA != nil && delta > p.quorumAlertTimeout {
+			// Only alert on VAAs we observed ourselves, since bogus observations could cause invalid alerts.
+			gs := s.gs
+			if gs == nil {
+				gs = p.gs
+			}
+			p.logger.Warn("VAA didn't reach quorum in time", zap.String("digest", hash), zap.Duration("delta", delta))
+			p.notifier.Notify(notify.QuorumTimeout(s.ourVAA, len(s.signatures), CalculateQuorum(len(gs.Keys)), delta))
+			s.quorumAlerted = true
+			p.storeState(hash)
+		}
+
 		switch {
 		case !s.submitted && s.ourVAA != nil && delta > settlementTime:
 			// Expire pending VAAs post settlement time if we have a stored quorum VAA.
@@ -77,7 +94,7 @@ func (p *Processor) handleCleanup(ctx context.Context) {
 				// have a quorum VAA.
 				p.logger.Info("Expiring late VAA", zap.String("digest", hash), zap.Duration("delta", delta))
 				aggregationStateLate.Inc()
-				delete(p.state.vaaSignatures, hash)
+				p.deleteState(hash)
 				break
 			} else if err != db.ErrVAANotFound {
 				p.logger.Error("failed to look up VAA in database",
@@ -133,11 +150,7 @@ func (p *Processor) handleCleanup(ctx context.Context) {
 					// Send notification for individual message when quorum has failed or
 					// more than one node is missing.
 					if !quorum || len(missing) > 1 {
-						go func(v *vaa.VAA, hasSigs, wantSigs int, quorum bool, missing []string) {
-							if err := p.notifier.MissingSignaturesOnTransaction(v, hasSigs, wantSigs, quorum, missing); err != nil {
-								p.logger.Error("failed to send notification", zap.Error(err))
-							}
-						}(s.ourVAA, hasSigs, wantSigs, quorum, missing)
+						p.notifier.Notify(notify.MissingSignatures(s.ourVAA, hasSigs, wantSigs, quorum, missing))
 					}
 				}
 			}
@@ -158,20 +171,21 @@ func (p *Processor) handleCleanup(ctx context.Context) {
 					aggregationStateFulfillment.WithLabelValues(k.Hex(), s.source, "missing").Inc()
 				}
 			}
+			p.storeState(hash)
 		case s.submitted && delta.Hours() >= 1:
 			// We could delete submitted VAAs right away, but then we'd lose context about additional (late)
 			// observation that come in. Therefore, keep it for a reasonable amount of time.
 			// If a very late observation arrives after cleanup, a nil aggregation state will be created
 			// and then expired after a while (as noted in observation.go, this can be abused by a byzantine guardian).
 			p.logger.Info("expiring submitted VAA", zap.String("digest", hash), zap.Duration("delta", delta))
-			delete(p.state.vaaSignatures, hash)
+			p.deleteState(hash)
 			aggregationStateExpiration.Inc()
 		case !s.submitted && ((s.ourMsg != nil && s.retryCount >= 14400 /* 120 hours */) || (s.ourMsg == nil && s.retryCount >= 10 /* 5 minutes */)):
 			// Clearly, this horse is dead and continued beatings won't bring it closer to quorum.
 			p.logger.Info("expiring unsubmitted VAA after exhausting retries", zap.String("digest", hash), zap.Duration("delta", delta))
-			delete(p.state.vaaSignatures, hash)
+			p.deleteState(hash)
 			aggregationStateTimeout.Inc()
-		case !s.submitted && delta.Minutes() >= 5:
+		case !s.submitted && delta >= firstRetryDelay:
 			// Poor VAA has been unsubmitted for five minutes - clearly, something went wrong.
 			// If we have previously submitted an observation, we can make another attempt to get it over
 			// the finish line by rebroadcasting our sig. If we do not have a VAA, it means we either never observed it,
@@ -185,6 +199,7 @@ func (p *Processor) handleCleanup(ctx context.Context) {
 				p.sendC <- s.ourMsg
 				s.retryCount += 1
 				aggregationStateRetries.Inc()
+				p.storeState(hash)
 			} else {
 				// For nil state entries, we log the quorum to determine whether the
 				// network reached consensus without us. We don't know the correct guardian
@@ -199,7 +214,7 @@ func (p *Processor) handleCleanup(ctx context.Context) {
 					zap.Int("required_sigs", wantSigs),
 					zap.Bool("quorum", hasSigs >= wantSigs),
 				)
-				delete(p.state.vaaSignatures, hash)
+				p.deleteState(hash)
 				aggregationStateUnobserved.Inc()
 			}
 		}
@@ -156,6 +156,13 @@ func (p *Processor) handleMessage(ctx context.Context, k *common.MessagePublicat
 	messagesSignedTotal.With(prometheus.Labels{
 		"emitter_chain": k.EmitterChain.String()}).Add(1)
 
+	if err := p.db.StoreSourceTxHash(*db.VaaIDFromVAA(v), k.TxHash.Bytes()); err != nil {
+		p.logger.Error("failed to store source tx hash",
+			zap.String("message_id", v.MessageID()),
+			zap.Stringer("txhash", k.TxHash),
+			zap.Error(err))
+	}
+
 	p.attestationEvents.ReportMessagePublication(&reporter.MessagePublication{VAA: *v, InitiatingTxID: k.TxHash})
 
 	p.broadcastSignature(v, s, k.TxHash.Bytes())
@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+type (
+	// storedVAA is the unsigned VAA of a stored aggregation state. Unsigned VAAs with short
+	// payloads can't be parsed by vaa.Unmarshal, so the fields are stored individually.
+	storedVAA struct {
+		Version          uint8       `json:"version"`
+		GuardianSetIndex uint32      `json:"guardianSetIndex"`
+		Timestamp        time.Time   `json:"timestamp"`
+		Nonce            uint32      `json:"nonce"`
+		Sequence         uint64      `json:"sequence"`
+		ConsistencyLevel uint8       `json:"consistencyLevel"`
+		EmitterChain     vaa.ChainID `json:"emitterChain"`
+		EmitterAddress   []byte      `json:"emitterAddress"`
+		Payload          []byte      `json:"payload"`
+	}
+
+	// storedVAAState is the serialized form of a vaaState in the node's database
+	storedVAAState struct {
+		FirstObserved time.Time                    `json:"firstObserved"`
+		OurVAA        *storedVAA                   `json:"ourVAA,omitempty"`
+		Signatures    map[ethcommon.Address][]byte `json:"signatures"`
+		Submitted     bool                         `json:"submitted"`
+		Settled       bool                         `json:"settled"`
+		Source        string                       `json:"source"`
+		RetryCount    uint                         `json:"retryCount"`
+		OurMsg        []byte                       `json:"ourMsg,omitempty"`
+		GuardianSet   *common.GuardianSet          `json:"guardianSet,omitempty"`
+		QuorumAlerted bool                         `json:"quorumAlerted,omitempty"`
+	}
+)
+
+func marshalVAAState(s *vaaState) ([]byte, error) {
+	stored := &storedVAAState{
+		FirstObserved: s.firstObserved,
+		Signatures:    s.signatures,
+		Submitted:     s.submitted,
+		Settled:       s.settled,
+		Source:        s.source,
+		RetryCount:    s.retryCount,
+		OurMsg:        s.ourMsg,
+		GuardianSet:   s.gs,
+		QuorumAlerted: s.quorumAlerted,
+	}
+	if v := s.ourVAA; v != nil {
+		stored.OurVAA = &storedVAA{
+			Version:          v.Version,
+			GuardianSetIndex: v.GuardianSetIndex,
+			Timestamp:        v.Timestamp,
+			Nonce:            v.Nonce,
+			Sequence:         v.Sequence,
+			ConsistencyLevel: v.ConsistencyLevel,
+			EmitterChain:     v.EmitterChain,
+			EmitterAddress:   v.EmitterAddress[:],
+			Payload:          v.Payload,
+		}
+	}
+	return json.Marshal(stored)
+}
+
+func unmarshalVAAState(data []byte) (*vaaState, error) {
+	var stored storedVAAState
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	s := &vaaState{
+		firstObserved: stored.FirstObserved,
+		signatures:    stored.Signatures,
+		submitted:     stored.Submitted,
+		settled:       stored.Settled,
+		source:        stored.Source,
+		retryCount:    stored.RetryCount,
+		ourMsg:        stored.OurMsg,
+		gs:            stored.GuardianSet,
+		quorumAlerted: stored.QuorumAlerted,
+	}
+	if s.signatures == nil {
+		s.signatures = map[ethcommon.Address][]byte{}
+	}
+	if v := stored.OurVAA; v != nil {
+		s.ourVAA = &vaa.VAA{
+			Version:          v.Version,
+			GuardianSetIndex: v.GuardianSetIndex,
+			Timestamp:        v.Timestamp,
+			Nonce:            v.Nonce,
+			Sequence:         v.Sequence,
+			ConsistencyLevel: v.ConsistencyLevel,
+			EmitterChain:     v.EmitterChain,
+			Payload:          v.Payload,
+		}
+		copy(s.ourVAA.EmitterAddress[:], v.EmitterAddress)
+	}
+	return s, nil
+}
+
+// storeState persists the aggregation state of the given digest, so it survives restarts.
+// Failures are logged only, the in-memory state stays authoritative.
+func (p *Processor) storeState(hash string) {
+	s := p.state.vaaSignatures[hash]
+	if s == nil {
+		return
+	}
+	b, err := marshalVAAState(s)
+	if err != nil {
+		p.logger.Error("failed to serialize aggregation state", zap.String("digest", hash), zap.Error(err))
+		return
+	}
+	if err := p.db.StoreAggregationState(hash, b); err != nil {
+		p.logger.Error("failed to store aggregation state", zap.String("digest", hash), zap.Error(err))
+	}
+}
+
+// deleteState removes the aggregation state of the given digest from memory and the database.
+func (p *Processor) deleteState(hash string) {
+	delete(p.state.vaaSignatures, hash)
+	if err := p.db.DeleteAggregationState(hash); err != nil {
+		p.logger.Error("failed to delete aggregation state", zap.String("digest", hash), zap.Error(err))
+	}
+}
+
+// missedRetries returns the number of retransmissions the cleanup would have attempted for a
+// state of the given age, i.e. one per cleanup interval after the first retry delay.
+func missedRetries(age time.Duration) uint {
+	if age < firstRetryDelay {
+		return 0
+	}
+	return uint((age-firstRetryDelay)/cleanupInterval) + 1
+}
+
+// restoreState loads the aggregation states persisted before a restart. The retry count is raised to
+// account for the downtime, so that the cleanup expires the restored states like it would have if the
+// node had kept running.
+func (p *Processor) restoreState() error {
+	stored, err := p.db.GetAggregationStates()
+	if err != nil {
+		return err
+	}
+
+	restored := 0
+	for hash, b := range stored {
+		if _, ok := p.state.vaaSignatures[hash]; ok {
+			continue
+		}
+		s, err := unmarshalVAAState(b)
+		if err != nil {
+			p.logger.Error("failed to parse stored aggregation state, dropping it", zap.String("digest", hash), zap.Error(err))
+			p.deleteState(hash)
+			continue
+		}
+		if retries := missedRetries(time.Since(s.firstObserved)); retries > s.retryCount {
+			s.retryCount = retries
+		}
+		p.state.vaaSignatures[hash] = s
+		restored++
+	}
+
+	p.logger.Info("restored aggregation state", zap.Int("entries", restored))
+	aggregationStateEntries.Set(float64(len(p.state.vaaSignatures)))
+	return nil
+}
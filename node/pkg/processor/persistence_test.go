@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/db"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newTestProcessor(t *testing.T) *Processor {
+	database, err := db.Open(t.TempDir())
+	assert.Nil(t, err)
+	t.Cleanup(func() { database.Close() })
+	return &Processor{
+		db:     database,
+		logger: zap.NewNop(),
+		state:  &aggregationState{vaaMap{}},
+		sendC:  make(chan []byte, 8),
+	}
+}
+
+func testVAAState(firstObserved time.Time) *vaaState {
+	guardian := ethcommon.HexToAddress("0xbeFA429d57cD18b7F8A4d91A2da9AB4AF05d0FBe")
+	return &vaaState{
+		firstObserved: firstObserved.Round(0).UTC(),
+		ourVAA: &vaa.VAA{
+			Version:          vaa.SupportedVAAVersion,
+			GuardianSetIndex: 1,
+			Timestamp:        time.Unix(1650000000, 0).UTC(),
+			Nonce:            7,
+			Sequence:         42,
+			ConsistencyLevel: 1,
+			EmitterChain:     vaa.ChainIDEthereum,
+			EmitterAddress:   vaa.Address{1, 2, 3},
+			Payload:          []byte{1},
+		},
+		signatures: map[ethcommon.Address][]byte{guardian: {4, 5, 6}},
+		settled:    true,
+		source:     "ethereum",
+		retryCount: 3,
+		ourMsg:     []byte("signed observation"),
+		gs:         &common.GuardianSet{Keys: []ethcommon.Address{guardian}, Index: 1},
+		// restarts must not repeat quorum timeout alerts
+		quorumAlerted: true,
+	}
+}
+
+func TestVAAStateSerialization(t *testing.T) {
+	s := testVAAState(time.Now())
+	b, err := marshalVAAState(s)
+	assert.Nil(t, err)
+	restored, err := unmarshalVAAState(b)
+	assert.Nil(t, err)
+	assert.Equal(t, s, restored)
+
+	// states created by observations of other guardians have no VAA yet
+	s = &vaaState{firstObserved: time.Now().Round(0).UTC(), signatures: map[ethcommon.Address][]byte{}, source: "unknown"}
+	b, err = marshalVAAState(s)
+	assert.Nil(t, err)
+	restored, err = unmarshalVAAState(b)
+	assert.Nil(t, err)
+	assert.Equal(t, s, restored)
+}
+
+func TestMissedRetries(t *testing.T) {
+	assert.Equal(t, uint(0), missedRetries(firstRetryDelay-time.Second))
+	assert.Equal(t, uint(1), missedRetries(firstRetryDelay))
+	assert.Equal(t, uint(3), missedRetries(firstRetryDelay+2*cleanupInterval))
+}
+
+func TestRestoreState(t *testing.T) {
+	p := newTestProcessor(t)
+	p.state.vaaSignatures["recent"] = testVAAState(time.Now())
+	p.storeState("recent")
+	p.state.vaaSignatures["old"] = testVAAState(time.Now().Add(-firstRetryDelay - 10*cleanupInterval))
+	p.storeState("old")
+	p.state.vaaSignatures["deleted"] = testVAAState(time.Now())
+	p.storeState("deleted")
+	p.deleteState("deleted")
+	assert.Nil(t, p.db.StoreAggregationState("invalid", []byte("{")))
+
+	// simulate a restart
+	restarted := &Processor{db: p.db, logger: zap.NewNop(), state: &aggregationState{vaaMap{}}}
+	assert.Nil(t, restarted.restoreState())
+	assert.Equal(t, 2, len(restarted.state.vaaSignatures))
+	assert.Equal(t, p.state.vaaSignatures["recent"], restarted.state.vaaSignatures["recent"])
+	// the retry count accounts for the downtime
+	assert.Equal(t, uint(11), restarted.state.vaaSignatures["old"].retryCount)
+
+	stored, err := p.db.GetAggregationStates()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(stored))
+	assert.Nil(t, stored["invalid"])
+}
+
+func TestCleanupUpdatesStoredState(t *testing.T) {
+	p := newTestProcessor(t)
+	pending := testVAAState(time.Now().Add(-time.Minute))
+	pending.settled = false
+	p.state.vaaSignatures["pending"] = pending
+	submitted := testVAAState(time.Now().Add(-2 * time.Hour))
+	submitted.submitted = true
+	p.state.vaaSignatures["submitted"] = submitted
+	p.storeState("pending")
+	p.storeState("submitted")
+
+	p.handleCleanup(context.Background())
+
+	// the pending VAA settled, and the submitted one expired
+	stored, err := p.db.GetAggregationStates()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(stored))
+	restored, err := unmarshalVAAState(stored["pending"])
+	assert.Nil(t, err)
+	assert.True(t, restored.settled)
+}
@@ -3,7 +3,8 @@ package processor
 import (
 	"context"
 	"crypto/ecdsa"
-	"github.com/certusone/wormhole/node/pkg/notify/discord"
+	"fmt"
+	"github.com/certusone/wormhole/node/pkg/notify"
 	"time"
 
 	"github.com/certusone/wormhole/node/pkg/db"
@@ -41,6 +42,8 @@ type (
 		ourMsg []byte
 		// Copy of the guardian set valid at observation/injection time.
 		gs *common.GuardianSet
+		// Flag set by the cleanup service after alerting that quorum wasn't reached in time.
+		quorumAlerted bool
 	}
 
 	vaaMap map[string]*vaaState
@@ -99,7 +102,10 @@ type Processor struct {
 	// cleanup triggers periodic state cleanup
 	cleanup *time.Ticker
 
-	notifier *discord.DiscordNotifier
+	// notifier raises operator alerts, nil if alerting is disabled.
+	notifier notify.Notifier
+	// quorumAlertTimeout is the time after our observation at which a VAA without quorum is alerted on, 0 disables the alert.
+	quorumAlertTimeout time.Duration
 }
 
 func NewProcessor(
@@ -119,7 +125,8 @@ func NewProcessor(
 	terraLCD string,
 	terraContract string,
 	attestationEvents *reporter.AttestationEventReporter,
-	notifier *discord.DiscordNotifier,
+	notifier notify.Notifier,
+	quorumAlertTimeout time.Duration,
 ) *Processor {
 
 	return &Processor{
@@ -141,7 +148,8 @@ func NewProcessor(
 
 		attestationEvents: attestationEvents,
 
-		notifier: notifier,
+		notifier:           notifier,
+		quorumAlertTimeout: quorumAlertTimeout,
 
 		logger:  supervisor.Logger(ctx),
 		state:   &aggregationState{vaaMap{}},
@@ -150,16 +158,25 @@ func NewProcessor(
 }
 
 func (p *Processor) Run(ctx context.Context) error {
-	p.cleanup = time.NewTicker(30 * time.Second)
+	// Resume the aggregation of the VAAs which were in flight before a restart
+	if err := p.restoreState(); err != nil {
+		return fmt.Errorf("failed to restore aggregation state: %w", err)
+	}
+
+	p.cleanup = time.NewTicker(cleanupInterval)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case p.gs = <-p.setC:
+		case gs := <-p.setC:
 			p.logger.Info("guardian set updated",
-				zap.Strings("set", p.gs.KeysAsHexStrings()),
-				zap.Uint32("index", p.gs.Index))
+				zap.Strings("set", gs.KeysAsHexStrings()),
+				zap.Uint32("index", gs.Index))
+			if p.notifier != nil && p.gs != nil && p.gs.Index != gs.Index {
+				p.notifier.Notify(notify.GuardianSetChange(p.gs, gs))
+			}
+			p.gs = gs
 			p.gst.Set(p.gs)
 		case k := <-p.lockC:
 			p.handleMessage(ctx, k)
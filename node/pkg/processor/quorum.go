@@ -1,5 +1,12 @@
 package processor
 
+import (
+	"fmt"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+)
+
 // CalculateQuorum returns the minimum number of guardians that need to sign a VAA for a given guardian set.
 //
 // The canonical source is the calculation in the contracts (solana/bridge/src/processor.rs and
@@ -7,3 +14,26 @@ package processor
 func CalculateQuorum(numGuardians int) int {
 	return ((numGuardians*10/3)*2)/10 + 1
 }
+
+// VerifyQuorum checks that the VAA is signed by a quorum of distinct guardians of the given guardian set.
+//
+// VAA.VerifySignatures accepts the same guardian's signature more than once, so like the contracts, we
+// require strictly increasing guardian indexes before counting the signatures. This matters for VAAs
+// from untrusted sources, such as other nodes or archives.
+func VerifyQuorum(v *vaa.VAA, gs *common.GuardianSet) error {
+	if v.GuardianSetIndex != gs.Index {
+		return fmt.Errorf("VAA is signed by guardian set %d, expected %d", v.GuardianSetIndex, gs.Index)
+	}
+	for i := 1; i < len(v.Signatures); i++ {
+		if v.Signatures[i].Index <= v.Signatures[i-1].Index {
+			return fmt.Errorf("signature indexes are not strictly increasing at guardian %d", v.Signatures[i].Index)
+		}
+	}
+	if !v.VerifySignatures(gs.Keys) {
+		return fmt.Errorf("invalid signatures")
+	}
+	if quorum := CalculateQuorum(len(gs.Keys)); len(v.Signatures) < quorum {
+		return fmt.Errorf("VAA has %d signatures, %d required", len(v.Signatures), quorum)
+	}
+	return nil
+}
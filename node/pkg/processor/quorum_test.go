@@ -1,8 +1,16 @@
 package processor
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestCalculateQuorum(t *testing.T) {
@@ -34,3 +42,40 @@ func TestCalculateQuorum(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyQuorum(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 4)
+	gs := &common.GuardianSet{Index: 2}
+	for i := range keys {
+		k, err := crypto.GenerateKey()
+		assert.Nil(t, err)
+		keys[i] = k
+		gs.Keys = append(gs.Keys, crypto.PubkeyToAddress(k.PublicKey))
+	}
+	signed := func(indexes ...int) *vaa.VAA {
+		v := &vaa.VAA{
+			Version:          vaa.SupportedVAAVersion,
+			GuardianSetIndex: 2,
+			Timestamp:        time.Unix(1650000000, 0),
+			EmitterChain:     vaa.ChainIDEthereum,
+			EmitterAddress:   vaa.Address{1},
+			Payload:          []byte{1},
+		}
+		for _, i := range indexes {
+			v.AddSignature(keys[i], uint8(i))
+		}
+		return v
+	}
+
+	assert.Nil(t, VerifyQuorum(signed(0, 1, 2), gs))
+	assert.Nil(t, VerifyQuorum(signed(0, 1, 2, 3), gs))
+	assert.NotNil(t, VerifyQuorum(signed(0, 1), gs))
+	// repeated signatures of the same guardian don't count towards the quorum
+	assert.NotNil(t, VerifyQuorum(signed(0, 0, 0), gs))
+	assert.NotNil(t, VerifyQuorum(signed(2, 1, 0), gs))
+
+	other := &common.GuardianSet{Index: 1, Keys: gs.Keys}
+	assert.NotNil(t, VerifyQuorum(signed(0, 1, 2), other))
+	invalid := &common.GuardianSet{Index: 2, Keys: append([]ethcommon.Address{{1}}, gs.Keys[1:]...)}
+	assert.NotNil(t, VerifyQuorum(signed(0, 1, 2), invalid))
+}
@@ -0,0 +1,218 @@
+package publicrpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	publicrpcv1 "github.com/certusone/wormhole/node/pkg/proto/publicrpc/v1"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Token bridge payload IDs
+const (
+	tokenBridgeTransfer            = 1
+	tokenBridgeAttestation         = 2
+	tokenBridgeTransferWithPayload = 3
+)
+
+// Offset of the target chain in transfer payloads:
+// payload ID (1) + amount (32) + token address (32) + token chain (2) + recipient (32)
+const transferTargetChainOffset = 99
+
+// Minimum payload lengths, see the token bridge contracts
+const (
+	transferPayloadLength    = 133
+	attestationPayloadLength = 100
+)
+
+// Filter selects the VAAs streamed to a subscriber.
+type Filter interface {
+	Matches(v *vaa.VAA) bool
+}
+
+// emitterFilter matches a single emitter
+type emitterFilter struct {
+	chainId     vaa.ChainID
+	emitterAddr vaa.Address
+}
+
+func (f emitterFilter) Matches(v *vaa.VAA) bool {
+	return f.chainId == v.EmitterChain && f.emitterAddr == v.EmitterAddress
+}
+
+// emitterChainFilter matches all emitters of a chain
+type emitterChainFilter struct {
+	chainId vaa.ChainID
+}
+
+func (f emitterChainFilter) Matches(v *vaa.VAA) bool {
+	return f.chainId == v.EmitterChain
+}
+
+// payloadTypeFilter matches the token bridge messages of a type
+type payloadTypeFilter struct {
+	payloadType publicrpcv1.TokenBridgePayloadType
+}
+
+func (f payloadTypeFilter) Matches(v *vaa.VAA) bool {
+	return tokenBridgePayloadType(v) == f.payloadType
+}
+
+// targetChainFilter matches the token transfers to a chain
+type targetChainFilter struct {
+	chainId vaa.ChainID
+}
+
+func (f targetChainFilter) Matches(v *vaa.VAA) bool {
+	if tokenBridgePayloadType(v) != publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_TRANSFER {
+		return false
+	}
+	targetChain := binary.BigEndian.Uint16(v.Payload[transferTargetChainOffset:])
+	return f.chainId == vaa.ChainID(targetChain)
+}
+
+// sequenceFilter matches the messages of an emitter starting at a sequence
+type sequenceFilter struct {
+	emitterFilter
+	minSequence uint64
+}
+
+func (f sequenceFilter) Matches(v *vaa.VAA) bool {
+	return f.emitterFilter.Matches(v) && v.Sequence >= f.minSequence
+}
+
+// tokenBridgePayloadType returns the token bridge payload type of the VAA, or
+// TOKEN_BRIDGE_PAYLOAD_TYPE_UNSPECIFIED if the payload isn't a token bridge payload.
+func tokenBridgePayloadType(v *vaa.VAA) publicrpcv1.TokenBridgePayloadType {
+	if v.EmitterChain == vaa.GovernanceChain && v.EmitterAddress == vaa.GovernanceEmitter {
+		if bytes.HasPrefix(v.Payload, vaa.TokenBridgeModule) {
+			return publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_GOVERNANCE
+		}
+		return publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_UNSPECIFIED
+	}
+	if len(v.Payload) == 0 {
+		return publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_UNSPECIFIED
+	}
+
+	switch v.Payload[0] {
+	case tokenBridgeTransfer:
+		if len(v.Payload) == transferPayloadLength {
+			return publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_TRANSFER
+		}
+	case tokenBridgeTransferWithPayload:
+		if len(v.Payload) >= transferPayloadLength {
+			return publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_TRANSFER
+		}
+	case tokenBridgeAttestation:
+		if len(v.Payload) == attestationPayloadLength {
+			return publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_ATTESTATION
+		}
+	}
+	return publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_UNSPECIFIED
+}
+
+func decodeEmitterAddr(hexAddr string) (vaa.Address, error) {
+	address, err := hex.DecodeString(hexAddr)
+	if err != nil {
+		return vaa.Address{}, status.Error(codes.InvalidArgument, fmt.Sprintf("failed to decode address: %v", err))
+	}
+	if len(address) != 32 {
+		return vaa.Address{}, status.Error(codes.InvalidArgument, "address must be 32 bytes")
+	}
+
+	addr := vaa.Address{}
+	copy(addr[:], address)
+
+	return addr, nil
+}
+
+func parseChainID(chainId uint32) (vaa.ChainID, error) {
+	if chainId == 0 || chainId > 0xffff {
+		return 0, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid chain id %d", chainId))
+	}
+	return vaa.ChainID(chainId), nil
+}
+
+func parseEmitterFilter(chainId uint32, emitterAddress string) (emitterFilter, error) {
+	chain, err := parseChainID(chainId)
+	if err != nil {
+		return emitterFilter{}, err
+	}
+	addr, err := decodeEmitterAddr(emitterAddress)
+	if err != nil {
+		return emitterFilter{}, status.Error(codes.InvalidArgument, fmt.Sprintf("failed to decode emitter address: %v", err))
+	}
+	return emitterFilter{chainId: chain, emitterAddr: addr}, nil
+}
+
+// ParseFilter converts a filter entry of a subscription request, the errors are gRPC status errors
+func ParseFilter(f *publicrpcv1.FilterEntry) (Filter, error) {
+	switch t := f.Filter.(type) {
+	case *publicrpcv1.FilterEntry_EmitterFilter:
+		addr, err := decodeEmitterAddr(t.EmitterFilter.EmitterAddress)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("failed to decode emitter address: %v", err))
+		}
+		return emitterFilter{chainId: vaa.ChainID(t.EmitterFilter.ChainId), emitterAddr: addr}, nil
+	case *publicrpcv1.FilterEntry_EmitterChainFilter:
+		chain, err := parseChainID(uint32(t.EmitterChainFilter.ChainId))
+		if err != nil {
+			return nil, err
+		}
+		return emitterChainFilter{chainId: chain}, nil
+	case *publicrpcv1.FilterEntry_PayloadTypeFilter:
+		switch t.PayloadTypeFilter.PayloadType {
+		case publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_TRANSFER,
+			publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_ATTESTATION,
+			publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_GOVERNANCE:
+			return payloadTypeFilter{payloadType: t.PayloadTypeFilter.PayloadType}, nil
+		default:
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("unsupported payload type %v", t.PayloadTypeFilter.PayloadType))
+		}
+	case *publicrpcv1.FilterEntry_TargetChainFilter:
+		chain, err := parseChainID(uint32(t.TargetChainFilter.ChainId))
+		if err != nil {
+			return nil, err
+		}
+		return targetChainFilter{chainId: chain}, nil
+	case *publicrpcv1.FilterEntry_SequenceFilter:
+		emitter, err := parseEmitterFilter(uint32(t.SequenceFilter.ChainId), t.SequenceFilter.EmitterAddress)
+		if err != nil {
+			return nil, err
+		}
+		return sequenceFilter{emitterFilter: emitter, minSequence: t.SequenceFilter.MinSequence}, nil
+	default:
+		return nil, status.Error(codes.InvalidArgument, "unsupported filter type")
+	}
+}
+
+// ParseFilters converts the filter entries of a subscription request, the errors are gRPC status errors
+func ParseFilters(entries []*publicrpcv1.FilterEntry) ([]Filter, error) {
+	filters := make([]Filter, 0, len(entries))
+	for _, f := range entries {
+		parsed, err := ParseFilter(f)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, parsed)
+	}
+	return filters, nil
+}
+
+// MatchesAny returns whether any of the filters matches the VAA. Filters are OR'ed,
+// so an empty list matches all VAAs.
+func MatchesAny(filters []Filter, v *vaa.VAA) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		if f.Matches(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package publicrpc
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	publicrpcv1 "github.com/certusone/wormhole/node/pkg/proto/publicrpc/v1"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testEmitter = vaa.Address{1, 2, 3}
+
+func transferPayload(targetChain vaa.ChainID) []byte {
+	payload := make([]byte, transferPayloadLength)
+	payload[0] = tokenBridgeTransfer
+	binary.BigEndian.PutUint16(payload[transferTargetChainOffset:], uint16(targetChain))
+	return payload
+}
+
+func attestationPayload() []byte {
+	payload := make([]byte, attestationPayloadLength)
+	payload[0] = tokenBridgeAttestation
+	return payload
+}
+
+func testVAA(chain vaa.ChainID, emitter vaa.Address, sequence uint64, payload []byte) *vaa.VAA {
+	return &vaa.VAA{
+		Version:          vaa.SupportedVAAVersion,
+		Timestamp:        time.Unix(0, 0),
+		EmitterChain:     chain,
+		EmitterAddress:   emitter,
+		Sequence:         sequence,
+		ConsistencyLevel: 1,
+		Payload:          payload,
+	}
+}
+
+func mustParseFilter(t *testing.T, f *publicrpcv1.FilterEntry) Filter {
+	parsed, err := ParseFilter(f)
+	assert.Nil(t, err)
+	return parsed
+}
+
+func TestTokenBridgePayloadType(t *testing.T) {
+	governance := testVAA(vaa.GovernanceChain, vaa.GovernanceEmitter, 1, append(vaa.TokenBridgeModule, 1))
+	coreGovernance := testVAA(vaa.GovernanceChain, vaa.GovernanceEmitter, 1, append(vaa.CoreModule, 1))
+
+	assert.Equal(t, publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_TRANSFER,
+		tokenBridgePayloadType(testVAA(vaa.ChainIDEthereum, testEmitter, 1, transferPayload(vaa.ChainIDSolana))))
+	assert.Equal(t, publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_ATTESTATION,
+		tokenBridgePayloadType(testVAA(vaa.ChainIDEthereum, testEmitter, 1, attestationPayload())))
+	assert.Equal(t, publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_GOVERNANCE, tokenBridgePayloadType(governance))
+	assert.Equal(t, publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_UNSPECIFIED, tokenBridgePayloadType(coreGovernance))
+	// payloads which don't have the length of a token bridge payload
+	assert.Equal(t, publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_UNSPECIFIED,
+		tokenBridgePayloadType(testVAA(vaa.ChainIDEthereum, testEmitter, 1, []byte{tokenBridgeTransfer, 0})))
+	assert.Equal(t, publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_UNSPECIFIED,
+		tokenBridgePayloadType(testVAA(vaa.ChainIDEthereum, testEmitter, 1, nil)))
+}
+
+func TestFilters(t *testing.T) {
+	transfer := testVAA(vaa.ChainIDEthereum, testEmitter, 10, transferPayload(vaa.ChainIDSolana))
+	attestation := testVAA(vaa.ChainIDTerra, testEmitter, 5, attestationPayload())
+
+	emitterChain := mustParseFilter(t, &publicrpcv1.FilterEntry{Filter: &publicrpcv1.FilterEntry_EmitterChainFilter{
+		EmitterChainFilter: &publicrpcv1.EmitterChainFilter{ChainId: publicrpcv1.ChainID_CHAIN_ID_ETHEREUM},
+	}})
+	assert.True(t, emitterChain.Matches(transfer))
+	assert.False(t, emitterChain.Matches(attestation))
+
+	payloadType := mustParseFilter(t, &publicrpcv1.FilterEntry{Filter: &publicrpcv1.FilterEntry_PayloadTypeFilter{
+		PayloadTypeFilter: &publicrpcv1.PayloadTypeFilter{PayloadType: publicrpcv1.TokenBridgePayloadType_TOKEN_BRIDGE_PAYLOAD_TYPE_ATTESTATION},
+	}})
+	assert.False(t, payloadType.Matches(transfer))
+	assert.True(t, payloadType.Matches(attestation))
+
+	targetChain := mustParseFilter(t, &publicrpcv1.FilterEntry{Filter: &publicrpcv1.FilterEntry_TargetChainFilter{
+		TargetChainFilter: &publicrpcv1.TargetChainFilter{ChainId: publicrpcv1.ChainID_CHAIN_ID_SOLANA},
+	}})
+	assert.True(t, targetChain.Matches(transfer))
+	assert.False(t, targetChain.Matches(testVAA(vaa.ChainIDEthereum, testEmitter, 10, transferPayload(vaa.ChainIDTerra))))
+	assert.False(t, targetChain.Matches(attestation))
+
+	sequence := mustParseFilter(t, &publicrpcv1.FilterEntry{Filter: &publicrpcv1.FilterEntry_SequenceFilter{
+		SequenceFilter: &publicrpcv1.SequenceFilter{
+			ChainId:        publicrpcv1.ChainID_CHAIN_ID_ETHEREUM,
+			EmitterAddress: hex.EncodeToString(testEmitter[:]),
+			MinSequence:    10,
+		},
+	}})
+	assert.True(t, sequence.Matches(transfer))
+	assert.False(t, sequence.Matches(testVAA(vaa.ChainIDEthereum, testEmitter, 9, nil)))
+	assert.False(t, sequence.Matches(testVAA(vaa.ChainIDEthereum, vaa.Address{4}, 11, nil)))
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, f := range []*publicrpcv1.FilterEntry{
+		{},
+		{Filter: &publicrpcv1.FilterEntry_EmitterChainFilter{EmitterChainFilter: &publicrpcv1.EmitterChainFilter{}}},
+		{Filter: &publicrpcv1.FilterEntry_PayloadTypeFilter{PayloadTypeFilter: &publicrpcv1.PayloadTypeFilter{}}},
+		{Filter: &publicrpcv1.FilterEntry_TargetChainFilter{TargetChainFilter: &publicrpcv1.TargetChainFilter{}}},
+		{Filter: &publicrpcv1.FilterEntry_SequenceFilter{SequenceFilter: &publicrpcv1.SequenceFilter{
+			ChainId:        publicrpcv1.ChainID_CHAIN_ID_ETHEREUM,
+			EmitterAddress: "invalid",
+		}}},
+	} {
+		_, err := ParseFilter(f)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	transfer := testVAA(vaa.ChainIDEthereum, testEmitter, 10, transferPayload(vaa.ChainIDSolana))
+	attestation := testVAA(vaa.ChainIDTerra, testEmitter, 5, attestationPayload())
+
+	assert.True(t, MatchesAny(nil, attestation))
+
+	filters, err := ParseFilters([]*publicrpcv1.FilterEntry{
+		{Filter: &publicrpcv1.FilterEntry_EmitterChainFilter{EmitterChainFilter: &publicrpcv1.EmitterChainFilter{ChainId: publicrpcv1.ChainID_CHAIN_ID_SOLANA}}},
+		{Filter: &publicrpcv1.FilterEntry_EmitterChainFilter{EmitterChainFilter: &publicrpcv1.EmitterChainFilter{ChainId: publicrpcv1.ChainID_CHAIN_ID_ETHEREUM}}},
+	})
+	assert.Nil(t, err)
+	assert.True(t, MatchesAny(filters, transfer))
+	assert.False(t, MatchesAny(filters, attestation))
+
+	_, err = ParseFilters([]*publicrpcv1.FilterEntry{{}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
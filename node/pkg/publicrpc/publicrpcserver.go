@@ -2,7 +2,10 @@ package publicrpc
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/certusone/wormhole/node/pkg/common"
 	"github.com/certusone/wormhole/node/pkg/db"
@@ -19,17 +22,21 @@ type PublicrpcServer struct {
 	logger *zap.Logger
 	db     *db.Database
 	gst    *common.GuardianSetState
+	stream *SignedVAAStream
 }
 
+// NewPublicrpcServer creates the publicrpc service. SubscribeSignedVAA is unavailable if stream is nil.
 func NewPublicrpcServer(
 	logger *zap.Logger,
 	db *db.Database,
 	gst *common.GuardianSetState,
+	stream *SignedVAAStream,
 ) *PublicrpcServer {
 	return &PublicrpcServer{
 		logger: logger.Named("publicrpcserver"),
 		db:     db,
 		gst:    gst,
+		stream: stream,
 	}
 }
 
@@ -92,6 +99,76 @@ func (s *PublicrpcServer) GetSignedVAA(ctx context.Context, req *publicrpcv1.Get
 	}, nil
 }
 
+const (
+	defaultListPageSize = 100
+	maxListPageSize     = 1000
+)
+
+// encodePageToken encodes the first sequence of the next page. Clients should treat tokens as opaque.
+func encodePageToken(seq uint64) string {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], seq)
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+func decodePageToken(token string) (uint64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) != 8 {
+		return 0, errors.New("invalid page token")
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func (s *PublicrpcServer) ListSignedVAAs(ctx context.Context, req *publicrpcv1.ListSignedVAAsRequest) (*publicrpcv1.ListSignedVAAsResponse, error) {
+	chain, err := parseChainID(uint32(req.EmitterChain))
+	if err != nil {
+		return nil, err
+	}
+	addr, err := decodeEmitterAddr(req.EmitterAddress)
+	if err != nil {
+		return nil, err
+	}
+	if req.To != 0 && req.To < req.From {
+		return nil, status.Error(codes.InvalidArgument, "to must not be lower than from")
+	}
+
+	from := req.From
+	if req.PageToken != "" {
+		next, err := decodePageToken(req.PageToken)
+		if err != nil || next < req.From {
+			return nil, status.Error(codes.InvalidArgument, "invalid page token")
+		}
+		from = next
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = defaultListPageSize
+	} else if pageSize > maxListPageSize {
+		pageSize = maxListPageSize
+	}
+
+	vaas, more, err := s.db.ListSignedVAAs(db.VAAID{EmitterChain: chain, EmitterAddress: addr}, from, req.To, pageSize)
+	if err != nil {
+		s.logger.Error("failed to list VAAs", zap.Error(err), zap.Any("request", req))
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+
+	resp := &publicrpcv1.ListSignedVAAsResponse{
+		Entries: make([]*publicrpcv1.ListSignedVAAsResponse_Entry, len(vaas)),
+	}
+	for i, v := range vaas {
+		resp.Entries[i] = &publicrpcv1.ListSignedVAAsResponse_Entry{
+			Sequence: v.Sequence,
+			VaaBytes: v.Bytes,
+		}
+	}
+	if more {
+		resp.NextPageToken = encodePageToken(vaas[len(vaas)-1].Sequence + 1)
+	}
+	return resp, nil
+}
+
 func (s *PublicrpcServer) GetCurrentGuardianSet(ctx context.Context, req *publicrpcv1.GetCurrentGuardianSetRequest) (*publicrpcv1.GetCurrentGuardianSetResponse, error) {
 	gs := s.gst.Get()
 	if gs == nil {
@@ -111,3 +188,32 @@ func (s *PublicrpcServer) GetCurrentGuardianSet(ctx context.Context, req *public
 
 	return resp, nil
 }
+
+func (s *PublicrpcServer) SubscribeSignedVAA(req *publicrpcv1.SubscribeSignedVAARequest, resp publicrpcv1.PublicRPCService_SubscribeSignedVAAServer) error {
+	if s.stream == nil {
+		return status.Error(codes.Unimplemented, "signed VAA stream not enabled on this endpoint")
+	}
+	filters, err := ParseFilters(req.Filters)
+	if err != nil {
+		return err
+	}
+
+	id, sub, err := s.stream.subscribe(filters)
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	defer s.stream.unsubscribe(id)
+
+	for {
+		select {
+		case <-resp.Context().Done():
+			return resp.Context().Err()
+		case <-sub.overflow:
+			return status.Error(codes.ResourceExhausted, "VAAs were missed by the signed VAA stream, resubscribe and backfill them")
+		case b := <-sub.ch:
+			if err := resp.Send(&publicrpcv1.SubscribeSignedVAAResponse{VaaBytes: b}); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package publicrpc
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/certusone/wormhole/node/pkg/db"
+	publicrpcv1 "github.com/certusone/wormhole/node/pkg/proto/publicrpc/v1"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestListSignedVAAs(t *testing.T) {
+	d, err := db.Open(t.TempDir())
+	assert.Nil(t, err)
+	defer d.Close()
+	for seq := uint64(0); seq < 5; seq++ {
+		v := testVAA(vaa.ChainIDEthereum, testEmitter, seq, make([]byte, 100))
+		v.Signatures = []*vaa.Signature{{Index: 0}}
+		assert.Nil(t, d.StoreSignedVAA(v))
+	}
+	server := NewPublicrpcServer(zap.NewNop(), d, nil, nil)
+
+	req := &publicrpcv1.ListSignedVAAsRequest{
+		EmitterChain:   publicrpcv1.ChainID_CHAIN_ID_ETHEREUM,
+		EmitterAddress: hex.EncodeToString(testEmitter[:]),
+		From:           1,
+		PageSize:       3,
+	}
+	var seqs []uint64
+	for {
+		resp, err := server.ListSignedVAAs(context.Background(), req)
+		assert.Nil(t, err)
+		for _, e := range resp.Entries {
+			seqs = append(seqs, e.Sequence)
+		}
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	assert.Equal(t, []uint64{1, 2, 3, 4}, seqs)
+
+	for _, req := range []*publicrpcv1.ListSignedVAAsRequest{
+		{EmitterChain: publicrpcv1.ChainID_CHAIN_ID_ETHEREUM, EmitterAddress: "beef"},
+		{EmitterChain: publicrpcv1.ChainID_CHAIN_ID_ETHEREUM, EmitterAddress: hex.EncodeToString(testEmitter[:]), From: 3, To: 2},
+		{EmitterChain: publicrpcv1.ChainID_CHAIN_ID_ETHEREUM, EmitterAddress: hex.EncodeToString(testEmitter[:]), PageToken: "x"},
+	} {
+		_, err := server.ListSignedVAAs(context.Background(), req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
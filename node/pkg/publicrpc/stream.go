@@ -0,0 +1,173 @@
+package publicrpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/certusone/wormhole/node/pkg/reporter"
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	vaaStreamSubscribers = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "wormhole_publicrpc_vaa_stream_subscribers",
+			Help: "Current number of SubscribeSignedVAA streams",
+		})
+	vaaStreamOverflows = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_publicrpc_vaa_stream_overflows_total",
+			Help: "Total number of SubscribeSignedVAA streams closed because the client didn't keep up",
+		})
+	vaaStreamRejections = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_publicrpc_vaa_stream_rejections_total",
+			Help: "Total number of SubscribeSignedVAA streams rejected because the maximum number of streams was reached",
+		})
+	vaaStreamMissedEvents = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wormhole_publicrpc_vaa_stream_missed_events_total",
+			Help: "Total number of times all SubscribeSignedVAA streams were closed because the stream missed events",
+		})
+)
+
+// errTooManyStreams is returned by subscribe if the maximum number of streams is reached.
+var errTooManyStreams = errors.New("too many signed VAA streams")
+
+// SignedVAAStream fans the VAAs which reach quorum out to the SubscribeSignedVAA streams.
+//
+// Every stream has its own bounded buffer. The stream can't slow down the processor, so a client which
+// falls behind by more than the buffer size is disconnected instead of silently missing VAAs. Likewise, if the
+// fan-out itself falls behind and misses events, all clients are disconnected.
+type SignedVAAStream struct {
+	events     *reporter.AttestationEventReporter
+	bufferSize int
+	// maxStreams is the maximum number of concurrent streams, or 0 for no limit.
+	maxStreams int
+
+	mu     sync.Mutex
+	subs   map[int]*streamSubscription
+	nextID int
+}
+
+type streamSubscription struct {
+	filters []Filter
+	ch      chan []byte
+	// overflow is closed when VAAs were missed, either because the buffer overflowed or because the
+	// fan-out missed events. The subscription is removed at the same time.
+	overflow chan struct{}
+}
+
+func NewSignedVAAStream(events *reporter.AttestationEventReporter, bufferSize int, maxStreams int) *SignedVAAStream {
+	return &SignedVAAStream{
+		events:     events,
+		bufferSize: bufferSize,
+		maxStreams: maxStreams,
+		subs:       make(map[int]*streamSubscription),
+	}
+}
+
+// Run passes the VAAs reported by the processor to the streams until the context is cancelled.
+func (s *SignedVAAStream) Run(ctx context.Context) error {
+	logger := supervisor.Logger(ctx)
+	sub := s.events.SubscribeWithOptions(reporter.SubscriptionOptions{Name: "vaastream"})
+	defer s.events.Unsubscribe(sub.ClientId)
+
+	supervisor.Signal(ctx, supervisor.SignalHealthy)
+
+	var dropped uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sub.Channels.MessagePublicationC:
+			// Only VAAs with quorum are streamed, but the channel has to be drained.
+		case v := <-sub.Channels.VAAQuorumC:
+			if err := s.publish(v); err != nil {
+				logger.Error("failed to stream VAA", zap.String("message_id", v.MessageID()), zap.Error(err))
+			}
+		}
+
+		// The dropped events might have been VAAs, which the clients would never see.
+		if d := sub.Dropped(); d != dropped {
+			logger.Warn("signed VAA stream missed events, disconnecting all clients", zap.Uint64("dropped", d-dropped))
+			dropped = d
+			s.disconnectAll()
+			vaaStreamMissedEvents.Inc()
+		}
+	}
+}
+
+func (s *SignedVAAStream) publish(v *vaa.VAA) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.subs) == 0 {
+		return nil
+	}
+	b, err := v.Marshal()
+	if err != nil {
+		return err
+	}
+
+	for id, sub := range s.subs {
+		if !MatchesAny(sub.filters, v) {
+			continue
+		}
+		select {
+		case sub.ch <- b:
+		default:
+			close(sub.overflow)
+			s.remove(id)
+			vaaStreamOverflows.Inc()
+		}
+	}
+	return nil
+}
+
+// disconnectAll closes all streams, since they might have missed VAAs.
+func (s *SignedVAAStream) disconnectAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sub := range s.subs {
+		close(sub.overflow)
+		s.remove(id)
+	}
+}
+
+func (s *SignedVAAStream) subscribe(filters []Filter) (int, *streamSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxStreams > 0 && len(s.subs) >= s.maxStreams {
+		vaaStreamRejections.Inc()
+		return 0, nil, errTooManyStreams
+	}
+	id := s.nextID
+	s.nextID++
+	sub := &streamSubscription{
+		filters:  filters,
+		ch:       make(chan []byte, s.bufferSize),
+		overflow: make(chan struct{}),
+	}
+	s.subs[id] = sub
+	vaaStreamSubscribers.Set(float64(len(s.subs)))
+	return id, sub, nil
+}
+
+func (s *SignedVAAStream) unsubscribe(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(id)
+}
+
+// remove must be called with the lock held.
+func (s *SignedVAAStream) remove(id int) {
+	delete(s.subs, id)
+	vaaStreamSubscribers.Set(float64(len(s.subs)))
+}
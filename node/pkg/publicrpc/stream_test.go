@@ -0,0 +1,184 @@
+package publicrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	publicrpcv1 "github.com/certusone/wormhole/node/pkg/proto/publicrpc/v1"
+	"github.com/certusone/wormhole/node/pkg/reporter"
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testSubscribeServer struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *publicrpcv1.SubscribeSignedVAAResponse
+	// Send blocks until release is closed, if set
+	release chan struct{}
+}
+
+func (s *testSubscribeServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testSubscribeServer) Send(resp *publicrpcv1.SubscribeSignedVAAResponse) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.sent <- resp
+	return nil
+}
+
+func waitForSubscribers(t *testing.T, s *SignedVAAStream, n int) {
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.subs) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestSubscribeSignedVAA(t *testing.T) {
+	stream := NewSignedVAAStream(reporter.EventListener(zap.NewNop()), 10, 0)
+	server := NewPublicrpcServer(zap.NewNop(), nil, nil, stream)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resp := &testSubscribeServer{ctx: ctx, sent: make(chan *publicrpcv1.SubscribeSignedVAAResponse, 10)}
+	errC := make(chan error)
+	go func() {
+		errC <- server.SubscribeSignedVAA(&publicrpcv1.SubscribeSignedVAARequest{
+			Filters: []*publicrpcv1.FilterEntry{{Filter: &publicrpcv1.FilterEntry_EmitterChainFilter{
+				EmitterChainFilter: &publicrpcv1.EmitterChainFilter{ChainId: publicrpcv1.ChainID_CHAIN_ID_ETHEREUM},
+			}}},
+		}, resp)
+	}()
+	waitForSubscribers(t, stream, 1)
+
+	eth := testVAA(vaa.ChainIDEthereum, testEmitter, 1, []byte{1})
+	assert.Nil(t, stream.publish(testVAA(vaa.ChainIDTerra, testEmitter, 1, []byte{1})))
+	assert.Nil(t, stream.publish(eth))
+
+	expected, _ := eth.Marshal()
+	assert.Equal(t, expected, (<-resp.sent).VaaBytes)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-errC)
+	waitForSubscribers(t, stream, 0)
+	assert.Empty(t, resp.sent)
+}
+
+func TestSubscribeSignedVAAOverflow(t *testing.T) {
+	stream := NewSignedVAAStream(reporter.EventListener(zap.NewNop()), 2, 0)
+	id, sub, err := stream.subscribe(nil)
+	assert.Nil(t, err)
+	defer stream.unsubscribe(id)
+
+	for i := uint64(0); i < 2; i++ {
+		assert.Nil(t, stream.publish(testVAA(vaa.ChainIDEthereum, testEmitter, i, []byte{1})))
+	}
+	select {
+	case <-sub.overflow:
+		t.Fatal("subscription overflowed before its buffer was full")
+	default:
+	}
+
+	// the third VAA doesn't fit into the buffer, so the client is disconnected
+	assert.Nil(t, stream.publish(testVAA(vaa.ChainIDEthereum, testEmitter, 2, []byte{1})))
+	<-sub.overflow
+	waitForSubscribers(t, stream, 0)
+}
+
+func TestSubscribeSignedVAAOverflowStatus(t *testing.T) {
+	stream := NewSignedVAAStream(reporter.EventListener(zap.NewNop()), 1, 0)
+	server := NewPublicrpcServer(zap.NewNop(), nil, nil, stream)
+
+	// the client is stuck, so the VAAs pile up in the buffer
+	resp := &testSubscribeServer{
+		ctx:     context.Background(),
+		sent:    make(chan *publicrpcv1.SubscribeSignedVAAResponse, 10),
+		release: make(chan struct{}),
+	}
+	errC := make(chan error)
+	go func() {
+		errC <- server.SubscribeSignedVAA(&publicrpcv1.SubscribeSignedVAARequest{}, resp)
+	}()
+	waitForSubscribers(t, stream, 1)
+
+	for i := uint64(0); i < 3; i++ {
+		assert.Nil(t, stream.publish(testVAA(vaa.ChainIDEthereum, testEmitter, i, []byte{1})))
+	}
+	close(resp.release)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(<-errC))
+}
+
+func TestSubscribeSignedVAAMaxStreams(t *testing.T) {
+	stream := NewSignedVAAStream(reporter.EventListener(zap.NewNop()), 1, 1)
+	server := NewPublicrpcServer(zap.NewNop(), nil, nil, stream)
+
+	id, _, err := stream.subscribe(nil)
+	assert.Nil(t, err)
+	err = server.SubscribeSignedVAA(&publicrpcv1.SubscribeSignedVAARequest{}, &testSubscribeServer{ctx: context.Background()})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// a new stream is accepted once another one is closed
+	stream.unsubscribe(id)
+	id, _, err = stream.subscribe(nil)
+	assert.Nil(t, err)
+	stream.unsubscribe(id)
+}
+
+func TestSignedVAAStreamMissedEvents(t *testing.T) {
+	events := reporter.EventListener(zap.NewNop())
+	stream := NewSignedVAAStream(events, 1000, 0)
+	id, sub, err := stream.subscribe(nil)
+	assert.Nil(t, err)
+	defer stream.unsubscribe(id)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	supervisor.New(ctx, zap.NewNop(), func(ctx context.Context) error {
+		if err := supervisor.Run(ctx, "vaastream", stream.Run); err != nil {
+			return err
+		}
+		supervisor.Signal(ctx, supervisor.SignalHealthy)
+		<-ctx.Done()
+		return nil
+	})
+
+	// wait until the stream is subscribed to the events
+	assert.Eventually(t, func() bool {
+		events.ReportVAAQuorum(testVAA(vaa.ChainIDEthereum, testEmitter, 0, []byte{1}))
+		select {
+		case <-sub.ch:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, time.Millisecond)
+
+	// the fan-out is stuck, so events are dropped
+	stream.mu.Lock()
+	for i := uint64(1); i <= 100; i++ {
+		events.ReportVAAQuorum(testVAA(vaa.ChainIDEthereum, testEmitter, i, []byte{1}))
+	}
+	stream.mu.Unlock()
+
+	select {
+	case <-sub.overflow:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream wasn't closed after missing events")
+	}
+	waitForSubscribers(t, stream, 0)
+}
+
+func TestSubscribeSignedVAAUnavailable(t *testing.T) {
+	server := NewPublicrpcServer(zap.NewNop(), nil, nil, nil)
+	err := server.SubscribeSignedVAA(&publicrpcv1.SubscribeSignedVAARequest{}, &testSubscribeServer{ctx: context.Background()})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
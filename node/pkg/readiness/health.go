@@ -1,5 +1,5 @@
-// package readiness implements a minimal health-checking mechanism for use as k8s readiness probes. It will always
-// return a "ready" state after the conditions have been met for the first time - it's not meant for monitoring.
+// package readiness implements a minimal health-checking mechanism for use as k8s readiness probes. Components
+// report whether they're currently ready, and may become unready again, e.g. when they lose their connection.
 //
 // Uses a global singleton registry (similar to the Prometheus client's default behavior).
 package readiness
@@ -8,33 +8,87 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
+	"time"
 )
 
+type componentState struct {
+	ready      bool
+	lastChange time.Time
+}
+
 var (
 	mu       = sync.Mutex{}
-	registry = map[string]bool{}
+	registry = map[string]*componentState{}
 )
 
 type Component string
 
+// ComponentStatus is a snapshot of the state of a component.
+type ComponentStatus struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	// LastChange is the time at which the component was registered or its state last changed.
+	LastChange time.Time `json:"lastChange"`
+}
+
 // RegisterComponent registers the given component name such that it is required to be ready for the global check to succeed.
 func RegisterComponent(component Component) {
 	mu.Lock()
 	if _, ok := registry[string(component)]; ok {
 		panic("component already registered")
 	}
-	registry[string(component)] = false
+	registry[string(component)] = &componentState{lastChange: time.Now()}
 	mu.Unlock()
 }
 
+func setState(component Component, ready bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := registry[string(component)]
+	if !ok {
+		s = &componentState{ready: !ready}
+		registry[string(component)] = s
+	}
+	if s.ready != ready {
+		s.ready = ready
+		s.lastChange = time.Now()
+	}
+}
+
 // SetReady sets the given global component state.
 func SetReady(component Component) {
+	setState(component, true)
+}
+
+// SetNotReady marks the given component as not ready, until SetReady is called again.
+func SetNotReady(component Component) {
+	setState(component, false)
+}
+
+// Status returns the state of all components, ordered by name.
+func Status() []ComponentStatus {
 	mu.Lock()
-	if !registry[string(component)] {
-		registry[string(component)] = true
+	defer mu.Unlock()
+	status := make([]ComponentStatus, 0, len(registry))
+	for k, v := range registry {
+		status = append(status, ComponentStatus{Name: k, Ready: v.ready, LastChange: v.lastChange})
 	}
-	mu.Unlock()
+	sort.Slice(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+	return status
+}
+
+// Ready returns whether all components are ready.
+func Ready() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, v := range registry {
+		if !v.ready {
+			return false
+		}
+	}
+	return true
 }
 
 // Handler returns a net/http handler for the readiness check. It returns 200 OK if all components are ready,
@@ -44,27 +98,21 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	ready := true
 
 	resp := new(bytes.Buffer)
-	_, err := resp.Write([]byte("[not suitable for monitoring - do not parse]\n\n"))
-	if err != nil {
-		panic(err)
-	}
-	_, err = resp.Write([]byte("[these values update AT STARTUP ONLY - see https://github.com/certusone/wormhole/blob/dev.v2/docs/operations.md#readyz]\n\n"))
+	_, err := resp.Write([]byte("[not suitable for monitoring - do not parse, use /health/ready instead]\n\n"))
 	if err != nil {
 		panic(err)
 	}
 
-	mu.Lock()
-	for k, v := range registry {
-		_, err = fmt.Fprintf(resp, "%s\t%v\n", k, v)
+	for _, c := range Status() {
+		_, err = fmt.Fprintf(resp, "%s\t%v\n", c.Name, c.Ready)
 		if err != nil {
 			panic(err)
 		}
 
-		if !v {
+		if !c.Ready {
 			ready = false
 		}
 	}
-	mu.Unlock()
 
 	if !ready {
 		w.WriteHeader(http.StatusPreconditionFailed)
@@ -4,6 +4,8 @@ import (
 	"math/rand"
 	"sync"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.uber.org/zap"
 
 	"github.com/certusone/wormhole/node/pkg/vaa"
@@ -11,6 +13,14 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 )
 
+var (
+	eventsDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_reporter_events_dropped_total",
+			Help: "Total number of attestation events dropped because a subscriber didn't keep up",
+		}, []string{"subscriber"})
+)
+
 type (
 	// MessagePublication is a VAA along with a transaction identifer from the EmiterChain
 	MessagePublication struct {
@@ -20,55 +30,197 @@ type (
 	}
 )
 
+type SubscriptionOptions struct {
+	// Name identifies the subscriber in logs and metrics.
+	Name string
+	// BufferSize is the number of events buffered for the subscriber. Once it's full, the oldest
+	// buffered event is dropped to make room for a new one, so the reporter never waits for a subscriber.
+	BufferSize int
+}
+
+const defaultSubscriptionBufferSize = 50
+
 type lifecycleEventChannels struct {
 	// channel for each event
 	MessagePublicationC chan *MessagePublication
 	VAAQuorumC          chan *vaa.VAA
 }
 
+// subscriberEvent is a buffered event, exactly one of the fields is set.
+type subscriberEvent struct {
+	publication *MessagePublication
+	quorum      *vaa.VAA
+}
+
+// subscriber buffers the events of a single subscription in a ring buffer, which is drained into the
+// subscription's channels by a separate goroutine, in the order the events were reported.
+type subscriber struct {
+	options  SubscriptionOptions
+	channels *lifecycleEventChannels
+
+	mu      sync.Mutex
+	ring    []subscriberEvent
+	head    int
+	size    int
+	closed  bool
+	dropped uint64
+
+	// pending is signalled when an event is added to the ring.
+	pending chan struct{}
+	done    chan struct{}
+}
+
+func newSubscriber(options SubscriptionOptions) *subscriber {
+	s := &subscriber{
+		options: options,
+		channels: &lifecycleEventChannels{
+			MessagePublicationC: make(chan *MessagePublication),
+			VAAQuorumC:          make(chan *vaa.VAA),
+		},
+		ring:    make([]subscriberEvent, options.BufferSize),
+		pending: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// push buffers the event and returns whether an older event had to be dropped for it.
+func (s *subscriber) push(e subscriberEvent) (dropped bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if s.size == len(s.ring) {
+		s.ring[s.head] = subscriberEvent{}
+		s.head = (s.head + 1) % len(s.ring)
+		s.size--
+		s.dropped++
+		dropped = true
+	}
+	s.ring[(s.head+s.size)%len(s.ring)] = e
+	s.size++
+
+	select {
+	case s.pending <- struct{}{}:
+	default:
+	}
+	return dropped
+}
+
+func (s *subscriber) pop() (e subscriberEvent, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size == 0 {
+		return e, false
+	}
+	e = s.ring[s.head]
+	s.ring[s.head] = subscriberEvent{}
+	s.head = (s.head + 1) % len(s.ring)
+	s.size--
+	return e, true
+}
+
+func (s *subscriber) run() {
+	for {
+		e, ok := s.pop()
+		if !ok {
+			select {
+			case <-s.pending:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+
+		if e.publication != nil {
+			select {
+			case s.channels.MessagePublicationC <- e.publication:
+			case <-s.done:
+				return
+			}
+		} else {
+			select {
+			case s.channels.VAAQuorumC <- e.quorum:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// close discards the buffered events and stops the delivery to the subscription's channels.
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+}
+
 type AttestationEventReporter struct {
 	mu     sync.RWMutex
 	logger *zap.Logger
 
-	subs map[int]*lifecycleEventChannels
+	subs map[int]*subscriber
 }
 type activeSubscription struct {
 	ClientId int
 	Channels *lifecycleEventChannels
+	sub      *subscriber
+}
+
+// Dropped returns the number of events which were dropped because the subscription didn't keep up.
+func (s *activeSubscription) Dropped() uint64 {
+	s.sub.mu.Lock()
+	defer s.sub.mu.Unlock()
+	return s.sub.dropped
 }
 
 func EventListener(logger *zap.Logger) *AttestationEventReporter {
 	events := &AttestationEventReporter{
 		logger: logger.Named("eventlistener"),
-		subs:   map[int]*lifecycleEventChannels{},
+		subs:   map[int]*subscriber{},
 	}
 	return events
 }
 
-// getUniqueClientId loops to generate & test integers for existence as key of map. returns an int that is not a key in map.
+// getUniqueClientId returns a random integer which isn't a key of the subscriptions map yet.
 func (re *AttestationEventReporter) getUniqueClientId() int {
-	clientId := rand.Intn(1e6)
-	found := false
-	for found {
-		clientId = rand.Intn(1e6)
-		_, found = re.subs[clientId]
+	for {
+		clientId := rand.Intn(1e6)
+		if _, found := re.subs[clientId]; !found {
+			return clientId
+		}
 	}
-	return clientId
 }
 
+// Subscribe subscribes to the events with a buffer of 50 events, dropping the oldest events if it runs full.
 func (re *AttestationEventReporter) Subscribe() *activeSubscription {
+	return re.SubscribeWithOptions(SubscriptionOptions{
+		Name:       "default",
+		BufferSize: defaultSubscriptionBufferSize,
+	})
+}
+
+func (re *AttestationEventReporter) SubscribeWithOptions(options SubscriptionOptions) *activeSubscription {
+	if options.BufferSize <= 0 {
+		options.BufferSize = defaultSubscriptionBufferSize
+	}
+
 	re.mu.Lock()
 	defer re.mu.Unlock()
 
 	clientId := re.getUniqueClientId()
-	re.logger.Debug("Subscribe for client", zap.Int("clientId", clientId))
-	channels := &lifecycleEventChannels{
-		MessagePublicationC: make(chan *MessagePublication, 50),
-		VAAQuorumC:          make(chan *vaa.VAA, 50),
-	}
-	re.subs[clientId] = channels
-	sub := &activeSubscription{ClientId: clientId, Channels: channels}
-	return sub
+	re.logger.Debug("Subscribe for client", zap.Int("clientId", clientId), zap.String("name", options.Name))
+	sub := newSubscriber(options)
+	re.subs[clientId] = sub
+	return &activeSubscription{ClientId: clientId, Channels: sub.channels, sub: sub}
 }
 
 func (re *AttestationEventReporter) Unsubscribe(clientId int) {
@@ -76,36 +228,32 @@ func (re *AttestationEventReporter) Unsubscribe(clientId int) {
 	defer re.mu.Unlock()
 
 	re.logger.Debug("Unsubscribe for client", zap.Int("clientId", clientId))
-	delete(re.subs, clientId)
+	if sub, ok := re.subs[clientId]; ok {
+		sub.close()
+		delete(re.subs, clientId)
+	}
 }
 
-// ReportMessagePublication is invoked when an on-chain message is observed.
-func (re *AttestationEventReporter) ReportMessagePublication(msg *MessagePublication) {
+func (re *AttestationEventReporter) report(e subscriberEvent) {
 	re.mu.RLock()
 	defer re.mu.RUnlock()
 
 	for client, sub := range re.subs {
-		select {
-		case sub.MessagePublicationC <- msg:
-			re.logger.Debug("published MessagePublication to client", zap.Int("client", client))
-		default:
-			re.logger.Error("buffer overrun when attempting to publish message", zap.Int("client", client))
+		if sub.push(e) {
+			eventsDropped.WithLabelValues(sub.options.Name).Inc()
+			re.logger.Warn("subscriber buffer full, dropped the oldest event",
+				zap.Int("client", client),
+				zap.String("name", sub.options.Name))
 		}
 	}
 }
 
+// ReportMessagePublication is invoked when an on-chain message is observed.
+func (re *AttestationEventReporter) ReportMessagePublication(msg *MessagePublication) {
+	re.report(subscriberEvent{publication: msg})
+}
+
 // ReportVAAQuorum is invoked when quorum is reached.
 func (re *AttestationEventReporter) ReportVAAQuorum(msg *vaa.VAA) {
-	re.mu.RLock()
-	defer re.mu.RUnlock()
-
-	for client, sub := range re.subs {
-		select {
-		case sub.VAAQuorumC <- msg:
-			re.logger.Debug("published VAAQuorum to client", zap.Int("client", client))
-		default:
-			re.logger.Error("buffer overrun when attempting to publish message", zap.Int("client", client))
-
-		}
-	}
+	re.report(subscriberEvent{quorum: msg})
 }
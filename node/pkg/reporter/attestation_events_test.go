@@ -0,0 +1,45 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func buffered(sub *activeSubscription) int {
+	sub.sub.mu.Lock()
+	defer sub.sub.mu.Unlock()
+	return sub.sub.size
+}
+
+func TestSubscriptionDropsOldest(t *testing.T) {
+	events := EventListener(zap.NewNop())
+	sub := events.SubscribeWithOptions(SubscriptionOptions{Name: "test", BufferSize: 3})
+	defer events.Unsubscribe(sub.ClientId)
+
+	// the first event is handed to the channel, the others are buffered
+	events.ReportVAAQuorum(testVAA(0))
+	assert.Eventually(t, func() bool { return buffered(sub) == 0 }, time.Second, time.Millisecond)
+	for seq := uint64(1); seq < 6; seq++ {
+		events.ReportVAAQuorum(testVAA(seq))
+	}
+	assert.Equal(t, uint64(2), sub.Dropped())
+
+	for _, seq := range []uint64{0, 3, 4, 5} {
+		v := <-sub.Channels.VAAQuorumC
+		assert.Equal(t, seq, v.Sequence)
+	}
+}
+
+func TestUniqueClientIds(t *testing.T) {
+	events := EventListener(zap.NewNop())
+	ids := make(map[int]bool)
+	for i := 0; i < 1000; i++ {
+		sub := events.Subscribe()
+		defer events.Unsubscribe(sub.ClientId)
+		assert.False(t, ids[sub.ClientId])
+		ids[sub.ClientId] = true
+	}
+}
@@ -68,7 +68,7 @@ func BigTableWriter(events *AttestationEventReporter, connectionConfig *BigTable
 		logger.Info("GCP PubSub.Topic initialized",
 			zap.String("Topic", e.connectionConfig.TopicName))
 		// call to subscribe to event channels
-		sub := e.events.Subscribe()
+		sub := e.events.SubscribeWithOptions(SubscriptionOptions{Name: "bigtable"})
 		logger.Info("subscribed to AttestationEvents")
 
 		go func() {
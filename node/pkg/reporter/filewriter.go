@@ -0,0 +1,126 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	fileSinkCurrent       = "events.jsonl"
+	fileSinkRotatedPrefix = "events-"
+	fileSinkRotatedSuffix = ".jsonl"
+)
+
+type FileSinkConfig struct {
+	Dir string
+	// MaxBytes is the size after which the current file is rotated. Zero disables rotation.
+	MaxBytes int64
+	// MaxFiles is the number of rotated files which are kept. Zero keeps all of them.
+	MaxFiles int
+}
+
+// fileSink appends events as JSON lines to <dir>/events.jsonl. Rotated files are
+// renamed to events-<timestamp>.jsonl, so they sort by age.
+type fileSink struct {
+	config FileSinkConfig
+	f      *os.File
+	size   int64
+}
+
+func NewFileSink(config FileSinkConfig) Sink {
+	return &fileSink{config: config}
+}
+
+func (s *fileSink) Name() string {
+	return "file"
+}
+
+func (s *fileSink) Open(ctx context.Context) error {
+	if err := os.MkdirAll(s.config.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create event directory: %w", err)
+	}
+	return s.openCurrent()
+}
+
+func (s *fileSink) openCurrent() error {
+	f, err := os.OpenFile(filepath.Join(s.config.Dir, fileSinkCurrent), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open event file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat event file: %w", err)
+	}
+	s.f = f
+	s.size = info.Size()
+	return nil
+}
+
+func (s *fileSink) Deliver(ctx context.Context, e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	b = append(b, '\n')
+
+	if s.config.MaxBytes > 0 && s.size > 0 && s.size+int64(len(b)) > s.config.MaxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+func (s *fileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return fmt.Errorf("failed to close event file: %w", err)
+	}
+	rotated := fileSinkRotatedPrefix + time.Now().UTC().Format("20060102T150405.000000000") + fileSinkRotatedSuffix
+	if err := os.Rename(filepath.Join(s.config.Dir, fileSinkCurrent), filepath.Join(s.config.Dir, rotated)); err != nil {
+		return fmt.Errorf("failed to rotate event file: %w", err)
+	}
+	if err := s.openCurrent(); err != nil {
+		return err
+	}
+	return s.prune()
+}
+
+// prune removes the oldest rotated files beyond MaxFiles.
+func (s *fileSink) prune() error {
+	if s.config.MaxFiles <= 0 {
+		return nil
+	}
+	rotated, err := filepath.Glob(filepath.Join(s.config.Dir, fileSinkRotatedPrefix+"*"+fileSinkRotatedSuffix))
+	if err != nil {
+		return err
+	}
+	sort.Strings(rotated)
+	for len(rotated) > s.config.MaxFiles {
+		if err := os.Remove(rotated[0]); err != nil {
+			return fmt.Errorf("failed to remove rotated event file: %w", err)
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
@@ -0,0 +1,418 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/db"
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	sinkEventsDelivered = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_reporter_sink_events_delivered_total",
+			Help: "Total number of attestation events delivered to a sink",
+		}, []string{"sink"})
+	sinkDeliveryErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_reporter_sink_delivery_errors_total",
+			Help: "Total number of failed attempts to deliver an attestation event to a sink",
+		}, []string{"sink"})
+	sinkDeadLetters = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_reporter_sink_dead_letters_total",
+			Help: "Total number of attestation events which could not be delivered to a sink",
+		}, []string{"sink", "reason"})
+	sinkReplayCursor = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "wormhole_reporter_sink_replay_cursor",
+			Help: "Position in the VAA log up to which quorum events were delivered to a sink",
+		}, []string{"sink"})
+	sinkQueueLength = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "wormhole_reporter_sink_queue_length",
+			Help: "Current number of attestation events waiting for delivery to a sink",
+		}, []string{"sink"})
+)
+
+type EventType string
+
+const (
+	EventMessagePublication EventType = "MessagePublication"
+	EventVAAQuorum          EventType = "VAAQuorum"
+)
+
+// Event is the backend-agnostic form of the events reported by the AttestationEventReporter.
+type Event struct {
+	Type EventType `json:"type"`
+	// MessageID is <chain>/<emitter>/<sequence>, like the keys of the VAA store.
+	MessageID      string      `json:"messageId"`
+	EmitterChain   vaa.ChainID `json:"emitterChain"`
+	EmitterAddress string      `json:"emitterAddress"`
+	Sequence       uint64      `json:"sequence"`
+	// InitiatingTxID is only set for message publications.
+	InitiatingTxID string `json:"initiatingTxId,omitempty"`
+	// VAA is the serialized VAA. Message publications are reported before signing and carry no signatures.
+	VAA        []byte    `json:"vaa"`
+	ReportedAt time.Time `json:"reportedAt"`
+}
+
+func newEvent(t EventType, v *vaa.VAA) (*Event, error) {
+	b, err := v.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal VAA: %w", err)
+	}
+	return &Event{
+		Type:           t,
+		MessageID:      v.MessageID(),
+		EmitterChain:   v.EmitterChain,
+		EmitterAddress: v.EmitterAddress.String(),
+		Sequence:       v.Sequence,
+		VAA:            b,
+		ReportedAt:     time.Now(),
+	}, nil
+}
+
+// MessagePublicationEvent converts a reported message publication into an Event.
+func MessagePublicationEvent(msg *MessagePublication) (*Event, error) {
+	e, err := newEvent(EventMessagePublication, &msg.VAA)
+	if err != nil {
+		return nil, err
+	}
+	e.InitiatingTxID = msg.InitiatingTxID.Hex()
+	return e, nil
+}
+
+// VAAQuorumEvent converts a VAA which reached quorum into an Event.
+func VAAQuorumEvent(v *vaa.VAA) (*Event, error) {
+	return newEvent(EventVAAQuorum, v)
+}
+
+// Sink is a backend which attestation events are delivered to.
+type Sink interface {
+	// Name identifies the sink in logs and metrics.
+	Name() string
+	// Open prepares the sink for delivery. It is called every time the sink's runnable is (re)started.
+	Open(ctx context.Context) error
+	// Deliver persists or forwards a single event. Failed deliveries are retried, so Deliver must be idempotent.
+	Deliver(ctx context.Context, e *Event) error
+	// Close releases the resources acquired by Open.
+	Close() error
+}
+
+type SinkConfig struct {
+	// QueueSize is the number of events buffered for the sink. Events which don't fit are dead-lettered.
+	QueueSize int
+	// MaxAttempts is the number of delivery attempts before an event is dead-lettered.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DeadLetterPath is the JSON-lines file undeliverable events are appended to.
+	// If empty, undeliverable events are only logged.
+	DeadLetterPath string
+	// ReplayDB makes the delivery of quorum events lossless. Instead of the live events, the sink is fed
+	// from the VAA log of the database, starting after its durable cursor, and a delivery which fails is
+	// retried rather than dead-lettered. A sink which was unavailable catches up on the VAAs it missed.
+	// On the first start of a sink, its cursor is set to the end of the log.
+	ReplayDB *db.Database
+	// ReplayInterval is the interval at which the VAA log is polled in addition to the live quorum events.
+	ReplayInterval time.Duration
+}
+
+func DefaultSinkConfig() SinkConfig {
+	return SinkConfig{
+		QueueSize:      1000,
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		ReplayInterval: 10 * time.Second,
+	}
+}
+
+// replayBatchSize is the number of VAA log entries read at once.
+const replayBatchSize = 100
+
+// DeadLetter is an event which could not be delivered to a sink, as written to the dead-letter file.
+type DeadLetter struct {
+	Sink     string    `json:"sink"`
+	Reason   string    `json:"reason"`
+	Error    string    `json:"error,omitempty"`
+	FailedAt time.Time `json:"failedAt"`
+	Event    *Event    `json:"event"`
+}
+
+const (
+	deadLetterQueueFull    = "queue_full"
+	deadLetterUndelivered  = "undelivered"
+	deadLetterSinkStopped  = "sink_stopped"
+	deadLetterInvalidEvent = "invalid_event"
+)
+
+type deadLetterWriter struct {
+	mu     sync.Mutex
+	sink   string
+	logger *zap.Logger
+	f      *os.File
+}
+
+func openDeadLetterWriter(logger *zap.Logger, sink string, path string) (*deadLetterWriter, error) {
+	w := &deadLetterWriter{sink: sink, logger: logger}
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+		}
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+		}
+		w.f = f
+	}
+	return w, nil
+}
+
+func (w *deadLetterWriter) write(e *Event, reason string, deliveryErr error) {
+	sinkDeadLetters.WithLabelValues(w.sink, reason).Inc()
+	l := DeadLetter{Sink: w.sink, Reason: reason, FailedAt: time.Now(), Event: e}
+	if deliveryErr != nil {
+		l.Error = deliveryErr.Error()
+	}
+	w.logger.Error("failed to deliver attestation event",
+		zap.String("messageId", e.MessageID),
+		zap.String("type", string(e.Type)),
+		zap.String("reason", reason),
+		zap.Error(deliveryErr))
+	if w.f == nil {
+		return
+	}
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		w.logger.Error("failed to marshal dead letter", zap.Error(err))
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.f.Write(append(b, '\n')); err != nil {
+		w.logger.Error("failed to write dead letter", zap.String("messageId", e.MessageID), zap.Error(err))
+	}
+}
+
+func (w *deadLetterWriter) close() error {
+	if w.f == nil {
+		return nil
+	}
+	return w.f.Close()
+}
+
+// RunSink returns a runnable which delivers the events of the AttestationEventReporter to the sink.
+// Every sink has its own delivery queue, so a slow or unavailable backend doesn't hold up other sinks.
+// Events which can't be queued or delivered are written to the sink's dead-letter file, except for
+// quorum events replayed from the VAA log (see SinkConfig.ReplayDB).
+func RunSink(events *AttestationEventReporter, sink Sink, config SinkConfig) supervisor.Runnable {
+	return func(ctx context.Context) error {
+		logger := supervisor.Logger(ctx).With(zap.String("sink", sink.Name()))
+
+		deadLetters, err := openDeadLetterWriter(logger, sink.Name(), config.DeadLetterPath)
+		if err != nil {
+			return err
+		}
+		defer deadLetters.close()
+
+		if err := sink.Open(ctx); err != nil {
+			return fmt.Errorf("failed to open sink %s: %w", sink.Name(), err)
+		}
+		defer func() {
+			if err := sink.Close(); err != nil {
+				logger.Error("failed to close sink", zap.Error(err))
+			}
+		}()
+
+		var (
+			cursor  uint64
+			replayC <-chan time.Time
+			// wakeC triggers a replay when a live quorum event was reported.
+			wakeC = make(chan struct{}, 1)
+		)
+		if config.ReplayDB != nil {
+			if cursor, err = initReplayCursor(config.ReplayDB, sink.Name()); err != nil {
+				return err
+			}
+			logger.Info("replaying quorum events from the VAA log", zap.Uint64("cursor", cursor))
+			ticker := time.NewTicker(config.ReplayInterval)
+			defer ticker.Stop()
+			replayC = ticker.C
+			wakeC <- struct{}{}
+		}
+
+		sub := events.SubscribeWithOptions(SubscriptionOptions{Name: "sink-" + sink.Name()})
+		defer events.Unsubscribe(sub.ClientId)
+		logger.Info("subscribed to AttestationEvents")
+
+		queue := make(chan *Event, config.QueueSize)
+		enqueue := func(e *Event, err error) {
+			if err != nil {
+				logger.Error("failed to convert attestation event", zap.Error(err))
+				sinkDeadLetters.WithLabelValues(sink.Name(), deadLetterInvalidEvent).Inc()
+				return
+			}
+			select {
+			case queue <- e:
+				sinkQueueLength.WithLabelValues(sink.Name()).Set(float64(len(queue)))
+			default:
+				deadLetters.write(e, deadLetterQueueFull, nil)
+			}
+		}
+
+		supervisor.Signal(ctx, supervisor.SignalHealthy)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg := <-sub.Channels.MessagePublicationC:
+					enqueue(MessagePublicationEvent(msg))
+				case v := <-sub.Channels.VAAQuorumC:
+					if config.ReplayDB == nil {
+						enqueue(VAAQuorumEvent(v))
+						continue
+					}
+					// the VAA is in the log already
+					select {
+					case wakeC <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				// Keep whatever is still queued, since the queue doesn't survive a restart.
+				<-done
+				for {
+					select {
+					case e := <-queue:
+						deadLetters.write(e, deadLetterSinkStopped, nil)
+					default:
+						sinkQueueLength.WithLabelValues(sink.Name()).Set(0)
+						return ctx.Err()
+					}
+				}
+			case e := <-queue:
+				sinkQueueLength.WithLabelValues(sink.Name()).Set(float64(len(queue)))
+				if err := deliverWithRetry(ctx, sink, config, e); err != nil {
+					reason := deadLetterUndelivered
+					if ctx.Err() != nil {
+						reason = deadLetterSinkStopped
+					}
+					deadLetters.write(e, reason, err)
+					continue
+				}
+				sinkEventsDelivered.WithLabelValues(sink.Name()).Inc()
+			case <-replayC:
+				cursor = replay(ctx, logger, sink, config, cursor)
+			case <-wakeC:
+				cursor = replay(ctx, logger, sink, config, cursor)
+			}
+		}
+	}
+}
+
+func replayCursorName(sink string) string {
+	return "sink-" + sink
+}
+
+// initReplayCursor returns the stored cursor of the sink, or stores the end of the VAA log as its cursor.
+func initReplayCursor(d *db.Database, sink string) (uint64, error) {
+	cursor, found, err := d.GetCursor(replayCursorName(sink))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read replay cursor: %w", err)
+	}
+	if !found {
+		cursor = d.LastVAALogPosition()
+		if err := d.StoreCursor(replayCursorName(sink), cursor); err != nil {
+			return 0, fmt.Errorf("failed to store replay cursor: %w", err)
+		}
+	}
+	sinkReplayCursor.WithLabelValues(sink).Set(float64(cursor))
+	return cursor, nil
+}
+
+// replay delivers the VAAs logged after the cursor until the end of the log or a failed delivery,
+// and returns the new cursor.
+func replay(ctx context.Context, logger *zap.Logger, sink Sink, config SinkConfig, cursor uint64) uint64 {
+	for {
+		logged, err := config.ReplayDB.ReadVAALog(cursor+1, replayBatchSize)
+		if err != nil {
+			logger.Error("failed to read VAA log", zap.Error(err))
+			return cursor
+		}
+		if len(logged) == 0 {
+			return cursor
+		}
+
+		for _, l := range logged {
+			v, err := vaa.Unmarshal(l.Bytes)
+			if err != nil {
+				logger.Error("skipping invalid VAA in the VAA log", zap.Uint64("position", l.Position), zap.Error(err))
+				sinkDeadLetters.WithLabelValues(sink.Name(), deadLetterInvalidEvent).Inc()
+			} else if e, err := VAAQuorumEvent(v); err != nil {
+				logger.Error("skipping invalid VAA in the VAA log", zap.Uint64("position", l.Position), zap.Error(err))
+				sinkDeadLetters.WithLabelValues(sink.Name(), deadLetterInvalidEvent).Inc()
+			} else if err := deliverWithRetry(ctx, sink, config, e); err != nil {
+				logger.Warn("failed to deliver logged VAA, retrying later",
+					zap.Uint64("position", l.Position),
+					zap.String("messageId", e.MessageID),
+					zap.Error(err))
+				return cursor
+			} else {
+				sinkEventsDelivered.WithLabelValues(sink.Name()).Inc()
+			}
+
+			cursor = l.Position
+			if err := config.ReplayDB.StoreCursor(replayCursorName(sink.Name()), cursor); err != nil {
+				logger.Error("failed to store replay cursor", zap.Uint64("cursor", cursor), zap.Error(err))
+			}
+			sinkReplayCursor.WithLabelValues(sink.Name()).Set(float64(cursor))
+		}
+	}
+}
+
+func deliverWithRetry(ctx context.Context, sink Sink, config SinkConfig, e *Event) error {
+	backoff := config.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = sink.Deliver(ctx, e); err == nil {
+			return nil
+		}
+		sinkDeliveryErrors.WithLabelValues(sink.Name()).Inc()
+		if attempt >= config.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > config.MaxBackoff {
+			backoff = config.MaxBackoff
+		}
+	}
+}
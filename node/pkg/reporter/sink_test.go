@@ -0,0 +1,252 @@
+package reporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/db"
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var testSinkConfig = SinkConfig{
+	QueueSize:      10,
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     time.Millisecond,
+}
+
+func testVAA(seq uint64) *vaa.VAA {
+	return &vaa.VAA{
+		Version:          vaa.SupportedVAAVersion,
+		Timestamp:        time.Unix(1650000000, 0),
+		Sequence:         seq,
+		ConsistencyLevel: 1,
+		EmitterChain:     vaa.ChainIDEthereum,
+		EmitterAddress:   vaa.Address{1, 2, 3},
+		Payload:          []byte{1, 2, 3},
+	}
+}
+
+func testEvent(t *testing.T, seq uint64) *Event {
+	e, err := VAAQuorumEvent(testVAA(seq))
+	assert.Nil(t, err)
+	return e
+}
+
+func readJSONLines(t *testing.T, path string, v func() interface{}) []interface{} {
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	var lines []interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := v()
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestFileSinkRotates(t *testing.T) {
+	dir := t.TempDir()
+	b, err := json.Marshal(testEvent(t, 0))
+	assert.Nil(t, err)
+	// two events per file
+	sink := NewFileSink(FileSinkConfig{Dir: dir, MaxBytes: int64(2*len(b) + 10), MaxFiles: 2})
+	assert.Nil(t, sink.Open(context.Background()))
+	for seq := uint64(0); seq < 7; seq++ {
+		assert.Nil(t, sink.Deliver(context.Background(), testEvent(t, seq)))
+	}
+	assert.Nil(t, sink.Close())
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "events-*.jsonl"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rotated))
+
+	// the oldest file was pruned
+	var sequences []uint64
+	for _, path := range append(rotated, filepath.Join(dir, "events.jsonl")) {
+		for _, e := range readJSONLines(t, path, func() interface{} { return &Event{} }) {
+			sequences = append(sequences, e.(*Event).Sequence)
+		}
+	}
+	assert.Equal(t, []uint64{2, 3, 4, 5, 6}, sequences)
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	var mu sync.Mutex
+	var received []*Event
+	failures := 2
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+		if failures > 0 {
+			failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		e := &Event{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(e))
+		received = append(received, e)
+	}))
+	defer s.Close()
+
+	sink := NewWebhookSink(WebhookSinkConfig{URL: s.URL, Timeout: time.Second, Headers: map[string]string{"Authorization": "secret"}})
+	assert.Nil(t, sink.Open(context.Background()))
+	defer sink.Close()
+
+	e := testEvent(t, 1)
+	assert.Nil(t, deliverWithRetry(context.Background(), sink, testSinkConfig, e))
+	assert.Equal(t, 1, len(received))
+	assert.Equal(t, e.MessageID, received[0].MessageID)
+	assert.Equal(t, e.VAA, received[0].VAA)
+
+	failures = 3
+	err := deliverWithRetry(context.Background(), sink, testSinkConfig, e)
+	assert.Contains(t, err.Error(), "giving up after 3 attempts")
+}
+
+// startSink runs the sink until the end of the test and waits for its subscription.
+func startSink(t *testing.T, events *AttestationEventReporter, sink Sink, config SinkConfig) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	supervisor.New(ctx, zap.NewNop(), func(ctx context.Context) error {
+		if err := supervisor.Run(ctx, "sink", RunSink(events, sink, config)); err != nil {
+			return err
+		}
+		supervisor.Signal(ctx, supervisor.SignalHealthy)
+		<-ctx.Done()
+		return nil
+	})
+
+	assert.Eventually(t, func() bool {
+		events.mu.RLock()
+		defer events.mu.RUnlock()
+		return len(events.subs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+type failingSink struct{}
+
+func (failingSink) Name() string                                { return "failing" }
+func (failingSink) Open(ctx context.Context) error              { return nil }
+func (failingSink) Deliver(ctx context.Context, e *Event) error { return errors.New("unavailable") }
+func (failingSink) Close() error                                { return nil }
+
+func TestRunSinkDeadLetters(t *testing.T) {
+	events := EventListener(zap.NewNop())
+	config := testSinkConfig
+	config.DeadLetterPath = filepath.Join(t.TempDir(), "dead", "failing.jsonl")
+
+	startSink(t, events, failingSink{}, config)
+
+	events.ReportMessagePublication(&MessagePublication{VAA: *testVAA(1)})
+	events.ReportVAAQuorum(testVAA(1))
+
+	var letters []interface{}
+	assert.Eventually(t, func() bool {
+		letters = readJSONLines(t, config.DeadLetterPath, func() interface{} { return &DeadLetter{} })
+		return len(letters) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var types []EventType
+	for _, l := range letters {
+		l := l.(*DeadLetter)
+		assert.Equal(t, "failing", l.Sink)
+		assert.Equal(t, deadLetterUndelivered, l.Reason)
+		assert.Contains(t, l.Error, "unavailable")
+		assert.Equal(t, testVAA(1).MessageID(), l.Event.MessageID)
+		assert.Equal(t, l.Event.Type == EventMessagePublication, l.Event.InitiatingTxID != "")
+		types = append(types, l.Event.Type)
+	}
+	assert.ElementsMatch(t, []EventType{EventMessagePublication, EventVAAQuorum}, types)
+}
+
+// recordingSink records the sequences of the delivered events and fails while it's down.
+type recordingSink struct {
+	mu        sync.Mutex
+	down      bool
+	sequences []uint64
+}
+
+func (s *recordingSink) Name() string                   { return "recording" }
+func (s *recordingSink) Open(ctx context.Context) error { return nil }
+func (s *recordingSink) Close() error                   { return nil }
+
+func (s *recordingSink) Deliver(ctx context.Context, e *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("down")
+	}
+	s.sequences = append(s.sequences, e.Sequence)
+	return nil
+}
+
+func (s *recordingSink) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *recordingSink) delivered() []uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint64{}, s.sequences...)
+}
+
+func TestRunSinkReplays(t *testing.T) {
+	d, err := db.Open(t.TempDir())
+	assert.Nil(t, err)
+	defer d.Close()
+
+	storeQuorum := func(events *AttestationEventReporter, seq uint64) {
+		v := testVAA(seq)
+		v.Signatures = []*vaa.Signature{{Index: 0}}
+		assert.Nil(t, d.StoreSignedVAA(v))
+		if events != nil {
+			events.ReportVAAQuorum(v)
+		}
+	}
+
+	// VAAs stored before the sink's first start aren't replayed
+	storeQuorum(nil, 0)
+
+	events := EventListener(zap.NewNop())
+	sink := &recordingSink{}
+	config := testSinkConfig
+	config.ReplayDB = d
+	config.ReplayInterval = time.Hour
+	startSink(t, events, sink, config)
+
+	storeQuorum(events, 1)
+	assert.Eventually(t, func() bool { return len(sink.delivered()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// VAAs aren't dead-lettered during an outage, they're delivered once the sink is back
+	sink.setDown(true)
+	storeQuorum(events, 2)
+	storeQuorum(events, 3)
+	time.Sleep(50 * time.Millisecond)
+	sink.setDown(false)
+	storeQuorum(events, 4)
+	assert.Eventually(t, func() bool { return len(sink.delivered()) == 4 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []uint64{1, 2, 3, 4}, sink.delivered())
+
+	cursor, found, err := d.GetCursor(replayCursorName(sink.Name()))
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, uint64(5), cursor)
+}
@@ -0,0 +1,113 @@
+package reporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type SQLSinkConfig struct {
+	// Driver is the database/sql driver name, e.g. "sqlite3" or "postgres". The driver
+	// package has to be linked into the binary to register itself; none is bundled.
+	Driver string
+	DSN    string
+	Table  string
+}
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqlSink inserts events into a single table, which is created if it doesn't exist.
+// Events are keyed by type and message ID, so redelivered events are ignored.
+type sqlSink struct {
+	config SQLSinkConfig
+	db     *sql.DB
+	insert string
+}
+
+func NewSQLSink(config SQLSinkConfig) (Sink, error) {
+	if !sqlIdentifier.MatchString(config.Table) {
+		return nil, fmt.Errorf("invalid table name %q", config.Table)
+	}
+	return &sqlSink{config: config}, nil
+}
+
+func (s *sqlSink) Name() string {
+	return "sql"
+}
+
+// postgres returns whether the driver speaks the Postgres dialect, which numbers its placeholders and has no BLOB type.
+func (s *sqlSink) postgres() bool {
+	switch s.config.Driver {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return true
+	}
+	return false
+}
+
+func (s *sqlSink) Open(ctx context.Context) error {
+	db, err := sql.Open(s.config.Driver, s.config.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	blob := "BLOB"
+	placeholders := make([]string, 8)
+	for i := range placeholders {
+		placeholders[i] = "?"
+	}
+	if s.postgres() {
+		blob = "BYTEA"
+		for i := range placeholders {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		event_type TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		emitter_chain INTEGER NOT NULL,
+		emitter_address TEXT NOT NULL,
+		sequence BIGINT NOT NULL,
+		initiating_tx_id TEXT,
+		vaa %s NOT NULL,
+		reported_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (event_type, message_id)
+	)`, s.config.Table, blob)); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create table %s: %w", s.config.Table, err)
+	}
+
+	s.db = db
+	s.insert = fmt.Sprintf(`INSERT INTO %s
+		(event_type, message_id, emitter_chain, emitter_address, sequence, initiating_tx_id, vaa, reported_at)
+		VALUES (%s) ON CONFLICT DO NOTHING`, s.config.Table, strings.Join(placeholders, ", "))
+	return nil
+}
+
+func (s *sqlSink) Deliver(ctx context.Context, e *Event) error {
+	var txID sql.NullString
+	if e.InitiatingTxID != "" {
+		txID = sql.NullString{String: e.InitiatingTxID, Valid: true}
+	}
+	if _, err := s.db.ExecContext(ctx, s.insert,
+		string(e.Type), e.MessageID, int64(e.EmitterChain), e.EmitterAddress, int64(e.Sequence), txID, e.VAA, e.ReportedAt.UTC(),
+	); err != nil {
+		return fmt.Errorf("failed to insert event: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlSink) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
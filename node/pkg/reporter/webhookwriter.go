@@ -0,0 +1,75 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type WebhookSinkConfig struct {
+	URL string
+	// Timeout bounds a single delivery attempt.
+	Timeout time.Duration
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string
+}
+
+// webhookSink POSTs every event as a JSON object. Any response other than 2xx is
+// treated as a failed delivery and retried by the sink's runnable.
+type webhookSink struct {
+	config WebhookSinkConfig
+	client *http.Client
+}
+
+func NewWebhookSink(config WebhookSinkConfig) Sink {
+	return &webhookSink{config: config}
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Open(ctx context.Context) error {
+	s.client = &http.Client{Timeout: s.config.Timeout}
+	return nil
+}
+
+func (s *webhookSink) Deliver(ctx context.Context, e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post event: %w", err)
+	}
+	defer resp.Body.Close()
+	// drain the body so that the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	if s.client != nil {
+		s.client.CloseIdleConnections()
+	}
+	return nil
+}
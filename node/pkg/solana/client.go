@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/db"
 	"github.com/certusone/wormhole/node/pkg/p2p"
 	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
 	"github.com/certusone/wormhole/node/pkg/readiness"
 	"github.com/certusone/wormhole/node/pkg/supervisor"
 	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
 	eth_common "github.com/ethereum/go-ethereum/common"
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -19,6 +21,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.uber.org/zap"
+	"sync"
 	"time"
 )
 
@@ -30,6 +33,14 @@ type SolanaWatcher struct {
 	messageEvent chan *common.MessagePublication
 	obsvReqC     chan *gossipv1.ObservationRequest
 	rpcClient    *rpc.Client
+	db           *db.Database
+
+	// The message accounts of observation requests are fetched with the logger of
+	// the current run, which is replaced on every restart of the watcher.
+	clientMu sync.Mutex
+	logger   *zap.Logger
+
+	health *watchers.HealthTracker
 }
 
 var (
@@ -53,6 +64,21 @@ var (
 			Name: "wormhole_solana_current_height",
 			Help: "Current Solana slot height",
 		}, []string{"commitment"})
+	solanaLastProcessedSlot = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "wormhole_solana_last_processed_slot",
+			Help: "Last Solana slot up to which all slots have been processed",
+		}, []string{"commitment"})
+	solanaPendingSlots = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "wormhole_solana_pending_slots",
+			Help: "Number of Solana slots which are scheduled but not yet processed, including the backfill after a restart",
+		}, []string{"commitment"})
+	solanaSlotsFailed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_solana_slots_failed_total",
+			Help: "Total number of times a Solana slot could not be fetched after all retries and was rescheduled",
+		}, []string{"commitment"})
 	queryLatency = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: "wormhole_solana_query_latency",
@@ -66,6 +92,12 @@ const rpcTimeout = time.Second * 5
 const maxRetries = 10
 const retryDelay = 5 * time.Second
 
+// Maximum number of blocks which are fetched concurrently
+const maxConcurrentFetches = 16
+
+// Maximum number of slots which are backfilled after a restart, RPC nodes don't keep older blocks anyway
+const maxBackfillSlots = 100000
+
 type ConsistencyLevel uint8
 
 // Mappings from consistency levels constants to commitment level.
@@ -103,7 +135,8 @@ func NewSolanaWatcher(
 	contractAddress solana.PublicKey,
 	messageEvents chan *common.MessagePublication,
 	obsvReqC chan *gossipv1.ObservationRequest,
-	commitment rpc.CommitmentType) *SolanaWatcher {
+	commitment rpc.CommitmentType,
+	db *db.Database) *SolanaWatcher {
 	return &SolanaWatcher{
 		contract: contractAddress,
 		wsUrl:    wsUrl, rpcUrl: rpcUrl,
@@ -111,9 +144,20 @@ func NewSolanaWatcher(
 		obsvReqC:     obsvReqC,
 		commitment:   commitment,
 		rpcClient:    rpc.New(rpcUrl),
+		db:           db,
+		logger:       zap.NewNop(),
+		health:       watchers.NewHealthTracker(vaa.ChainIDSolana),
 	}
 }
 
+func (s *SolanaWatcher) ChainID() vaa.ChainID {
+	return vaa.ChainIDSolana
+}
+
+func (s *SolanaWatcher) Health() watchers.Health {
+	return s.health.Health()
+}
+
 func (s *SolanaWatcher) Run(ctx context.Context) error {
 	// Initialize gossip metrics (we want to broadcast the address even if we're not yet syncing)
 	contractAddr := base58.Encode(s.contract[:])
@@ -122,28 +166,24 @@ func (s *SolanaWatcher) Run(ctx context.Context) error {
 	})
 
 	logger := supervisor.Logger(ctx)
+	s.clientMu.Lock()
+	s.logger = logger
+	s.clientMu.Unlock()
 	errC := make(chan error)
-	var lastSlot uint64
+
+	go watchers.HandleObservationRequests(ctx, logger, s, s.obsvReqC)
 
 	go func() {
 		timer := time.NewTicker(time.Second * 1)
 		defer timer.Stop()
 
+		var cursor *slotCursor
+		slotC := make(chan uint64)
+
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case m := <-s.obsvReqC:
-				if m.ChainId != uint32(vaa.ChainIDSolana) {
-					panic("unexpected chain id")
-				}
-
-				acc := solana.PublicKeyFromBytes(m.TxHash)
-				logger.Info("received observation request", zap.String("account", acc.String()))
-
-				rCtx, cancel := context.WithTimeout(ctx, rpcTimeout)
-				s.fetchMessageAccount(rCtx, logger, acc, 0)
-				cancel()
 			case <-timer.C:
 				// Get current slot height
 				rCtx, cancel := context.WithTimeout(ctx, rpcTimeout)
@@ -157,36 +197,66 @@ func (s *SolanaWatcher) Run(ctx context.Context) error {
 					errC <- err
 					return
 				}
-				if lastSlot == 0 {
-					lastSlot = slot - 1
+				if cursor == nil {
+					lastSlot, err := s.initialSlot(logger, slot)
+					if err != nil {
+						errC <- err
+						return
+					}
+					cursor = newSlotCursor(lastSlot, slot)
+					solanaLastProcessedSlot.WithLabelValues(string(s.commitment)).Set(float64(lastSlot))
+					if cursor.caughtUp() {
+						readiness.SetReady(common.ReadinessSolanaSyncing)
+					}
+					for i := 0; i < maxConcurrentFetches; i++ {
+						go s.fetchWorker(ctx, logger, cursor, slotC)
+					}
 				}
 				currentSolanaHeight.WithLabelValues(string(s.commitment)).Set(float64(slot))
-				readiness.SetReady(common.ReadinessSolanaSyncing)
+				s.health.SetHeight(slot)
 				p2p.DefaultRegistry.SetNetworkStats(vaa.ChainIDSolana, &gossipv1.Heartbeat_Network{
 					Height:          int64(slot),
 					ContractAddress: contractAddr,
 				})
+
+				// Fetch the failed slots again before the new ones, this blocks until a worker is available
+				for _, slot := range cursor.takeFailed() {
+					logger.Info("fetching failed slot again", zap.Uint64("slot", slot), zap.String("commitment", string(s.commitment)))
+					select {
+					case <-ctx.Done():
+						return
+					case slotC <- slot:
+					}
+				}
+
+				rangeStart := cursor.scheduled() + 1
+				rangeEnd := slot
 				logger.Info("fetched current Solana height",
 					zap.String("commitment", string(s.commitment)),
 					zap.Uint64("slot", slot),
-					zap.Uint64("lastSlot", lastSlot),
-					zap.Uint64("pendingSlots", slot-lastSlot),
+					zap.Uint64("lastSlot", rangeStart-1),
+					zap.Uint64("pendingSlots", cursor.pending()),
 					zap.Duration("took", time.Since(start)))
 
-				rangeStart := lastSlot + 1
-				rangeEnd := slot
+				if rangeStart > rangeEnd {
+					continue
+				}
 
 				logger.Info("fetching slots in range",
 					zap.Uint64("from", rangeStart), zap.Uint64("to", rangeEnd),
 					zap.Duration("took", time.Since(start)),
 					zap.String("commitment", string(s.commitment)))
 
-				// Requesting each slot
+				// Requesting each slot, this blocks until a worker is available
 				for slot := rangeStart; slot <= rangeEnd; slot++ {
-					go s.retryFetchBlock(ctx, logger, slot, 0)
+					cursor.schedule(slot)
+					solanaPendingSlots.WithLabelValues(string(s.commitment)).Set(float64(cursor.pending()))
+					select {
+					case <-ctx.Done():
+						return
+					case slotC <- slot:
+					}
 				}
-
-				lastSlot = slot
 			}
 		}
 	}()
@@ -195,34 +265,148 @@ func (s *SolanaWatcher) Run(ctx context.Context) error {
 	case <-ctx.Done():
 		return ctx.Err()
 	case err := <-errC:
+		s.health.AddError(err)
 		return err
 	}
 }
 
-func (s *SolanaWatcher) retryFetchBlock(ctx context.Context, logger *zap.Logger, slot uint64, retry uint) {
-	ok := s.fetchBlock(ctx, logger, slot, 0)
+// HandleObservationRequest re-observes the requested message account. The tx hash of
+// Solana observation requests is the address of the message account.
+func (s *SolanaWatcher) HandleObservationRequest(ctx context.Context, m *gossipv1.ObservationRequest) error {
+	s.clientMu.Lock()
+	logger := s.logger
+	s.clientMu.Unlock()
+
+	acc := solana.PublicKeyFromBytes(m.TxHash)
+	logger.Info("received observation request", zap.String("account", acc.String()))
+
+	rCtx, cancel := context.WithTimeout(ctx, rpcTimeout)
+	defer cancel()
+	if retryable := s.fetchMessageAccount(rCtx, logger, acc, 0); retryable {
+		return fmt.Errorf("failed to fetch message account %s", acc)
+	}
+	return nil
+}
+
+// initialSlot returns the slot after which the watcher starts fetching blocks. It resumes from the
+// persisted cursor, so that the slots produced while the guardian was down are backfilled.
+func (s *SolanaWatcher) initialSlot(logger *zap.Logger, currentSlot uint64) (uint64, error) {
+	lastSlot, err := s.db.GetSolanaLastSlot(string(s.commitment))
+	if err == db.ErrSolanaSlotNotFound {
+		logger.Info("no persisted Solana slot, starting from the current slot",
+			zap.String("commitment", string(s.commitment)),
+			zap.Uint64("slot", currentSlot))
+		return currentSlot - 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load the last processed slot: %w", err)
+	}
+
+	if lastSlot >= currentSlot {
+		return currentSlot, nil
+	}
+	if currentSlot-lastSlot > maxBackfillSlots {
+		logger.Warn("too many slots to backfill, skipping the oldest slots",
+			zap.String("commitment", string(s.commitment)),
+			zap.Uint64("lastSlot", lastSlot),
+			zap.Uint64("slot", currentSlot),
+			zap.Uint64("skipped", currentSlot-lastSlot-maxBackfillSlots))
+		lastSlot = currentSlot - maxBackfillSlots
+	}
+	logger.Info("backfilling missed Solana slots",
+		zap.String("commitment", string(s.commitment)),
+		zap.Uint64("from", lastSlot+1),
+		zap.Uint64("to", currentSlot))
+	return lastSlot, nil
+}
+
+func (s *SolanaWatcher) fetchWorker(ctx context.Context, logger *zap.Logger, cursor *slotCursor, slotC <-chan uint64) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case slot := <-slotC:
+			fetches := &accountFetches{}
+			ok := s.retryFetchBlock(ctx, logger, slot, fetches)
+			if ctx.Err() != nil {
+				return
+			}
+			if !ok {
+				s.slotFailed(cursor, slot, fmt.Errorf("failed to fetch block of slot %d", slot))
+				continue
+			}
+			// The message accounts of the slot are fetched in the background, don't block
+			// the worker while waiting for them.
+			go func(slot uint64) {
+				ok := fetches.wait()
+				if ctx.Err() != nil {
+					return
+				}
+				if !ok {
+					s.slotFailed(cursor, slot, fmt.Errorf("failed to fetch message accounts of slot %d", slot))
+					return
+				}
+				s.slotProcessed(logger, cursor, slot)
+			}(slot)
+		}
+	}
+}
+
+// slotFailed keeps the slot pending, so that the persisted cursor doesn't advance past it
+// and it's fetched again in the next round (or backfilled after a restart).
+func (s *SolanaWatcher) slotFailed(cursor *slotCursor, slot uint64, err error) {
+	cursor.fail(slot)
+	s.health.AddError(err)
+}
+
+func (s *SolanaWatcher) slotProcessed(logger *zap.Logger, cursor *slotCursor, slot uint64) {
+	lastProcessed, advanced := cursor.complete(slot)
+	solanaPendingSlots.WithLabelValues(string(s.commitment)).Set(float64(cursor.pending()))
+	if !advanced {
+		return
+	}
 
-	if !ok {
+	solanaLastProcessedSlot.WithLabelValues(string(s.commitment)).Set(float64(lastProcessed))
+	if err := s.db.StoreSolanaLastSlot(string(s.commitment), lastProcessed); err != nil {
+		logger.Error("failed to persist the last processed slot",
+			zap.String("commitment", string(s.commitment)),
+			zap.Uint64("slot", lastProcessed),
+			zap.Error(err))
+	}
+	if cursor.caughtUp() {
+		readiness.SetReady(common.ReadinessSolanaSyncing)
+	}
+}
+
+// retryFetchBlock returns whether the block has been fetched within maxRetries retries
+func (s *SolanaWatcher) retryFetchBlock(ctx context.Context, logger *zap.Logger, slot uint64, fetches *accountFetches) bool {
+	for retry := uint(0); !s.fetchBlock(ctx, logger, slot, 0, fetches); retry++ {
 		if retry >= maxRetries {
-			logger.Error("max retries for block",
+			solanaSlotsFailed.WithLabelValues(string(s.commitment)).Inc()
+			logger.Error("max retries for block, fetching it again in the next round",
 				zap.Uint64("slot", slot),
 				zap.String("commitment", string(s.commitment)),
 				zap.Uint("retry", retry))
-			return
+			return false
 		}
 
-		time.Sleep(retryDelay)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(retryDelay):
+		}
 
 		logger.Info("retrying block",
 			zap.Uint64("slot", slot),
 			zap.String("commitment", string(s.commitment)),
-			zap.Uint("retry", retry))
-
-		go s.retryFetchBlock(ctx, logger, slot, retry+1)
+			zap.Uint("retry", retry+1))
 	}
+	return true
 }
 
-func (s *SolanaWatcher) fetchBlock(ctx context.Context, logger *zap.Logger, slot uint64, emptyRetry uint) (ok bool) {
+// fetchBlock processes the Wormhole transactions of the block, the message accounts are fetched in the background
+// and tracked by fetches
+func (s *SolanaWatcher) fetchBlock(ctx context.Context, logger *zap.Logger, slot uint64, emptyRetry uint, fetches *accountFetches) (ok bool) {
 	logger.Debug("requesting block",
 		zap.Uint64("slot", slot),
 		zap.String("commitment", string(s.commitment)),
@@ -259,7 +443,8 @@ func (s *SolanaWatcher) fetchBlock(ctx context.Context, logger *zap.Logger, slot
 			if emptyRetry < maxEmptyRetry {
 				go func() {
 					time.Sleep(retryDelay)
-					s.fetchBlock(ctx, logger, slot, emptyRetry+1)
+					// The empty slot has been processed already, nobody waits for the accounts fetched by the retry.
+					s.fetchBlock(ctx, logger, slot, emptyRetry+1, &accountFetches{})
 				}()
 			}
 			return true
@@ -314,7 +499,7 @@ OUTER:
 
 		// Find top-level instructions
 		for i, inst := range tx.Transaction.Message.Instructions {
-			found, err := s.processInstruction(ctx, logger, slot, inst, programIndex, tx, signature, i)
+			found, err := s.processInstruction(ctx, logger, slot, fetches, inst, programIndex, tx, signature, i)
 			if err != nil {
 				logger.Error("malformed Wormhole instruction",
 					zap.Error(err),
@@ -358,7 +543,7 @@ OUTER:
 
 		for _, inner := range tr.Meta.InnerInstructions {
 			for i, inst := range inner.Instructions {
-				_, err := s.processInstruction(ctx, logger, slot, inst, programIndex, tx, signature, i)
+				_, err := s.processInstruction(ctx, logger, slot, fetches, inst, programIndex, tx, signature, i)
 				if err != nil {
 					logger.Error("malformed Wormhole instruction",
 						zap.Error(err),
@@ -381,7 +566,7 @@ OUTER:
 	return true
 }
 
-func (s *SolanaWatcher) processInstruction(ctx context.Context, logger *zap.Logger, slot uint64, inst solana.CompiledInstruction, programIndex uint16, tx rpc.TransactionWithMeta, signature solana.Signature, idx int) (bool, error) {
+func (s *SolanaWatcher) processInstruction(ctx context.Context, logger *zap.Logger, slot uint64, fetches *accountFetches, inst solana.CompiledInstruction, programIndex uint16, tx rpc.TransactionWithMeta, signature solana.Signature, idx int) (bool, error) {
 	if inst.ProgramIDIndex != programIndex {
 		return false, nil
 	}
@@ -419,34 +604,38 @@ func (s *SolanaWatcher) processInstruction(ctx context.Context, logger *zap.Logg
 	logger.Info("fetching VAA account", zap.Stringer("acc", acc),
 		zap.Stringer("signature", signature), zap.Uint64("slot", slot), zap.Int("idx", idx))
 
-	go s.retryFetchMessageAccount(ctx, logger, acc, slot, 0)
+	fetches.start(func() bool {
+		return s.retryFetchMessageAccount(ctx, logger, acc, slot)
+	})
 
 	return true, nil
 }
 
-func (s *SolanaWatcher) retryFetchMessageAccount(ctx context.Context, logger *zap.Logger, acc solana.PublicKey, slot uint64, retry uint) {
-	retryable := s.fetchMessageAccount(ctx, logger, acc, slot)
-
-	if retryable {
+// retryFetchMessageAccount returns whether the account has been fetched within maxRetries retries
+func (s *SolanaWatcher) retryFetchMessageAccount(ctx context.Context, logger *zap.Logger, acc solana.PublicKey, slot uint64) bool {
+	for retry := uint(0); s.fetchMessageAccount(ctx, logger, acc, slot); retry++ {
 		if retry >= maxRetries {
 			logger.Error("max retries for account",
 				zap.Uint64("slot", slot),
 				zap.Stringer("account", acc),
 				zap.String("commitment", string(s.commitment)),
 				zap.Uint("retry", retry))
-			return
+			return false
 		}
 
-		time.Sleep(retryDelay)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(retryDelay):
+		}
 
 		logger.Info("retrying account",
 			zap.Uint64("slot", slot),
 			zap.Stringer("account", acc),
 			zap.String("commitment", string(s.commitment)),
-			zap.Uint("retry", retry))
-
-		go s.retryFetchMessageAccount(ctx, logger, acc, slot, retry+1)
+			zap.Uint("retry", retry+1))
 	}
+	return true
 }
 
 func (s *SolanaWatcher) fetchMessageAccount(ctx context.Context, logger *zap.Logger, acc solana.PublicKey, slot uint64) (retryable bool) {
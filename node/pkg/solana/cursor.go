@@ -0,0 +1,121 @@
+package solana
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// slotCursor tracks the slots which are fetched concurrently. The last processed slot only
+// advances once all slots up to it have been processed, so that it's safe to resume from it.
+type slotCursor struct {
+	mu sync.Mutex
+	// all slots up to and including lastProcessed have been processed
+	lastProcessed uint64
+	lastScheduled uint64
+	// processed slots which are ahead of lastProcessed
+	done map[uint64]bool
+	// slots which could not be fetched and have to be fetched again
+	failed map[uint64]bool
+	// the watcher is ready once the slots up to catchUpSlot have been processed
+	catchUpSlot uint64
+}
+
+func newSlotCursor(lastProcessed uint64, catchUpSlot uint64) *slotCursor {
+	return &slotCursor{
+		lastProcessed: lastProcessed,
+		lastScheduled: lastProcessed,
+		done:          make(map[uint64]bool),
+		failed:        make(map[uint64]bool),
+		catchUpSlot:   catchUpSlot,
+	}
+}
+
+// schedule marks the slot as being fetched, slots must be scheduled in ascending order
+func (c *slotCursor) schedule(slot uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastScheduled = slot
+}
+
+// complete marks the slot as processed and returns the last processed slot,
+// and whether the last processed slot advanced
+func (c *slotCursor) complete(slot uint64) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if slot <= c.lastProcessed {
+		return c.lastProcessed, false
+	}
+	c.done[slot] = true
+	advanced := false
+	for c.done[c.lastProcessed+1] {
+		delete(c.done, c.lastProcessed+1)
+		c.lastProcessed++
+		advanced = true
+	}
+	return c.lastProcessed, advanced
+}
+
+// fail marks the slot as failed, it stays pending and keeps the cursor from advancing past it
+// until it has been fetched again
+func (c *slotCursor) fail(slot uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failed[slot] = true
+}
+
+// takeFailed returns the failed slots in ascending order, so that they can be fetched again
+func (c *slotCursor) takeFailed() []uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	slots := make([]uint64, 0, len(c.failed))
+	for slot := range c.failed {
+		slots = append(slots, slot)
+		delete(c.failed, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots
+}
+
+func (c *slotCursor) scheduled() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastScheduled
+}
+
+// pending returns the number of scheduled slots which have not been processed yet
+func (c *slotCursor) pending() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastScheduled - c.lastProcessed
+}
+
+func (c *slotCursor) caughtUp() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastProcessed >= c.catchUpSlot
+}
+
+// accountFetches tracks the message account fetches of a slot, which run in the background.
+// The slot must not be marked as processed before all of them have succeeded.
+type accountFetches struct {
+	wg     sync.WaitGroup
+	failed uint32
+}
+
+// start runs the fetch in the background, fetch returns whether the account has been fetched
+func (f *accountFetches) start(fetch func() bool) {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		if !fetch() {
+			atomic.StoreUint32(&f.failed, 1)
+		}
+	}()
+}
+
+// wait blocks until all fetches have finished and returns whether all of them succeeded
+func (f *accountFetches) wait() bool {
+	f.wg.Wait()
+	return atomic.LoadUint32(&f.failed) == 0
+}
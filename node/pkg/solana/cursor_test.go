@@ -0,0 +1,121 @@
+package solana
+
+import (
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/db"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSlotCursor(t *testing.T) {
+	cursor := newSlotCursor(10, 14)
+	for slot := uint64(11); slot <= 14; slot++ {
+		cursor.schedule(slot)
+	}
+	assert.Equal(t, uint64(14), cursor.scheduled())
+	assert.Equal(t, uint64(4), cursor.pending())
+
+	// out of order completions don't advance the cursor past unprocessed slots
+	lastProcessed, advanced := cursor.complete(13)
+	assert.False(t, advanced)
+	assert.Equal(t, uint64(10), lastProcessed)
+	lastProcessed, advanced = cursor.complete(12)
+	assert.False(t, advanced)
+	assert.Equal(t, uint64(10), lastProcessed)
+
+	lastProcessed, advanced = cursor.complete(11)
+	assert.True(t, advanced)
+	assert.Equal(t, uint64(13), lastProcessed)
+	assert.Equal(t, uint64(1), cursor.pending())
+	assert.False(t, cursor.caughtUp())
+
+	lastProcessed, advanced = cursor.complete(14)
+	assert.True(t, advanced)
+	assert.Equal(t, uint64(14), lastProcessed)
+	assert.Equal(t, uint64(0), cursor.pending())
+	assert.True(t, cursor.caughtUp())
+	assert.Equal(t, 0, len(cursor.done))
+}
+
+func TestSlotCursorFailedSlots(t *testing.T) {
+	cursor := newSlotCursor(10, 13)
+	for slot := uint64(11); slot <= 13; slot++ {
+		cursor.schedule(slot)
+	}
+
+	// a failed slot stays pending and keeps the cursor from advancing
+	cursor.fail(12)
+	cursor.fail(11)
+	lastProcessed, advanced := cursor.complete(13)
+	assert.False(t, advanced)
+	assert.Equal(t, uint64(10), lastProcessed)
+	assert.Equal(t, uint64(3), cursor.pending())
+
+	assert.Equal(t, []uint64{11, 12}, cursor.takeFailed())
+	assert.Empty(t, cursor.takeFailed())
+
+	// the cursor advances once the failed slots have been fetched again
+	lastProcessed, advanced = cursor.complete(11)
+	assert.True(t, advanced)
+	assert.Equal(t, uint64(11), lastProcessed)
+	lastProcessed, advanced = cursor.complete(12)
+	assert.True(t, advanced)
+	assert.Equal(t, uint64(13), lastProcessed)
+	assert.True(t, cursor.caughtUp())
+}
+
+func TestAccountFetches(t *testing.T) {
+	fetches := &accountFetches{}
+	assert.True(t, fetches.wait())
+
+	release := make(chan struct{})
+	fetches.start(func() bool { <-release; return true })
+	waited := make(chan bool)
+	go func() { waited <- fetches.wait() }()
+	select {
+	case <-waited:
+		t.Fatal("wait returned before the fetch finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	assert.True(t, <-waited)
+
+	// a single failed fetch fails the slot
+	fetches.start(func() bool { return true })
+	fetches.start(func() bool { return false })
+	assert.False(t, fetches.wait())
+}
+
+func TestInitialSlot(t *testing.T) {
+	database, err := db.Open(t.TempDir())
+	assert.Nil(t, err)
+	defer database.Close()
+
+	watcher := &SolanaWatcher{commitment: rpc.CommitmentFinalized, db: database}
+	logger := zap.NewNop()
+
+	// start from the current slot without a persisted cursor
+	slot, err := watcher.initialSlot(logger, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(999), slot)
+
+	// resume from the persisted cursor
+	assert.Nil(t, database.StoreSolanaLastSlot(string(rpc.CommitmentFinalized), 900))
+	slot, err = watcher.initialSlot(logger, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(900), slot)
+
+	// the cursors of different commitments are independent
+	confirmed := &SolanaWatcher{commitment: rpc.CommitmentConfirmed, db: database}
+	slot, err = confirmed.initialSlot(logger, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(999), slot)
+
+	// the backfill is bounded
+	slot, err = watcher.initialSlot(logger, 900+maxBackfillSlots+10)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(910), slot)
+}
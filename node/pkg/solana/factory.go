@@ -0,0 +1,70 @@
+package solana
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// ChainWatcher observes Solana at both the confirmed and the finalized commitment level, the consistency
+// level of each message selects the one which publishes it.
+type ChainWatcher struct {
+	confirmed *SolanaWatcher
+	finalized *SolanaWatcher
+}
+
+// Factory creates the watcher of a Solana chain entry.
+func Factory(c *watchers.ChainConfig, deps *watchers.Deps) (watchers.Watcher, error) {
+	if c.Websocket == "" {
+		return nil, errors.New("missing websocket endpoint")
+	}
+	address, err := solana.PublicKeyFromBase58(c.PrimaryContract())
+	if err != nil {
+		return nil, fmt.Errorf("invalid contract address %s: %w", c.PrimaryContract(), err)
+	}
+	return &ChainWatcher{
+		confirmed: NewSolanaWatcher(c.Websocket, c.PrimaryRPC(), address, deps.MsgC, nil, rpc.CommitmentConfirmed, deps.DB),
+		// Observation requests are handled at the finalized commitment level only
+		finalized: NewSolanaWatcher(c.Websocket, c.PrimaryRPC(), address, deps.MsgC, deps.ObsvReqC, rpc.CommitmentFinalized, deps.DB),
+	}, nil
+}
+
+func (w *ChainWatcher) Run(ctx context.Context) error {
+	if err := supervisor.Run(ctx, "confirmed", w.confirmed.Run); err != nil {
+		return err
+	}
+	if err := supervisor.Run(ctx, "finalized", w.finalized.Run); err != nil {
+		return err
+	}
+	supervisor.Signal(ctx, supervisor.SignalHealthy)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (w *ChainWatcher) ChainID() vaa.ChainID {
+	return vaa.ChainIDSolana
+}
+
+func (w *ChainWatcher) HandleObservationRequest(ctx context.Context, req *gossipv1.ObservationRequest) error {
+	return w.finalized.HandleObservationRequest(ctx, req)
+}
+
+// Health returns the height of the finalized commitment level and the errors of both levels.
+func (w *ChainWatcher) Health() watchers.Health {
+	health := w.finalized.Health()
+	confirmed := w.confirmed.Health()
+	health.Errors += confirmed.Errors
+	if confirmed.LastErrorTime.After(health.LastErrorTime) {
+		health.LastError = confirmed.LastError
+		health.LastErrorTime = confirmed.LastErrorTime
+	}
+	return health
+}
@@ -6,6 +6,7 @@ package supervisor
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"go.uber.org/zap"
@@ -116,3 +117,33 @@ func New(ctx context.Context, logger *zap.Logger, rootRunnable Runnable, opts ..
 
 	return sup
 }
+
+// RunnableStatus is the state of a runnable within the supervision tree.
+type RunnableStatus struct {
+	// DN is the distinguished name of the runnable, e.g. 'root.p2p'.
+	DN    string `json:"dn"`
+	State string `json:"state"`
+}
+
+// Runnables returns the state of all runnables within the supervision tree, ordered by their distinguished names.
+func (s *supervisor) Runnables() []RunnableStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var status []RunnableStatus
+	var walk func(n *node)
+	walk = func(n *node) {
+		status = append(status, RunnableStatus{DN: n.dn(), State: n.state.String()})
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(s.root)
+	sort.Slice(status, func(i, j int) bool { return status[i].DN < status[j].DN })
+	return status
+}
+
+// Dead returns whether the runnable has unexpectedly returned or panicked, and awaits its restart.
+func (r RunnableStatus) Dead() bool {
+	return r.State == nodeStateDead.String()
+}
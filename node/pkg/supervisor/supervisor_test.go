@@ -0,0 +1,41 @@
+package supervisor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRunnables(t *testing.T) {
+	ctx, ctxC := context.WithTimeout(context.Background(), 10*time.Second)
+	defer ctxC()
+
+	s := New(ctx, zap.NewNop(), func(ctx context.Context) error {
+		if err := Run(ctx, "serving", func(ctx context.Context) error {
+			Signal(ctx, SignalHealthy)
+			<-ctx.Done()
+			return ctx.Err()
+		}); err != nil {
+			return err
+		}
+		if err := Run(ctx, "starting", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}); err != nil {
+			return err
+		}
+		Signal(ctx, SignalHealthy)
+		Signal(ctx, SignalDone)
+		return nil
+	}, WithPropagatePanic)
+	s.waitSettleError(ctx, t)
+
+	assert.Equal(t, []RunnableStatus{
+		{DN: "root", State: "NODE_STATE_DONE"},
+		{DN: "root.serving", State: "NODE_STATE_HEALTHY"},
+		{DN: "root.starting", State: "NODE_STATE_NEW"},
+	}, s.Runnables())
+}
@@ -0,0 +1,18 @@
+package terra
+
+import (
+	"errors"
+
+	"github.com/certusone/wormhole/node/pkg/watchers"
+)
+
+// Factory creates the watcher of a Terra chain entry.
+func Factory(c *watchers.ChainConfig, deps *watchers.Deps) (watchers.Watcher, error) {
+	if c.Websocket == "" {
+		return nil, errors.New("missing websocket endpoint")
+	}
+	if c.PrimaryRPC() == "" {
+		return nil, errors.New("missing LCD endpoint")
+	}
+	return NewWatcher(c.Websocket, c.PrimaryRPC(), c.PrimaryContract(), deps.MsgC, deps.SetC, deps.ObsvReqC), nil
+}
@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -21,6 +22,7 @@ import (
 	"github.com/certusone/wormhole/node/pkg/readiness"
 	"github.com/certusone/wormhole/node/pkg/supervisor"
 	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/certusone/wormhole/node/pkg/watchers"
 	"github.com/gorilla/websocket"
 	"github.com/tidwall/gjson"
 	"go.uber.org/zap"
@@ -39,6 +41,13 @@ type (
 		// Incoming re-observation requests from the network. Pre-filtered to only
 		// include requests for our chainID.
 		obsvReqC chan *gossipv1.ObservationRequest
+
+		// Observation requests are handled outside of Run, they log the re-observed
+		// transactions with the logger of the current run.
+		clientMu sync.Mutex
+		logger   *zap.Logger
+
+		health *watchers.HealthTracker
 	}
 )
 
@@ -84,7 +93,24 @@ func NewWatcher(
 	lockEvents chan *common.MessagePublication,
 	setEvents chan *common.GuardianSet,
 	obsvReqC chan *gossipv1.ObservationRequest) *Watcher {
-	return &Watcher{urlWS: urlWS, urlLCD: urlLCD, contract: contract, msgChan: lockEvents, setChan: setEvents, obsvReqC: obsvReqC}
+	return &Watcher{
+		urlWS:    urlWS,
+		urlLCD:   urlLCD,
+		contract: contract,
+		msgChan:  lockEvents,
+		setChan:  setEvents,
+		obsvReqC: obsvReqC,
+		logger:   zap.NewNop(),
+		health:   watchers.NewHealthTracker(vaa.ChainIDTerra),
+	}
+}
+
+func (e *Watcher) ChainID() vaa.ChainID {
+	return vaa.ChainIDTerra
+}
+
+func (e *Watcher) Health() watchers.Health {
+	return e.health.Health()
 }
 
 func (e *Watcher) Run(ctx context.Context) error {
@@ -94,6 +120,9 @@ func (e *Watcher) Run(ctx context.Context) error {
 
 	errC := make(chan error)
 	logger := supervisor.Logger(ctx)
+	e.clientMu.Lock()
+	e.logger = logger
+	e.clientMu.Unlock()
 
 	logger.Info("connecting to websocket", zap.String("url", e.urlWS))
 
@@ -101,7 +130,9 @@ func (e *Watcher) Run(ctx context.Context) error {
 	if err != nil {
 		p2p.DefaultRegistry.AddErrorCount(vaa.ChainIDTerra, 1)
 		terraConnectionErrors.WithLabelValues("websocket_dial_error").Inc()
-		return fmt.Errorf("websocket dial failed: %w", err)
+		err = fmt.Errorf("websocket dial failed: %w", err)
+		e.health.AddError(err)
+		return err
 	}
 	defer c.Close()
 
@@ -117,7 +148,9 @@ func (e *Watcher) Run(ctx context.Context) error {
 	if err != nil {
 		p2p.DefaultRegistry.AddErrorCount(vaa.ChainIDTerra, 1)
 		terraConnectionErrors.WithLabelValues("websocket_subscription_error").Inc()
-		return fmt.Errorf("websocket subscription failed: %w", err)
+		err = fmt.Errorf("websocket subscription failed: %w", err)
+		e.health.AddError(err)
+		return err
 	}
 
 	// Wait for the success response
@@ -125,7 +158,9 @@ func (e *Watcher) Run(ctx context.Context) error {
 	if err != nil {
 		p2p.DefaultRegistry.AddErrorCount(vaa.ChainIDTerra, 1)
 		terraConnectionErrors.WithLabelValues("event_subscription_error").Inc()
-		return fmt.Errorf("event subscription failed: %w", err)
+		err = fmt.Errorf("event subscription failed: %w", err)
+		e.health.AddError(err)
+		return err
 	}
 	logger.Info("subscribed to new transaction events")
 
@@ -144,6 +179,7 @@ func (e *Watcher) Run(ctx context.Context) error {
 			resp, err := client.Get(fmt.Sprintf("%s/blocks/latest", e.urlLCD))
 			if err != nil {
 				logger.Error("query latest block response error", zap.Error(err))
+				e.health.AddError(err)
 				continue
 			}
 			blocksBody, err := ioutil.ReadAll(resp.Body)
@@ -159,6 +195,7 @@ func (e *Watcher) Run(ctx context.Context) error {
 			latestBlock := gjson.Get(blockJSON, "block.header.height")
 			logger.Info("current Terra height", zap.Int64("block", latestBlock.Int()))
 			currentTerraHeight.Set(float64(latestBlock.Int()))
+			e.health.SetHeight(latestBlock.Uint())
 			p2p.DefaultRegistry.SetNetworkStats(vaa.ChainIDTerra, &gossipv1.Heartbeat_Network{
 				Height:          latestBlock.Int(),
 				ContractAddress: e.contract,
@@ -166,62 +203,7 @@ func (e *Watcher) Run(ctx context.Context) error {
 		}
 	}()
 
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case r := <-e.obsvReqC:
-				if vaa.ChainID(r.ChainId) != vaa.ChainIDTerra {
-					panic("invalid chain ID")
-				}
-
-				tx := hex.EncodeToString(r.TxHash)
-
-				logger.Info("received observation request for terra",
-					zap.String("tx_hash", tx))
-
-				client := &http.Client{
-					Timeout: time.Second * 5,
-				}
-
-				// Query for tx by hash
-				resp, err := client.Get(fmt.Sprintf("%s/cosmos/tx/v1beta1/txs/%s", e.urlLCD, tx))
-				if err != nil {
-					logger.Error("query tx response error", zap.Error(err))
-					continue
-				}
-				txBody, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
-					logger.Error("query tx response read error", zap.Error(err))
-					resp.Body.Close()
-					continue
-				}
-				resp.Body.Close()
-
-				txJSON := string(txBody)
-
-				txHashRaw := gjson.Get(txJSON, "tx_response.txhash")
-				if !txHashRaw.Exists() {
-					logger.Error("terra tx does not have tx hash", zap.String("payload", txJSON))
-					continue
-				}
-				txHash := txHashRaw.String()
-
-				events := gjson.Get(txJSON, "tx_response.events")
-				if !events.Exists() {
-					logger.Error("terra tx has no events", zap.String("payload", txJSON))
-					continue
-				}
-
-				msgs := EventsToMessagePublications(e.contract, txHash, events.Array(), logger)
-				for _, msg := range msgs {
-					e.msgChan <- msg
-					terraMessagesConfirmed.Inc()
-				}
-			}
-		}
-	}()
+	go watchers.HandleObservationRequests(ctx, logger, e, e.obsvReqC)
 
 	go func() {
 		defer close(errC)
@@ -314,10 +296,67 @@ func (e *Watcher) Run(ctx context.Context) error {
 		}
 		return ctx.Err()
 	case err := <-errC:
+		if err != nil {
+			e.health.AddError(err)
+		}
+		// The watcher is ready again once it has resubscribed.
+		readiness.SetNotReady(common.ReadinessTerraSyncing)
 		return err
 	}
 }
 
+// HandleObservationRequest queries the requested transaction from the LCD and publishes
+// its messages. Terra has instant finality, so no confirmations need to be checked.
+func (e *Watcher) HandleObservationRequest(ctx context.Context, r *gossipv1.ObservationRequest) error {
+	e.clientMu.Lock()
+	logger := e.logger
+	e.clientMu.Unlock()
+
+	tx := hex.EncodeToString(r.TxHash)
+
+	logger.Info("received observation request for terra",
+		zap.String("tx_hash", tx))
+
+	client := &http.Client{
+		Timeout: time.Second * 5,
+	}
+
+	// Query for tx by hash
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/cosmos/tx/v1beta1/txs/%s", e.urlLCD, tx), nil)
+	if err != nil {
+		return fmt.Errorf("query tx request error: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("query tx response error: %w", err)
+	}
+	txBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("query tx response read error: %w", err)
+	}
+
+	txJSON := string(txBody)
+
+	txHashRaw := gjson.Get(txJSON, "tx_response.txhash")
+	if !txHashRaw.Exists() {
+		return fmt.Errorf("terra tx does not have tx hash: %s", txJSON)
+	}
+	txHash := txHashRaw.String()
+
+	events := gjson.Get(txJSON, "tx_response.events")
+	if !events.Exists() {
+		return fmt.Errorf("terra tx has no events: %s", txJSON)
+	}
+
+	msgs := EventsToMessagePublications(e.contract, txHash, events.Array(), logger)
+	for _, msg := range msgs {
+		e.msgChan <- msg
+		terraMessagesConfirmed.Inc()
+	}
+	return nil
+}
+
 func EventsToMessagePublications(contract string, txHash string, events []gjson.Result, logger *zap.Logger) []*common.MessagePublication {
 	msgs := make([]*common.MessagePublication, 0, len(events))
 	for _, event := range events {
@@ -0,0 +1,73 @@
+package watchers
+
+import (
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/vaa"
+)
+
+const (
+	ChainTypeEVM      = "evm"
+	ChainTypeTerra    = "terra"
+	ChainTypeSolana   = "solana"
+	ChainTypeAlgorand = "algorand"
+	ChainTypeAlephium = "alephium"
+)
+
+// ChainConfig is a chain entry of the guardiand config file (YAML or TOML, see --config), e.g.
+//
+//	chains:
+//	  - name: eth
+//	    type: evm
+//	    chainId: 2
+//	    rpc: ["ws://eth-devnet:8545"]
+//	    contracts: ["0xC89Ce4735882C9F0f0FE26686c53074E09B0D550"]
+//	    minConfirmations: 1
+//
+// The type selects the factory which creates the watcher of the entry.
+type ChainConfig struct {
+	Name    string      `mapstructure:"name"`
+	Type    string      `mapstructure:"type"`
+	ChainID vaa.ChainID `mapstructure:"chainId"`
+	// Entries are enabled unless explicitly disabled
+	Enabled          *bool    `mapstructure:"enabled"`
+	RPC              []string `mapstructure:"rpc"`
+	Contracts        []string `mapstructure:"contracts"`
+	MinConfirmations uint64   `mapstructure:"minConfirmations"`
+	// StallThreshold is the time after which the watcher is considered stalled if its height
+	// didn't advance, e.g. "2m". Defaults to --watcherStallThreshold.
+	StallThreshold time.Duration `mapstructure:"stallThreshold"`
+
+	// Terra and Solana websocket endpoint
+	Websocket string `mapstructure:"websocket"`
+	// Algorand algod token or Alephium api key
+	APIKey string `mapstructure:"apiKey"`
+	// Algorand indexer
+	IndexerRPC    string `mapstructure:"indexerRpc"`
+	IndexerAPIKey string `mapstructure:"indexerApiKey"`
+	// Alephium
+	Quorum            int    `mapstructure:"quorum"`
+	GroupIndex        uint8  `mapstructure:"groupIndex"`
+	ContractServerRPC string `mapstructure:"contractServerRpc"`
+	ContractWebServer string `mapstructure:"contractWebServer"`
+}
+
+func (c *ChainConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// PrimaryRPC returns the first rpc endpoint, or an empty string if there is none.
+func (c *ChainConfig) PrimaryRPC() string {
+	if len(c.RPC) == 0 {
+		return ""
+	}
+	return c.RPC[0]
+}
+
+// PrimaryContract returns the first contract, or an empty string if there is none.
+func (c *ChainConfig) PrimaryContract() string {
+	if len(c.Contracts) == 0 {
+		return ""
+	}
+	return c.Contracts[0]
+}
@@ -0,0 +1,224 @@
+package watchers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/supervisor"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	watcherStalled = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "wormhole_watcher_stalled",
+			Help: "1 if the height of the chain watcher didn't advance within its stall threshold, 0 otherwise",
+		}, []string{"chain"})
+)
+
+// MinStallThreshold is the smallest accepted stall threshold. The watchers are checked
+// four times per threshold, so smaller thresholds would only busy-loop the monitor.
+const MinStallThreshold = time.Second
+
+// ChainLiveness is the liveness of a single chain watcher, as served by the /healthz endpoint.
+type ChainLiveness struct {
+	Chain   string      `json:"chain"`
+	ChainID vaa.ChainID `json:"chainId"`
+	Height  uint64      `json:"height"`
+	// LastHeightUpdate is unset if the watcher didn't see a block yet.
+	LastHeightUpdate *time.Time `json:"lastHeightUpdate,omitempty"`
+	// SinceHeightUpdate is the number of seconds since the height last advanced, or since the
+	// monitor started if the watcher didn't see a block yet.
+	SinceHeightUpdate float64 `json:"sinceHeightUpdateSeconds"`
+	Threshold         float64 `json:"thresholdSeconds"`
+	Stalled           bool    `json:"stalled"`
+	Errors            uint64  `json:"errors"`
+	LastError         string  `json:"lastError,omitempty"`
+}
+
+type monitoredWatcher struct {
+	watcher   Watcher
+	threshold time.Duration
+	stalled   bool
+	// reported is the LastHeightUpdate of the watcher at the time its stall was reported.
+	reported *time.Time
+}
+
+// LivenessMonitor tracks when the height of each watcher last advanced, and considers a watcher stalled
+// if that's longer ago than the watcher's threshold. Stalls are exported as metrics, served on /healthz
+// and passed to a callback, once per stall.
+type LivenessMonitor struct {
+	defaultThreshold time.Duration
+	onStall          func(h Health, stalledFor time.Duration)
+	started          time.Time
+
+	mu       sync.Mutex
+	watchers map[vaa.ChainID]*monitoredWatcher
+}
+
+// NewLivenessMonitor returns a monitor which uses defaultThreshold for watchers without a threshold of their
+// own. onStall may be nil.
+func NewLivenessMonitor(defaultThreshold time.Duration, onStall func(h Health, stalledFor time.Duration)) *LivenessMonitor {
+	return &LivenessMonitor{
+		defaultThreshold: defaultThreshold,
+		onStall:          onStall,
+		started:          time.Now(),
+		watchers:         make(map[vaa.ChainID]*monitoredWatcher),
+	}
+}
+
+// Add monitors the watcher, replacing a previously added watcher of the same chain. A threshold of zero
+// selects the default threshold.
+func (m *LivenessMonitor) Add(w Watcher, threshold time.Duration) {
+	if threshold <= 0 {
+		threshold = m.defaultThreshold
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watchers[w.ChainID()] = &monitoredWatcher{watcher: w, threshold: threshold}
+	watcherStalled.WithLabelValues(w.ChainID().String()).Set(0)
+}
+
+// liveness returns the liveness of the watcher and the time since its height last advanced.
+func (m *LivenessMonitor) liveness(w *monitoredWatcher, now time.Time) (ChainLiveness, Health, time.Duration) {
+	h := w.watcher.Health()
+	l := ChainLiveness{
+		Chain:     h.ChainID.String(),
+		ChainID:   h.ChainID,
+		Height:    h.Height,
+		Threshold: w.threshold.Seconds(),
+		Errors:    h.Errors,
+		LastError: h.LastError,
+	}
+	// Watchers which haven't seen a block yet count from the start of the monitor.
+	since := m.started
+	if !h.LastHeightUpdate.IsZero() {
+		t := h.LastHeightUpdate
+		l.LastHeightUpdate = &t
+		since = t
+	}
+	l.SinceHeightUpdate = now.Sub(since).Seconds()
+	l.Stalled = now.Sub(since) > w.threshold
+	return l, h, now.Sub(since)
+}
+
+type stall struct {
+	health     Health
+	stalledFor time.Duration
+}
+
+// check updates the stall state of all watchers and returns the stalls which weren't reported yet.
+func (m *LivenessMonitor) check(logger *zap.Logger, now time.Time) []stall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stalls []stall
+	for _, w := range m.watchers {
+		l, h, since := m.liveness(w, now)
+		if !l.Stalled {
+			if w.stalled {
+				logger.Info("chain watcher recovered", zap.Stringer("chain", h.ChainID), zap.Uint64("height", h.Height))
+				watcherStalled.WithLabelValues(l.Chain).Set(0)
+			}
+			w.stalled = false
+			continue
+		}
+
+		if !w.stalled {
+			logger.Warn("chain watcher stalled",
+				zap.Stringer("chain", h.ChainID),
+				zap.Uint64("height", h.Height),
+				zap.Duration("threshold", w.threshold))
+			watcherStalled.WithLabelValues(l.Chain).Set(1)
+		}
+		w.stalled = true
+		if w.reported != nil && w.reported.Equal(h.LastHeightUpdate) {
+			continue
+		}
+		reported := h.LastHeightUpdate
+		w.reported = &reported
+		stalls = append(stalls, stall{h, since})
+	}
+	return stalls
+}
+
+// Status returns the liveness of all watchers, ordered by chain ID.
+func (m *LivenessMonitor) Status(now time.Time) []ChainLiveness {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := make([]ChainLiveness, 0, len(m.watchers))
+	for _, w := range m.watchers {
+		l, _, _ := m.liveness(w, now)
+		status = append(status, l)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].ChainID < status[j].ChainID })
+	return status
+}
+
+// ServeHTTP serves the liveness of all watchers as JSON. It returns 200 OK if no watcher is stalled,
+// or 503 Service Unavailable otherwise.
+func (m *LivenessMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp := struct {
+		Healthy bool            `json:"healthy"`
+		Chains  []ChainLiveness `json:"chains"`
+	}{Healthy: true, Chains: m.Status(time.Now())}
+	for _, c := range resp.Chains {
+		if c.Stalled {
+			resp.Healthy = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// checkInterval returns a quarter of the smallest threshold, but at least a quarter of MinStallThreshold.
+func (m *LivenessMonitor) checkInterval() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	interval := m.defaultThreshold
+	for _, w := range m.watchers {
+		if w.threshold < interval {
+			interval = w.threshold
+		}
+	}
+	if interval < MinStallThreshold {
+		interval = MinStallThreshold
+	}
+	return interval / 4
+}
+
+// Run checks the watchers until the context is cancelled. Every stall is reported once, until the
+// watcher sees a new block.
+func (m *LivenessMonitor) Run(ctx context.Context) error {
+	logger := supervisor.Logger(ctx)
+	ticker := time.NewTicker(m.checkInterval())
+	defer ticker.Stop()
+
+	supervisor.Signal(ctx, supervisor.SignalHealthy)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			for _, s := range m.check(logger, now) {
+				if m.onStall != nil {
+					m.onStall(s.health, s.stalledFor)
+				}
+			}
+		}
+	}
+}
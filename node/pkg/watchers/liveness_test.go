@@ -0,0 +1,104 @@
+package watchers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func stalledChains(stalls []stall) []vaa.ChainID {
+	chains := make([]vaa.ChainID, len(stalls))
+	for i, s := range stalls {
+		chains[i] = s.health.ChainID
+	}
+	return chains
+}
+
+func TestLivenessMonitor(t *testing.T) {
+	terra := newTestWatcher(vaa.ChainIDTerra)
+	terra.health.SetHeight(10)
+	solana := newTestWatcher(vaa.ChainIDSolana)
+	eth := newTestWatcher(vaa.ChainIDEthereum)
+	eth.health.SetHeight(100)
+
+	m := NewLivenessMonitor(time.Minute, nil)
+	m.Add(terra, 0)
+	m.Add(solana, 0)
+	m.Add(eth, 10*time.Minute)
+	assert.Equal(t, 15*time.Second, m.checkInterval())
+	// tiny thresholds don't make the monitor busy-loop
+	m.Add(newTestWatcher(vaa.ChainIDAlgorand), time.Nanosecond)
+	assert.Equal(t, MinStallThreshold/4, m.checkInterval())
+	m.Add(newTestWatcher(vaa.ChainIDAlgorand), time.Hour)
+	assert.Equal(t, 15*time.Second, m.checkInterval())
+
+	assert.Empty(t, m.check(zap.NewNop(), time.Now()))
+
+	// terra and solana are stalled, solana never reported a height
+	stalls := m.check(zap.NewNop(), time.Now().Add(2*time.Minute))
+	assert.ElementsMatch(t, []vaa.ChainID{vaa.ChainIDTerra, vaa.ChainIDSolana}, stalledChains(stalls))
+	for _, s := range stalls {
+		assert.InDelta(t, (2 * time.Minute).Seconds(), s.stalledFor.Seconds(), 1)
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(watcherStalled.WithLabelValues("terra")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(watcherStalled.WithLabelValues("ethereum")))
+
+	// stalls are reported once
+	assert.Empty(t, m.check(zap.NewNop(), time.Now().Add(3*time.Minute)))
+
+	// a new stall after a new block is reported again
+	terra.health.SetHeight(11)
+	assert.Empty(t, m.check(zap.NewNop(), time.Now().Add(30*time.Second)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(watcherStalled.WithLabelValues("terra")))
+	stalls = m.check(zap.NewNop(), time.Now().Add(2*time.Minute))
+	assert.Equal(t, []vaa.ChainID{vaa.ChainIDTerra}, stalledChains(stalls))
+}
+
+func TestLivenessMonitorHandler(t *testing.T) {
+	terra := newTestWatcher(vaa.ChainIDTerra)
+	terra.health.SetHeight(10)
+	solana := newTestWatcher(vaa.ChainIDSolana)
+
+	m := NewLivenessMonitor(time.Minute, nil)
+	m.Add(terra, 0)
+	m.Add(solana, time.Hour)
+
+	get := func() (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var resp map[string]interface{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+
+	code, resp := get()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, resp["healthy"])
+	chains := resp["chains"].([]interface{})
+	assert.Equal(t, 2, len(chains))
+	sol := chains[0].(map[string]interface{})
+	assert.Equal(t, "solana", sol["chain"])
+	assert.Equal(t, float64(3600), sol["thresholdSeconds"])
+	assert.NotContains(t, sol, "lastHeightUpdate")
+	assert.Equal(t, float64(10), chains[1].(map[string]interface{})["height"])
+
+	// let terra stall
+	m.started = m.started.Add(-2 * time.Minute)
+	terra.health.mu.Lock()
+	terra.health.health.LastHeightUpdate = time.Now().Add(-2 * time.Minute)
+	terra.health.mu.Unlock()
+
+	code, resp = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, false, resp["healthy"])
+	chains = resp["chains"].([]interface{})
+	assert.Equal(t, false, chains[0].(map[string]interface{})["stalled"])
+	assert.Equal(t, true, chains[1].(map[string]interface{})["stalled"])
+}
@@ -0,0 +1,81 @@
+package watchers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/certusone/wormhole/node/pkg/common"
+	"github.com/certusone/wormhole/node/pkg/db"
+	"github.com/certusone/wormhole/node/pkg/notify"
+	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
+)
+
+// Deps are the node resources shared by the watchers.
+type Deps struct {
+	// Channel to send new messages to.
+	MsgC chan *common.MessagePublication
+	// Channel to send guardian set changes to. Factories only pass it to the watcher
+	// of the chain the guardian set is read from.
+	SetC chan *common.GuardianSet
+	// Observation requests for the chain of the entry, nil if re-observation is not supported.
+	ObsvReqC chan *gossipv1.ObservationRequest
+	DB       *db.Database
+	// Notifier raises operator alerts, nil if alerting is disabled.
+	Notifier notify.Notifier
+}
+
+// Factory creates the watcher of an enabled chain entry.
+type Factory func(c *ChainConfig, deps *Deps) (Watcher, error)
+
+// Registry maps the chain types of the config entries to their watcher factories.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// Register adds the factory of the given chain type. It panics if the type is already
+// registered, since that can only happen due to a programming error.
+func (r *Registry) Register(chainType string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.factories[chainType]; ok {
+		panic(fmt.Sprintf("watcher factory for chain type %q registered twice", chainType))
+	}
+	r.factories[chainType] = factory
+}
+
+// Types returns the registered chain types in alphabetical order.
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.factories))
+	for t := range r.factories {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Create instantiates the watcher of the given chain entry with the factory of its type.
+func (r *Registry) Create(c *ChainConfig, deps *Deps) (Watcher, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[c.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown chain type %q", c.Type)
+	}
+
+	w, err := factory(c, deps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s watcher: %w", c.Name, err)
+	}
+	if w.ChainID() != c.ChainID {
+		return nil, fmt.Errorf("%s watcher observes chain %v instead of %v", c.Name, w.ChainID(), c.ChainID)
+	}
+	return w, nil
+}
@@ -0,0 +1,89 @@
+package watchers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/stretchr/testify/assert"
+)
+
+type testWatcher struct {
+	chainID  vaa.ChainID
+	deps     *Deps
+	health   *HealthTracker
+	requests chan *gossipv1.ObservationRequest
+	err      error
+}
+
+func newTestWatcher(chainID vaa.ChainID) *testWatcher {
+	return &testWatcher{chainID: chainID, health: NewHealthTracker(chainID), requests: make(chan *gossipv1.ObservationRequest, 8)}
+}
+
+func (w *testWatcher) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (w *testWatcher) ChainID() vaa.ChainID {
+	return w.chainID
+}
+
+func (w *testWatcher) HandleObservationRequest(ctx context.Context, req *gossipv1.ObservationRequest) error {
+	w.requests <- req
+	return w.err
+}
+
+func (w *testWatcher) Health() Health {
+	return w.health.Health()
+}
+
+func testFactory(chainID vaa.ChainID) Factory {
+	return func(c *ChainConfig, deps *Deps) (Watcher, error) {
+		if c.PrimaryRPC() == "" {
+			return nil, errors.New("missing rpc endpoint")
+		}
+		w := newTestWatcher(chainID)
+		w.deps = deps
+		return w, nil
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(ChainTypeTerra, testFactory(vaa.ChainIDTerra))
+	registry.Register(ChainTypeEVM, testFactory(vaa.ChainIDEthereum))
+	assert.Equal(t, []string{ChainTypeEVM, ChainTypeTerra}, registry.Types())
+	assert.Panics(t, func() { registry.Register(ChainTypeEVM, testFactory(vaa.ChainIDEthereum)) })
+
+	deps := &Deps{ObsvReqC: make(chan *gossipv1.ObservationRequest)}
+	w, err := registry.Create(&ChainConfig{Name: "eth", Type: ChainTypeEVM, ChainID: vaa.ChainIDEthereum, RPC: []string{"ws://eth:8545"}}, deps)
+	assert.Nil(t, err)
+	assert.Equal(t, vaa.ChainIDEthereum, w.ChainID())
+	assert.Equal(t, deps, w.(*testWatcher).deps)
+
+	_, err = registry.Create(&ChainConfig{Name: "sol", Type: ChainTypeSolana, ChainID: vaa.ChainIDSolana}, deps)
+	assert.EqualError(t, err, `unknown chain type "solana"`)
+
+	_, err = registry.Create(&ChainConfig{Name: "eth", Type: ChainTypeEVM, ChainID: vaa.ChainIDEthereum}, deps)
+	assert.EqualError(t, err, "failed to create eth watcher: missing rpc endpoint")
+
+	// the factory of the type must create a watcher of the configured chain
+	_, err = registry.Create(&ChainConfig{Name: "bsc", Type: ChainTypeTerra, ChainID: vaa.ChainIDBSC, RPC: []string{"http://bsc"}}, deps)
+	assert.NotNil(t, err)
+}
+
+func TestChainConfigHelpers(t *testing.T) {
+	disabled := false
+	c := &ChainConfig{}
+	assert.True(t, c.IsEnabled())
+	assert.Equal(t, "", c.PrimaryRPC())
+	assert.Equal(t, "", c.PrimaryContract())
+
+	c = &ChainConfig{Enabled: &disabled, RPC: []string{"a", "b"}, Contracts: []string{"c"}}
+	assert.False(t, c.IsEnabled())
+	assert.Equal(t, "a", c.PrimaryRPC())
+	assert.Equal(t, "c", c.PrimaryContract())
+}
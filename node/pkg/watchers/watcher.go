@@ -0,0 +1,129 @@
+// Package watchers defines the contract shared by the chain watchers and a registry of
+// factories which lets the node instantiate any watcher from its chain config entry.
+package watchers
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	watcherHeight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "wormhole_watcher_height",
+			Help: "Latest block height (or slot/round) seen by the chain watcher",
+		}, []string{"chain"})
+	watcherErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_watcher_errors_total",
+			Help: "Total number of errors reported by the chain watcher",
+		}, []string{"chain"})
+	watcherObservationRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "wormhole_watcher_observation_requests_total",
+			Help: "Total number of observation requests handled by the chain watcher",
+		}, []string{"chain", "result"})
+)
+
+// Watcher observes the messages published on one chain.
+type Watcher interface {
+	// Run watches the chain until the context is cancelled. It is a supervisor.Runnable.
+	Run(ctx context.Context) error
+	// ChainID returns the chain the watcher observes.
+	ChainID() vaa.ChainID
+	// HandleObservationRequest re-observes the messages of the requested transaction. Messages
+	// which are found and sufficiently confirmed are published like newly observed messages.
+	HandleObservationRequest(ctx context.Context, req *gossipv1.ObservationRequest) error
+	// Health returns a snapshot of the watcher's progress.
+	Health() Health
+}
+
+// Health is a snapshot of a watcher's progress.
+type Health struct {
+	ChainID vaa.ChainID
+	// Height is the latest block height (or slot/round) seen by the watcher, 0 if unknown.
+	Height uint64
+	// LastHeightUpdate is the time at which Height last changed.
+	LastHeightUpdate time.Time
+	// Errors is the number of errors reported since the watcher was created.
+	Errors uint64
+	// LastError is the most recent error, empty if there was none.
+	LastError string
+	// LastErrorTime is the time at which the most recent error was reported.
+	LastErrorTime time.Time
+}
+
+// HealthTracker records the progress of a watcher and exports it as per-chain metrics.
+// It is safe for concurrent use.
+type HealthTracker struct {
+	mu     sync.Mutex
+	health Health
+}
+
+func NewHealthTracker(chainID vaa.ChainID) *HealthTracker {
+	return &HealthTracker{health: Health{ChainID: chainID}}
+}
+
+// SetHeight records the latest height seen by the watcher.
+func (h *HealthTracker) SetHeight(height uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if height != h.health.Height {
+		h.health.Height = height
+		h.health.LastHeightUpdate = time.Now()
+	}
+	watcherHeight.WithLabelValues(h.health.ChainID.String()).Set(float64(height))
+}
+
+// AddError records an error encountered by the watcher.
+func (h *HealthTracker) AddError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.health.Errors++
+	h.health.LastError = err.Error()
+	h.health.LastErrorTime = time.Now()
+	watcherErrors.WithLabelValues(h.health.ChainID.String()).Inc()
+}
+
+// Health returns a snapshot of the recorded progress.
+func (h *HealthTracker) Health() Health {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.health
+}
+
+// HandleObservationRequests passes the observation requests received on obsvReqC to the watcher
+// until the context is cancelled. Failed requests are logged and counted, but don't stop the loop.
+func HandleObservationRequests(ctx context.Context, logger *zap.Logger, w Watcher, obsvReqC <-chan *gossipv1.ObservationRequest) {
+	chain := w.ChainID().String()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-obsvReqC:
+			// This can't happen unless there is a programming error - the caller
+			// is expected to send us only requests for our chainID.
+			if vaa.ChainID(req.ChainId) != w.ChainID() {
+				panic("invalid chain ID")
+			}
+
+			if err := w.HandleObservationRequest(ctx, req); err != nil {
+				logger.Error("failed to process observation request",
+					zap.String("chain", chain),
+					zap.String("tx_hash", hex.EncodeToString(req.TxHash)),
+					zap.Error(err))
+				watcherObservationRequests.WithLabelValues(chain, "error").Inc()
+				continue
+			}
+			watcherObservationRequests.WithLabelValues(chain, "success").Inc()
+		}
+	}
+}
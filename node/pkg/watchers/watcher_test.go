@@ -0,0 +1,65 @@
+package watchers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gossipv1 "github.com/certusone/wormhole/node/pkg/proto/gossip/v1"
+	"github.com/certusone/wormhole/node/pkg/vaa"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestHealthTracker(t *testing.T) {
+	tracker := NewHealthTracker(vaa.ChainIDTerra)
+	health := tracker.Health()
+	assert.Equal(t, vaa.ChainIDTerra, health.ChainID)
+	assert.Equal(t, uint64(0), health.Height)
+	assert.True(t, health.LastHeightUpdate.IsZero())
+
+	tracker.SetHeight(10)
+	health = tracker.Health()
+	assert.Equal(t, uint64(10), health.Height)
+	updated := health.LastHeightUpdate
+	assert.False(t, updated.IsZero())
+
+	// the update time only changes with the height
+	tracker.SetHeight(10)
+	assert.Equal(t, updated, tracker.Health().LastHeightUpdate)
+
+	tracker.AddError(errors.New("first"))
+	tracker.AddError(errors.New("second"))
+	health = tracker.Health()
+	assert.Equal(t, uint64(2), health.Errors)
+	assert.Equal(t, "second", health.LastError)
+	assert.False(t, health.LastErrorTime.IsZero())
+}
+
+func TestHandleObservationRequests(t *testing.T) {
+	w := newTestWatcher(vaa.ChainIDTerra)
+	w.err = errors.New("failed")
+	obsvReqC := make(chan *gossipv1.ObservationRequest)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		HandleObservationRequests(ctx, zap.NewNop(), w, obsvReqC)
+		close(done)
+	}()
+
+	// failed requests don't stop the loop
+	for i := byte(0); i < 2; i++ {
+		req := &gossipv1.ObservationRequest{ChainId: uint32(vaa.ChainIDTerra), TxHash: []byte{i}}
+		obsvReqC <- req
+		assert.Equal(t, req, <-w.requests)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("observation request loop did not stop")
+	}
+}